@@ -10,4 +10,4 @@ export MAX_GVIM_VERSION=
 export DEFAULT_VIM_COMMAND="vim"
 export DEFAULT_GVIM_COMMAND="xvfb-run -a gvim -f"
 
-export VALID_FLAVORS="vim gvim"
+export VALID_FLAVORS="vim gvim neovim"
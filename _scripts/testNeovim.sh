@@ -0,0 +1,21 @@
+#!/usr/bin/env bash
+
+# testNeovim.sh runs the tests of the plugin API against a headless
+# nvim --embed. cmd/govim does not yet run under Neovim, so its tests are not
+# run.
+#
+# NEOVIM_VERSION is the version of Neovim to install, e.g. v0.9.5
+
+source "${BASH_SOURCE%/*}/common.bash"
+
+pushd $(mktemp -d) > /dev/null
+curl -fsSL https://github.com/neovim/neovim/releases/download/${NEOVIM_VERSION}/nvim-linux64.tar.gz | tar -xz
+export PATH="$PWD/nvim-linux64/bin:$PATH"
+
+nvim --version
+
+popd > /dev/null
+
+export VIM_FLAVOR=neovim
+
+go test . ./testdriver
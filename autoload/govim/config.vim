@@ -198,6 +198,44 @@ function! s:validExperimentalAllowModfileModifications(v)
   return s:validBool(a:v)
 endfunction
 
+function! s:validExperimentalVirtualTextDiagnostics(v)
+  return s:validBool(a:v)
+endfunction
+
+function! s:validGoplsRemote(v)
+  return s:validString(a:v)
+endfunction
+
+function! s:validLanguageServers(v)
+  if type(a:v) != 4
+    return [v:false, "must be of type dict"]
+  endif
+  for [name, server] in items(a:v)
+    if type(server) != 4
+      return [v:false, "value for key ".name." must be a dict"]
+    endif
+    for key in ["Command", "Filetypes"]
+      if type(get(server, key, 0)) != 3 || len(server[key]) == 0
+        return [v:false, key." for ".name." must be a non-empty list"]
+      endif
+      for item in server[key]
+        if type(item) != 1
+          return [v:false, key." for ".name." must be a list of strings"]
+        endif
+      endfor
+    endfor
+    if has_key(server, "LanguageID") && type(server.LanguageID) != 1
+      return [v:false, "LanguageID for ".name." must be a string"]
+    endif
+    for key in ["InitializationOptions", "Settings"]
+      if has_key(server, key) && type(server[key]) != 4
+        return [v:false, key." for ".name." must be a dict"]
+      endif
+    endfor
+  endfor
+  return [v:true, ""]
+endfunction
+
 function! s:validExperimentalWorkspaceModule(v)
   return [v:false, "feature has been removed from gopls"]
 endfunction
@@ -232,6 +270,9 @@ let s:validators = {
       \ "ExperimentalWorkaroundCompleteoptLongest": function("s:validExperimentalWorkaroundCompleteoptLongest"),
       \ "ExperimentalProgressPopups": function("s:validExperimentalProgressPopups"),
       \ "ExperimentalAllowModfileModifications": function("s:validExperimentalProgressPopups"),
+      \ "ExperimentalVirtualTextDiagnostics": function("s:validExperimentalVirtualTextDiagnostics"),
+      \ "GoplsRemote": function("s:validGoplsRemote"),
+      \ "LanguageServers": function("s:validLanguageServers"),
       \ "ExperimentalWorkspaceModule": function("s:validExperimentalWorkspaceModule"),
       \ "ExperimentalGoplsMemoryMode": function("s:validExperimentalGoplsMemoryMode"),
       \ }
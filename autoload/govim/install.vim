@@ -0,0 +1,47 @@
+" govim#install#Install installs govim and gopls for the govim plugin in
+" plugindir, if they are not already installed or if force is set, and returns
+" the directory that contains them. It is shared by plugin/govim.vim and the
+" Neovim glue in autoload/govim/nvim.vim.
+function! govim#install#Install(plugindir, force)
+  let oldpath = getcwd()
+  execute "cd ".a:plugindir
+
+  " Note that we use "env -i" to use git with an empty environment.
+  " Otherwise, using vim with govim as the editor in "git commit" will break,
+  " as "git commit" will set GIT_DIR, which "git rev-parse" will follow.
+  " We'd then end up with the user's in-progress commit, not govim's HEAD.
+  " TODO: make work on Windows
+  let commit = trim(system("env -i git rev-parse HEAD 2>&1"))
+  if v:shell_error
+    throw commit
+  endif
+
+  let goversion = trim(system("go version"))
+  if v:shell_error
+    throw goversion
+  endif
+
+  let targetdir = a:plugindir."/cmd/govim/.bin/".sha256(commit.goversion)."/"
+  if $GOVIM_DEBUG_INSTALL == "true"
+	  " The first line may directly follow a "hint" message from Vim.
+	  echo "\n"
+	  echom "oldpath: ".oldpath
+	  echom "plugindir: ".a:plugindir
+	  echom "commit: ".commit
+	  echom "force: ".a:force
+	  echom "always install: ".$GOVIM_ALWAYS_INSTALL
+	  echom "govim readable: ".filereadable(targetdir."govim")
+	  echom "gopls readable: ".filereadable(targetdir."gopls")
+  endif
+  if a:force || $GOVIM_ALWAYS_INSTALL == "true" || !filereadable(targetdir."govim") || !filereadable(targetdir."gopls")
+    echom "Installing govim and gopls"
+    call feedkeys(" ") " to prevent press ENTER to continue
+    " TODO: make work on Windows
+    let install = system("env GO111MODULE=on GOBIN=".shellescape(targetdir)." GOWORK=off go install github.com/govim/govim/cmd/govim golang.org/x/tools/gopls 2>&1")
+    if v:shell_error
+      throw install
+    endif
+  endif
+  execute "cd ".oldpath
+  return targetdir
+endfunction
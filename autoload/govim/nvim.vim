@@ -0,0 +1,533 @@
+" The glue between Neovim and govim. Neovim does not have Vim's channels, so
+" on Neovim plugin/govim.vim calls govim#nvim#Start in place of its own
+" setup, and govim and Neovim talk msgpack-RPC instead:
+"
+" * Neovim makes requests of govim via rpcrequest() with the method "govim"
+"   and a single parameter: a message of the same form as those sent to govim
+"   by plugin/govim.vim via ch_evalexpr(). The result is [err, val].
+"
+" * govim makes requests of Neovim by calling govim#nvim#Handle with a
+"   message of the same form as those handled by s:define in
+"   plugin/govim.vim. The result is [err] or [err, val].
+"
+" Features of Vim that Neovim does not have, e.g. popups, text properties and
+" listeners, are not available via Neovim.
+
+let s:channel = 0
+let s:job = 0
+let s:plugindir = ""
+
+let s:govim_status = "loading"
+let s:loadStatusCallbacks = []
+
+let s:govim_logfile = "<unset>"
+let s:gopls_logfile = "<unset>"
+
+let s:statusline = {"workspace": {}, "buffers": {}, "busy": v:false, "view": "", "lastProgressTitle": ""}
+
+let s:userBusy = 0
+
+" s:shutdownTimeout is the time in milliseconds that Neovim waits for the
+" govim process to exit once its shutdown request has completed
+let s:shutdownTimeout = 5000
+
+" govim#nvim#Start starts govim for the govim plugin in plugindir, or
+" connects to the govim listening on $GOVIMTEST_SOCKET when under test
+function! govim#nvim#Start(plugindir)
+  let s:plugindir = a:plugindir
+
+  augroup govim
+  augroup END
+
+  command -bar GOVIMPluginInstall echom "Installed to ".govim#install#Install(s:plugindir, 1)
+  command -bar GOVIMLogfilePaths call s:logFilePaths()
+
+  if $GOVIMTEST_SOCKET != ""
+    let s:channel = sockconnect("tcp", $GOVIMTEST_SOCKET, {"rpc": v:true})
+  else
+    let l:targetdir = govim#install#Install(s:plugindir, 0)
+    let l:start = $GOVIM_RUNCMD
+    if l:start == ""
+      let l:start = [l:targetdir."govim", l:targetdir."gopls"]
+    endif
+    let s:job = jobstart(l:start, {
+          \ "rpc": v:true,
+          \ "env": {"GOVIM_NEOVIM": "true"},
+          \ "on_exit": function("s:govimExit"),
+          \ })
+    if s:job <= 0
+      throw "failed to start govim via ".string(l:start)
+    endif
+    let s:channel = s:job
+  endif
+
+  au VimLeavePre * call s:doShutdown()
+endfunction
+
+" govim#nvim#Handle handles the call msg from govim, of the form
+" [id, type, ...]
+function! govim#nvim#Handle(msg)
+  let l:resp = [""]
+  try
+    if a:msg[1] == "loaded"
+      let s:govim_status = "loaded"
+      for F in s:loadStatusCallbacks
+        call call(F, [s:govim_status])
+      endfor
+    elseif a:msg[1] == "initcomplete"
+      let s:govim_status = "initcomplete"
+      " doautoall BufRead also triggers ftplugin stuff
+      doautoall govim BufRead
+      doautoall govim FileType
+      if $GOVIM_DISABLE_USER_BUSY != "true"
+        au govim CursorMoved,CursorMovedI * ++nested :call s:userBusy(1)
+        au govim CursorHold,CursorHoldI,FocusLost * ++nested :call s:userBusy(0)
+      endif
+      for F in s:loadStatusCallbacks
+        call call(F, [s:govim_status])
+      endfor
+    elseif a:msg[1] == "currentViewport"
+      call add(l:resp, s:buildCurrentViewport())
+    elseif a:msg[1] == "function"
+      call s:defineFunction(a:msg[2], a:msg[3], 0)
+    elseif a:msg[1] == "rangefunction"
+      call s:defineFunction(a:msg[2], a:msg[3], 1)
+    elseif a:msg[1] == "command"
+      call s:defineCommand(a:msg[2], a:msg[3])
+    elseif a:msg[1] == "autocmd"
+      call s:defineAutoCommand(a:msg[2], a:msg[3], a:msg[4])
+    elseif a:msg[1] == "redraw"
+      execute "redraw".(a:msg[2] == "force" ? "!" : "")
+    elseif a:msg[1] == "ex"
+      execute a:msg[2]
+    elseif a:msg[1] == "normal"
+      execute "normal ".a:msg[2]
+    elseif a:msg[1] == "expr"
+      call add(l:resp, eval(a:msg[2]))
+    elseif a:msg[1] == "call"
+      let F = function(a:msg[2], a:msg[3:-1])
+      call add(l:resp, F())
+    elseif a:msg[1] == "error"
+      " this is an async call from the client
+      throw a:msg[2]
+    else
+      throw "unknown callback function type ".a:msg[1]
+    endif
+  catch
+    let l:resp = ['Caught ' . string(v:exception) . ' in ' . v:throwpoint]
+  endtry
+  return l:resp
+endfunction
+
+function! s:rpc(args)
+  let l:resp = rpcrequest(s:channel, "govim", a:args)
+  if l:resp[0] != ""
+    throw l:resp[0]
+  endif
+  return l:resp[1]
+endfunction
+
+" s:schedule runs the scheduled work id once Neovim next waits for input, at
+" which point it is safe to do so
+function! s:schedule(id)
+  call timer_start(0, function("s:runScheduled", [a:id]))
+endfunction
+
+function! s:runScheduled(id, timer)
+  call s:rpc(["schedule", a:id])
+  " Scheduled work can change the viewport without triggering the autocommands
+  " that push changes in it
+  if s:viewportSubscribed
+    call s:viewportChanged()
+  endif
+endfunction
+
+function! s:callbackFunction(name, args)
+  return s:rpc(["function", "function:".a:name, a:args])
+endfunction
+
+function! s:callbackRangeFunction(name, first, last, args)
+  return s:rpc(["function", "function:".a:name, a:first, a:last, a:args])
+endfunction
+
+function! s:callbackCommand(name, flags, ...)
+  let l:args = ["function", "command:".a:name, a:flags]
+  call extend(l:args, a:000)
+  return s:rpc(l:args)
+endfunction
+
+function! s:callbackAutoCommand(name, def, exprs)
+  " As in plugin/govim.vim, autocmd events that fire whilst govim is loading
+  " are ignored; the doautoall once we are initcomplete puts things in order
+  if s:govim_status != "initcomplete"
+    return
+  endif
+  let l:exprVals = []
+  for e in a:exprs
+    call add(l:exprVals, eval(e))
+  endfor
+  return s:rpc(["function", a:name, a:def, l:exprVals])
+endfunction
+
+function! s:doShutdown()
+  if s:govim_status != "loaded" && s:govim_status != "initcomplete"
+    return
+  endif
+  " Unlike ch_evalexpr(), rpcrequest() cannot time out, so we rely on govim
+  " to respond to the shutdown request in good time
+  try
+    call s:rpc(["shutdown"])
+  catch
+    echom "govim shutdown failed: ".v:exception
+  endtry
+  call chanclose(s:channel)
+  if s:job > 0
+    " govim exits once the channel is closed; wait for it to do so
+    call jobwait([s:job], s:shutdownTimeout)
+  endif
+endfunction
+
+function! s:govimExit(job, exitstatus, event)
+  if a:exitstatus != 0
+    let s:govim_status = "failed"
+  else
+    let s:govim_status = "exited"
+  endif
+  for F in s:loadStatusCallbacks
+    call call(F, [s:govim_status])
+  endfor
+  if a:exitstatus != 0
+    throw "govim plugin died :("
+  endif
+endfunction
+
+function! s:logFilePaths()
+  echom "govim logfile: ".s:govim_logfile
+  echom "gopls logfile: ".s:gopls_logfile
+endfunction
+
+function! s:buildCurrentViewport()
+  let l:currTabNr = tabpagenr()
+  let l:currWinNr = winnr()
+  let l:currWin = {}
+  let l:windows = []
+  for l:w in getwininfo()
+    let l:sw = filter(l:w, 'v:key != "variables"')
+    call add(l:windows, l:sw)
+    if l:sw.tabnr == l:currTabNr && l:sw.winnr == l:currWinNr
+      let l:currWin = l:sw
+    endif
+  endfor
+  return {
+        \ 'Current': l:currWin,
+        \ 'Windows': l:windows,
+        \ 'Cursor': {'bufnr': bufnr(""), 'line': line("."), 'col': col(".")},
+        \ }
+endfunction
+
+" The pushing of changes in the viewport to govim is as in plugin/govim.vim,
+" save that the push is an RPC notification
+let s:viewportDebounce = 50
+let s:viewportTimer = -1
+let s:viewport = {}
+let s:viewportSubscribed = 0
+
+function! s:subscribeViewport()
+  let l:vp = s:buildCurrentViewport()
+  let s:viewport = s:viewportState(l:vp)
+  let s:viewportSubscribed = 1
+  augroup govimViewport
+    au!
+    au BufEnter,CursorMoved,WinEnter,WinScrolled * call s:viewportChanged()
+  augroup END
+  return l:vp
+endfunction
+
+function! s:unsubscribeViewport()
+  let s:viewportSubscribed = 0
+  if s:viewportTimer != -1
+    call timer_stop(s:viewportTimer)
+    let s:viewportTimer = -1
+  endif
+  augroup govimViewport
+    au!
+  augroup END
+  augroup! govimViewport
+  let s:viewport = {}
+endfunction
+
+function! s:viewportState(vp)
+  let l:windows = {}
+  for l:w in a:vp.Windows
+    let l:windows[l:w.winid] = l:w
+  endfor
+  return {"current": get(a:vp.Current, "winid", 0), "windows": l:windows, "cursor": a:vp.Cursor}
+endfunction
+
+function! s:viewportChanged()
+  if s:viewportTimer != -1
+    call timer_stop(s:viewportTimer)
+  endif
+  let s:viewportTimer = timer_start(s:viewportDebounce, function("s:pushViewport"))
+endfunction
+
+function! s:pushViewport(timer)
+  let s:viewportTimer = -1
+  if !s:viewportSubscribed || (s:govim_status != "loaded" && s:govim_status != "initcomplete")
+    return
+  endif
+  let l:new = s:viewportState(s:buildCurrentViewport())
+  let l:delta = {}
+  if l:new.current != s:viewport.current
+    let l:delta.Current = l:new.current
+  endif
+  let l:changed = []
+  for [l:id, l:w] in items(l:new.windows)
+    if get(s:viewport.windows, l:id, {}) != l:w
+      call add(l:changed, l:w)
+    endif
+  endfor
+  if len(l:changed) > 0
+    let l:delta.Windows = l:changed
+  endif
+  let l:closed = []
+  for l:id in keys(s:viewport.windows)
+    if !has_key(l:new.windows, l:id)
+      call add(l:closed, str2nr(l:id))
+    endif
+  endfor
+  if len(l:closed) > 0
+    let l:delta.Closed = l:closed
+  endif
+  if l:new.cursor != s:viewport.cursor
+    let l:delta.Cursor = l:new.cursor
+  endif
+  let s:viewport = l:new
+  if l:delta != {}
+    call rpcnotify(s:channel, "govim", ["viewport", l:delta])
+  endif
+endfunction
+
+function! GOVIMPluginStatus(...)
+  if s:govim_status != "loaded" && s:govim_status != "failed" && len(a:000) != 0
+    call extend(s:loadStatusCallbacks, a:000)
+  endif
+  return s:govim_status
+endfunction
+
+" GOVIMStatusline is as described in plugin/govim.vim
+function! GOVIMStatusline(...)
+  if len(a:000) > 0
+    let l:bufnr = a:1
+  elseif exists("g:statusline_winid")
+    let l:bufnr = winbufnr(g:statusline_winid)
+  else
+    let l:bufnr = bufnr("")
+  endif
+  let l:counts = {"error": 0, "warning": 0, "info": 0, "hint": 0}
+  return {
+        \ "buffer": copy(get(s:statusline.buffers, string(l:bufnr), l:counts)),
+        \ "workspace": extend(copy(l:counts), s:statusline.workspace),
+        \ "busy": s:statusline.busy,
+        \ "view": s:statusline.view,
+        \ "lastProgressTitle": s:statusline.lastProgressTitle,
+        \ }
+endfunction
+
+function! GOVIM_internal_SetStatusline(status)
+  let s:statusline = a:status
+  if exists("#User#GOVIMStatusChanged")
+    doautocmd <nomodeline> User GOVIMStatusChanged
+  endif
+endfunction
+
+function! s:userBusy(busy)
+  if s:userBusy != a:busy
+    let s:userBusy = a:busy
+    call GOVIM_internal_SetUserBusy(s:userBusy, s:cursorPos())
+  endif
+endfunction
+
+" s:cursorPos is as in plugin/govim.vim
+function! s:cursorPos()
+  let l:winnr = winnr()
+  let l:winid = win_getid(l:winnr)
+  return {"bufnr": bufnr(""),
+        \ "line": line("."),
+        \ "col": col("."),
+        \ "winnr": l:winnr,
+        \ "winid": l:winid,
+        \ "screenpos": screenpos(l:winid, line("."), col("."))
+        \ }
+endfunction
+
+if $GOVIM_DISABLE_USER_BUSY == "true"
+  function! GOVIM_test_SetUserBusy(busy)
+    return s:userBusy(a:busy)
+  endfunction
+endif
+
+function! s:defineAutoCommand(name, def, exprs)
+  let l:exprStrings = []
+  for e in a:exprs
+    call add(l:exprStrings, '"'.escape(e, '"').'"')
+  endfor
+  execute "autocmd " . a:def . " call s:callbackAutoCommand(\"" . a:name . "\", \"".escape(a:def, '"')."\", [".join(l:exprStrings, ",")."])"
+endfunction
+
+function! s:defineCommand(name, attrs)
+  let l:def = "command! "
+  let l:args = ""
+  let l:flags = ['"mods": expand("<mods>")']
+  if has_key(a:attrs, "nargs")
+    let l:def .= " ". a:attrs["nargs"]
+    if a:attrs["nargs"] != "-nargs=0"
+      let l:args = ", <f-args>"
+    endif
+  endif
+  if has_key(a:attrs, "range")
+    let l:def .= " ".a:attrs["range"]
+    call add(l:flags, '"line1": <line1>')
+    call add(l:flags, '"line2": <line2>')
+    call add(l:flags, '"range": <range>')
+  endif
+  if has_key(a:attrs, "count")
+    let l:def .= " ". a:attrs["count"]
+    call add(l:flags, '"count": <count>')
+  endif
+  if has_key(a:attrs, "complete")
+    let l:def .= " ". a:attrs["complete"]
+  endif
+  if has_key(a:attrs, "general")
+    for l:a in a:attrs["general"]
+      let l:def .= " ". l:a
+      if l:a == "-bang"
+        call add(l:flags, '"bang": "<bang>"')
+      endif
+      if l:a == "-register"
+        call add(l:flags, '"register": "<reg>"')
+      endif
+    endfor
+  endif
+  let l:flagsStr = "{" . join(l:flags, ", ") . "}"
+  let l:def .= " " . a:name . " call s:callbackCommand(\"". a:name . "\", " . l:flagsStr . l:args . ")"
+  execute l:def
+endfunction
+
+function! s:defineFunction(name, argsStr, range)
+  let l:params = join(a:argsStr, ", ")
+  let l:args = "let l:args = []\n"
+  if len(a:argsStr) == 1 && a:argsStr[0] == "..."
+    let l:args = "let l:args = a:000\n"
+  elseif len(a:argsStr) > 0
+    let l:args = "let l:args = ["
+    let l:join = ""
+    for i in a:argsStr
+      if i == "..."
+        let l:args = l:args.l:join."a:000"
+      else
+        let l:args = l:args.l:join."a:".i
+      endif
+      let l:join = ", "
+    endfor
+    let l:args = l:args."]"
+  endif
+  if a:range == 1
+    let l:range = " range"
+    let l:ret = "return s:callbackRangeFunction(\"" . a:name . "\", a:firstline, a:lastline, l:args)"
+  else
+    let l:range = ""
+    let l:ret = "return s:callbackFunction(\"" . a:name . "\", l:args)"
+  endif
+  execute "function! "  . a:name . "(" . l:params . ") " . l:range . "\n" .
+        \ l:args . "\n" .
+        \ l:ret . "\n" .
+        \ "endfunction\n"
+endfunction
+
+" s:batchCall is as in plugin/govim.vim, save that Neovim uses v:null where
+" Vim uses v:none
+function! s:batchCall(calls)
+  let l:results = []
+  for l:call in a:calls
+    let l:type = l:call[0]
+    let l:mustName = l:call[1][0]
+    let l:mustArgs = l:call[1][1]
+    if l:mustArgs is v:null
+      let l:mustArgs = []
+    endif
+    let Must = call(l:mustName, l:mustArgs)
+    let l:res = v:null
+    let l:err = v:null
+    if l:type == "call"
+      let l:fn = l:call[2]
+      let l:args = l:call[3:-1]
+      let F = function(l:fn, l:args)
+      try
+        let l:res = F()
+      catch
+        let l:err = v:exception
+      endtry
+      let l:check = Must(l:res, l:err)
+      if !l:check[0]
+        return {'results': l:results, 'failed': len(l:results), 'error': "failed to call ".l:fn."(".string(l:args)."): ".l:check[1]}
+      endif
+    elseif l:type == "expr"
+      let l:expr = l:call[2]
+      try
+        let l:res = eval(l:expr)
+      catch
+        let l:err = v:exception
+      endtry
+      let l:check = Must(l:res, l:err)
+      if !l:check[0]
+        return {'results': l:results, 'failed': len(l:results), 'error': "failed to eval ".l:expr.": ".l:check[1]}
+      endif
+    else
+      throw "Unknown batch type: ".l:type
+    endif
+    call add(l:results, l:res)
+  endfor
+  return {'results': l:results, 'failed': -1, 'error': ''}
+endfunction
+
+function! s:mustNoError()
+  let l:args = {}
+  function l:args.f(v, err)
+    if a:err isnot v:null
+      return [v:false, a:err]
+    endif
+    return [v:true, ""]
+  endfunction
+  return l:args.f
+endfunction
+
+function! s:mustBeZero()
+  let l:args = {}
+  function l:args.f(v, err)
+    if a:err isnot v:null
+      return [v:false, a:err]
+    endif
+    if a:v != 0
+      return [v:false, "got non-zero return value"]
+    endif
+    return [v:true, ""]
+  endfunction
+  return l:args.f
+endfunction
+
+function! s:mustBeErrorOrNil(...)
+  let l:args = {'patterns': a:000}
+  function l:args.f(v, err)
+    if a:err is v:null
+      return [v:true, ""]
+    endif
+    for l:v in self.patterns
+      if match(a:err, l:v) >= 0
+        return [v:true, ""]
+      endif
+    endfor
+    return [v:false, a:err]
+  endfunction
+  return l:args.f
+endfunction
@@ -0,0 +1,253 @@
+package govim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInBatch is returned by Govim.Batch when a batch is already open, and by
+// calls to Vim that are made outside of the batch whilst it is open. This
+// detection only applies to the Govim instance of the event queue, the
+// calls of which are known to be sequential.
+var ErrInBatch = errors.New("a batch is already open")
+
+// ErrBatchCallNotMade is the error of the calls in a batch that follow a
+// call that failed, and were hence not made
+var ErrBatchCallNotMade = errors.New("call not made because an earlier call in the batch failed")
+
+// AssertExpr is an assertion on the result of a call in a Batch, made by Vim
+// as the batch is run. Fn is the name of a Vim function that, called with
+// Args, returns a function that checks the result and error of the call.
+type AssertExpr struct {
+	Fn   string
+	Args []interface{}
+}
+
+// AssertNoError asserts that a call does not result in an error
+func AssertNoError() AssertExpr {
+	return AssertExpr{
+		Fn: "s:mustNoError",
+	}
+}
+
+// AssertIsZero asserts that a call does not result in an error, and returns
+// zero
+func AssertIsZero() AssertExpr {
+	return AssertExpr{
+		Fn: "s:mustBeZero",
+	}
+}
+
+// AssertIsErrorOrNil asserts that a call either does not result in an error,
+// or results in an error that matches one of the Vim regular expressions
+// patterns
+func AssertIsErrorOrNil(patterns ...string) AssertExpr {
+	args := make([]interface{}, 0, len(patterns))
+	for _, v := range patterns {
+		args = append(args, v)
+	}
+	return AssertExpr{
+		Fn:   "s:mustBeErrorOrNil",
+		Args: args,
+	}
+}
+
+// BatchCallError is the error returned by Batch.End when a call in the batch
+// fails its assertion
+type BatchCallError struct {
+	// Index is the index of the call within the batch
+	Index int
+
+	// Err is the reason given by Vim for the failure
+	Err string
+}
+
+func (e *BatchCallError) Error() string {
+	return fmt.Sprintf("call %v of batch failed: %v", e.Index, e.Err)
+}
+
+// Batch is a sequence of calls to Vim that are made in a single round trip
+// when the batch is ended. A Batch is created by Govim.Batch. Each call is
+// made subject to an AssertExpr: the first call that fails its assertion ends
+// the batch, and the calls that follow it are not made.
+type Batch struct {
+	c BatchCaller
+
+	// release is called when the batch is ended or cancelled
+	release func()
+
+	calls   []interface{}
+	results []json.RawMessage
+	ended   bool
+
+	// failed is the index of the call that failed its assertion, or -1, and
+	// failedErr its error
+	failed    int
+	failedErr *BatchCallError
+
+	// err is set if the batch as a whole failed
+	err error
+}
+
+// BatchCaller makes the single call of s:batchCall by which a Batch is run. It
+// is implemented by Govim.
+type BatchCaller interface {
+	ChannelCall(fn string, args ...interface{}) (json.RawMessage, error)
+}
+
+// NewBatch returns a Batch that is run via c. Unlike Govim.Batch, it does not
+// detect nested batches; it is intended for implementations of Govim and for
+// tests.
+func NewBatch(c BatchCaller) *Batch {
+	return newBatch(c, func() {})
+}
+
+func newBatch(c BatchCaller, release func()) *Batch {
+	return &Batch{
+		c:       c,
+		release: release,
+		failed:  -1,
+	}
+}
+
+// BatchResult is the result of a call in a Batch, available once the batch
+// has ended
+type BatchResult struct {
+	b *Batch
+	i int
+}
+
+// Raw returns the result of the call, or nil if the call failed or was not
+// made. It panics if the batch has not ended.
+func (r BatchResult) Raw() json.RawMessage {
+	if !r.b.ended {
+		panic(fmt.Errorf("tried to get result from incomplete Batch"))
+	}
+	if r.i < len(r.b.results) {
+		return r.b.results[r.i]
+	}
+	return nil
+}
+
+// Err returns the error of the call: a *BatchCallError if the call failed its
+// assertion, ErrBatchCallNotMade if an earlier call failed, or the error of
+// the batch as a whole. It panics if the batch has not ended.
+func (r BatchResult) Err() error {
+	if !r.b.ended {
+		panic(fmt.Errorf("tried to get error from incomplete Batch"))
+	}
+	switch {
+	case r.b.err != nil:
+		return r.b.err
+	case r.b.failed == -1 || r.i < r.b.failed:
+		return nil
+	case r.i == r.b.failed:
+		return r.b.failedErr
+	}
+	return ErrBatchCallNotMade
+}
+
+// Decode decodes the result of the call into v, returning the error of the
+// call if it failed
+func (r BatchResult) Decode(v interface{}) error {
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(r.Raw(), v); err != nil {
+		return fmt.Errorf("failed to decode result %v of batch: %v", r.i, err)
+	}
+	return nil
+}
+
+func (b *Batch) add(call []interface{}) BatchResult {
+	if b.ended {
+		panic(fmt.Errorf("tried to add to an ended Batch"))
+	}
+	b.calls = append(b.calls, call)
+	return BatchResult{b: b, i: len(b.calls) - 1}
+}
+
+// ChannelCall adds a call of fn with args to the batch, asserting that the
+// call does not result in an error
+func (b *Batch) ChannelCall(fn string, args ...interface{}) BatchResult {
+	return b.AssertChannelCall(AssertNoError(), fn, args...)
+}
+
+// AssertChannelCall adds a call of fn with args to the batch, subject to the
+// assertion a
+func (b *Batch) AssertChannelCall(a AssertExpr, fn string, args ...interface{}) BatchResult {
+	call := []interface{}{
+		"call",
+		[2]interface{}{a.Fn, a.Args},
+		fn,
+	}
+	return b.add(append(call, args...))
+}
+
+// ChannelExprf adds the evaluation of an expression to the batch, asserting
+// that the evaluation does not result in an error
+func (b *Batch) ChannelExprf(format string, args ...interface{}) BatchResult {
+	return b.AssertChannelExprf(AssertNoError(), format, args...)
+}
+
+// AssertChannelExprf adds the evaluation of an expression to the batch,
+// subject to the assertion a
+func (b *Batch) AssertChannelExprf(a AssertExpr, format string, args ...interface{}) BatchResult {
+	return b.add([]interface{}{
+		"expr",
+		[2]interface{}{a.Fn, a.Args},
+		fmt.Sprintf(format, args...),
+	})
+}
+
+// Len returns the number of calls in the batch
+func (b *Batch) Len() int {
+	return len(b.calls)
+}
+
+// Cancel ends the batch without making its calls. It is a no-op if the batch
+// has already ended.
+func (b *Batch) Cancel() {
+	if b.ended {
+		return
+	}
+	b.ended = true
+	b.release()
+}
+
+// End ends the batch, making its calls in Vim. It returns the results of the
+// calls that were made. If a call failed its assertion, the error is a
+// *BatchCallError that identifies it, and the results are those of the calls
+// that preceded it.
+func (b *Batch) End() ([]json.RawMessage, error) {
+	if b.ended {
+		panic(fmt.Errorf("called End on an ended Batch"))
+	}
+	b.ended = true
+	b.release()
+	if len(b.calls) == 0 {
+		return nil, nil
+	}
+	raw, err := b.c.ChannelCall("s:batchCall", b.calls)
+	if err != nil {
+		b.err = err
+		return nil, err
+	}
+	var resp struct {
+		Results []json.RawMessage `json:"results"`
+		Failed  int               `json:"failed"`
+		Error   string            `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		b.err = fmt.Errorf("failed to decode result of batch: %v", err)
+		return nil, b.err
+	}
+	b.results = resp.Results
+	if resp.Failed >= 0 {
+		b.failed = resp.Failed
+		b.failedErr = &BatchCallError{Index: resp.Failed, Err: resp.Error}
+		return b.results, b.failedErr
+	}
+	return b.results, nil
+}
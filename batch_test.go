@@ -0,0 +1,135 @@
+package govim
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/govim/govim/internal/logging"
+	"github.com/govim/govim/internal/queue"
+	"gopkg.in/tomb.v2"
+)
+
+type fakeBatchCaller struct {
+	calls  []string
+	result string
+}
+
+func (f *fakeBatchCaller) ChannelCall(fn string, args ...interface{}) (json.RawMessage, error) {
+	byts, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	f.calls = append(f.calls, fn+string(byts))
+	return json.RawMessage(f.result), nil
+}
+
+func TestBatchResults(t *testing.T) {
+	c := &fakeBatchCaller{result: `{"results":[5,4],"failed":-1,"error":""}`}
+	b := NewBatch(c)
+	r0 := b.ChannelCall("eval", "5")
+	r1 := b.AssertChannelExprf(AssertIsZero(), "%v", 4)
+	if b.Len() != 2 {
+		t.Fatalf("got batch length %v; want 2", b.Len())
+	}
+	if _, err := b.End(); err != nil {
+		t.Fatal(err)
+	}
+	want := `s:batchCall[[["call",["s:mustNoError",null],"eval","5"],["expr",["s:mustBeZero",null],"4"]]]`
+	if len(c.calls) != 1 || c.calls[0] != want {
+		t.Errorf("got calls %v; want [%v]", c.calls, want)
+	}
+	var got0, got1 int
+	if err := r0.Decode(&got0); err != nil || got0 != 5 {
+		t.Errorf("got result %v, %v; want 5, nil", got0, err)
+	}
+	if err := r1.Decode(&got1); err != nil || got1 != 4 {
+		t.Errorf("got result %v, %v; want 4, nil", got1, err)
+	}
+}
+
+func TestBatchCallError(t *testing.T) {
+	c := &fakeBatchCaller{result: `{"results":[5],"failed":1,"error":"failed to call execute"}`}
+	b := NewBatch(c)
+	r0 := b.ChannelCall("eval", "5")
+	r1 := b.ChannelCall("execute", "throw 'x'")
+	r2 := b.ChannelExprf("4")
+	_, err := b.End()
+	var bce *BatchCallError
+	if !errors.As(err, &bce) || bce.Index != 1 {
+		t.Fatalf("got error %v; want a *BatchCallError for call 1", err)
+	}
+	if want := "call 1 of batch failed: failed to call execute"; err.Error() != want {
+		t.Errorf("got error %q; want %q", err, want)
+	}
+	if err := r0.Err(); err != nil {
+		t.Errorf("got error %v for call 0; want nil", err)
+	}
+	if err := r1.Err(); err != bce {
+		t.Errorf("got error %v for call 1; want %v", err, bce)
+	}
+	if err := r2.Err(); err != ErrBatchCallNotMade {
+		t.Errorf("got error %v for call 2; want %v", err, ErrBatchCallNotMade)
+	}
+}
+
+func TestEventQueueBatchNesting(t *testing.T) {
+	e := eventQueueInst{govimImpl: &govimImpl{}}
+	b, err := e.Batch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Batch(); err != ErrInBatch {
+		t.Errorf("got error %v starting a nested batch; want %v", err, ErrInBatch)
+	}
+	if err := e.ChannelEx("echo"); !errors.Is(err, ErrInBatch) {
+		t.Errorf("got error %v calling ChannelEx in a batch; want %v", err, ErrInBatch)
+	}
+	b.Cancel()
+	b, err = e.Batch()
+	if err != nil {
+		t.Fatalf("failed to start a batch after cancelling the previous one: %v", err)
+	}
+	if _, err := b.End(); err != nil {
+		t.Fatalf("failed to end empty batch: %v", err)
+	}
+	if e.queueBatch != nil {
+		t.Errorf("batch still open after End")
+	}
+}
+
+func TestEventQueueCancelsOpenBatch(t *testing.T) {
+	var tb tomb.Tomb
+	g := &govimImpl{
+		logger:      logging.New(io.Discard, logging.Text, logging.Levels{Default: logging.Debug}),
+		flushEvents: make(chan struct{}),
+		eventQueue:  queue.NewQueue(),
+		tomb:        &tb,
+	}
+	tb.Go(g.runEventQueue)
+	defer func() {
+		tb.Kill(nil)
+		tb.Wait()
+	}()
+	<-g.Enqueue(func(g Govim) error {
+		b, err := g.Batch()
+		if err != nil {
+			return err
+		}
+		// Returned without ending the batch
+		b.ChannelCall("eval", "5")
+		return nil
+	})
+	var err error
+	<-g.Enqueue(func(g Govim) error {
+		var b *Batch
+		if b, err = g.Batch(); err == nil {
+			b.Cancel()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("failed to start a batch after one was left open: %v", err)
+	}
+}
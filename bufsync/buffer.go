@@ -0,0 +1,121 @@
+package bufsync
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrSuperseded is the error from Buffer.Parsed for a version of a buffer
+// that was not parsed, because a later version arrived before its parse
+// could start
+var ErrSuperseded = errors.New("superseded by a later version")
+
+// Buffer is a version of the contents of a buffer in Vim. A Buffer is never
+// changed: each change in Vim results in a new Buffer, with the next
+// version, so a Buffer may be used from any goroutine.
+type Buffer struct {
+	// Num is the number of the buffer in Vim
+	Num int
+
+	// Name is the full path of the buffer
+	Name string
+
+	// Filetype is the &filetype of the buffer when it was read. A later
+	// change of filetype does not result in a new version.
+	Filetype string
+
+	// Version is 1 when the buffer is read, and is incremented with each
+	// change
+	Version int
+
+	contents []byte
+
+	// parse is the parse of contents, or nil if no parser handles the
+	// buffer
+	parse *parse
+}
+
+// parse is the result of parsing a Buffer in the background with parser.
+// result and err are set before done is closed.
+type parse struct {
+	parser Parser
+	done   chan struct{}
+	result interface{}
+	err    error
+}
+
+// Contents returns the contents of b, one line after another, each ended by
+// a newline. The contents must not be mutated.
+func (b *Buffer) Contents() []byte {
+	return b.contents
+}
+
+// Lines returns the 1-indexed lines start up to, but not including, end
+func (b *Buffer) Lines(start, end int) []string {
+	lines := b.lines()
+	if start < 1 {
+		start = 1
+	}
+	if end > len(lines)+1 {
+		end = len(lines) + 1
+	}
+	var res []string
+	for _, l := range lines[start-1 : end-1] {
+		res = append(res, string(l))
+	}
+	return res
+}
+
+// LineCount returns the number of lines in b
+func (b *Buffer) LineCount() int {
+	return len(b.lines())
+}
+
+func (b *Buffer) lines() [][]byte {
+	if len(b.contents) == 0 {
+		return nil
+	}
+	return bytes.Split(b.contents[:len(b.contents)-1], []byte("\n"))
+}
+
+// Parsed returns the result of parsing b with the parser that handles it,
+// waiting for the parse, which runs in the background, to complete. Parsed
+// returns nil, nil if no parser handles b, and ErrSuperseded if b was not
+// parsed because a later version arrived first; Sync.Buffer gives the latest
+// version. As per go/parser, a parser may return a partial result along with
+// an error.
+func (b *Buffer) Parsed() (interface{}, error) {
+	if b.parse == nil {
+		return nil, nil
+	}
+	<-b.parse.done
+	return b.parse.result, b.parse.err
+}
+
+// Change is a change in a buffer: the 1-indexed lines Start up to, but not
+// including, End of the previous version of the buffer were replaced by
+// Lines. Lines is empty if the lines were deleted, and Start and End are
+// equal if Lines were inserted before Start.
+type Change struct {
+	Start int
+	End   int
+	Lines []string
+}
+
+// apply returns contents with changes applied in order
+func apply(contents []byte, changes []Change) []byte {
+	var lines [][]byte
+	if len(contents) > 0 {
+		lines = bytes.Split(contents[:len(contents)-1], []byte("\n"))
+	}
+	for _, c := range changes {
+		var res [][]byte
+		res = append(res, lines[:c.Start-1]...)
+		for _, l := range c.Lines {
+			res = append(res, []byte(l))
+		}
+		res = append(res, lines[c.End-1:]...)
+		lines = res
+	}
+	return append(bytes.Join(lines, []byte("\n")), '\n')
+}
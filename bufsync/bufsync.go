@@ -0,0 +1,493 @@
+// Package bufsync keeps an up-to-date, versioned copy of the contents of
+// buffers in Vim, for plugins that need more than the occasional
+// getbufline().
+//
+// A Sync tracks the buffers read in Vim whose names match its patterns. Vim
+// sends each change in a tracked buffer to govim via a listener (see :help
+// listener_add()), from which the Sync derives a new version of the buffer,
+// so a plugin never calls Vim for the contents of a buffer. Versions are
+// parsed in the background, one at a time per buffer, by the first of the
+// parsers of the Sync that handles the buffer, for example go/parser for .go
+// files; a version superseded while waiting is not parsed. Subscribers are
+// told of buffers being read, changed, loaded again and deleted. A plugin may
+// also track a buffer whatever its name, for example because of its filetype.
+//
+// Listeners are specific to Vim: a Sync is not available under Neovim.
+package bufsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/govim/govim"
+)
+
+// Config is the configuration of a Sync
+type Config struct {
+	// Patterns are the autocommand patterns of the buffers that are tracked,
+	// as per :help autocmd-patterns. They default to all buffers.
+	Patterns govim.Patterns
+
+	// Parsers parse each version of a tracked buffer. A buffer is parsed by
+	// the first parser that handles it; a buffer that no parser handles is
+	// not parsed.
+	Parsers []Parser
+
+	// Group is the augroup of the autocommands of the Sync. It defaults to
+	// that of the Govim passed to New. The buffers already loaded when
+	// govim has loaded are read by a doautoall of the govim augroup, so a
+	// Govim that is not that of a hosted plugin needs Group "govim".
+	Group string
+}
+
+// EventKind is the kind of an Event
+type EventKind int
+
+const (
+	// EventRead is the reading of a buffer that was not tracked
+	EventRead EventKind = iota
+
+	// EventChanged is a change in a buffer, including the reading again of
+	// a buffer, for example by :edit!
+	EventChanged
+
+	// EventWipeout is the deleting (see :help :bdelete) or wiping out of a
+	// buffer, or its untracking (see Untrack), after which it is no longer
+	// tracked
+	EventWipeout
+
+	// EventReloaded is the reading again of a buffer without its contents
+	// changing, for example having been unloaded. Vim removes the text
+	// properties of a buffer that is unloaded.
+	EventReloaded
+)
+
+// Event is a change in the buffers tracked by a Sync
+type Event struct {
+	Kind EventKind
+
+	// Buffer is the version of the buffer after the event. For EventWipeout
+	// and EventReloaded it is the latest version of the buffer.
+	Buffer *Buffer
+
+	// Previous is the version of the buffer before an EventChanged
+	Previous *Buffer
+
+	// Changes are the changes from Previous to Buffer, in order, for an
+	// EventChanged
+	Changes []Change
+}
+
+// Sync keeps an up-to-date, versioned copy of the buffers in Vim that match
+// its patterns
+type Sync struct {
+	name   string
+	config Config
+
+	mu          sync.Mutex
+	buffers     map[int]*Buffer
+	listeners   map[int]int
+	readTicks   map[int]int
+	subscribers []*subscriber
+	nextSubID   int
+
+	// parsing holds, by buffer number, the buffers that are being parsed.
+	// The value is the version to parse next, if any, which is superseded
+	// should a later version arrive before the parse finishes.
+	parsing map[int]*Buffer
+}
+
+type subscriber struct {
+	id int
+	f  func(g govim.Govim, e Event) error
+}
+
+// exprAbuf gives the buffer of an autocommand
+const exprAbuf = "eval(expand('<abuf>'))"
+
+// exprBufInfo gives the buffer num, which is an expression, its contents,
+// its &filetype, and its b:changedtick, by which the autocommands for two
+// patterns that match the buffer are told from the buffer being read twice
+func exprBufInfo(num string) string {
+	return fmt.Sprintf(`{"Num": %[1]v, "Name": fnamemodify(bufname(%[1]v),':p'), "Contents": join(getbufline(%[1]v, 1, "$"), "\n")."\n", "Filetype": getbufvar(%[1]v, "&filetype"), "Tick": getbufvar(%[1]v, "changedtick")}`, num)
+}
+
+// exprBufContents gives the contents of the buffer whose number is its
+// argument
+const exprBufContents = `join(getbufline(%v, 1, "$"), "\n")."\n"`
+
+// New returns a Sync with config that tracks buffers on g from their being
+// read. It receives changes via the Vim function name+"Changed", which it
+// defines on g, so name must begin with a capital letter. A plugin calls New
+// in its Init, so that the buffers already loaded are tracked once govim has
+// loaded.
+func New(g govim.Govim, name string, config Config) (*Sync, error) {
+	if len(config.Patterns) == 0 {
+		config.Patterns = govim.Patterns{"*"}
+	}
+	s := &Sync{
+		name:      name,
+		config:    config,
+		buffers:   make(map[int]*Buffer),
+		listeners: make(map[int]int),
+		readTicks: make(map[int]int),
+		parsing:   make(map[int]*Buffer),
+	}
+	if err := g.DefineFunction(s.changedFunc(), []string{"bufnr", "start", "end", "added", "changes"}, s.bufChanged); err != nil {
+		return nil, err
+	}
+	// The deleting of all buffers is handled, rather than of those that match
+	// the patterns, because a buffer tracked by Track need not match them
+	if err := g.DefineAutoCommand(config.Group, govim.Events{govim.EventBufDelete, govim.EventBufWipeout}, govim.Patterns{"*"}, false, s.bufDelete, exprAbuf); err != nil {
+		return nil, err
+	}
+	if err := s.AddPatterns(g, config.Patterns); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// AddPatterns tracks the buffers whose names match patterns from their being
+// read, as well as those that match the patterns of the Config of s. A
+// buffer that matches more than one pattern is tracked once.
+func (s *Sync) AddPatterns(g govim.Govim, patterns govim.Patterns) error {
+	return g.DefineAutoCommand(s.config.Group, govim.Events{govim.EventBufRead, govim.EventBufNewFile}, patterns, false, s.bufRead, exprBufInfo(exprAbuf))
+}
+
+// Track tracks the buffer num, whether or not its name matches the patterns
+// of s, as if it had just been read. A plugin tracks a buffer for example
+// because of its filetype, when the FileType autocommand is triggered. Track
+// is a no-op for a buffer that is already tracked.
+func (s *Sync) Track(g govim.Govim, num int) error {
+	if s.Buffer(num) != nil {
+		return nil
+	}
+	res, err := g.ChannelExpr(exprBufInfo(strconv.Itoa(num)))
+	if err != nil {
+		return fmt.Errorf("failed to get buffer %v: %v", num, err)
+	}
+	return s.bufRead(g, res)
+}
+
+// Untrack stops tracking the buffer num, as if it had been deleted. A buffer
+// whose name matches the patterns of s is tracked again when it is next
+// read. Untrack is a no-op for a buffer that is not tracked.
+func (s *Sync) Untrack(g govim.Govim, num int) error {
+	return s.untrack(g, num)
+}
+
+func (s *Sync) changedFunc() string { return s.name + "Changed" }
+
+// Buffer returns the latest version of the buffer with number num, or nil if
+// the buffer is not tracked
+func (s *Sync) Buffer(num int) *Buffer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buffers[num]
+}
+
+// Buffers returns the latest versions of the buffers that are tracked, in
+// order of their numbers
+func (s *Sync) Buffers() []*Buffer {
+	s.mu.Lock()
+	var res []*Buffer
+	for _, b := range s.buffers {
+		res = append(res, b)
+	}
+	s.mu.Unlock()
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Num < res[j].Num
+	})
+	return res
+}
+
+// OnChange subscribes f to the events of s. f is called, in the order of
+// subscription, from the handler of the event, so may call Vim; an error
+// returned by f is returned to Vim. cancel ends the subscription.
+func (s *Sync) OnChange(f func(g govim.Govim, e Event) error) (cancel func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextSubID
+	s.nextSubID++
+	s.subscribers = append(s.subscribers, &subscriber{id: id, f: f})
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, sub := range s.subscribers {
+			if sub.id == id {
+				s.subscribers = append(s.subscribers[:i], s.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// publish sets the latest version of the buffer of e, and calls the
+// subscribers of s with e
+func (s *Sync) publish(g govim.Govim, e Event) error {
+	s.mu.Lock()
+	if e.Kind == EventWipeout {
+		delete(s.buffers, e.Buffer.Num)
+	} else {
+		s.buffers[e.Buffer.Num] = e.Buffer
+	}
+	subs := append([]*subscriber{}, s.subscribers...)
+	s.mu.Unlock()
+	var errs []string
+	for _, sub := range subs {
+		if err := sub.f(g, e); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to handle change in buffer %v: %v", e.Buffer.Name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// newBuffer returns a version of a buffer, which is parsed in the
+// background. At most one version of a buffer is parsed at a time: a version
+// that arrives during a parse waits for it, and is superseded by any later
+// version that arrives in the meantime.
+func (s *Sync) newBuffer(num int, name, filetype string, version int, contents []byte) *Buffer {
+	b := &Buffer{
+		Num:      num,
+		Name:     name,
+		Filetype: filetype,
+		Version:  version,
+		contents: contents,
+	}
+	for _, p := range s.config.Parsers {
+		if !p.Handles(name) {
+			continue
+		}
+		b.parse = &parse{parser: p, done: make(chan struct{})}
+		break
+	}
+	if b.parse == nil {
+		return b
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if next, ok := s.parsing[num]; ok {
+		if next != nil {
+			next.parse.err = ErrSuperseded
+			close(next.parse.done)
+		}
+		s.parsing[num] = b
+		return b
+	}
+	s.parsing[num] = nil
+	go s.parseBuffers(b)
+	return b
+}
+
+// parseBuffers parses b, then any version of the buffer that arrived in the
+// meantime
+func (s *Sync) parseBuffers(b *Buffer) {
+	for b != nil {
+		b.parse.result, b.parse.err = b.parse.parser.Parse(b.Name, b.contents)
+		close(b.parse.done)
+		s.mu.Lock()
+		next := s.parsing[b.Num]
+		if next == nil {
+			delete(s.parsing, b.Num)
+		} else {
+			s.parsing[b.Num] = nil
+		}
+		s.mu.Unlock()
+		b = next
+	}
+}
+
+func (s *Sync) bufRead(g govim.Govim, args ...json.RawMessage) error {
+	var info struct {
+		Num      int
+		Name     string
+		Contents string
+		Filetype string
+		Tick     int
+	}
+	if err := json.Unmarshal(args[0], &info); err != nil {
+		return fmt.Errorf("failed to decode buffer: %v", err)
+	}
+	s.mu.Lock()
+	prev := s.buffers[info.Num]
+	tick, read := s.readTicks[info.Num]
+	s.readTicks[info.Num] = info.Tick
+	s.mu.Unlock()
+	if prev != nil && read && tick == info.Tick {
+		// The autocommand for another of the patterns of s that match the
+		// buffer
+		return nil
+	}
+	if prev == nil {
+		if err := s.addListener(g, info.Num); err != nil {
+			return err
+		}
+		b := s.newBuffer(info.Num, info.Name, info.Filetype, 1, []byte(info.Contents))
+		return s.publish(g, Event{Kind: EventRead, Buffer: b})
+	}
+	if info.Contents == string(prev.contents) && info.Name == prev.Name {
+		// The buffer was loaded again without changing, for example having
+		// been unloaded
+		return s.publish(g, Event{Kind: EventReloaded, Buffer: prev})
+	}
+	// The buffer was read again, for example by :edit!, which replaces all
+	// of its lines
+	return s.replace(g, prev, info.Name, []byte(info.Contents))
+}
+
+// replace publishes a new version of the buffer prev with name and contents,
+// as a change that replaces all of its lines
+func (s *Sync) replace(g govim.Govim, prev *Buffer, name string, contents []byte) error {
+	b := s.newBuffer(prev.Num, name, prev.Filetype, prev.Version+1, contents)
+	change := Change{Start: 1, End: prev.LineCount() + 1, Lines: b.Lines(1, b.LineCount()+1)}
+	return s.publish(g, Event{Kind: EventChanged, Buffer: b, Previous: prev, Changes: []Change{change}})
+}
+
+// addListener adds the listener of s to buffer num
+func (s *Sync) addListener(g govim.Govim, num int) error {
+	var listener int
+	res, err := g.ChannelCall("s:listenerAdd", s.changedFunc(), num)
+	if err == nil {
+		err = json.Unmarshal(res, &listener)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add listener to buffer %v: %v", num, err)
+	}
+	s.mu.Lock()
+	s.listeners[num] = listener
+	s.mu.Unlock()
+	return nil
+}
+
+// removeListener removes the listener of s from buffer num
+func (s *Sync) removeListener(g govim.Govim, num int) error {
+	s.mu.Lock()
+	listener := s.listeners[num]
+	delete(s.listeners, num)
+	s.mu.Unlock()
+	if _, err := g.ChannelCall("listener_remove", listener); err != nil {
+		return fmt.Errorf("failed to remove listener from buffer %v: %v", num, err)
+	}
+	return nil
+}
+
+// Edit makes the changes to the buffer num in Vim that edit adds to a batch.
+// In the same batch, the listener of s is removed from the buffer for the
+// changes and then added again, and the contents of the buffer are read
+// afresh. The edit results in at most one new version of the buffer, with a
+// change that replaces all of its lines, rather than one for each change
+// edit makes. Edit is for a plugin that makes many changes to a buffer at
+// once, for example when formatting it.
+func (s *Sync) Edit(g govim.Govim, num int, edit func(b *govim.Batch)) error {
+	prev := s.Buffer(num)
+	if prev == nil {
+		return fmt.Errorf("buffer %v is not tracked", num)
+	}
+	s.mu.Lock()
+	listener := s.listeners[num]
+	s.mu.Unlock()
+	b, err := g.Batch()
+	if err != nil {
+		return err
+	}
+	// edit might panic, as a plugin.Driver does on an error
+	defer b.Cancel()
+	b.ChannelCall("listener_remove", listener)
+	edit(b)
+	added := b.ChannelCall("s:listenerAdd", s.changedFunc(), num)
+	read := b.ChannelExprf(exprBufContents, num)
+	_, err = b.End()
+	if _, ok := err.(*govim.BatchCallError); err != nil && !ok {
+		return err
+	}
+	// If a change failed, the calls that follow it were not made, and the
+	// listener is added and the contents read without the batch
+	if aerr := added.Decode(&listener); aerr == nil {
+		s.mu.Lock()
+		s.listeners[num] = listener
+		s.mu.Unlock()
+	} else if aerr := s.addListener(g, num); aerr != nil {
+		return aerr
+	}
+	var contents string
+	if rerr := read.Decode(&contents); rerr != nil {
+		res, rerr := g.ChannelExpr(fmt.Sprintf(exprBufContents, num))
+		if rerr == nil {
+			rerr = json.Unmarshal(res, &contents)
+		}
+		if rerr != nil {
+			return fmt.Errorf("failed to get the contents of buffer %v: %v", num, rerr)
+		}
+	}
+	if contents != string(prev.contents) {
+		if perr := s.replace(g, prev, prev.Name, []byte(contents)); perr != nil {
+			return perr
+		}
+	}
+	return err
+}
+
+// listenerChange is a change as passed to a listener, with the changed lines
+// added by s:enrichDelta
+type listenerChange struct {
+	Lnum  int      `json:"lnum"`
+	End   int      `json:"end"`
+	Added int      `json:"added"`
+	Lines []string `json:"lines"`
+}
+
+func (s *Sync) bufChanged(g govim.Govim, args ...json.RawMessage) (interface{}, error) {
+	var num int
+	var lchanges []listenerChange
+	if err := json.Unmarshal(args[0], &num); err != nil {
+		return nil, fmt.Errorf("failed to decode buffer number: %v", err)
+	}
+	if err := json.Unmarshal(args[4], &lchanges); err != nil {
+		return nil, fmt.Errorf("failed to decode changes: %v", err)
+	}
+	prev := s.Buffer(num)
+	if prev == nil {
+		return nil, fmt.Errorf("failed to resolve buffer %v in %v callback", num, s.changedFunc())
+	}
+	if len(lchanges) == 0 {
+		return nil, nil
+	}
+	var changes []Change
+	for _, c := range lchanges {
+		changes = append(changes, Change{Start: c.Lnum, End: c.End, Lines: c.Lines})
+	}
+	b := s.newBuffer(num, prev.Name, prev.Filetype, prev.Version+1, apply(prev.contents, changes))
+	return nil, s.publish(g, Event{Kind: EventChanged, Buffer: b, Previous: prev, Changes: changes})
+}
+
+func (s *Sync) bufDelete(g govim.Govim, args ...json.RawMessage) error {
+	var num int
+	if err := json.Unmarshal(args[0], &num); err != nil {
+		return fmt.Errorf("failed to decode buffer number: %v", err)
+	}
+	return s.untrack(g, num)
+}
+
+// untrack stops tracking the buffer num, publishing an EventWipeout
+func (s *Sync) untrack(g govim.Govim, num int) error {
+	b := s.Buffer(num)
+	if b == nil {
+		// Not tracked, or already deleted: wiping out a listed buffer
+		// deletes it first
+		return nil
+	}
+	if err := s.removeListener(g, num); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.readTicks, num)
+	s.mu.Unlock()
+	return s.publish(g, Event{Kind: EventWipeout, Buffer: b})
+}
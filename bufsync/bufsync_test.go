@@ -0,0 +1,323 @@
+package bufsync_test
+
+import (
+	"fmt"
+	"go/parser"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/govim/govim"
+	"github.com/govim/govim/bufsync"
+	"github.com/govim/govim/govimtest"
+	"golang.org/x/mod/modfile"
+)
+
+// plugin is a plugin that syncs all buffers, recording the events of its
+// Sync
+type plugin struct {
+	s        *bufsync.Sync
+	patterns govim.Patterns
+	parsers  []bufsync.Parser
+
+	mu     sync.Mutex
+	events []string
+}
+
+func (p *plugin) Init(g govim.Govim, errCh chan error) (err error) {
+	parsers := p.parsers
+	if parsers == nil {
+		parsers = []bufsync.Parser{
+			bufsync.GoParser{Mode: parser.AllErrors},
+			bufsync.ModParser{},
+			bufsync.TextParser{},
+		}
+	}
+	p.s, err = bufsync.New(g, "Sync", bufsync.Config{Patterns: p.patterns, Parsers: parsers})
+	if err != nil {
+		return err
+	}
+	p.s.OnChange(func(g govim.Govim, e bufsync.Event) error {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		ev := fmt.Sprintf("%v %v v%v", e.Kind, filepath.Base(e.Buffer.Name), e.Buffer.Version)
+		for _, c := range e.Changes {
+			ev += fmt.Sprintf(" %v-%v%q", c.Start, c.End, c.Lines)
+		}
+		p.events = append(p.events, ev)
+		return nil
+	})
+	return nil
+}
+
+func (p *plugin) Shutdown() error {
+	return nil
+}
+
+func (p *plugin) checkEvents(t *testing.T, want ...string) {
+	t.Helper()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !reflect.DeepEqual(p.events, want) {
+		t.Errorf("got events %q; want %q", p.events, want)
+	}
+	p.events = nil
+}
+
+func newVim(t *testing.T) (*govimtest.Vim, *plugin) {
+	t.Helper()
+	return newVimWithPlugin(t, new(plugin))
+}
+
+func newVimWithPlugin(t *testing.T, p *plugin) (*govimtest.Vim, *plugin) {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":    "package main\n\nfunc main() {}\n",
+		"go.mod":     "module example.com/m\n\ngo 1.18\n",
+		"README.txt": "hello\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return govimtest.NewT(t, p, govimtest.Config{Dir: dir}), p
+}
+
+func ex(t *testing.T, v *govimtest.Vim, cmds ...string) {
+	t.Helper()
+	for _, cmd := range cmds {
+		if err := v.Ex(cmd); err != nil {
+			t.Fatalf("failed to run %v: %v", cmd, err)
+		}
+	}
+}
+
+func TestChanges(t *testing.T) {
+	v, p := newVim(t)
+	ex(t, v, "edit main.go")
+	b := p.s.Buffer(2)
+	if b == nil {
+		t.Fatal("main.go is not tracked")
+	}
+	g := v.Govim()
+	if _, err := g.ChannelCall("append", 2, []string{"var x int", ""}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.ChannelCall("setline", 1, "package other"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Call("listener_flush"); err != nil {
+		t.Fatal(err)
+	}
+	p.checkEvents(t,
+		`0 main.go v1`,
+		`1 main.go v2 3-3["var x int" ""] 1-2["package other"]`,
+	)
+	latest := p.s.Buffer(2)
+	if want := "package other\n\nvar x int\n\nfunc main() {}\n"; string(latest.Contents()) != want {
+		t.Errorf("got contents %q; want %q", latest.Contents(), want)
+	}
+	if got, want := latest.Lines(3, 4), []string{"var x int"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got line 3 %q; want %q", got, want)
+	}
+	parsed, err := latest.Parsed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.(*bufsync.GoFile).File.Name.Name; got != "other" {
+		t.Errorf("got package %v; want other", got)
+	}
+
+	// The earlier version is unchanged
+	parsed, err = b.Parsed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.(*bufsync.GoFile).File.Name.Name; got != "main" {
+		t.Errorf("got package %v in version 1; want main", got)
+	}
+
+	ex(t, v, "edit!")
+	p.checkEvents(t, `1 main.go v3 1-6["package main" "" "func main() {}"]`)
+	ex(t, v, "bwipeout")
+	p.checkEvents(t, `2 main.go v3`)
+	if b := p.s.Buffer(2); b != nil {
+		t.Errorf("main.go is still tracked after being wiped out")
+	}
+}
+
+func TestEditAndReload(t *testing.T) {
+	v, p := newVim(t)
+	ex(t, v, "edit main.go", "edit!")
+	p.checkEvents(t, `0 main.go v1`, `3 main.go v1`)
+	g := v.Govim()
+	err := p.s.Edit(g, 2, func(b *govim.Batch) {
+		b.AssertChannelCall(govim.AssertIsZero(), "setbufline", 2, 1, "package other")
+		b.AssertChannelCall(govim.AssertIsZero(), "appendbufline", 2, 1, []string{"// edited"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Call("listener_flush"); err != nil {
+		t.Fatal(err)
+	}
+	p.checkEvents(t, `1 main.go v2 1-4["package other" "// edited" "" "func main() {}"]`)
+
+	// The listener is back in place
+	if _, err := g.ChannelCall("setline", 2, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Call("listener_flush"); err != nil {
+		t.Fatal(err)
+	}
+	p.checkEvents(t, `1 main.go v3 2-3[""]`)
+
+	// A change that fails ends the edit, but the changes before it are
+	// synced and the listener is put back in place
+	err = p.s.Edit(g, 2, func(b *govim.Batch) {
+		b.AssertChannelCall(govim.AssertIsZero(), "setbufline", 2, 1, "package main")
+		b.AssertChannelCall(govim.AssertIsZero(), "setbufline", 2, 10, "// no such line")
+		b.AssertChannelCall(govim.AssertIsZero(), "setbufline", 2, 2, "// not made")
+	})
+	if _, ok := err.(*govim.BatchCallError); !ok {
+		t.Fatalf("got error %v; want a *govim.BatchCallError", err)
+	}
+	p.checkEvents(t, `1 main.go v4 1-5["package main" "" "" "func main() {}"]`)
+	if _, err := g.ChannelCall("setline", 2, "// listening"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Call("listener_flush"); err != nil {
+		t.Fatal(err)
+	}
+	p.checkEvents(t, `1 main.go v5 2-3["// listening"]`)
+}
+
+func TestAddPatterns(t *testing.T) {
+	v, p := newVimWithPlugin(t, &plugin{patterns: govim.Patterns{"*.go"}})
+	ex(t, v, "edit go.mod")
+	if err := p.s.AddPatterns(v.Govim(), govim.Patterns{"*.txt", "*.go"}); err != nil {
+		t.Fatal(err)
+	}
+	ex(t, v, "edit README.txt", "edit main.go")
+	p.checkEvents(t, `0 README.txt v1`, `0 main.go v1`)
+}
+
+func TestTrack(t *testing.T) {
+	v, p := newVimWithPlugin(t, &plugin{patterns: govim.Patterns{"*.go"}})
+	ex(t, v, "edit README.txt")
+	g := v.Govim()
+	for i := 0; i < 2; i++ {
+		if err := p.s.Track(g, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.checkEvents(t, `0 README.txt v1`)
+	if b := p.s.Buffer(2); b == nil || b.Filetype != "text" {
+		t.Fatalf("got buffer %v; want README.txt with filetype text", b)
+	}
+
+	// The deleting of a tracked buffer is handled whatever its name
+	ex(t, v, "bdelete")
+	p.checkEvents(t, `2 README.txt v1`)
+
+	ex(t, v, "edit README.txt")
+	if err := p.s.Track(g, 2); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := p.s.Untrack(g, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := g.ChannelCall("setline", 1, "goodbye"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Call("listener_flush"); err != nil {
+		t.Fatal(err)
+	}
+	ex(t, v, "bwipeout!")
+	p.checkEvents(t, `0 README.txt v1`, `2 README.txt v1`)
+}
+
+func TestParsers(t *testing.T) {
+	v, p := newVim(t)
+	ex(t, v, "edit go.mod", "edit README.txt")
+	bufs := p.s.Buffers()
+	if len(bufs) != 2 {
+		t.Fatalf("got %v buffers; want 2", len(bufs))
+	}
+	parsed, err := bufs[0].Parsed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.(*modfile.File).Module.Mod.Path; got != "example.com/m" {
+		t.Errorf("got module %v; want example.com/m", got)
+	}
+	parsed, err = bufs[1].Parsed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := parsed, []string{"hello"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q; want %q", got, want)
+	}
+}
+
+// blockingParser records the contents it parses, each parse waiting until
+// release is closed
+type blockingParser struct {
+	release chan struct{}
+
+	mu     sync.Mutex
+	parsed []string
+}
+
+func (p *blockingParser) Handles(name string) bool {
+	return true
+}
+
+func (p *blockingParser) Parse(name string, contents []byte) (interface{}, error) {
+	<-p.release
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.parsed = append(p.parsed, string(contents))
+	return nil, nil
+}
+
+func TestSupersededParse(t *testing.T) {
+	bp := &blockingParser{release: make(chan struct{})}
+	v, p := newVimWithPlugin(t, &plugin{parsers: []bufsync.Parser{bp}})
+	ex(t, v, "edit main.go")
+	g := v.Govim()
+	var versions []*bufsync.Buffer
+	for _, pkg := range []string{"package a", "package b"} {
+		if _, err := g.ChannelCall("setline", 1, pkg); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := v.Call("listener_flush"); err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, p.s.Buffer(2))
+	}
+	// Version 1 is being parsed; version 2 waits for it, until version 3
+	// supersedes it
+	close(bp.release)
+	if _, err := versions[1].Parsed(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := versions[0].Parsed(); err != bufsync.ErrSuperseded {
+		t.Errorf("got error %v parsing version %v; want %v", err, versions[0].Version, bufsync.ErrSuperseded)
+	}
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	want := []string{
+		"package main\n\nfunc main() {}\n",
+		"package b\n\nfunc main() {}\n",
+	}
+	if !reflect.DeepEqual(bp.parsed, want) {
+		t.Errorf("parsed %q; want %q", bp.parsed, want)
+	}
+}
@@ -0,0 +1,75 @@
+package bufsync
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// Parser parses the contents of buffers. Each version of a buffer is parsed
+// in the background by the first of the parsers of a Sync that handles the
+// buffer; see Buffer.Parsed.
+type Parser interface {
+	// Handles reports whether the parser parses the buffer with name
+	Handles(name string) bool
+
+	// Parse parses the contents of the buffer with name. Parse is called
+	// concurrently for different buffers, but for at most one version of a
+	// buffer at a time.
+	Parse(name string, contents []byte) (interface{}, error)
+}
+
+// GoFile is the result of a GoParser
+type GoFile struct {
+	Fset *token.FileSet
+	File *ast.File
+}
+
+// GoParser parses .go files with go/parser, with Mode
+type GoParser struct {
+	Mode parser.Mode
+}
+
+func (p GoParser) Handles(name string) bool {
+	return strings.HasSuffix(name, ".go")
+}
+
+// Parse returns a *GoFile
+func (p GoParser) Parse(name string, contents []byte) (interface{}, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, contents, p.Mode)
+	return &GoFile{Fset: fset, File: f}, err
+}
+
+// ModParser parses go.mod files with golang.org/x/mod/modfile
+type ModParser struct{}
+
+func (ModParser) Handles(name string) bool {
+	return filepath.Base(name) == "go.mod"
+}
+
+// Parse returns a *modfile.File, or nil if the file cannot be parsed
+func (ModParser) Parse(name string, contents []byte) (interface{}, error) {
+	f, err := modfile.Parse(name, contents, nil)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// TextParser handles all buffers, splitting their contents into lines. It is
+// therefore the last of the parsers of a Sync.
+type TextParser struct{}
+
+func (TextParser) Handles(name string) bool {
+	return true
+}
+
+// Parse returns the lines of the contents as a []string
+func (TextParser) Parse(name string, contents []byte) (interface{}, error) {
+	return strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n"), nil
+}
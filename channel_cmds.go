@@ -113,6 +113,11 @@ func (g *govimImpl) channelCallImpl(ch callback, fn string, args ...interface{})
 	})
 }
 
+// Batch implements Govim.Batch
+func (g *govimImpl) Batch() (*Batch, error) {
+	return NewBatch(g), nil
+}
+
 func (g *govimImpl) DoProto(f func() error) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -3,103 +3,59 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-)
-
-type batch struct {
-	calls   []interface{}
-	results []json.RawMessage
-}
 
-func (b *batch) result() batchResult {
-	i := len(b.calls) - 1
-	return func() json.RawMessage {
-		if b.results == nil {
-			panic(fmt.Errorf("tried to get result from incomplete Batch"))
-		}
-		return b.results[i]
-	}
-}
+	"github.com/govim/govim"
+	"github.com/govim/govim/internal/plugin"
+)
 
 func (v *vimstate) BatchStart() {
 	if v.currBatch != nil {
 		panic(fmt.Errorf("called BatchStart whilst in a batch"))
 	}
-	v.currBatch = &batch{}
+	b, err := v.Driver.Govim.Batch()
+	if err != nil {
+		panic(fmt.Errorf("failed to start batch: %v", err))
+	}
+	v.currBatch = b
 }
 
 func (v *vimstate) BatchStartIfNeeded() bool {
 	if v.currBatch != nil {
 		return false
 	}
-	v.currBatch = &batch{}
+	v.BatchStart()
 	return true
 }
 
 type batchResult func() json.RawMessage
 
-type AssertExpr struct {
-	Fn   string
-	Args []interface{}
-}
-
-func AssertNoError() AssertExpr {
-	return AssertExpr{
-		Fn: "s:mustNoError",
-	}
-}
-
-func AssertIsZero() AssertExpr {
-	return AssertExpr{
-		Fn: "s:mustBeZero",
-	}
-}
-
-func AssertIsErrorOrNil(patterns ...string) AssertExpr {
-	args := make([]interface{}, 0, len(patterns))
-	for _, v := range patterns {
-		args = append(args, v)
-	}
-	return AssertExpr{
-		Fn:   "s:mustBeErrorOrNil",
-		Args: args,
-	}
-}
-
 func (v *vimstate) BatchChannelExprf(format string, args ...interface{}) batchResult {
-	return v.BatchAssertChannelExprf(AssertNoError(), format, args...)
+	return v.BatchAssertChannelExprf(govim.AssertNoError(), format, args...)
 }
 
-func (v *vimstate) BatchAssertChannelExprf(a AssertExpr, format string, args ...interface{}) batchResult {
+func (v *vimstate) BatchAssertChannelExprf(a govim.AssertExpr, format string, args ...interface{}) batchResult {
 	if v.currBatch == nil {
 		panic(fmt.Errorf("cannot call BatchChannelExprf: not in batch"))
 	}
-	v.currBatch.calls = append(v.currBatch.calls, []interface{}{
-		"expr",
-		[2]interface{}{a.Fn, a.Args},
-		fmt.Sprintf(format, args...),
-	})
-	return v.currBatch.result()
+	return v.currBatch.AssertChannelExprf(a, format, args...).Raw
 }
+
 func (v *vimstate) BatchChannelCall(name string, args ...interface{}) batchResult {
-	return v.BatchAssertChannelCall(AssertNoError(), name, args...)
+	return v.BatchAssertChannelCall(govim.AssertNoError(), name, args...)
 }
 
-func (v *vimstate) BatchAssertChannelCall(a AssertExpr, name string, args ...interface{}) batchResult {
+func (v *vimstate) BatchAssertChannelCall(a govim.AssertExpr, name string, args ...interface{}) batchResult {
 	if v.currBatch == nil {
 		panic(fmt.Errorf("cannot call BatchChannelCall: not in batch"))
 	}
-	callargs := []interface{}{
-		"call",
-		[2]interface{}{a.Fn, a.Args},
-		name,
-	}
-	callargs = append(callargs, args...)
-	v.currBatch.calls = append(v.currBatch.calls, callargs)
-	return v.currBatch.result()
+	return v.currBatch.AssertChannelCall(a, name, args...).Raw
 }
 
 func (v *vimstate) BatchCancelIfNotEnded() {
-	v.currBatch = nil
+	if v.currBatch != nil {
+		v.currBatch.Cancel()
+		v.currBatch = nil
+	}
 }
 
 func (v *vimstate) BatchEnd() ([]json.RawMessage, error) {
@@ -117,20 +73,13 @@ func (v *vimstate) batchEndImpl(must bool) (res []json.RawMessage, err error) {
 	}
 	b := v.currBatch
 	v.currBatch = nil
-	if len(b.calls) == 0 {
-		return
-	}
-	var vs json.RawMessage
-	if must {
-		vs = v.ChannelCall("s:batchCall", b.calls)
-	} else {
-		vs, err = v.Driver.Govim.ChannelCall("s:batchCall", b.calls)
-		if err != nil {
-			return
+	res, err = b.End()
+	if err != nil && must {
+		if err == govim.ErrShuttingDown {
+			panic(err)
 		}
+		panic(plugin.ErrDriver{Underlying: fmt.Errorf("batch failed: %w", err)})
 	}
-	v.Parse(vs, &res)
-	b.results = res
 	return
 }
 
@@ -1,38 +1,71 @@
 package main
 
 import (
-	"bytes"
-	"context"
 	"encoding/json"
 	"fmt"
-	"go/parser"
-	"go/token"
 	"path/filepath"
 	"strings"
-	"sync"
 
-	"github.com/govim/govim/cmd/govim/config"
+	"github.com/govim/govim"
+	"github.com/govim/govim/bufsync"
 	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/file"
 	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
 	"github.com/govim/govim/cmd/govim/internal/types"
+	"github.com/govim/govim/internal/logging"
 )
 
 func filename(d protocol.DocumentURI) string {
 	return filepath.Base(d.Path())
 }
 
-func (v *vimstate) bufReadPost(args ...json.RawMessage) error {
-	nb := v.currentBufferInfo(args[0])
-	if v.vimgrepPendingBufs != nil {
-		// We are getting BufRead autocommands during a vimgrep.
-
-		// Save the buffer without adding it yet since vimgrep could open
-		// a lot of files that are closed immediately, and we only want to
-		// add buffers still open when vimgrep is done.
-		v.vimgrepPendingBufs[nb.Num] = nb
+// bufferEvent handles an event in the buffers synced by v.buffersSync. It is
+// called from the handler of the event, i.e. on the vimstate thread.
+func (v *vimstate) bufferEvent(_ govim.Govim, e bufsync.Event) error {
+	switch e.Kind {
+	case bufsync.EventRead:
+		nb := types.NewBuffer(e.Buffer.Num, e.Buffer.Name, nil, true)
+		nb.Filetype = e.Buffer.Filetype
+		nb.Sync(e.Buffer)
+		if v.vimgrepPendingBufs != nil {
+			// We are getting BufRead autocommands during a vimgrep.
+
+			// Save the buffer without adding it yet since vimgrep could open
+			// a lot of files that are closed immediately, and we only want to
+			// add buffers still open when vimgrep is done.
+			v.vimgrepPendingBufs[nb.Num] = nb
+			return nil
+		}
+		return v.addBuffer(nb)
+	case bufsync.EventReloaded:
+		cb, ok := v.buffers[e.Buffer.Num]
+		if !ok {
+			return nil
+		}
+		// We probably just re-loaded a currently unloaded buffer. We have to
+		// re-place signs and redefine highlights since text properties are
+		// removed when a buffer is unloaded.
+		cb.Loaded = true
+		if err := v.updateSigns(true); err != nil {
+			v.logger.Warnf(logging.Buffers, "failed to update signs for buffer %d: %v", cb.Num, err)
+		}
+		if err := v.redefineHighlights(true); err != nil {
+			v.logger.Warnf(logging.Buffers, "failed to update highlights for buffer %d: %v", cb.Num, err)
+		}
+		if err := v.updateVirtualText(true); err != nil {
+			v.logger.Warnf(logging.Buffers, "failed to update virtual text for buffer %d: %v", cb.Num, err)
+		}
 		return nil
+	case bufsync.EventChanged:
+		return v.bufChanged(e)
+	case bufsync.EventWipeout:
+		if v.vimgrepPendingBufs != nil {
+			delete(v.vimgrepPendingBufs, e.Buffer.Num)
+		}
+		if cb, ok := v.buffers[e.Buffer.Num]; ok {
+			return v.deleteBuffer(cb)
+		}
 	}
-	return v.addBuffer(nb)
+	return nil
 }
 
 func (v *vimstate) addBuffer(nb *types.Buffer) error {
@@ -45,41 +78,28 @@ func (v *vimstate) addBuffer(nb *types.Buffer) error {
 		}
 	}
 
-	if cb, ok := v.buffers[nb.Num]; ok {
-		// reload of buffer, e.v. e!
-		cb.Loaded = nb.Loaded
-
-		// If the contents are the same we probably just re-loaded a currently
-		// unloaded buffer.  We shouldn't increase version in that case, but we
-		// have to re-place signs and redefine highlights since text properties
-		// are removed when a buffer is unloaded.
-		if bytes.Equal(nb.Contents(), cb.Contents()) {
-			if err := v.updateSigns(true); err != nil {
-				v.Logf("failed to update signs for buffer %d: %v", nb.Num, err)
-			}
-			if err := v.redefineHighlights(true); err != nil {
-				v.Logf("failed to update highlights for buffer %d: %v", nb.Num, err)
-			}
-			return nil
-		}
-		cb.SetContents(nb.Contents())
-		cb.Version++
-		return v.handleBufferEvent(cb)
-	}
-
 	v.buffers[nb.Num] = nb
-	nb.Version = 1
-	nb.Listener = v.ParseInt(v.ChannelCall("listener_add", v.Prefix()+string(config.FunctionEnrichDelta), nb.Num))
 
 	if err := v.updateSigns(true); err != nil {
-		v.Logf("failed to update signs for buffer %d: %v", nb.Num, err)
+		v.logger.Warnf(logging.Buffers, "failed to update signs for buffer %d: %v", nb.Num, err)
 	}
 
 	if err := v.redefineHighlights(true); err != nil {
-		v.Logf("failed to update highlights for buffer %d: %v", nb.Num, err)
+		v.logger.Warnf(logging.Buffers, "failed to update highlights for buffer %d: %v", nb.Num, err)
+	}
+
+	if err := v.updateVirtualText(true); err != nil {
+		v.logger.Warnf(logging.Buffers, "failed to update virtual text for buffer %d: %v", nb.Num, err)
 	}
 
-	return v.handleBufferEvent(nb)
+	v.updateStatusline()
+
+	if goplsHandles(nb.Name) {
+		if err := v.ensureWorkspaceFolder(nb); err != nil {
+			return err
+		}
+	}
+	return v.didOpen(nb)
 }
 
 func (v *vimstate) bufQuickFixCmdPre(args ...json.RawMessage) error {
@@ -103,78 +123,48 @@ func (v *vimstate) bufQuickFixCmdPost(args ...json.RawMessage) error {
 	return nil
 }
 
-type bufChangedChange struct {
-	Lnum  uint32   `json:"lnum"`
-	Col   uint32   `json:"col"`
-	Added int      `json:"added"`
-	End   uint32   `json:"end"`
-	Type  string   `json:"type"`
-	Lines []string `json:"lines"`
-}
-
-// bufChanged is fired as a result of the listener_add callback for a buffer; it is mutually
-// exclusive with bufTextChanged. args are:
-//
-// bufChanged(bufnr, start, end, added, changes)
-func (v *vimstate) bufChanged(args ...json.RawMessage) (interface{}, error) {
+// bufChanged handles a change in the contents of a buffer, be that from
+// the listener on the buffer, from the buffer being read again, or from
+// govim editing the buffer.
+func (v *vimstate) bufChanged(e bufsync.Event) error {
+	b, ok := v.buffers[e.Buffer.Num]
+	if !ok {
+		if pb, ok := v.vimgrepPendingBufs[e.Buffer.Num]; ok {
+			pb.Sync(e.Buffer)
+		}
+		return nil
+	}
 	// For now, if we are "manually" highlighting, any change (in a .go file)
 	// causes an existing highlights to be removed.
 	if v.highlightingReferences {
 		v.highlightingReferences = false
 		v.removeReferenceHighlight(nil)
 	}
-
-	bufnr := v.ParseInt(args[0])
-	b, ok := v.buffers[bufnr]
-	if !ok {
-		return nil, fmt.Errorf("failed to resolve buffer %v in bufChanged callback", bufnr)
-	}
-	var changes []bufChangedChange
-	v.Parse(args[4], &changes)
-	if len(changes) == 0 {
-		v.Logf("bufChanged: no changes to apply for %v", b.Name)
-		return nil, nil
-	}
-	contents := bytes.Split(b.Contents()[:len(b.Contents())-1], []byte("\n"))
-	b.Version++
-	params := &protocol.DidChangeTextDocumentParams{
-		TextDocument: protocol.VersionedTextDocumentIdentifier{
-			TextDocumentIdentifier: b.ToTextDocumentIdentifier(),
-			Version:                b.Version,
-		},
-	}
-	for _, c := range changes {
-		var newcontents [][]byte
+	b.Loaded = true
+	b.Sync(e.Buffer)
+	var changes []protocol.TextDocumentContentChangeEvent
+	for _, c := range e.Changes {
 		change := protocol.TextDocumentContentChangeEvent{
 			Range: &protocol.Range{
 				Start: protocol.Position{
-					Line:      c.Lnum - 1,
+					Line:      uint32(c.Start - 1),
+					Character: 0,
+				},
+				End: protocol.Position{
+					Line:      uint32(c.End - 1),
 					Character: 0,
 				},
 			},
 		}
-		newcontents = append(newcontents, contents[:c.Lnum-1]...)
-		for _, l := range c.Lines {
-			newcontents = append(newcontents, []byte(l))
-		}
 		if len(c.Lines) > 0 {
 			change.Text = strings.Join(c.Lines, "\n") + "\n"
 		}
-		newcontents = append(newcontents, contents[c.End-1:]...)
-		change.Range.End = protocol.Position{
-			Line:      c.End - 1,
-			Character: 0,
-		}
-		contents = newcontents
-		params.ContentChanges = append(params.ContentChanges, change)
+		changes = append(changes, change)
 	}
-	// add back trailing newline
-	b.SetContents(append(bytes.Join(contents, []byte("\n")), '\n'))
-	v.triggerBufferASTUpdate(b)
-	if err := v.server.DidChange(context.Background(), params); err != nil {
-		return nil, fmt.Errorf("failed to notify gopls of change: %v", err)
+	if err := v.didChange(b, changes); err != nil {
+		return fmt.Errorf("failed to notify gopls of change: %v", err)
 	}
-	return nil, nil
+	return nil
 }
 
 func (v *vimstate) bufUnload(args ...json.RawMessage) error {
@@ -183,6 +173,9 @@ func (v *vimstate) bufUnload(args ...json.RawMessage) error {
 		return nil
 	}
 	v.buffers[bufnr].Loaded = false
+	// vim removes text properties, including virtual text, when a buffer is
+	// unloaded
+	delete(v.virtualTexts, bufnr)
 	return nil
 }
 
@@ -201,59 +194,6 @@ func detectLanguage(filename string) file.Kind {
 	}
 }
 
-func (v *vimstate) handleBufferEvent(b *types.Buffer) error {
-	v.triggerBufferASTUpdate(b)
-	if b.Version == 1 {
-		params := &protocol.DidOpenTextDocumentParams{
-			TextDocument: protocol.TextDocumentItem{
-				LanguageID: protocol.LanguageKind(detectLanguage(filename(b.URI())).String()),
-				URI:        protocol.DocumentURI(b.URI()),
-				Version:    b.Version,
-				Text:       string(b.Contents()),
-			},
-		}
-		err := v.server.DidOpen(context.Background(), params)
-		return err
-	}
-
-	params := &protocol.DidChangeTextDocumentParams{
-		TextDocument: protocol.VersionedTextDocumentIdentifier{
-			TextDocumentIdentifier: b.ToTextDocumentIdentifier(),
-			Version:                b.Version,
-		},
-		ContentChanges: []protocol.TextDocumentContentChangeEvent{
-			{
-				Text: string(b.Contents()),
-			},
-		},
-	}
-	err := v.server.DidChange(context.Background(), params)
-	return err
-}
-
-func (v *vimstate) bufDelete(args ...json.RawMessage) error {
-	currBufNr := v.ParseInt(args[0])
-	cb, ok := v.buffers[currBufNr]
-	if !ok {
-		return fmt.Errorf("tried to remove buffer %v; but we have no record of it", currBufNr)
-	}
-
-	return v.deleteBuffer(cb)
-}
-
-func (v *vimstate) bufWipeout(args ...json.RawMessage) error {
-	currBufNr := v.ParseInt(args[0])
-
-	if v.vimgrepPendingBufs != nil {
-		delete(v.vimgrepPendingBufs, currBufNr)
-	}
-
-	if cb, ok := v.buffers[currBufNr]; ok {
-		return v.deleteBuffer(cb)
-	}
-	return nil
-}
-
 func (v *vimstate) deleteBuffer(b *types.Buffer) error {
 	// The diagnosticsCache is updated with -1 (unknown buffer) as bufnr.
 	// We don't want to remove the entries completely here since we want to show them in
@@ -266,12 +206,9 @@ func (v *vimstate) deleteBuffer(b *types.Buffer) error {
 		}
 	}
 
-	v.ChannelCall("listener_remove", b.Listener)
 	delete(v.buffers, b.Num)
-	params := &protocol.DidCloseTextDocumentParams{
-		TextDocument: b.ToTextDocumentIdentifier(),
-	}
-	if err := v.server.DidClose(context.Background(), params); err != nil {
+	delete(v.virtualTexts, b.Num)
+	if err := v.didClose(b); err != nil {
 		return fmt.Errorf("failed to call gopls.DidClose on %v: %v", b.Name, err)
 	}
 	return nil
@@ -283,75 +220,8 @@ func (v *vimstate) bufWritePost(args ...json.RawMessage) error {
 	if !ok {
 		return fmt.Errorf("tried to handle BufWritePost for buffer %v; but we have no record of it", currBufNr)
 	}
-	params := &protocol.DidSaveTextDocumentParams{
-		TextDocument: protocol.TextDocumentIdentifier{
-			URI: protocol.DocumentURI(cb.URI()),
-		},
-	}
-	if err := v.server.DidSave(context.Background(), params); err != nil {
+	if err := v.didSave(cb); err != nil {
 		return fmt.Errorf("failed to call gopls.DidSave on %v: %v", cb.Name, err)
 	}
 	return nil
 }
-
-type bufferUpdate struct {
-	buffer   *types.Buffer
-	wait     chan bool
-	name     string
-	version  int32
-	contents []byte
-}
-
-func (g *govimplugin) startProcessBufferUpdates() {
-	g.bufferUpdates = make(chan *bufferUpdate)
-	g.tomb.Go(func() error {
-		latest := make(map[*types.Buffer]int32)
-		var lock sync.Mutex
-		for upd := range g.bufferUpdates {
-			upd := upd
-			lock.Lock()
-			latest[upd.buffer] = upd.version
-			lock.Unlock()
-
-			// Note we are not restricting the number of concurrent parses here.
-			// This is simply because we are unlikely to ever get a sufficiently
-			// high number of concurrent updates from Vim to make this necessary.
-			// Like the Vim <-> govim <-> gopls "channel" would get
-			// flooded/overloaded first
-			g.tomb.Go(func() error {
-				fset := token.NewFileSet()
-				f, err := parser.ParseFile(fset, upd.name, upd.contents, parser.AllErrors)
-				if err != nil {
-					// This is best efforts so we just log the error as an info
-					// message
-					g.Logf("info only: failed to parse buffer %v: %v", upd.name, err)
-				}
-				lock.Lock()
-				if latest[upd.buffer] == upd.version {
-					upd.buffer.Fset = fset
-					upd.buffer.AST = f
-					delete(latest, upd.buffer)
-				}
-				lock.Unlock()
-				close(upd.wait)
-				return nil
-			})
-		}
-		return nil
-	})
-}
-
-func (v *vimstate) triggerBufferASTUpdate(b *types.Buffer) {
-	// We are only interested in .go files
-	if !strings.HasSuffix(b.Name, ".go") {
-		return
-	}
-	b.ASTWait = make(chan bool)
-	v.bufferUpdates <- &bufferUpdate{
-		buffer:   b,
-		wait:     b.ASTWait,
-		name:     b.Name,
-		version:  b.Version,
-		contents: b.Contents(),
-	}
-}
@@ -2,10 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"os"
+	"strings"
+	"time"
 )
 
 // commandName defines the top-level command names in child-parent mode
@@ -15,12 +18,17 @@ const (
 	cmdNameGopls commandName = "gopls"
 )
 
-// goplsMethodName defines the method names for the gopls command
+// goplsMethodName defines the method names for the gopls command. Each
+// method defines its name alongside its implementation.
 type goplsMethodName string
 
-const (
-	methodGoplsSymbol goplsMethodName = "Symbol"
-)
+// childDialTimeout is the timeout for a child connecting to its parent
+const childDialTimeout = 5 * time.Second
+
+// parentTCPPrefix is the prefix of the address of a parent that listens on
+// TCP; any other address is that of a Unix Domain Socket. The prefix is chosen
+// such that the address does not need to be quoted in a shell.
+const parentTCPPrefix = "tcp://"
 
 // knownChildErr is a type used by a "child" instance of govim to bail out
 // of processing in such a way that the panic-ed error is then returned
@@ -43,15 +51,23 @@ func runAsChild() (err error) {
 		panic(knownChildErr(fmt.Errorf(format, args...)))
 	}
 
-	// TODO: support TCP?
-	conn, err := net.Dial("unix", *fParent)
+	network, addr := "unix", *fParent
+	if strings.HasPrefix(addr, parentTCPPrefix) {
+		network, addr = "tcp", strings.TrimPrefix(addr, parentTCPPrefix)
+	}
+	conn, err := net.DialTimeout(network, addr, childDialTimeout)
 	if err != nil {
-		errorf("failed to dial parent at %v: %v", *fParent, err)
+		errorf("failed to connect to parent at %v (is Vim still running?): %v", *fParent, err)
 	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(*fParentTimeout))
 
 	enc := json.NewEncoder(conn)
 	dec := json.NewDecoder(conn)
 
+	if err := enc.Encode(*fParentToken); err != nil {
+		return fmt.Errorf("failed to encode token: %v", err)
+	}
 	if err := enc.Encode(flagSet.Args()); err != nil {
 		return fmt.Errorf("failed to encode args: %v", err)
 	}
@@ -68,6 +84,9 @@ func runAsChild() (err error) {
 			// because if we had we would have returned
 			errorf("connection closed before exit code received")
 		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			errorf("timed out after %v waiting for parent", *fParentTimeout)
+		}
 		errorf("failed to decode %v: %v", thing, err)
 	}
 
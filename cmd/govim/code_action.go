@@ -9,22 +9,25 @@ import (
 	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
 	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/settings"
 	"github.com/govim/govim/cmd/govim/internal/types"
+	"github.com/govim/govim/vimfn"
 )
 
 func (v *vimstate) runGoTest(flags govim.CommandFlags, args ...string) error {
 	if c := v.config.ExperimentalProgressPopups; c == nil || !*c {
-		opts := make(map[string]interface{})
-		opts["mousemoved"] = "any"
-		opts["moved"] = "any"
-		opts["padding"] = []int{0, 1, 0, 1}
-		opts["wrap"] = true
-		opts["border"] = []int{}
-		opts["highlight"] = "ErrorMsg"
-		opts["line"] = 1
-		opts["close"] = "click"
-		v.ChannelCall("popup_create", []string{"GOVIMGoTest requires progress popups. Add this to your .vimrc:",
+		wrap := true
+		opts := vimfn.PopupOptions{
+			MouseMoved: "any",
+			Moved:      "any",
+			Padding:    []int{0, 1, 0, 1},
+			Wrap:       &wrap,
+			Border:     []int{},
+			Highlight:  "ErrorMsg",
+			Line:       1,
+			Close:      "click",
+		}
+		_, err := vimfn.New(v.Driver.Govim).PopupCreate([]string{"GOVIMGoTest requires progress popups. Add this to your .vimrc:",
 			" call govim#config#Set(\"ExperimentalProgressPopups\", 1)"}, opts)
-		return nil
+		return err
 	}
 	b, _, err := v.bufCursorPos()
 	if err != nil {
@@ -4,6 +4,11 @@ package config
 
 const (
 	InternalFunctionPrefix = "_internal_"
+
+	// BufSyncName is the name, less the plugin prefix, of the bufsync.Sync
+	// by which govim keeps the contents of buffers in sync. The Sync defines
+	// FunctionBufChanged.
+	BufSyncName = InternalFunctionPrefix + "Buf"
 )
 
 type EnvVar string
@@ -36,6 +41,15 @@ const (
 	// GOMAXPROCS > runtime.NumCPU()
 	EnvVarGoplsGOMAXPROCSMinusN EnvVar = "GOVIM_GOPLS_GOMAXPROCS_MINUS_N"
 
+	// EnvVarParentListen is an environment variable which, when set to a TCP
+	// address of the form host:port, configures govim to listen for child
+	// instances on that address instead of on a Unix Domain Socket. This
+	// allows tools that run in a different container or machine to talk to
+	// govim. A port of 0 selects a free port. Either way, the command returned
+	// by GOVIMParentCommand() includes the address and the per-session token
+	// that a child must present.
+	EnvVarParentListen EnvVar = "GOVIM_PARENT_LISTEN"
+
 	// EnvLogfileTmpl specifies the filename format of logfiles created by govim
 	// for govim, gopls and Vim. The default value is "%v_%v_%v". The first %v
 	// verb is expanded to either "govim", "gopls" or "vim_channel". The second
@@ -47,16 +61,54 @@ const (
 	// EnvLog controls whether log files are created by govim. Log files are only
 	// created when GOVIM_LOG=on. This is the default.
 	EnvLog = "GOVIM_LOG"
+
+	// EnvLogFormat is the format of the govim log: either "json" (the
+	// default), where each message is a JSON object on a line of its own, or
+	// "text".
+	EnvLogFormat = "GOVIM_LOG_FORMAT"
+
+	// EnvLogLevel is a comma-separated list of the minimum levels at which
+	// messages are written to the govim log. An entry that is just a level
+	// (debug, info, warn or error) sets the level for all subsystems; an entry
+	// of the form subsystem=level sets the level for one subsystem (channel,
+	// gopls-client, gopls-server, buffers, watcher or govim). The default is
+	// "debug". For example: "info,gopls-server=debug".
+	EnvLogLevel = "GOVIM_LOG_LEVEL"
+
+	// EnvLogMaxSize is the size in megabytes beyond which the govim log is
+	// rotated. The previous logs are kept alongside with the suffixes .1,
+	// .2 and .3. The default is 100; 0 disables rotation.
+	EnvLogMaxSize = "GOVIM_LOG_MAXSIZE"
+
+	// EnvRecord, when set to a file path, causes govim to record the messages
+	// it exchanges with Vim and with gopls to that file. A recording can be
+	// replayed against a fresh govim using the replay command of
+	// github.com/govim/govim/testdriver, for example to turn a bug report
+	// into a regression test.
+	EnvRecord = "GOVIM_RECORD"
+
+	// EnvNeovim is set to "true" by the Neovim glue in
+	// autoload/govim/nvim.vim when it starts govim, to indicate that govim
+	// must talk msgpack-RPC to Neovim rather than use a Vim channel. It is
+	// not intended to be set by users.
+	EnvNeovim = "GOVIM_NEOVIM"
 )
 
 //go:generate go run github.com/govim/govim/cmd/govim/config/internal/applygen Config
 
+// Config is the govim configuration, set from Vim via govim#config#Set.
+//
+// Every field carries a config struct tag that classifies it as either
+// "live" or "init". A change to a live field is applied as soon as it is
+// set. A change to an init field can only be applied when gopls is
+// initialized, because the field is sent as part of the Initialize call,
+// and hence causes gopls to be restarted.
 type Config struct {
 	// FormatOnSave is a string value that configures which tool to use for
 	// formatting on save. Options are given by constants of type FormatOnSave.
 	//
 	// Default: FormatOnSaveGoImportsGoFmt.
-	FormatOnSave *FormatOnSave `json:",omitempty"`
+	FormatOnSave *FormatOnSave `json:",omitempty" config:"live"`
 
 	// QuickfixAutoDiagnostics is a boolean (0 or 1 in VimScript) that controls
 	// whether auto-population of the quickfix window with gopls diagnostics is
@@ -66,7 +118,7 @@ type Config struct {
 	// manually trigger the population.
 	//
 	// Default: true
-	QuickfixAutoDiagnostics *bool `json:",omitempty"`
+	QuickfixAutoDiagnostics *bool `json:",omitempty" config:"live"`
 
 	// QuickfixSigns is a boolean (0 or 1 in VimScript) that controls whether
 	// diagnostic errors should be shown with signs in the gutter. When enabled,
@@ -74,7 +126,7 @@ type Config struct {
 	// using the current gopls diagnostics.
 	//
 	// Default: true
-	QuickfixSigns *bool `json:",omitempty"`
+	QuickfixSigns *bool `json:",omitempty" config:"live"`
 
 	// HighlightDiagnostics enables in-code highlighting of diagnostics using
 	// text properties. Each diagnostic reported by gopls will be highlighted
@@ -82,7 +134,7 @@ type Config struct {
 	// groups: GOVIMErr, GOVIMWarn, GOVIMInfo & GOVIMHint.
 	//
 	// Default: true
-	HighlightDiagnostics *bool `json:",omitempty"`
+	HighlightDiagnostics *bool `json:",omitempty" config:"live"`
 
 	// HighlightReferences is a boolean (0 or 1 in VimScript) that controls
 	// whether references to what is currently under the cursor should be
@@ -93,7 +145,7 @@ type Config struct {
 	// property style.
 	//
 	// Default: true
-	HighlightReferences *bool `json:",omitempty"`
+	HighlightReferences *bool `json:",omitempty" config:"live"`
 
 	// HoverDiagnostics is a boolean (0 or 1 in VimScript) that controls
 	// whether diagnostics should be shown in the hover popup. When enabled
@@ -106,61 +158,63 @@ type Config struct {
 	// source being applied last) to provide a wide range of styles.
 	//
 	// Default: true
-	HoverDiagnostics *bool `json:",omitempty"`
+	HoverDiagnostics *bool `json:",omitempty" config:"live"`
 
 	// CompletionDeepCompletiions enables gopls' deep completion option
 	// in the derivation of completion candidates.
 	//
 	// Default: true
-	CompletionDeepCompletions *bool `json:",omitempty"`
+	CompletionDeepCompletions *bool `json:",omitempty" config:"live"`
 
 	// CompletionMatcher is a string value that tells gopls which matcher
 	// to use when computing completion candidates.
 	//
 	// Default: CompletionMatcherFuzzy
-	CompletionMatcher *CompletionMatcher `json:",omitempty"`
+	CompletionMatcher *CompletionMatcher `json:",omitempty" config:"live"`
 
 	// SymbolMatcher is a string value that tells gopls which matcher
-	// to use when computing workspace symbol candidates.
+	// to use when computing workspace symbol candidates. Changing this value
+	// restarts gopls.
 	//
 	// Default: SymbolMatcherFuzzy
-	SymbolMatcher *SymbolMatcher `json:",omitempty"`
+	SymbolMatcher *SymbolMatcher `json:",omitempty" config:"init"`
 
 	// SymbolStyle is a string value that tells gopls which qualification style
-	// to use when computing workspace symbol candidates.
+	// to use when computing workspace symbol candidates. Changing this value
+	// restarts gopls.
 	//
 	// Default: SymbolStyleFull
-	SymbolStyle *SymbolStyle `json:",omitempty"`
+	SymbolStyle *SymbolStyle `json:",omitempty" config:"init"`
 
 	// Staticcheck enables staticcheck analyses in gopls
 	//
 	// Default: false
-	Staticcheck *bool `json:",omitempty"`
+	Staticcheck *bool `json:",omitempty" config:"live"`
 
 	// CompleteUnimported configures gopls to attempt completions for unimported
 	// standard library packages. e.g. when a user completes rand.<>, propose
 	// rand.Seed (from math/rand) and rand.Prime (from crypto/rand), etc.
 	//
 	// Default: true
-	CompleteUnimported *bool `json:",omitempty"`
+	CompleteUnimported *bool `json:",omitempty" config:"live"`
 
 	// GoImportsLocalPrefix is used to specify goimports's -local behavior. When
 	// set, put imports beginning with this string after 3rd-party packages;
 	// comma-separated list
-	GoImportsLocalPrefix *string `json:",omitempty"`
+	GoImportsLocalPrefix *string `json:",omitempty" config:"live"`
 
 	// CompletionBudget is the soft latency string-format time.Duration goal for
 	// gopls completion requests. Most requests finish in a couple milliseconds,
 	// but in some cases deep completions can take much longer. As we use up our
 	// budget we dynamically reduce the search scope to ensure we return timely
 	// results. Zero seconds means unlimited. Examples values: "0s", "100ms"
-	CompletionBudget *string `json:",omitempty"`
+	CompletionBudget *string `json:",omitempty" config:"live"`
 
 	// GoplsEnv configures the set of environment variables gopls is using in
 	// calls to go/packages. This is most easily understood in the context of
 	// build tags/constraints where GOOS/GOARCH could be set, or by setting set
 	// GOFLAGS=-modfile=go.local.mod in order to use an alternative go.mod file.
-	GoplsEnv *map[string]string `json:",omitempty"`
+	GoplsEnv *map[string]string `json:",omitempty" config:"live"`
 
 	// GoplsDirectoryFilters can be used to exclude unwanted directories from the
 	// workspace. By default, all directories are included. Filters are an
@@ -172,7 +226,7 @@ type Config struct {
 	// For more details and examples see:
 	//
 	// https://github.com/golang/tools/blob/master/gopls/doc/settings.md#directoryfilters-string
-	GoplsDirectoryFilters *[]string `json:",omitempty"`
+	GoplsDirectoryFilters *[]string `json:",omitempty" config:"live"`
 
 	// Analyses is a map of booleans (0 or 1 in VimScript) used to enable/disable
 	// specific analyses in gopls. Entries in the map are used to override
@@ -184,7 +238,7 @@ type Config struct {
 	// Example: govim#config#Set("Analyses", {"fillstruct": 1, "unreachable": 0})
 	//
 	// Default: nil
-	Analyses *map[string]bool `json:",omitempty"`
+	Analyses *map[string]bool `json:",omitempty" config:"live"`
 
 	// OpenLastProgressWith configures how vim should open the buffer created
 	// when calling :GOVIMLastProgress.
@@ -198,7 +252,7 @@ type Config struct {
 	//    "above 5split" - Split into 5 lines above (left)
 	//
 	// Default: "below 10split"
-	OpenLastProgressWith *string `json:",omitempty"`
+	OpenLastProgressWith *string `json:",omitempty" config:"live"`
 
 	// Gofumpt configures gopls to use gofumpt as formatter.
 	// It is a stricter formatter than gofmt, while being backwards compatible.
@@ -209,7 +263,7 @@ type Config struct {
 	// See https://github.com/golang/go/issues/39805.
 	//
 	// Default: false
-	Gofumpt *bool `json:",omitempty"`
+	Gofumpt *bool `json:",omitempty" config:"live"`
 
 	// ExperimentalAutoreadLoadedBuffers is used to reload buffers that are
 	// changed outside vim even when they are loaded (e.g. running two vim
@@ -224,7 +278,7 @@ type Config struct {
 	// set autoread
 	//
 	// Default: false
-	ExperimentalAutoreadLoadedBuffers *bool `json:",omitempty"`
+	ExperimentalAutoreadLoadedBuffers *bool `json:",omitempty" config:"live"`
 
 	// ExperimentalMouseTriggeredHoverPopupOptions is a map of options to apply
 	// when creating hover-based popup windows triggered by the mouse hovering
@@ -247,7 +301,7 @@ type Config struct {
 	// away in the future, be renamed etc.
 	//
 	// Default: nil
-	ExperimentalMouseTriggeredHoverPopupOptions *map[string]interface{} `json:",omitempty"`
+	ExperimentalMouseTriggeredHoverPopupOptions *map[string]interface{} `json:",omitempty" config:"live"`
 
 	// ExperimentalCursorTriggeredHoverPopupOptions is a map of options to apply
 	// when creating hover-based popup windows triggered by a call to
@@ -271,7 +325,7 @@ type Config struct {
 	// the future, be renamed etc.
 	//
 	// Default: nil
-	ExperimentalCursorTriggeredHoverPopupOptions *map[string]interface{} `json:",omitempty"`
+	ExperimentalCursorTriggeredHoverPopupOptions *map[string]interface{} `json:",omitempty" config:"live"`
 
 	// ExperimentalWorkaroundCompleteoptLongest provides a partial workaround
 	// for users who would otherwise set completeopt+=longest but can't because
@@ -280,7 +334,7 @@ type Config struct {
 	// workaround needs to be in govim. Set this config option along with
 	// completeopt=menu,popup and Vim+govim will behave approximately like
 	// completeopt+=longest.
-	ExperimentalWorkaroundCompleteoptLongest *bool `json:",omitempty"`
+	ExperimentalWorkaroundCompleteoptLongest *bool `json:",omitempty" config:"live"`
 
 	// ExperimentalProgressPopups will, when enabled, show a notification
 	// popup when gopls run tasks that support reporting of progress.
@@ -291,7 +345,7 @@ type Config struct {
 	// renamed etc.
 	//
 	// Default: false
-	ExperimentalProgressPopups *bool `json:",omitempty"`
+	ExperimentalProgressPopups *bool `json:",omitempty" config:"live"`
 
 	// ExperimentalAllowModfileModifications controls whether gopls should
 	// automatically modify go.{mod,sum} as a side effect of its use of cmd/go
@@ -306,8 +360,85 @@ type Config struct {
 	// whereas prior to Go 1.16 the default was -mod=mod (equivalent to setting
 	// this option to true).
 	//
+	// Changing this value restarts gopls.
+	//
 	// Default: false
-	ExperimentalAllowModfileModifications *bool `json:",omitempty"`
+	ExperimentalAllowModfileModifications *bool `json:",omitempty" config:"init"`
+
+	// ExperimentalVirtualTextDiagnostics is a boolean (0 or 1 in VimScript)
+	// that controls whether diagnostic messages should be shown as virtual
+	// text at the end of the line they apply to. Only the message of the
+	// diagnostic with the highest severity is shown for each line, and long
+	// messages are truncated. The text is formatted using the following
+	// highlight groups: GOVIMVirtualTextErr, GOVIMVirtualTextWarn,
+	// GOVIMVirtualTextInfo and GOVIMVirtualTextHint, which by default link to
+	// their GOVIMHover* equivalents.
+	//
+	// Virtual text requires Vim v9.0.0067 or later; the option has no effect
+	// with older versions.
+	//
+	// This is an experimental feature that might go away in the future, be
+	// renamed etc.
+	//
+	// Default: false
+	ExperimentalVirtualTextDiagnostics *bool `json:",omitempty" config:"live"`
+
+	// GoplsRemote is a string value that, when set, makes govim connect to a
+	// shared gopls daemon instead of starting its own gopls instance. This
+	// allows several Vim instances to share the memory used by gopls. The
+	// value is either "auto", in which case govim starts a daemon (if one is
+	// not already running) listening on a unix socket in the temp directory
+	// that is specific to the gopls binary being used, or a network address of
+	// the form "network;address", e.g. "unix;/path/to/socket" or
+	// "tcp;localhost:4389", of a daemon started via "gopls serve -listen". An
+	// address without a network is assumed to be a TCP address.
+	//
+	// Each client has its own session within the daemon, and hence its own
+	// config. When the daemon dies, govim reconnects (starting a new daemon in
+	// the case of "auto") in the same way that it restarts a crashed gopls.
+	//
+	// Changing this value restarts gopls.
+	//
+	// Default: ""
+	GoplsRemote *string `json:",omitempty" config:"init"`
+
+	// LanguageServers configures language servers that govim runs alongside
+	// gopls, keyed by name. Buffers with the filetypes of a language server
+	// are synced with that server, and its diagnostics are shown
+	// alongside those of gopls (each server's diagnostics are kept
+	// separately). gopls remains the server for *.go, go.mod and go.sum
+	// files for all other functionality, e.g. hover. See LanguageServer for
+	// details.
+	//
+	// Changing this value restarts the configured language servers, but not
+	// gopls.
+	//
+	// Default: {}
+	LanguageServers *map[string]LanguageServer `json:",omitempty" config:"live"`
+}
+
+// LanguageServer is the configuration of a language server, other than gopls,
+// that govim runs
+type LanguageServer struct {
+	// Command is the command, and its arguments, that starts the language
+	// server. The server must speak LSP via stdin and stdout.
+	Command []string `json:",omitempty"`
+
+	// Filetypes are the filetypes (see :help 'filetype') of the buffers
+	// synced with the language server, e.g. "proto"
+	Filetypes []string `json:",omitempty"`
+
+	// LanguageID is the LSP language identifier of buffers opened in the
+	// language server. Defaults to the name of the language server.
+	LanguageID string `json:",omitempty"`
+
+	// InitializationOptions is passed to the language server as part of the
+	// Initialize call
+	InitializationOptions map[string]interface{} `json:",omitempty"`
+
+	// Settings is the response to all workspace/configuration requests from
+	// the language server
+	Settings map[string]interface{} `json:",omitempty"`
 }
 
 type Command string
@@ -406,6 +537,39 @@ const (
 	// Open a new buffer that contain the current output from the most recently
 	// created progress popup. Useful for looking at a failed test for example.
 	CommandLastProgress Command = "LastProgress"
+
+	// CommandDiagnosticNext moves the cursor to the start of the next
+	// diagnostic and shows the hover popup for it. A count can be used to
+	// skip diagnostics. When the cursor is after the last diagnostic in the
+	// current file, the cursor moves to the first diagnostic in the next file
+	// (respecting &switchbuf), wrapping around after the last file. The
+	// following optional flags can be used to filter diagnostics:
+	//
+	//    -severity=S  only include diagnostics with severity S or higher,
+	//                 where S is one of error, warning, info or hint
+	//    -source=S    only include diagnostics from source S, e.g. compiler
+	//    -buffer      only include diagnostics in the current buffer,
+	//                 wrapping around at the end of the buffer
+	CommandDiagnosticNext Command = "DiagnosticNext"
+
+	// CommandDiagnosticPrev is the same as CommandDiagnosticNext but moves
+	// to the previous diagnostic.
+	CommandDiagnosticPrev Command = "DiagnosticPrev"
+
+	// CommandRestartGopls stops the running gopls instance and starts a new
+	// one, without losing the state of the editing session. Loaded buffers
+	// are reopened in the new instance and the current config is restored.
+	// gopls is also restarted automatically, with backoff, when it crashes.
+	CommandRestartGopls Command = "RestartGopls"
+
+	// CommandWorkspaceFolders lists the workspace folders when called without
+	// arguments. With arguments "add" or "remove" followed by one or more
+	// directories, the directories are added to or removed from the set of
+	// workspace folders. Independent of this command, the module root of
+	// every buffer that is opened is added as a workspace folder, unless the
+	// buffer is already within a workspace folder that is either its module
+	// root or a go.work workspace.
+	CommandWorkspaceFolders Command = "WorkspaceFolders"
 )
 
 type Function string
@@ -425,12 +589,14 @@ const (
 	FunctionHover Function = "Hover"
 
 	// FunctionBufChanged is an internal function used by govim for handling
-	// delta-based changes in buffers.
-	FunctionBufChanged Function = InternalFunctionPrefix + "BufChanged"
+	// delta-based changes in buffers. It is defined by the bufsync.Sync named
+	// BufSyncName.
+	FunctionBufChanged Function = BufSyncName + "Changed"
 
-	// FunctionEnrichDelta is an internal function used by govim for enriching
-	// listener_add based callbacks before calling FunctionBufChanged
-	FunctionEnrichDelta Function = InternalFunctionPrefix + "EnrichDelta"
+	// FunctionSetStatusline is an internal function used by govim for pushing
+	// the state returned by GOVIMStatusline() to Vim. It fires the User
+	// autocommand GOVIMStatusChanged.
+	FunctionSetStatusline Function = InternalFunctionPrefix + "SetStatusline"
 
 	// FunctionSetConfig is an internal function used by govim for pushing config
 	// changes from Vim to govim.
@@ -455,6 +621,26 @@ const (
 	// should be run to create a "child" instance of govim to communicate with
 	// its "parent" (the instance which responded to this function call)
 	FunctionParentCommand Function = "ParentCommand"
+
+	// FunctionInspectorEntries is an internal function used by the
+	// GOVIMInspector command to fetch the rows of recorded Vim channel and
+	// LSP traffic that it has yet to show
+	FunctionInspectorEntries Function = InternalFunctionPrefix + "InspectorEntries"
+
+	// FunctionInspectorStart is an internal function used by the
+	// GOVIMInspector command to start the recording of traffic when an
+	// inspector is opened
+	FunctionInspectorStart Function = InternalFunctionPrefix + "InspectorStart"
+
+	// FunctionInspectorStop is an internal function used by the
+	// GOVIMInspector command to stop the recording of traffic when an
+	// inspector is closed
+	FunctionInspectorStop Function = InternalFunctionPrefix + "InspectorStop"
+
+	// FunctionLogTail is an internal function used by the GOVIMLogs command
+	// to read the lines that have been added to a log since it was last read,
+	// without reading the whole log
+	FunctionLogTail Function = InternalFunctionPrefix + "LogTail"
 )
 
 // FormatOnSave typed constants define the set of valid values that
@@ -582,6 +768,15 @@ const (
 	// HighlightHoverHint is ths group used to add hints to the hover popup
 	HighlightHoverHint Highlight = "GOVIMHoverHint"
 
+	// HighlightVirtualTextErr is the group used to show errors as virtual text
+	HighlightVirtualTextErr Highlight = "GOVIMVirtualTextErr"
+	// HighlightVirtualTextWarn is the group used to show warnings as virtual text
+	HighlightVirtualTextWarn Highlight = "GOVIMVirtualTextWarn"
+	// HighlightVirtualTextInfo is the group used to show informations as virtual text
+	HighlightVirtualTextInfo Highlight = "GOVIMVirtualTextInfo"
+	// HighlightVirtualTextHint is the group used to show hints as virtual text
+	HighlightVirtualTextHint Highlight = "GOVIMVirtualTextHint"
+
 	// HighlightHoverDiagSrc is the group used to format the source part of a hover diagnostic
 	HighlightHoverDiagSrc Highlight = "GOVIMHoverDiagSrc"
 
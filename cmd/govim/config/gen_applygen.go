@@ -1,5 +1,7 @@
 package config
 
+import "reflect"
+
 func (r *Config) Apply(v *Config) {
 	if v.FormatOnSave != nil {
 		r.FormatOnSave = v.FormatOnSave
@@ -76,4 +78,100 @@ func (r *Config) Apply(v *Config) {
 	if v.ExperimentalAllowModfileModifications != nil {
 		r.ExperimentalAllowModfileModifications = v.ExperimentalAllowModfileModifications
 	}
+	if v.ExperimentalVirtualTextDiagnostics != nil {
+		r.ExperimentalVirtualTextDiagnostics = v.ExperimentalVirtualTextDiagnostics
+	}
+	if v.GoplsRemote != nil {
+		r.GoplsRemote = v.GoplsRemote
+	}
+	if v.LanguageServers != nil {
+		r.LanguageServers = v.LanguageServers
+	}
+}
+func (r *Config) Changes(v *Config) (live, init []string) {
+	if !reflect.DeepEqual(r.FormatOnSave, v.FormatOnSave) {
+		live = append(live, "FormatOnSave")
+	}
+	if !reflect.DeepEqual(r.QuickfixAutoDiagnostics, v.QuickfixAutoDiagnostics) {
+		live = append(live, "QuickfixAutoDiagnostics")
+	}
+	if !reflect.DeepEqual(r.QuickfixSigns, v.QuickfixSigns) {
+		live = append(live, "QuickfixSigns")
+	}
+	if !reflect.DeepEqual(r.HighlightDiagnostics, v.HighlightDiagnostics) {
+		live = append(live, "HighlightDiagnostics")
+	}
+	if !reflect.DeepEqual(r.HighlightReferences, v.HighlightReferences) {
+		live = append(live, "HighlightReferences")
+	}
+	if !reflect.DeepEqual(r.HoverDiagnostics, v.HoverDiagnostics) {
+		live = append(live, "HoverDiagnostics")
+	}
+	if !reflect.DeepEqual(r.CompletionDeepCompletions, v.CompletionDeepCompletions) {
+		live = append(live, "CompletionDeepCompletions")
+	}
+	if !reflect.DeepEqual(r.CompletionMatcher, v.CompletionMatcher) {
+		live = append(live, "CompletionMatcher")
+	}
+	if !reflect.DeepEqual(r.SymbolMatcher, v.SymbolMatcher) {
+		init = append(init, "SymbolMatcher")
+	}
+	if !reflect.DeepEqual(r.SymbolStyle, v.SymbolStyle) {
+		init = append(init, "SymbolStyle")
+	}
+	if !reflect.DeepEqual(r.Staticcheck, v.Staticcheck) {
+		live = append(live, "Staticcheck")
+	}
+	if !reflect.DeepEqual(r.CompleteUnimported, v.CompleteUnimported) {
+		live = append(live, "CompleteUnimported")
+	}
+	if !reflect.DeepEqual(r.GoImportsLocalPrefix, v.GoImportsLocalPrefix) {
+		live = append(live, "GoImportsLocalPrefix")
+	}
+	if !reflect.DeepEqual(r.CompletionBudget, v.CompletionBudget) {
+		live = append(live, "CompletionBudget")
+	}
+	if !reflect.DeepEqual(r.GoplsEnv, v.GoplsEnv) {
+		live = append(live, "GoplsEnv")
+	}
+	if !reflect.DeepEqual(r.GoplsDirectoryFilters, v.GoplsDirectoryFilters) {
+		live = append(live, "GoplsDirectoryFilters")
+	}
+	if !reflect.DeepEqual(r.Analyses, v.Analyses) {
+		live = append(live, "Analyses")
+	}
+	if !reflect.DeepEqual(r.OpenLastProgressWith, v.OpenLastProgressWith) {
+		live = append(live, "OpenLastProgressWith")
+	}
+	if !reflect.DeepEqual(r.Gofumpt, v.Gofumpt) {
+		live = append(live, "Gofumpt")
+	}
+	if !reflect.DeepEqual(r.ExperimentalAutoreadLoadedBuffers, v.ExperimentalAutoreadLoadedBuffers) {
+		live = append(live, "ExperimentalAutoreadLoadedBuffers")
+	}
+	if !reflect.DeepEqual(r.ExperimentalMouseTriggeredHoverPopupOptions, v.ExperimentalMouseTriggeredHoverPopupOptions) {
+		live = append(live, "ExperimentalMouseTriggeredHoverPopupOptions")
+	}
+	if !reflect.DeepEqual(r.ExperimentalCursorTriggeredHoverPopupOptions, v.ExperimentalCursorTriggeredHoverPopupOptions) {
+		live = append(live, "ExperimentalCursorTriggeredHoverPopupOptions")
+	}
+	if !reflect.DeepEqual(r.ExperimentalWorkaroundCompleteoptLongest, v.ExperimentalWorkaroundCompleteoptLongest) {
+		live = append(live, "ExperimentalWorkaroundCompleteoptLongest")
+	}
+	if !reflect.DeepEqual(r.ExperimentalProgressPopups, v.ExperimentalProgressPopups) {
+		live = append(live, "ExperimentalProgressPopups")
+	}
+	if !reflect.DeepEqual(r.ExperimentalAllowModfileModifications, v.ExperimentalAllowModfileModifications) {
+		init = append(init, "ExperimentalAllowModfileModifications")
+	}
+	if !reflect.DeepEqual(r.ExperimentalVirtualTextDiagnostics, v.ExperimentalVirtualTextDiagnostics) {
+		live = append(live, "ExperimentalVirtualTextDiagnostics")
+	}
+	if !reflect.DeepEqual(r.GoplsRemote, v.GoplsRemote) {
+		init = append(init, "GoplsRemote")
+	}
+	if !reflect.DeepEqual(r.LanguageServers, v.LanguageServers) {
+		live = append(live, "LanguageServers")
+	}
+	return
 }
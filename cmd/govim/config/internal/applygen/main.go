@@ -1,6 +1,11 @@
 // applygen is a command that automates the generation of an Apply method on
 // the pointer receiver of a struct type which has exported pointer-type fields
-// to apply overrides from the argument onto the receiver
+// to apply overrides from the argument onto the receiver.
+//
+// Each field must carry a config struct tag with a value of either "live" or
+// "init". applygen also generates a Changes method that returns the names of
+// the fields that differ between the receiver and the argument, split
+// according to that classification.
 package main
 
 import (
@@ -11,6 +16,7 @@ import (
 	"go/types"
 	"os"
 	"os/exec"
+	"reflect"
 	"sort"
 
 	"golang.org/x/tools/go/packages"
@@ -39,7 +45,7 @@ func mainerr() error {
 	}
 
 	cfg := &packages.Config{
-		Mode: packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedName,
+		Mode: packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedName | packages.NeedImports | packages.NeedDeps,
 	}
 	pkgs, err := packages.Load(cfg)
 	if err != nil {
@@ -49,6 +55,7 @@ func mainerr() error {
 		return fmt.Errorf("expected a single package; got %v", l)
 	}
 	toGen := make(map[string][]string)
+	kinds := make(map[string]map[string]string)
 	var names []string
 	pkg := pkgs[0]
 	for _, name := range flag.Args() {
@@ -60,8 +67,16 @@ func mainerr() error {
 		t := tn.(*types.TypeName).Type().Underlying()
 		st := t.(*types.Struct)
 		fields := make([]string, st.NumFields())
+		kinds[name] = make(map[string]string)
 		for i := 0; i < st.NumFields(); i++ {
 			fields[i] = st.Field(i).Name()
+			kind := reflect.StructTag(st.Tag(i)).Get("config")
+			switch kind {
+			case "live", "init":
+			default:
+				return fmt.Errorf("field %v.%v has config tag %q; must be live or init", name, fields[i], kind)
+			}
+			kinds[name][fields[i]] = kind
 		}
 		toGen[name] = fields
 	}
@@ -76,6 +91,7 @@ func mainerr() error {
 		fmt.Fprintf(&buf, format, args...)
 	}
 	pf("package %v", pkg.Name)
+	pf("import \"reflect\"")
 	for _, name := range names {
 		pf("func (r *%[1]v) Apply(v *%[1]v) {", name)
 		for _, field := range toGen[name] {
@@ -84,6 +100,14 @@ func mainerr() error {
 			pf("}")
 		}
 		pf("}")
+		pf("func (r *%[1]v) Changes(v *%[1]v) (live, init []string) {", name)
+		for _, field := range toGen[name] {
+			pf("if !reflect.DeepEqual(r.%[1]v, v.%[1]v) {", field)
+			pf("  %[1]v = append(%[1]v, %[2]q)", kinds[name][field], field)
+			pf("}")
+		}
+		pf("return")
+		pf("}")
 	}
 	res, err := format.Source(buf.Bytes())
 	if err != nil {
@@ -0,0 +1,43 @@
+" This file is the init.vim with which the testdriver runs Neovim, to test the
+" plugin API of github.com/govim/govim against Neovim. govim itself does not
+" yet run in Neovim. Neovim's defaults already cover much of minimal.vimrc,
+" and the Vim-only settings there (ttymouse, balloondelay and completepopup)
+" do not apply.
+"
+" We also include the suggested settings of minimal.vimrc that apply to
+" Neovim.
+
+" The glue between Neovim and govim is only used if this is set
+let g:govim_neovim = 1
+
+set nobackup
+set nowritebackup
+set noswapfile
+
+set mouse=a
+
+" Suggestion: By default, govim populates the quickfix window with diagnostics
+" reported by gopls after a period of inactivity, the time period being
+" defined by updatetime (help updatetime). Here we suggest a short updatetime
+" time in order that govim/Neovim are more responsive/IDE-like
+set updatetime=500
+
+" Suggestion: Turn on the sign column so you can see error marks on lines
+" where there are quickfix errors. Some users who already show line number
+" might prefer to instead have the signs shown in the number column; in which
+" case set signcolumn=number
+set signcolumn=yes
+
+" Suggestion: Turn on syntax highlighting for .go files. You might prefer to
+" turn on syntax highlighting for all files, in which case
+"
+" syntax on
+"
+" will suffice, no autocmd required.
+autocmd! BufEnter,BufNewFile *.go,go.mod syntax on
+autocmd! BufLeave *.go,go.mod syntax off
+
+" Suggestion: turn on auto-indenting. If you want closing parentheses, braces
+" etc to be added, https://github.com/jiangmiao/auto-pairs. In future we might
+" include this by default in govim.
+set smartindent
@@ -15,13 +15,28 @@ package main
 // create that child instance that then connects to the parent. This is exposed
 // via the GOVIMParentCommand() Vim function.
 //
+// Commands register themselves via registerParentCommand. The gopls command
+// in turn exposes a subset of the gopls API as methods, each of which
+// registers itself via registerGoplsMethod, e.g.:
+//
+//     gopls Definition -format quickfix main.go:12:5
+//
+// Position arguments take the form file:line:col, where col is a byte index,
+// and relative filenames are resolved against the working directory of Vim.
+// Positions are resolved against the current (possibly unsaved) contents of
+// the buffers in Vim. Most methods output either JSON or quickfix format,
+// selected via the -format flag.
+//
 // TODO: expose help information for the various commands etc
 //
-// The child and parent communicate using a Unix domain socket. Messages on the
-// wire are encoded JSON. The child starts by sending all of its non-flag args
-// to the parent. The parent is then responds with pairs of data. The pair
-// comprises a JSON-encoded number followed by a JSON-encoded value. The
-// following list of modes is supported:
+// The child and parent communicate using a Unix domain socket or, when
+// EnvVarParentListen is set, a TCP connection. Messages on the wire are
+// encoded JSON. The child starts by sending the per-session token of the
+// parent, which the parent generates on startup and which is included in the
+// command returned by GOVIMParentCommand(), followed by all of its non-flag
+// args. The parent is then responds with pairs of data. The pair comprises a
+// JSON-encoded number followed by a JSON-encoded value. The following list of
+// modes is supported:
 //
 // 0X - X is an integer representing the exit code the client should use
 // 1X - X is a string value that should be output to os.Stdout
@@ -29,6 +44,9 @@ package main
 // 3x - X is an interface{} value whose JSON representation should be output to os.Stdout
 // 4x - X is an interface{} value whose JSON representation should be output to os.Stderr
 //
+// A child that does not send a valid token within parentHandshakeTimeout is
+// sent an error and a non-zero exit code.
+//
 // If the parent instance encounters an error writing to the child's
 // connection, it assumes the child has closed the connected and it (the
 // parent) continues without error.
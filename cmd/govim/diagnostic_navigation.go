@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/govim/govim"
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
+	"github.com/govim/govim/cmd/govim/internal/types"
+)
+
+// severityNames maps the names accepted by the -severity flag of
+// CommandDiagnosticNext and CommandDiagnosticPrev to a severity.
+var severityNames = map[string]types.Severity{
+	"error":   types.SeverityErr,
+	"warning": types.SeverityWarn,
+	"info":    types.SeverityInfo,
+	"hint":    types.SeverityHint,
+}
+
+// diagnosticNavFilter is the set of filters, parsed from command arguments,
+// used to select the diagnostics that CommandDiagnosticNext and
+// CommandDiagnosticPrev move between.
+type diagnosticNavFilter struct {
+	// severity is the lowest severity to include
+	severity types.Severity
+
+	// source, if set, is the diagnostic source to include
+	source string
+
+	// buffer restricts navigation to the current buffer, wrapping around at
+	// the start and end of the buffer rather than moving to the next file
+	buffer bool
+}
+
+func parseDiagnosticNavArgs(args ...string) (diagnosticNavFilter, error) {
+	var severity string
+	res := diagnosticNavFilter{severity: types.SeverityHint}
+	fs := flag.NewFlagSet("diagnostics", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&severity, "severity", "hint", "lowest severity to include: error, warning, info or hint")
+	fs.StringVar(&res.source, "source", "", "only include diagnostics from this source")
+	fs.BoolVar(&res.buffer, "buffer", false, "only include diagnostics in the current buffer")
+	if err := fs.Parse(args); err != nil {
+		return res, err
+	}
+	if fs.NArg() > 0 {
+		return res, fmt.Errorf("unexpected arguments: %v", strings.Join(fs.Args(), " "))
+	}
+	s, ok := severityNames[severity]
+	if !ok {
+		return res, fmt.Errorf("invalid severity %q; must be one of error, warning, info or hint", severity)
+	}
+	res.severity = s
+	return res, nil
+}
+
+func (v *vimstate) diagnosticNext(flags govim.CommandFlags, args ...string) error {
+	return v.navigateDiagnostics(true, flags, args...)
+}
+
+func (v *vimstate) diagnosticPrev(flags govim.CommandFlags, args ...string) error {
+	return v.navigateDiagnostics(false, flags, args...)
+}
+
+// navigateDiagnostics moves the cursor to the start of the next (or previous)
+// diagnostic that matches the filters given in args, relative to the cursor
+// position, and shows the hover popup for that position.
+func (v *vimstate) navigateDiagnostics(forward bool, flags govim.CommandFlags, args ...string) error {
+	filter, err := parseDiagnosticNavArgs(args...)
+	if err != nil {
+		return fmt.Errorf("failed to parse arguments: %v", err)
+	}
+	pos, err := v.cursorPos()
+	if err != nil {
+		return fmt.Errorf("failed to get cursor position: %v", err)
+	}
+	var cb *types.Buffer
+	if pos.Point != nil {
+		cb = pos.Point.Buffer()
+	}
+	if filter.buffer && cb == nil {
+		return fmt.Errorf("cursor position in buffer %v not tracked by govim", pos.BufNr)
+	}
+
+	var diags []types.Diagnostic
+	for _, d := range *v.diagnostics() {
+		if d.Severity > filter.severity {
+			continue
+		}
+		if filter.source != "" && d.Source != filter.source {
+			continue
+		}
+		if filter.buffer && d.Filename != cb.URI().Path() {
+			continue
+		}
+		diags = append(diags, d)
+	}
+	if len(diags) == 0 {
+		v.ChannelEx(`echom "No matching diagnostics"`)
+		return nil
+	}
+
+	// Diagnostics are sorted by filename, line and column. Find the index of
+	// the first diagnostic after (or last before) the cursor. Without a
+	// cursor position in a buffer tracked by govim we start from either end.
+	idx := -1
+	if cb != nil {
+		fn := cb.URI().Path()
+		for i, d := range diags {
+			cmp := strings.Compare(d.Filename, fn)
+			if cmp == 0 {
+				cmp = d.Range.Start.Line() - pos.Line()
+			}
+			if cmp == 0 {
+				cmp = d.Range.Start.Col() - pos.Col()
+			}
+			if forward && cmp > 0 {
+				idx = i
+				break
+			}
+			if !forward && cmp < 0 {
+				idx = i
+			}
+		}
+	}
+	count := 1
+	if flags.Count != nil && *flags.Count > 1 {
+		count = *flags.Count
+	}
+	if idx == -1 {
+		// Wrap around
+		if forward {
+			idx = 0
+		} else {
+			idx = len(diags) - 1
+		}
+	}
+	if forward {
+		idx = (idx + count - 1) % len(diags)
+	} else {
+		idx = ((idx-count+1)%len(diags) + len(diags)) % len(diags)
+	}
+	d := diags[idx]
+
+	if cb != nil && d.Filename == cb.URI().Path() {
+		v.ChannelEx("normal! m'")
+		v.ChannelCall("cursor", d.Range.Start.Line(), d.Range.Start.Col())
+	} else {
+		loc := protocol.Location{
+			URI: protocol.URIFromPath(d.Filename),
+			Range: protocol.Range{
+				Start: d.Range.Start.ToPosition(),
+				End:   d.Range.Start.ToPosition(),
+			},
+		}
+		if err := v.loadLocation(flags.Mods, loc); err != nil {
+			return err
+		}
+	}
+
+	// Make sure the screen position of the cursor is up to date before
+	// placing the popup
+	v.ChannelRedraw(false)
+	_, err = v.hover()
+	return err
+}
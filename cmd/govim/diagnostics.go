@@ -9,8 +9,8 @@ import (
 	"github.com/govim/govim/cmd/govim/internal/types"
 )
 
-// diagnostics returns the last received LSP diagnostics from gopls
-// and acts as a lazy conversion mechanism. The purpose is to avoid converting
+// diagnostics returns the last received LSP diagnostics from gopls and any
+// other language servers, and acts as a lazy conversion mechanism. The purpose is to avoid converting
 // lsp diagnostics unless they are needed by govim.
 func (v *vimstate) diagnostics() *[]types.Diagnostic {
 	v.diagnosticsChangedLock.Lock()
@@ -23,6 +23,16 @@ func (v *vimstate) diagnostics() *[]types.Diagnostic {
 	for k, v := range v.rawDiagnostics {
 		filediags[k] = v.Diagnostics
 	}
+	for name, lsDiags := range v.languageServerDiagnostics {
+		for k, v := range lsDiags {
+			for _, d := range v.Diagnostics {
+				if d.Source == "" {
+					d.Source = name
+				}
+				filediags[k] = append(filediags[k][:len(filediags[k]):len(filediags[k])], d)
+			}
+		}
+	}
 	v.diagnosticsChanged = false
 	v.diagnosticsChangedLock.Unlock()
 
@@ -96,5 +106,11 @@ func (v *vimstate) handleDiagnosticsChanged() error {
 	if err := v.redefineHighlights(false); err != nil {
 		v.Logf("redefineDiagnostics: failed to apply highlights: %v", err)
 	}
+
+	if err := v.updateVirtualText(false); err != nil {
+		v.Logf("redefineDiagnostics: failed to update virtual text: %v", err)
+	}
+
+	v.updateStatusline()
 	return nil
 }
@@ -4,12 +4,16 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 )
 
 var (
 	flagSet = flag.NewFlagSet("govim", flag.ContinueOnError)
 	fTail   = flagSet.Bool("tail", false, "whether to also log output to stdout")
-	fParent = flagSet.String("parent", "", "the Unix Domain Socket on which a parent instance can be contacted")
+	fParent = flagSet.String("parent", "", "the address on which a parent instance can be contacted: either a Unix Domain Socket or tcp://host:port")
+
+	fParentToken   = flagSet.String("parenttoken", "", "the token to present to the parent instance")
+	fParentTimeout = flagSet.Duration("parenttimeout", time.Minute, "the maximum time to wait for the parent instance to respond")
 )
 
 func init() { flagSet.Usage = usage }
@@ -18,7 +22,7 @@ func usage() {
 	fmt.Fprintf(os.Stderr, `
 Usage of govim:
 
-	govim [-tail] [-parent /path/to/uds] gopls ...
+	govim [-tail] [-parent ADDR -parenttoken TOKEN] gopls ...
 
 `[1:])
 	flagSet.PrintDefaults()
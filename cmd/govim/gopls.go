@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -12,7 +13,9 @@ import (
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/govim/govim"
 	"github.com/govim/govim/cmd/govim/config"
 	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools/fakenet"
 	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools/jsonrpc2"
@@ -20,112 +23,160 @@ import (
 	"github.com/govim/govim/cmd/govim/internal/util"
 )
 
+// startGopls establishes the workspace in which govim is running, and then
+// starts gopls via launchGopls.
 func (g *govimplugin) startGopls() error {
-	goplsArgs := []string{"-rpc.trace"}
+	// gomodspec points at the workspace file (go.work) in workspace mode, or go.mod in
+	// module mode.
+	gomodspec, err := goModSpecPath(g.vimstate.workingDirectory)
+	if err != nil {
+		return fmt.Errorf("failed to derive go.work/go.mod path: %v", err)
+	}
 
-	if g.logging["on"] {
-		logfile, err := g.createLogFile("gopls")
-		if err != nil {
-			return err
-		}
-		logfile.Close()
-		g.Logf("gopls log file: %v", logfile.Name())
+	// "go env GOMOD" might return an empty string (not module mode) or os.DevNull (in module
+	// mode but without a module root).
+	if gomodspec != "" && gomodspec != os.DevNull {
+		// i.e. we are in a module or a workspace
+		g.vimstate.view = filepath.Dir(gomodspec)
+	}
 
-		g.ChannelExf("let s:gopls_logfile=%q", logfile.Name())
-		goplsArgs = append(goplsArgs, "-logfile", logfile.Name())
-	} else {
-		goplsArgs = append(goplsArgs, "-logfile", os.DevNull)
+	// The initial workspace folder also gets a modWatcher when it is the root
+	// of a module or workspace. Further folders are added as buffers are
+	// opened in other modules, see ensureWorkspaceFolder.
+	if _, err := g.addWorkspaceFolder(filepath.Dir(gomodspec)); err != nil {
+		return err
 	}
 
-	if flags, err := util.Split(os.Getenv(string(config.EnvVarGoplsFlags))); err != nil {
-		g.Logf("invalid env var %s: %v", config.EnvVarGoplsFlags, err)
-	} else {
-		goplsArgs = append(goplsArgs, flags...)
+	return g.launchGopls(g.Govim)
+}
+
+// launchGopls starts a new gopls process, or connects to a gopls daemon when
+// GoplsRemote is set, and then runs the Initialize/Initialized sequence. It is
+// used both when govim starts and when gopls is restarted, hence vim is the
+// govim.Govim instance appropriate for the caller's context.
+func (g *govimplugin) launchGopls(vim govim.Govim) error {
+	g.vimstate.configLock.Lock()
+	var remote string
+	if g.vimstate.config.GoplsRemote != nil {
+		remote = *g.vimstate.config.GoplsRemote
 	}
+	g.vimstate.configLock.Unlock()
 
-	gopls := exec.Command(g.goplspath, goplsArgs...)
-	gopls.Env = g.goplsEnv
-	if ev, ok := os.LookupEnv(string(config.EnvVarGoplsGOMAXPROCSMinusN)); ok {
-		v := strings.TrimSpace(ev)
-		var gmp int
-		if strings.HasSuffix(v, "%") {
-			v = strings.TrimSuffix(v, "%")
-			p, err := strconv.ParseFloat(v, 64)
+	stopped := make(chan struct{})
+	exited := make(chan struct{})
+	var rwc net.Conn
+	var gopls *os.Process
+	if remote != "" {
+		c, err := g.dialGoplsRemote(remote)
+		if err != nil {
+			return err
+		}
+		rwc = c
+		g.goplsStdin = c
+	} else {
+		goplsArgs := []string{"-rpc.trace"}
+
+		if g.logging["on"] {
+			logfile, err := g.createLogFile("gopls")
 			if err != nil {
-				return fmt.Errorf("failed to parse percentage from %v value %q: %v", config.EnvVarGoplsGOMAXPROCSMinusN, ev, err)
+				return err
 			}
-			gmp = int(math.Floor(float64(runtime.NumCPU()) * (1 - p/100)))
+			logfile.Close()
+			g.Logf("gopls log file: %v", logfile.Name())
+
+			vim.ChannelEx(fmt.Sprintf("let s:gopls_logfile=%q", logfile.Name()))
+			goplsArgs = append(goplsArgs, "-logfile", logfile.Name())
 		} else {
-			n, err := strconv.Atoi(v)
-			if err != nil {
-				return fmt.Errorf("failed to parse integer from %v value %q: %v", config.EnvVarGoplsGOMAXPROCSMinusN, ev, err)
-			}
-			gmp = runtime.NumCPU() - n
+			goplsArgs = append(goplsArgs, "-logfile", os.DevNull)
 		}
-		if gmp < 0 || gmp > runtime.NumCPU() {
-			return fmt.Errorf("%v value %q results in GOMAXPROCS value %v which is invalid", config.EnvVarGoplsGOMAXPROCSMinusN, ev, gmp)
+
+		cmd, err := g.goplsCommand(goplsArgs...)
+		if err != nil {
+			return err
 		}
-		g.Logf("Starting gopls with GOMAXPROCS=%v", gmp)
-		gopls.Env = append(gopls.Env, "GOMAXPROCS="+strconv.Itoa(gmp))
-	}
-	g.Logf("Running gopls: %v", strings.Join(gopls.Args, " "))
-	stderr, err := gopls.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe for gopls: %v", err)
-	}
-	g.tomb.Go(func() error {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			g.Logf("gopls stderr: %v", scanner.Text())
+		g.Logf("Running gopls: %v", strings.Join(cmd.Args, " "))
+		stderr, err := cmd.StderrPipe()
+		if err != nil {
+			return fmt.Errorf("failed to create stderr pipe for gopls: %v", err)
 		}
-		if err := scanner.Err(); err != nil {
-			return fmt.Errorf("reading standard input: %v", err)
+		g.tomb.Go(func() error {
+			scanner := bufio.NewScanner(stderr)
+			for scanner.Scan() {
+				g.Logf("gopls stderr: %v", scanner.Text())
+			}
+			if err := scanner.Err(); err != nil {
+				return fmt.Errorf("reading standard input: %v", err)
+			}
+			return nil
+		})
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return fmt.Errorf("failed to create stdout pipe for gopls: %v", err)
 		}
-		return nil
-	})
-	stdout, err := gopls.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdout pipe for gopls: %v", err)
-	}
-	stdin, err := gopls.StdinPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdin pipe for gopls: %v", err)
-	}
-	g.goplsStdin = stdin
-	if err := gopls.Start(); err != nil {
-		return fmt.Errorf("failed to start gopls: %v", err)
-	}
-	g.tomb.Go(func() (err error) {
-		if err = gopls.Wait(); err != nil {
-			err = fmt.Errorf("got error running gopls: %v", err)
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return fmt.Errorf("failed to create stdin pipe for gopls: %v", err)
 		}
-		select {
-		case <-g.inShutdown:
-			return nil
-		default:
+		g.goplsStdin = stdin
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("failed to start gopls: %v", err)
+		}
+		gopls = cmd.Process
+		g.tomb.Go(func() (err error) {
+			err = cmd.Wait()
+			close(exited)
 			if err != nil {
-				g.errCh <- err
+				err = fmt.Errorf("got error running gopls: %v", err)
+			} else {
+				err = fmt.Errorf("gopls exited unexpectedly")
 			}
-			return
-		}
-	})
+			g.goplsExitedUnexpectedly(stopped, err)
+			return nil
+		})
+		rwc = fakenet.NewConn("stdio", stdout, stdin)
+	}
+	g.goplsStopped = stopped
+	g.goplsExited = exited
+	g.goplsStarted = time.Now()
 
-	fakeconn := fakenet.NewConn("stdio", stdout, stdin)
-	stream := jsonrpc2.NewHeaderStream(fakeconn)
+	stream := jsonrpc2.NewHeaderStream(rwc)
+	if g.recording != nil {
+		stream = recordingStream{Stream: stream, rec: g.recording}
+	}
 	ctxt, cancel := context.WithCancel(context.Background())
 	conn := jsonrpc2.NewConn(stream)
-	server := protocol.ServerDispatcher(conn)
-	handler := protocol.ClientHandler(g, jsonrpc2.MethodNotFound)
+	server := protocol.ServerDispatcher(tracingConn{Conn: conn, traffic: g.traffic})
+	handler := tracingHandler(g.traffic, protocol.ClientHandler(g, jsonrpc2.MethodNotFound))
 	handler = protocol.Handlers(handler)
 	ctxt = protocol.WithClient(ctxt, g)
 
 	g.tomb.Go(func() error {
 		conn.Go(ctxt, handler)
 		<-conn.Done()
-		return conn.Err()
+		select {
+		case <-g.inShutdown:
+			return conn.Err()
+		default:
+		}
+		if gopls == nil {
+			// The connection is all there is to a gopls daemon
+			close(exited)
+			g.goplsExitedUnexpectedly(stopped, fmt.Errorf("connection to gopls daemon closed: %v", conn.Err()))
+			return nil
+		}
+		select {
+		case <-stopped:
+			return nil
+		default:
+		}
+		// The connection to a running gopls failed; treat this as a crash
+		// by killing the process, the exit of which is handled above.
+		g.Logf("connection to gopls failed: %v", conn.Err())
+		gopls.Kill()
+		return nil
 	})
 
-	g.gopls = gopls.Process
+	g.gopls = gopls
 	g.goplsConn = conn
 	g.goplsCancel = cancel
 	g.server = loggingGoplsServer{
@@ -133,32 +184,15 @@ func (g *govimplugin) startGopls() error {
 		g: g,
 	}
 
-	// gomodspec points at the workspace file (go.work) in workspace mode, or go.mod in
-	// module mode.
-	gomodspec, err := goModSpecPath(g.vimstate.workingDirectory)
-	if err != nil {
-		return fmt.Errorf("failed to derive go.work/go.mod path: %v", err)
-	}
-
-	// "go env GOMOD" might return an empty string (not module mode) or os.DevNull (in module
-	// mode but without a module root).
-	if gomodspec != "" && gomodspec != os.DevNull {
-		// i.e. we are in a module or a workspace
-		mw, err := newModWatcher(g, gomodspec)
-		if err != nil {
-			return fmt.Errorf("failed to create modWatcher for %v: %v", gomodspec, err)
-		}
-		g.modWatcher = mw
-	}
-
 	initParams := &protocol.ParamInitialize{}
-	initParams.WorkspaceFolders = []protocol.WorkspaceFolder{
-		{URI: string(protocol.URIFromPath(filepath.Dir(gomodspec)))},
-	}
+	g.workspaceFoldersLock.Lock()
+	initParams.WorkspaceFolders = append([]protocol.WorkspaceFolder(nil), g.workspaceFolders...)
+	g.workspaceFoldersLock.Unlock()
 	initParams.Capabilities.TextDocument.Hover = &protocol.HoverClientCapabilities{
 		ContentFormat: []protocol.MarkupKind{protocol.PlainText},
 	}
 	initParams.Capabilities.Workspace.Configuration = true
+	initParams.Capabilities.Workspace.WorkspaceFolders = true
 	// TODO: actually handle these registrations dynamically, if we ever want to
 	// target language servers other than gopls.
 	initParams.Capabilities.Workspace.DidChangeConfiguration.DynamicRegistration = true
@@ -175,7 +209,9 @@ func (g *govimplugin) startGopls() error {
 
 	// Session-level config should be able to be set post initialize, but that
 	// is not currently supported by gopls. So for now a restart is required
-	// in order to change symbol matcher/style config
+	// in order to change symbol matcher/style config. Such fields are
+	// classified as "init" in config.Config, and setConfig restarts gopls
+	// when they change.
 	//
 	// TODO: clarify whether this method is in fact running as part of the vimstate
 	// "thread" and hence whether this lock is required
@@ -210,3 +246,57 @@ func (g *govimplugin) startGopls() error {
 
 	return nil
 }
+
+// goplsCommand returns the command to run gopls with args, in the environment
+// configured for gopls.
+func (g *govimplugin) goplsCommand(args ...string) (*exec.Cmd, error) {
+	if flags, err := util.Split(os.Getenv(string(config.EnvVarGoplsFlags))); err != nil {
+		g.Logf("invalid env var %s: %v", config.EnvVarGoplsFlags, err)
+	} else {
+		args = append(args, flags...)
+	}
+
+	gopls := exec.Command(g.goplspath, args...)
+	gopls.Env = g.goplsEnv
+	if ev, ok := os.LookupEnv(string(config.EnvVarGoplsGOMAXPROCSMinusN)); ok {
+		v := strings.TrimSpace(ev)
+		var gmp int
+		if strings.HasSuffix(v, "%") {
+			v = strings.TrimSuffix(v, "%")
+			p, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse percentage from %v value %q: %v", config.EnvVarGoplsGOMAXPROCSMinusN, ev, err)
+			}
+			gmp = int(math.Floor(float64(runtime.NumCPU()) * (1 - p/100)))
+		} else {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse integer from %v value %q: %v", config.EnvVarGoplsGOMAXPROCSMinusN, ev, err)
+			}
+			gmp = runtime.NumCPU() - n
+		}
+		if gmp < 0 || gmp > runtime.NumCPU() {
+			return nil, fmt.Errorf("%v value %q results in GOMAXPROCS value %v which is invalid", config.EnvVarGoplsGOMAXPROCSMinusN, ev, gmp)
+		}
+		g.Logf("Starting gopls with GOMAXPROCS=%v", gmp)
+		gopls.Env = append(gopls.Env, "GOMAXPROCS="+strconv.Itoa(gmp))
+	}
+	return gopls, nil
+}
+
+// goplsExitedUnexpectedly schedules the handling of the exit of the gopls
+// instance identified by stopped, unless that instance was deliberately
+// stopped or govim is shutting down.
+func (g *govimplugin) goplsExitedUnexpectedly(stopped chan struct{}, err error) {
+	// govim can stop without the plugin being shut down, e.g. when the
+	// channel to Vim is closed
+	defer absorbShutdownErr()
+	select {
+	case <-g.inShutdown:
+	case <-stopped:
+	default:
+		g.Schedule(func(govim.Govim) error {
+			return g.vimstate.handleGoplsCrash(stopped, err)
+		})
+	}
+}
@@ -13,6 +13,8 @@ import (
 	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
 	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/settings"
 	"github.com/govim/govim/cmd/govim/internal/types"
+	"github.com/govim/govim/internal/logging"
+	"github.com/govim/govim/vimfn"
 	"github.com/kr/pretty"
 )
 
@@ -45,33 +47,38 @@ func (g *govimplugin) ShowDocument(context.Context, *protocol.ShowDocumentParams
 
 func (g *govimplugin) ShowMessage(ctxt context.Context, params *protocol.ShowMessageParams) error {
 	defer absorbShutdownErr()
-	g.logGoplsClientf("ShowMessage callback: %v", pretty.Sprint(params))
+	g.logGoplsClientf("ShowMessage callback: %v", logging.Pretty(params))
+	g.showMessage(params.Type, params.Message)
+	return nil
+}
 
+// showMessage shows msg in a popup if it is an error or warning
+func (g *govimplugin) showMessage(typ protocol.MessageType, msg string) {
 	var hl string
-	switch params.Type {
+	switch typ {
 	case protocol.Error:
 		hl = "ErrorMsg"
 	case protocol.Warning:
 		hl = "WarningMsg"
 	default:
-		return nil
+		return
 	}
 
-	g.Schedule(func(govim.Govim) error {
-		opts := make(map[string]interface{})
-		opts["mousemoved"] = "any"
-		opts["moved"] = "any"
-		opts["padding"] = []int{0, 1, 0, 1}
-		opts["wrap"] = true
-		opts["border"] = []int{}
-		opts["highlight"] = hl
-		opts["line"] = 1
-		opts["close"] = "click"
-
-		g.ChannelCall("popup_create", strings.Split(params.Message, "\n"), opts)
-		return nil
+	g.Schedule(func(vim govim.Govim) error {
+		wrap := true
+		opts := vimfn.PopupOptions{
+			MouseMoved: "any",
+			Moved:      "any",
+			Padding:    []int{0, 1, 0, 1},
+			Wrap:       &wrap,
+			Border:     []int{},
+			Highlight:  hl,
+			Line:       1,
+			Close:      "click",
+		}
+		_, err := vimfn.New(vim).PopupCreate(strings.Split(msg, "\n"), opts)
+		return err
 	})
-	return nil
 }
 
 func (g *govimplugin) ShowMessageRequest(context.Context, *protocol.ShowMessageRequestParams) (*protocol.MessageActionItem, error) {
@@ -81,7 +88,7 @@ func (g *govimplugin) ShowMessageRequest(context.Context, *protocol.ShowMessageR
 
 func (g *govimplugin) LogMessage(ctxt context.Context, params *protocol.LogMessageParams) error {
 	defer absorbShutdownErr()
-	g.logGoplsClientf("LogMessage callback: %v", pretty.Sprint(params))
+	g.logGoplsClientf("LogMessage callback: %v", logging.Pretty(params))
 	return nil
 }
 
@@ -102,7 +109,8 @@ func (g *govimplugin) RegisterCapability(ctxt context.Context, params *protocol.
 		case "workspace/didChangeConfiguration":
 			// For now ignore per github.com/govim/govim/issues/949
 		case "workspace/didChangeWorkspaceFolders":
-			// For now ignore per github.com/govim/govim/issues/172
+			// We notify gopls of workspace folder changes regardless of this
+			// registration, because gopls always supports them
 		case "workspace/didChangeWatchedFiles":
 			// For now ignore per github.com/govim/govim/issues/950
 		case "textDocument/semanticTokens":
@@ -111,7 +119,7 @@ func (g *govimplugin) RegisterCapability(ctxt context.Context, params *protocol.
 			panic(fmt.Errorf("RegisterCapability called with unknown method: %v", r.Method))
 		}
 	}
-	g.logGoplsClientf("RegisterCapability: %v", pretty.Sprint(params))
+	g.logGoplsClientf("RegisterCapability: %v", logging.Pretty(params))
 	return nil
 }
 
@@ -129,19 +137,23 @@ func (g *govimplugin) UnregisterCapability(ctxt context.Context, params *protoco
 			panic(fmt.Errorf("UnregisterCapability called with unknown method: %v", pretty.Sprint(params)))
 		}
 	}
-	g.logGoplsClientf("UnregisterCapability: %v", pretty.Sprint(params))
+	g.logGoplsClientf("UnregisterCapability: %v", logging.Pretty(params))
 	return nil
 }
 
 func (g *govimplugin) WorkspaceFolders(context.Context) ([]protocol.WorkspaceFolder, error) {
 	defer absorbShutdownErr()
-	panic("WorkspaceFolders not implemented yet")
+	g.workspaceFoldersLock.Lock()
+	defer g.workspaceFoldersLock.Unlock()
+	res := append([]protocol.WorkspaceFolder(nil), g.workspaceFolders...)
+	g.logGoplsClientf("WorkspaceFolders: %v", logging.Pretty(res))
+	return res, nil
 }
 
 func (g *govimplugin) Configuration(ctxt context.Context, params *protocol.ParamConfiguration) ([]interface{}, error) {
 	defer absorbShutdownErr()
 
-	g.logGoplsClientf("Configuration: %v", pretty.Sprint(params))
+	g.logGoplsClientf("Configuration: %v", logging.Pretty(params))
 
 	g.vimstate.configLock.Lock()
 	conf := g.vimstate.config
@@ -187,7 +199,24 @@ func (g *govimplugin) Configuration(ctxt context.Context, params *protocol.Param
 	goplsConfig[goplsCodeLenses] = map[string]bool{
 		string(settings.CodeLensGCDetails): true, // gc_details
 	}
-	if conf.GoplsEnv != nil {
+	if conf.GoplsRemote != nil && *conf.GoplsRemote != "" {
+		// A gopls daemon runs with the environment of whichever client
+		// started it. Isolate this client from others by passing the Go
+		// environment that gopls would otherwise have inherited from us.
+		env := make(map[string]string)
+		for _, kv := range g.goplsEnv {
+			k, v, ok := strings.Cut(kv, "=")
+			if ok && (strings.HasPrefix(k, "GO") || strings.HasPrefix(k, "CGO_")) {
+				env[k] = v
+			}
+		}
+		if conf.GoplsEnv != nil {
+			for k, v := range *conf.GoplsEnv {
+				env[k] = v
+			}
+		}
+		goplsConfig[goplsEnv] = env
+	} else if conf.GoplsEnv != nil {
 		// It is safe not to copy the map here because a new config setting from
 		// Vim creates a new map.
 		goplsConfig[goplsEnv] = *conf.GoplsEnv
@@ -197,13 +226,13 @@ func (g *govimplugin) Configuration(ctxt context.Context, params *protocol.Param
 	}
 	res[0] = goplsConfig
 
-	g.logGoplsClientf("Configuration response: %v", pretty.Sprint(res))
+	g.logGoplsClientf("Configuration response: %v", logging.Pretty(res))
 	return res, nil
 }
 
 func (g *govimplugin) ApplyEdit(ctxt context.Context, params *protocol.ApplyWorkspaceEditParams) (*protocol.ApplyWorkspaceEditResult, error) {
 	defer absorbShutdownErr()
-	g.logGoplsClientf("ApplyEdit: %v", pretty.Sprint(params))
+	g.logGoplsClientf("ApplyEdit: %v", logging.Pretty(params))
 
 	var err error
 	var res *protocol.ApplyWorkspaceEditResult
@@ -230,7 +259,7 @@ func (g *govimplugin) ApplyEdit(ctxt context.Context, params *protocol.ApplyWork
 		err = aer.err
 	}
 
-	g.logGoplsClientf("ApplyEdit response: %v", pretty.Sprint(res))
+	g.logGoplsClientf("ApplyEdit response: %v", logging.Pretty(res))
 	return res, err
 }
 
@@ -241,7 +270,7 @@ func (g *govimplugin) Event(context.Context, *interface{}) error {
 
 func (g *govimplugin) PublishDiagnostics(ctxt context.Context, params *protocol.PublishDiagnosticsParams) error {
 	defer absorbShutdownErr()
-	g.logGoplsClientf("PublishDiagnostics callback: %v", pretty.Sprint(params))
+	g.logGoplsClientf("PublishDiagnostics callback: %v", logging.Pretty(params))
 	g.diagnosticsChangedLock.Lock()
 	uri := params.URI
 	curr, ok := g.rawDiagnostics[uri]
@@ -270,14 +299,7 @@ func (g *govimplugin) PublishDiagnostics(ctxt context.Context, params *protocol.
 
 func (g *govimplugin) Progress(ctxt context.Context, params *protocol.ProgressParams) error {
 	defer absorbShutdownErr()
-	g.logGoplsClientf("Progress callback: %v", pretty.Sprint(params))
-
-	g.vimstate.configLock.Lock()
-	if c := g.vimstate.config.ExperimentalProgressPopups; c == nil || !*c {
-		g.vimstate.configLock.Unlock()
-		return nil
-	}
-	g.vimstate.configLock.Unlock()
+	g.logGoplsClientf("Progress callback: %v", logging.Pretty(params))
 
 	var ok bool
 	var raw map[string]interface{}
@@ -296,8 +318,18 @@ func (g *govimplugin) Progress(ctxt context.Context, params *protocol.ProgressPa
 		return fmt.Errorf("expected required field 'title' not set")
 	}
 
+	// Progress is tracked for the statusline regardless of whether progress
+	// popups are enabled
+	g.vimstate.configLock.Lock()
+	popups := g.vimstate.config.ExperimentalProgressPopups != nil && *g.vimstate.config.ExperimentalProgressPopups
+	g.vimstate.configLock.Unlock()
+
 	g.Schedule(func(govim.Govim) error {
 		v := g.vimstate
+		v.trackProgress(params.Token, kind, title)
+		if !popups {
+			return nil
+		}
 
 		popup, ok := v.progressPopups[params.Token]
 		if !ok {
@@ -311,7 +343,7 @@ func (g *govimplugin) Progress(ctxt context.Context, params *protocol.ProgressPa
 
 func (g *govimplugin) WorkDoneProgressCreate(ctxt context.Context, params *protocol.WorkDoneProgressCreateParams) error {
 	defer absorbShutdownErr()
-	g.logGoplsClientf("WorkDoneProgressCreate callback: %v", pretty.Sprint(params))
+	g.logGoplsClientf("WorkDoneProgressCreate callback: %v", logging.Pretty(params))
 
 	g.vimstate.configLock.Lock()
 	if c := g.vimstate.config.ExperimentalProgressPopups; c == nil || !*c {
@@ -368,8 +400,5 @@ func absorbShutdownErr() {
 }
 
 func (g *govimplugin) logGoplsClientf(format string, args ...interface{}) {
-	if format[len(format)-1] != '\n' {
-		format = format + "\n"
-	}
-	g.Logf("gopls client start =======================\n"+format+"gopls client end =======================\n", args...)
+	g.logger.Debugf(logging.GoplsClient, format, args...)
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rogpeppe/go-internal/lockedfile"
+)
+
+const (
+	// goplsRemoteAuto is the GoplsRemote value that tells govim to connect to
+	// (and if needs be start) a gopls daemon specific to the gopls binary in
+	// use
+	goplsRemoteAuto = "auto"
+
+	// goplsDialTimeout is the timeout for a single attempt to connect to a
+	// gopls daemon
+	goplsDialTimeout = 5 * time.Second
+
+	// goplsDaemonStartTimeout is the time we wait for a gopls daemon that we
+	// started to accept connections
+	goplsDaemonStartTimeout = 10 * time.Second
+
+	// goplsDaemonIdleTimeout is the time after which a gopls daemon started
+	// by govim shuts down when it has no connected clients
+	goplsDaemonIdleTimeout = time.Minute
+)
+
+// dialGoplsRemote connects to the gopls daemon identified by remote, the
+// value of GoplsRemote. In the case of goplsRemoteAuto, a daemon is started
+// if one is not already running.
+func (g *govimplugin) dialGoplsRemote(remote string) (net.Conn, error) {
+	network, addr := parseGoplsRemote(remote)
+	if remote == goplsRemoteAuto {
+		addr = goplsDaemonAddr(g.tmpDir, g.goplspath)
+	}
+	g.Logf("connecting to gopls daemon at %v;%v", network, addr)
+	c, err := net.DialTimeout(network, addr, goplsDialTimeout)
+	if err == nil {
+		return c, nil
+	}
+	if remote != goplsRemoteAuto {
+		return nil, fmt.Errorf("failed to connect to gopls daemon at %v;%v: %v", network, addr, err)
+	}
+
+	// Serialise the start of the daemon between govim instances, lest two
+	// instances that fail to connect at the same time both remove the socket
+	// and start a daemon.
+	unlock, err := lockedfile.MutexAt(addr + ".lock").Lock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock gopls daemon start: %v", err)
+	}
+	defer unlock()
+
+	// Another instance might have started the daemon whilst we waited for the
+	// lock.
+	c, err = net.DialTimeout(network, addr, goplsDialTimeout)
+	if err == nil {
+		return c, nil
+	}
+
+	// Nothing is listening on the socket, hence any file that exists at addr
+	// is left over from a daemon that died.
+	os.Remove(addr)
+	if err := g.startGoplsDaemon(network, addr); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(goplsDaemonStartTimeout)
+	for {
+		c, err := net.DialTimeout(network, addr, goplsDialTimeout)
+		if err == nil {
+			return c, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("failed to connect to gopls daemon at %v;%v within %v: %v", network, addr, goplsDaemonStartTimeout, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// startGoplsDaemon starts a gopls daemon listening on addr. The daemon is not
+// tied to this govim instance: it keeps running for as long as it has
+// clients, and exits goplsDaemonIdleTimeout after the last client
+// disconnects.
+func (g *govimplugin) startGoplsDaemon(network, addr string) error {
+	logfile := os.DevNull
+	if g.logging["on"] {
+		f, err := g.createLogFile("gopls_daemon")
+		if err != nil {
+			return err
+		}
+		f.Close()
+		logfile = f.Name()
+		g.Logf("gopls daemon log file: %v", logfile)
+	}
+	cmd, err := g.goplsCommand("-logfile", logfile)
+	if err != nil {
+		return err
+	}
+	cmd.Args = append(cmd.Args, "serve",
+		"-listen", network+";"+addr,
+		"-listen.timeout", goplsDaemonIdleTimeout.String(),
+	)
+	g.Logf("Running gopls daemon: %v", strings.Join(cmd.Args, " "))
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start gopls daemon: %v", err)
+	}
+	// Reap the daemon should it exit before we do
+	go cmd.Wait()
+	return nil
+}
+
+// parseGoplsRemote splits a GoplsRemote value of the form "network;address"
+// into its network and address. An address without a network is assumed to
+// be a TCP address. goplsRemoteAuto is a unix socket the address of which is
+// determined by goplsDaemonAddr.
+func parseGoplsRemote(remote string) (network, addr string) {
+	if remote == goplsRemoteAuto {
+		return "unix", ""
+	}
+	if i := strings.Index(remote, ";"); i >= 0 {
+		return remote[:i], remote[i+1:]
+	}
+	return "tcp", remote
+}
+
+// goplsDaemonAddr returns the unix socket path of the daemon for goplspath,
+// such that govim instances using different gopls binaries do not share a
+// daemon.
+func goplsDaemonAddr(tmpDir, goplspath string) string {
+	h := sha256.Sum256([]byte(goplspath))
+	return filepath.Join(tmpDir, fmt.Sprintf("govim-gopls-daemon-%d-%x", os.Getuid(), h[:4]))
+}
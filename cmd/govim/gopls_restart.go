@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/govim/govim"
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
+)
+
+const (
+	// goplsStopTimeout is the time we wait for gopls to respond to Shutdown
+	// and Exit, and then to exit, before the process is killed. The same
+	// applies to other language servers. s:shutdownTimeout in
+	// plugin/govim.vim must exceed twice this.
+	goplsStopTimeout = 2 * time.Second
+
+	// goplsMaxCrashes is the number of consecutive crashes after which govim
+	// gives up restarting gopls
+	goplsMaxCrashes = 5
+
+	// goplsCrashBackoff is the delay before gopls is restarted after its
+	// first crash. The delay doubles with each consecutive crash.
+	goplsCrashBackoff = 500 * time.Millisecond
+
+	// goplsCrashResetAfter is the time that a gopls instance needs to have
+	// been running for before a crash is no longer considered consecutive to
+	// any previous crash
+	goplsCrashResetAfter = time.Minute
+)
+
+func (v *vimstate) restartGoplsCmd(flags govim.CommandFlags, args ...string) error {
+	v.goplsCrashes = 0
+	if err := v.restartGopls(); err != nil {
+		return err
+	}
+	v.ChannelEx(`echom "gopls restarted"`)
+	return nil
+}
+
+// handleGoplsCrash is called when the gopls instance identified by stopped
+// exits without having been stopped by govim. gopls is restarted after a
+// delay that increases with the number of consecutive crashes. Once
+// goplsMaxCrashes is reached, err is returned to govim which ends the
+// session.
+func (v *vimstate) handleGoplsCrash(stopped chan struct{}, err error) error {
+	if v.goplsStopped != stopped {
+		// A newer instance has been started in the meantime
+		return nil
+	}
+	if time.Since(v.goplsStarted) > goplsCrashResetAfter {
+		v.goplsCrashes = 0
+	}
+	v.goplsCrashes++
+	if v.goplsCrashes > goplsMaxCrashes {
+		v.errCh <- fmt.Errorf("gopls crashed %v times in a row; giving up: %v", goplsMaxCrashes, err)
+		return nil
+	}
+	delay := goplsCrashBackoff << (v.goplsCrashes - 1)
+	v.Logf("%v; restarting gopls in %v (attempt %v of %v)", err, delay, v.goplsCrashes, goplsMaxCrashes)
+	v.ChannelExf("echohl WarningMsg | echom %q | echohl None", fmt.Sprintf("gopls crashed; restarting in %v", delay))
+	// The timer fires outside of the event queue, so the work is scheduled
+	// via the plugin's own govim.Govim rather than that of vimstate, which
+	// is the event queue itself
+	time.AfterFunc(delay, func() {
+		defer absorbShutdownErr()
+		v.govimplugin.Schedule(func(govim.Govim) error {
+			if v.goplsStopped != stopped {
+				return nil
+			}
+			return v.restartGopls()
+		})
+	})
+	return nil
+}
+
+// restartGopls stops the running gopls instance, if it is still running, and
+// starts a new one. The new instance is brought up to date with the editing
+// session: all loaded buffers are opened and the current config is restored
+// (the latter via the Initialize call and the Configuration callback).
+// Diagnostics and progress from the previous instance are discarded.
+func (v *vimstate) restartGopls() error {
+	v.stopGopls()
+
+	v.diagnosticsChangedLock.Lock()
+	v.rawDiagnostics = make(map[protocol.DocumentURI]*protocol.PublishDiagnosticsParams)
+	v.diagnosticsChanged = true
+	v.diagnosticsChangedLock.Unlock()
+	v.activeProgress = make(map[protocol.ProgressToken]string)
+
+	if err := v.launchGopls(v.Govim); err != nil {
+		return fmt.Errorf("failed to restart gopls: %v", err)
+	}
+
+	var bufnrs []int
+	for bufnr, b := range v.buffers {
+		if b.Loaded && goplsHandles(b.Name) {
+			bufnrs = append(bufnrs, bufnr)
+		}
+	}
+	sort.Ints(bufnrs)
+	for _, bufnr := range bufnrs {
+		b := v.buffers[bufnr]
+		params := &protocol.DidOpenTextDocumentParams{
+			TextDocument: protocol.TextDocumentItem{
+				LanguageID: protocol.LanguageKind(detectLanguage(filename(b.URI())).String()),
+				URI:        protocol.DocumentURI(b.URI()),
+				Version:    b.Version,
+				Text:       string(b.Contents()),
+			},
+		}
+		if err := v.server.DidOpen(context.Background(), params); err != nil {
+			return fmt.Errorf("failed to reopen buffer %v in gopls: %v", bufnr, err)
+		}
+	}
+	return v.handleDiagnosticsChanged()
+}
+
+// stopGopls stops the running gopls instance. If the instance is still
+// running it is asked to Shutdown and Exit. The process is killed if it does
+// not exit within goplsStopTimeout. In the case of a gopls daemon, only our
+// session is shut down and the connection closed. stopGopls does nothing if
+// there is no gopls instance, for example because a restart failed to launch
+// one.
+func (g *govimplugin) stopGopls() {
+	if g.goplsStopped == nil {
+		return
+	}
+	close(g.goplsStopped)
+	g.goplsStopped = nil
+	select {
+	case <-g.goplsExited:
+	default:
+		ctxt, cancel := context.WithTimeout(context.Background(), goplsStopTimeout)
+		if err := g.server.Shutdown(ctxt); err != nil {
+			g.Logf("failed to call gopls Shutdown: %v", err)
+		} else if g.gopls != nil {
+			// A gopls daemon ends our session when we close the connection;
+			// Exit is only sent to a gopls process of our own
+			if err := g.server.Exit(ctxt); err != nil {
+				g.Logf("failed to call gopls Exit: %v", err)
+			}
+		}
+		cancel()
+	}
+	if err := g.goplsStdin.Close(); err != nil {
+		g.Logf("failed to close gopls stdin: %v", err)
+	}
+	select {
+	case <-g.goplsExited:
+	case <-time.After(goplsStopTimeout):
+		if g.gopls == nil {
+			break
+		}
+		g.Logf("gopls did not exit within %v; killing it", goplsStopTimeout)
+		if err := g.gopls.Kill(); err != nil {
+			g.Logf("failed to kill gopls: %v", err)
+		}
+	}
+	g.goplsConn.Close()
+	g.goplsCancel()
+}
@@ -31,7 +31,7 @@ type propAddDict struct {
 // assertPropAdd is used when we add text properties that might fail due to the fact
 // that the buffer might have changed since the text properties was calculated.
 // There are two vim errors that we like to suppress, invalid line and invalid column.
-var assertPropAdd AssertExpr = AssertIsErrorOrNil(
+var assertPropAdd govim.AssertExpr = govim.AssertIsErrorOrNil(
 	"^Vim(let):E964:", // Invalid column (col) passed to vim prop_add()
 	"^Vim(let):E966:") // Invalid line (lnum) passed to vim prop_add()
 
@@ -53,6 +53,12 @@ func (v *vimstate) textpropDefine() error {
 			Combine:   true, // Combine with syntax highlight
 			Priority:  types.SeverityPriority[s],
 		})
+
+		hi = types.SeverityVirtualTextHighlight[s]
+		v.BatchChannelCall("prop_type_add", hi, propDict{
+			Highlight: string(hi),
+			Priority:  types.SeverityPriority[s],
+		})
 	}
 
 	v.BatchChannelCall("prop_type_add", config.HighlightHoverDiagSrc, propDict{
@@ -28,14 +28,18 @@ func (v *vimstate) hover(args ...json.RawMessage) (interface{}, error) {
 	return v.showHover(posExpr, opts, v.config.ExperimentalCursorTriggeredHoverPopupOptions)
 }
 
-func (v *vimstate) hoverMsgAt(pos types.Point, tdi protocol.TextDocumentIdentifier) (string, error) {
+func (v *vimstate) hoverMsgAt(pos types.Point, b *types.Buffer) (string, error) {
 	params := &protocol.HoverParams{
 		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
-			TextDocument: tdi,
+			TextDocument: b.ToTextDocumentIdentifier(),
 			Position:     pos.ToPosition(),
 		},
 	}
-	hovRes, err := v.server.Hover(context.Background(), params)
+	server := v.hoverServer(b)
+	if server == nil {
+		return "", nil
+	}
+	hovRes, err := server.Hover(context.Background(), params)
 	if err != nil {
 		return "", fmt.Errorf("failed to get hover details: %v", err)
 	}
@@ -103,7 +107,7 @@ func (v *vimstate) showHover(posExpr string, opts map[string]interface{}, userOp
 			}
 		}
 	}
-	msg, err := v.hoverMsgAt(pos, b.ToTextDocumentIdentifier())
+	msg, err := v.hoverMsgAt(pos, b)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools/jsonrpc2"
+	"github.com/govim/govim/internal/logging"
+	"github.com/govim/govim/internal/traffic"
+)
+
+// inspectorSlow is the latency at or above which a response is marked as slow
+// by the inspector
+const inspectorSlow = 100 * time.Millisecond
+
+// tracingConn is a jsonrpc2.Conn that records the calls and notifications
+// made by govim to gopls. The responses to the calls from gopls are recorded
+// along with their latency.
+type tracingConn struct {
+	jsonrpc2.Conn
+	traffic *traffic.Recorder
+}
+
+func (t tracingConn) Call(ctx context.Context, method string, params, result interface{}) (jsonrpc2.ID, error) {
+	id := t.traffic.Request(logging.GoplsServer, traffic.Send, "", method, params)
+	res, err := t.Conn.Call(ctx, method, params, result)
+	t.traffic.Response(logging.GoplsServer, traffic.Recv, id, err, result)
+	return res, err
+}
+
+func (t tracingConn) Notify(ctx context.Context, method string, params interface{}) error {
+	t.traffic.Notification(logging.GoplsServer, traffic.Send, method, params)
+	return t.Conn.Notify(ctx, method, params)
+}
+
+// tracingHandler returns a jsonrpc2.Handler that records the calls and
+// notifications made by gopls to govim, and govim's responses to the calls,
+// before passing them to h.
+func tracingHandler(t *traffic.Recorder, h jsonrpc2.Handler) jsonrpc2.Handler {
+	return func(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+		call, ok := req.(*jsonrpc2.Call)
+		if !ok {
+			t.Notification(logging.GoplsClient, traffic.Recv, req.Method(), req.Params())
+			return h(ctx, reply, req)
+		}
+		id := fmt.Sprint(call.ID())
+		t.Request(logging.GoplsClient, traffic.Recv, id, req.Method(), req.Params())
+		return h(ctx, func(ctx context.Context, result interface{}, err error) error {
+			t.Response(logging.GoplsClient, traffic.Send, id, err, result)
+			return reply(ctx, result, err)
+		}, req)
+	}
+}
+
+// inspectorHeader is the header of the table of entries shown by the
+// inspector
+var inspectorHeader = formatInspectorRow(" ", "SEQ", "TIME", "SUBSYSTEM", "", "KIND", "ID", "METHOD", "LATENCY", "SUMMARY")
+
+func formatInspectorRow(slow, seq, time, sub, dir, kind, id, method, latency, summary string) string {
+	return fmt.Sprintf("%s%-6s %-12s %-12s %-2s %-12s %-6s %-40s %9s  %s", slow, seq, time, sub, dir, kind, id, method, latency, summary)
+}
+
+func formatInspectorEntry(e traffic.Entry) string {
+	slow := " "
+	var latency string
+	if e.Kind == traffic.Response && e.Method != "" {
+		latency = e.Latency.Round(10 * time.Microsecond).String()
+		if e.Latency >= inspectorSlow {
+			slow = "!"
+		}
+	}
+	summary := e.Summary
+	if e.Err != "" {
+		summary = "error: " + e.Err
+	}
+	summary = strings.Replace(summary, "\n", " ", -1)
+	return formatInspectorRow(slow, fmt.Sprint(e.Seq), e.Time.Format("15:04:05.000"), string(e.Subsystem), e.Direction.String(), e.Kind.String(), e.ID, e.Method, latency, summary)
+}
+
+// inspectorStart is the implementation of FunctionInspectorStart. Its argument
+// is the regular expression that the rows of the inspector must match, which
+// is validated before recording starts. It returns the header row. Traffic is
+// only recorded whilst at least one inspector is open.
+func (v *vimstate) inspectorStart(args ...json.RawMessage) (interface{}, error) {
+	if _, err := regexp.Compile(v.ParseString(args[0])); err != nil {
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+	v.traffic.Start()
+	return inspectorHeader, nil
+}
+
+// inspectorStop is the implementation of FunctionInspectorStop
+func (v *vimstate) inspectorStop(args ...json.RawMessage) (interface{}, error) {
+	v.traffic.Stop()
+	return nil, nil
+}
+
+// inspectorEntries is the implementation of FunctionInspectorEntries. Its
+// arguments are the sequence number of the last entry already shown, and a
+// regular expression that rows must match. It returns a dict with the
+// sequence number of the last entry returned and the new rows.
+func (v *vimstate) inspectorEntries(args ...json.RawMessage) (interface{}, error) {
+	var since uint64
+	v.Parse(args[0], &since)
+	re, err := regexp.Compile(v.ParseString(args[1]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %v", err)
+	}
+	lines := []string{}
+	for _, e := range v.traffic.Entries(since) {
+		since = e.Seq
+		if row := formatInspectorEntry(e); re.MatchString(row) {
+			lines = append(lines, row)
+		}
+	}
+	res := struct {
+		Seq   uint64   `json:"seq"`
+		Lines []string `json:"lines"`
+	}{
+		Seq:   since,
+		Lines: lines,
+	}
+	return res, nil
+}
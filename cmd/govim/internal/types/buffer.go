@@ -6,11 +6,13 @@ import (
 	"go/ast"
 	"go/token"
 
+	"github.com/govim/govim/bufsync"
 	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
 )
 
 // A Buffer is govim's representation of the current state of a buffer in Vim
-// i.e. it is versioned.
+// i.e. it is versioned. The contents and version of a buffer that is open in
+// Vim are those of the latest version of the buffer synced by bufsync.
 //
 // TODO: we need to reflect somehow whether a buffer is file-based or not. A
 // preview window is not, for example.
@@ -20,32 +22,15 @@ type Buffer struct {
 	contents []byte
 	Version  int32
 
-	// Listener is the ID of the listener for the buffer. Listeners number from
-	// 1 so the zero value indicates this buffer does not have a listener.
-	Listener int
-
 	// Loaded reflects vim's "loaded" buffer state. See :help bufloaded() for details.
 	Loaded bool
 
-	// AST is the parsed result of the Buffer. Buffer events (i.e. changes to
-	// the buffer contents) trigger an asynchronous re-parse of the buffer.
-	// These events are triggered from the *vimstate thread. Any subsequent
-	// (subsequent to the buffer event) attempt to use the current AST (which by
-	// definition must be on the *vimstate thread) must wait for the
-	// asnychronous parse to complete. This is achieved by the ASTWait channel
-	// which is closed when parsing completes. Access to AST and Fset must
-	// therefore be guarded by a receive on ASTWait.
-
-	// Fset is the fileset used in parsing the buffer contents. Access to Fset
-	// must be guarded by a receive on ASTWait.
-	Fset *token.FileSet
+	// Filetype is the buffer's &filetype
+	Filetype string
 
-	// AST is the parsed result of the Buffer. Access to Fset must be guarded by
-	// a receive on ASTWait.
-	AST *ast.File
-
-	// ASTWait is used to sychronise access to AST and Fset.
-	ASTWait chan bool
+	// synced is the latest version of the buffer as synced by bufsync, or nil
+	// if the buffer is not open in Vim
+	synced *bufsync.Buffer
 
 	// pm is lazily set whenever position information is required
 	pm *protocol.Mapper
@@ -61,17 +46,37 @@ func NewBuffer(num int, name string, contents []byte, loaded bool) *Buffer {
 }
 
 // Contents returns a Buffer's contents. These contents must not be
-// mutated. To update a Buffer's contents, call SetContents
+// mutated.
 func (b *Buffer) Contents() []byte {
 	return b.contents
 }
 
-// SetContents updates a Buffer's contents to byts
-func (b *Buffer) SetContents(byts []byte) {
-	b.contents = byts
+// Sync updates b to sb, the latest version of the buffer
+func (b *Buffer) Sync(sb *bufsync.Buffer) {
+	b.synced = sb
+	b.contents = sb.Contents()
+	b.Version = int32(sb.Version)
 	b.pm = nil
 }
 
+// AST returns the result of parsing the contents of b, waiting for the
+// parse, which runs in the background, to complete. As per go/parser, the
+// file may be partial if the contents do not parse.
+func (b *Buffer) AST() (*token.FileSet, *ast.File, error) {
+	if b.synced == nil {
+		return nil, nil, fmt.Errorf("buffer %v is not synced", b.Name)
+	}
+	res, err := b.synced.Parsed()
+	f, ok := res.(*bufsync.GoFile)
+	if !ok || f.File == nil {
+		if err == nil {
+			err = fmt.Errorf("buffer %v is not a Go file", b.Name)
+		}
+		return nil, nil, fmt.Errorf("failed to parse buffer %v: %v", b.Name, err)
+	}
+	return f.Fset, f.File, nil
+}
+
 // URI returns the b's Name as a protocol.DocumentURI, assuming it is a file.
 //
 // TODO: we should panic here is this is not a file-based buffer
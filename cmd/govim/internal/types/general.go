@@ -82,6 +82,14 @@ var SeverityHoverHighlight = map[Severity]config.Highlight{
 	SeverityHint: config.HighlightHoverHint,
 }
 
+// SeverityVirtualTextHighlight returns corresponding virtual text highlight name for a severity.
+var SeverityVirtualTextHighlight = map[Severity]config.Highlight{
+	SeverityErr:  config.HighlightVirtualTextErr,
+	SeverityWarn: config.HighlightVirtualTextWarn,
+	SeverityInfo: config.HighlightVirtualTextInfo,
+	SeverityHint: config.HighlightVirtualTextHint,
+}
+
 // TextPropID is the govim internal mapping of ID used when adding/removing text properties
 type TextPropID int
 
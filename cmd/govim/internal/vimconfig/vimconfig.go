@@ -32,6 +32,9 @@ type VimConfig struct {
 	ExperimentalWorkaroundCompleteoptLongest     *int
 	ExperimentalProgressPopups                   *int
 	ExperimentalAllowModfileModifications        *int
+	ExperimentalVirtualTextDiagnostics           *int
+	GoplsRemote                                  *string
+	LanguageServers                              *map[string]config.LanguageServer
 }
 
 func (c *VimConfig) ToConfig(d config.Config) config.Config {
@@ -61,6 +64,9 @@ func (c *VimConfig) ToConfig(d config.Config) config.Config {
 		ExperimentalWorkaroundCompleteoptLongest:     boolVal(c.ExperimentalWorkaroundCompleteoptLongest, d.ExperimentalWorkaroundCompleteoptLongest),
 		ExperimentalProgressPopups:                   boolVal(c.ExperimentalProgressPopups, d.ExperimentalProgressPopups),
 		ExperimentalAllowModfileModifications:        boolVal(c.ExperimentalAllowModfileModifications, d.ExperimentalAllowModfileModifications),
+		ExperimentalVirtualTextDiagnostics:           boolVal(c.ExperimentalVirtualTextDiagnostics, d.ExperimentalVirtualTextDiagnostics),
+		GoplsRemote:                                  stringVal(c.GoplsRemote, d.GoplsRemote),
+		LanguageServers:                              copyLanguageServers(c.LanguageServers, d.LanguageServers),
 	}
 	if v.FormatOnSave == nil {
 		v.FormatOnSave = d.FormatOnSave
@@ -139,6 +145,21 @@ func mergeBoolValMap(i *map[string]int, j *map[string]bool) *map[string]bool {
 	return &res
 }
 
+func copyLanguageServers(i, j *map[string]config.LanguageServer) *map[string]config.LanguageServer {
+	toCopy := i
+	if i == nil {
+		toCopy = j
+		if j == nil {
+			return nil
+		}
+	}
+	res := make(map[string]config.LanguageServer)
+	for ck, cv := range *toCopy {
+		res[ck] = cv
+	}
+	return &res
+}
+
 func copyMap(i, j *map[string]interface{}) *map[string]interface{} {
 	toCopy := i
 	if i == nil {
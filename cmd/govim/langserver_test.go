@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools/fakenet"
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools/jsonrpc2"
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
+)
+
+// fakeLanguageServer is a minimal language server, speaking LSP via stdin and
+// stdout, for use in scenarios that test LanguageServers. It uses full sync,
+// reports progress once initialised, reports a warning diagnostic for every
+// line that contains "TODO", and provides a fixed hover message. With the -hanginit flag it never responds to
+// initialize, for testing that such a server does not block govim.
+func fakeLanguageServer() int {
+	hangInit := len(os.Args) > 1 && os.Args[1] == "-hanginit"
+	stream := jsonrpc2.NewHeaderStream(fakenet.NewConn("fakelangserver", os.Stdin, os.Stdout))
+	conn := jsonrpc2.NewConn(stream)
+	client := protocol.ClientDispatcher(conn)
+
+	publish := func(ctxt context.Context, uri protocol.DocumentURI, text string) error {
+		diags := []protocol.Diagnostic{}
+		for i, l := range strings.Split(text, "\n") {
+			c := strings.Index(l, "TODO")
+			if c < 0 {
+				continue
+			}
+			diags = append(diags, protocol.Diagnostic{
+				Range: protocol.Range{
+					Start: protocol.Position{Line: uint32(i), Character: uint32(c)},
+					End:   protocol.Position{Line: uint32(i), Character: uint32(c + len("TODO"))},
+				},
+				Severity: protocol.SeverityWarning,
+				Message:  "found a TODO",
+			})
+		}
+		return client.PublishDiagnostics(ctxt, &protocol.PublishDiagnosticsParams{
+			URI:         uri,
+			Diagnostics: diags,
+		})
+	}
+
+	handler := func(ctxt context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+		switch req.Method() {
+		case "initialize":
+			if hangInit {
+				return nil
+			}
+			return reply(ctxt, map[string]interface{}{
+				"capabilities": map[string]interface{}{
+					"textDocumentSync": protocol.Full,
+					"hoverProvider":    true,
+				},
+			}, nil)
+		case "initialized":
+			return client.Progress(ctxt, &protocol.ProgressParams{
+				Token: "fake",
+				Value: map[string]interface{}{
+					"kind":  "begin",
+					"title": "fake indexing",
+				},
+			})
+		case "textDocument/didOpen":
+			var params protocol.DidOpenTextDocumentParams
+			if err := json.Unmarshal(req.Params(), &params); err != nil {
+				return reply(ctxt, nil, err)
+			}
+			return publish(ctxt, params.TextDocument.URI, params.TextDocument.Text)
+		case "textDocument/didChange":
+			var params protocol.DidChangeTextDocumentParams
+			if err := json.Unmarshal(req.Params(), &params); err != nil {
+				return reply(ctxt, nil, err)
+			}
+			text := params.ContentChanges[len(params.ContentChanges)-1].Text
+			return publish(ctxt, params.TextDocument.URI, text)
+		case "textDocument/hover":
+			return reply(ctxt, &protocol.Hover{
+				Contents: protocol.MarkupContent{
+					Kind:  protocol.PlainText,
+					Value: "fake hover",
+				},
+			}, nil)
+		case "exit":
+			conn.Close()
+			return nil
+		}
+		return reply(ctxt, nil, nil)
+	}
+	conn.Go(context.Background(), handler)
+	<-conn.Done()
+	return 0
+}
@@ -0,0 +1,710 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/govim/govim"
+	"github.com/govim/govim/cmd/govim/config"
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools/fakenet"
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools/jsonrpc2"
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
+	"github.com/govim/govim/cmd/govim/internal/types"
+	"github.com/kr/pretty"
+)
+
+// goplsPatterns are the autocommand patterns of the buffers that are synced
+// with gopls
+var goplsPatterns = govim.Patterns{"*.go", "go.mod", "go.sum"}
+
+// languageServerInitTimeout is the time we wait for a language server to
+// respond to Initialize before giving up on it
+const languageServerInitTimeout = 10 * time.Second
+
+// languageServer is a language server, other than gopls, configured via
+// config.LanguageServers. It acts as the protocol.Client for its connection,
+// deferring to govimplugin for everything other than diagnostics,
+// configuration, registrations, progress and messages.
+type languageServer struct {
+	*govimplugin
+
+	name   string
+	config config.LanguageServer
+
+	server       protocol.Server
+	capabilities protocol.ServerCapabilities
+
+	process *os.Process
+	stdin   io.WriteCloser
+	conn    jsonrpc2.Conn
+	cancel  context.CancelFunc
+
+	// stopped is closed when govim stops the language server, and exited
+	// is closed when its process has exited
+	stopped chan struct{}
+	exited  chan struct{}
+}
+
+var _ protocol.Client = (*languageServer)(nil)
+
+func (l *languageServer) Logf(format string, args ...interface{}) {
+	l.govimplugin.Logf("language server %v: "+format, append([]interface{}{l.name}, args...)...)
+}
+
+// startLanguageServers starts the language servers configured in
+// LanguageServers, and tracks the loaded buffers with their filetypes. It is
+// called on the vimstate thread, both when govim starts and when
+// LanguageServers changes. The language servers are started concurrently,
+// off the vimstate thread, because each can take up to
+// languageServerInitTimeout to initialise. A language server is used, and
+// opens the buffers with its filetypes, once it has started. A language
+// server that fails to start is logged and disabled, rather than failing
+// govim as a whole.
+func (v *vimstate) startLanguageServers() error {
+	v.configLock.Lock()
+	var conf map[string]config.LanguageServer
+	if v.config.LanguageServers != nil {
+		conf = *v.config.LanguageServers
+	}
+	v.configLock.Unlock()
+
+	v.languageFiletypes = make(map[string]bool)
+	for _, c := range conf {
+		for _, ft := range c.Filetypes {
+			v.languageFiletypes[ft] = true
+		}
+	}
+	if err := v.trackLanguageBuffers(); err != nil {
+		return err
+	}
+
+	v.languageServersLock.Lock()
+	gen := v.languageServersGen
+	v.languageServersLock.Unlock()
+	for name, c := range conf {
+		name, c := name, c
+		v.tomb.Go(func() error {
+			defer absorbShutdownErr()
+			l, err := v.startLanguageServer(name, c)
+			if err != nil {
+				v.Logf("disabling language server %v: %v", name, err)
+				v.govimplugin.Schedule(func(govim.Govim) error {
+					v.ChannelExf("echohl WarningMsg | echom %q | echohl None", fmt.Sprintf("govim: disabled language server %v: %v", name, err))
+					return nil
+				})
+				return nil
+			}
+			v.useLanguageServer(gen, l)
+			return nil
+		})
+	}
+	return nil
+}
+
+// useLanguageServer schedules the use of l, which was started for generation
+// gen of the language servers, on the vimstate thread. l is stopped instead if
+// that generation has since been stopped, or if govim is shutting down.
+func (v *vimstate) useLanguageServer(gen int, l *languageServer) {
+	scheduled := false
+	defer func() {
+		if !scheduled {
+			l.stop()
+		}
+	}()
+	_, err := v.govimplugin.Schedule(func(govim.Govim) error {
+		v.languageServersLock.Lock()
+		stale := gen != v.languageServersGen
+		if !stale {
+			v.languageServers = append(v.languageServers, l)
+		}
+		v.languageServersLock.Unlock()
+		if stale {
+			v.tomb.Go(func() error {
+				l.stop()
+				return nil
+			})
+			return nil
+		}
+		for _, b := range v.sortedBuffers() {
+			if b.Loaded && l.matches(b) && l.openClose() {
+				l.didOpen(b)
+			}
+		}
+		return nil
+	})
+	scheduled = err == nil
+}
+
+// trackLanguageBuffers tracks the loaded buffers with the filetypes of the
+// configured language servers, and stops tracking those that were tracked
+// because of a filetype that no longer has a language server
+func (v *vimstate) trackLanguageBuffers() error {
+	for _, b := range v.sortedBuffers() {
+		if goplsHandles(b.Name) || v.languageFiletypes[b.Filetype] {
+			continue
+		}
+		if err := v.buffersSync.Untrack(v.Driver.Govim, b.Num); err != nil {
+			return err
+		}
+	}
+	var loaded []struct {
+		Num      int
+		Filetype string
+	}
+	v.Parse(v.ChannelExpr(`map(getbufinfo({"bufloaded": 1}), {_, b -> {"Num": b.bufnr, "Filetype": getbufvar(b.bufnr, "&filetype")}})`), &loaded)
+	for _, b := range loaded {
+		if !v.languageFiletypes[b.Filetype] {
+			continue
+		}
+		if err := v.buffersSync.Track(v.Driver.Govim, b.Num); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bufFileType handles the setting of the filetype of a buffer, which
+// determines the language servers that the buffer is synced with. A buffer
+// that gopls does not handle is tracked for as long as its filetype has a
+// language server.
+func (v *vimstate) bufFileType(args ...json.RawMessage) error {
+	bufnr := v.ParseInt(args[0])
+	ft := v.ParseString(args[1])
+	b, ok := v.buffers[bufnr]
+	if !ok {
+		if !v.languageFiletypes[ft] {
+			return nil
+		}
+		return v.buffersSync.Track(v.Driver.Govim, bufnr)
+	}
+	if b.Filetype == ft {
+		return nil
+	}
+	for _, l := range v.languageServers {
+		if l.matches(b) && l.openClose() {
+			l.didClose(b)
+		}
+	}
+	b.Filetype = ft
+	if !goplsHandles(b.Name) && !v.languageFiletypes[ft] {
+		return v.buffersSync.Untrack(v.Driver.Govim, bufnr)
+	}
+	for _, l := range v.languageServers {
+		if l.matches(b) && l.openClose() {
+			l.didOpen(b)
+		}
+	}
+	return nil
+}
+
+// languageBufWritePost handles BufWritePost for all buffers, passing those
+// tracked because of their filetype to bufWritePost. The buffers that gopls
+// handles have an autocommand of their own.
+func (v *vimstate) languageBufWritePost(args ...json.RawMessage) error {
+	b, ok := v.buffers[v.ParseInt(args[0])]
+	if !ok || goplsHandles(b.Name) {
+		return nil
+	}
+	return v.bufWritePost(args...)
+}
+
+// sortedBuffers returns the buffers in order of their numbers
+func (v *vimstate) sortedBuffers() []*types.Buffer {
+	var res []*types.Buffer
+	for _, b := range v.buffers {
+		res = append(res, b)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Num < res[j].Num
+	})
+	return res
+}
+
+func (g *govimplugin) startLanguageServer(name string, conf config.LanguageServer) (_ *languageServer, err error) {
+	l := &languageServer{
+		govimplugin: g,
+		name:        name,
+		config:      conf,
+		stopped:     make(chan struct{}),
+		exited:      make(chan struct{}),
+	}
+	cmd := exec.Command(conf.Command[0], conf.Command[1:]...)
+	cmd.Env = g.goplsEnv
+	l.Logf("running: %v", strings.Join(cmd.Args, " "))
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stderr pipe for language server %v: %v", name, err)
+	}
+	g.tomb.Go(func() error {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			l.Logf("stderr: %v", scanner.Text())
+		}
+		return nil
+	})
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe for language server %v: %v", name, err)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdin pipe for language server %v: %v", name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start language server %v: %v", name, err)
+	}
+	l.process = cmd.Process
+	l.stdin = stdin
+	defer func() {
+		// A language server that fails to initialise is of no use, so we
+		// do not leave it running
+		if err != nil {
+			l.kill()
+		}
+	}()
+	g.tomb.Go(func() error {
+		err := cmd.Wait()
+		close(l.exited)
+		select {
+		case <-g.inShutdown:
+		case <-l.stopped:
+		default:
+			// Unlike gopls, other language servers are not restarted, nor
+			// does their exit end the govim session
+			l.Logf("exited unexpectedly: %v", err)
+			g.Schedule(func(govim.Govim) error {
+				g.vimstate.ChannelExf("echohl WarningMsg | echom %q | echohl None", fmt.Sprintf("language server %v exited unexpectedly", name))
+				return nil
+			})
+		}
+		return nil
+	})
+
+	fakeconn := fakenet.NewConn(name, stdout, stdin)
+	stream := jsonrpc2.NewHeaderStream(fakeconn)
+	ctxt, cancel := context.WithCancel(context.Background())
+	conn := jsonrpc2.NewConn(stream)
+	handler := protocol.ClientHandler(l, jsonrpc2.MethodNotFound)
+	handler = protocol.Handlers(handler)
+	ctxt = protocol.WithClient(ctxt, l)
+	g.tomb.Go(func() error {
+		conn.Go(ctxt, handler)
+		<-conn.Done()
+		return nil
+	})
+	l.conn = conn
+	l.cancel = cancel
+	l.server = protocol.ServerDispatcher(conn)
+
+	initParams := &protocol.ParamInitialize{}
+	initParams.ProcessID = int32(os.Getpid())
+	g.workspaceFoldersLock.Lock()
+	initParams.WorkspaceFolders = append([]protocol.WorkspaceFolder(nil), g.workspaceFolders...)
+	g.workspaceFoldersLock.Unlock()
+	if len(initParams.WorkspaceFolders) > 0 {
+		initParams.RootURI = protocol.DocumentURI(initParams.WorkspaceFolders[0].URI)
+	}
+	initParams.Capabilities.TextDocument.Hover = &protocol.HoverClientCapabilities{
+		ContentFormat: []protocol.MarkupKind{protocol.PlainText},
+	}
+	initParams.Capabilities.Workspace.Configuration = true
+	initParams.Capabilities.Window.WorkDoneProgress = true
+	initParams.ClientInfo = &protocol.ClientInfo{
+		Name: "govim",
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		initParams.ClientInfo.Version = bi.Main.Version
+	}
+	initParams.InitializationOptions = conf.InitializationOptions
+
+	l.Logf("Initialize call; params:\n%v", pretty.Sprint(initParams))
+	initCtxt, initCancel := context.WithTimeout(context.Background(), languageServerInitTimeout)
+	defer initCancel()
+	res, err := l.server.Initialize(initCtxt, initParams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialise language server %v: %v", name, err)
+	}
+	l.Logf("Initialize return; res:\n%v", pretty.Sprint(res))
+	l.capabilities = res.Capabilities
+	if err := l.server.Initialized(context.Background(), &protocol.InitializedParams{}); err != nil {
+		return nil, fmt.Errorf("failed to call Initialized on language server %v: %v", name, err)
+	}
+	return l, nil
+}
+
+// takeLanguageServers returns the running language servers, which are no
+// longer used, and increments languageServersGen, such that the language
+// servers that are starting are stopped once started
+func (g *govimplugin) takeLanguageServers() []*languageServer {
+	g.languageServersLock.Lock()
+	defer g.languageServersLock.Unlock()
+	ls := g.languageServers
+	g.languageServers = nil
+	g.languageServersGen++
+	return ls
+}
+
+// stopLanguageServers stops ls concurrently, so that the time taken does not
+// depend on how many language servers are configured
+func stopLanguageServers(ls []*languageServer) {
+	var wg sync.WaitGroup
+	for _, l := range ls {
+		l := l
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.stop()
+		}()
+	}
+	wg.Wait()
+}
+
+func (l *languageServer) stop() {
+	close(l.stopped)
+	ctxt, cancel := context.WithTimeout(context.Background(), goplsStopTimeout)
+	defer cancel()
+	if err := l.server.Shutdown(ctxt); err != nil {
+		l.Logf("failed to call Shutdown: %v", err)
+	} else if err := l.server.Exit(ctxt); err != nil {
+		l.Logf("failed to call Exit: %v", err)
+	}
+	l.stdin.Close()
+	select {
+	case <-l.exited:
+	case <-time.After(goplsStopTimeout):
+		l.process.Kill()
+	}
+	l.conn.Close()
+	l.cancel()
+}
+
+// kill stops the language server without giving it the chance to Shutdown,
+// for when it failed to start properly
+func (l *languageServer) kill() {
+	close(l.stopped)
+	l.process.Kill()
+	l.conn.Close()
+	l.cancel()
+}
+
+// restartLanguageServers replaces the running language servers with those
+// configured in LanguageServers, discarding the diagnostics of the former.
+// The language servers are stopped off the vimstate thread.
+func (v *vimstate) restartLanguageServers() error {
+	old := v.takeLanguageServers()
+	v.tomb.Go(func() error {
+		stopLanguageServers(old)
+		return nil
+	})
+	v.diagnosticsChangedLock.Lock()
+	v.languageServerDiagnostics = make(map[string]map[protocol.DocumentURI]*protocol.PublishDiagnosticsParams)
+	v.diagnosticsChanged = true
+	v.diagnosticsChangedLock.Unlock()
+	if err := v.startLanguageServers(); err != nil {
+		return err
+	}
+	return v.handleDiagnosticsChanged()
+}
+
+// matches reports whether the buffer b is synced with the language server,
+// which is the case if b has one of its filetypes
+func (l *languageServer) matches(b *types.Buffer) bool {
+	for _, ft := range l.config.Filetypes {
+		if ft == b.Filetype {
+			return true
+		}
+	}
+	return false
+}
+
+// syncKind returns how the language server wants buffer changes to be synced
+func (l *languageServer) syncKind() protocol.TextDocumentSyncKind {
+	switch s := l.capabilities.TextDocumentSync.(type) {
+	case float64:
+		return protocol.TextDocumentSyncKind(s)
+	case map[string]interface{}:
+		if c, ok := s["change"].(float64); ok {
+			return protocol.TextDocumentSyncKind(c)
+		}
+	}
+	return protocol.None
+}
+
+// openClose reports whether the language server wants open and close
+// notifications
+func (l *languageServer) openClose() bool {
+	switch s := l.capabilities.TextDocumentSync.(type) {
+	case float64:
+		return s != float64(protocol.None)
+	case map[string]interface{}:
+		oc, _ := s["openClose"].(bool)
+		return oc
+	}
+	return false
+}
+
+// hasHover reports whether the language server provides hover information
+func (l *languageServer) hasHover() bool {
+	if l.capabilities.HoverProvider == nil {
+		return false
+	}
+	switch h := l.capabilities.HoverProvider.Value.(type) {
+	case bool:
+		return h
+	case nil:
+		return false
+	}
+	return true
+}
+
+func (l *languageServer) PublishDiagnostics(ctxt context.Context, params *protocol.PublishDiagnosticsParams) error {
+	defer absorbShutdownErr()
+	l.Logf("PublishDiagnostics callback: %v", pretty.Sprint(params))
+	select {
+	case <-l.stopped:
+		// The diagnostics of a language server are discarded when it is
+		// stopped
+		return nil
+	default:
+	}
+	l.diagnosticsChangedLock.Lock()
+	diags, ok := l.languageServerDiagnostics[l.name]
+	if !ok {
+		diags = make(map[protocol.DocumentURI]*protocol.PublishDiagnosticsParams)
+		l.languageServerDiagnostics[l.name] = diags
+	}
+	curr := diags[params.URI]
+	diags[params.URI] = params
+	l.diagnosticsChanged = true
+	l.diagnosticsChangedLock.Unlock()
+	if reflect.DeepEqual(curr, params) {
+		return nil
+	}
+	l.Schedule(func(govim.Govim) error {
+		v := l.vimstate
+		if v.userBusy {
+			return nil
+		}
+		return v.handleDiagnosticsChanged()
+	})
+	return nil
+}
+
+func (l *languageServer) Configuration(ctxt context.Context, params *protocol.ParamConfiguration) ([]interface{}, error) {
+	defer absorbShutdownErr()
+	l.Logf("Configuration: %v", pretty.Sprint(params))
+	res := make([]interface{}, len(params.Items))
+	for i := range res {
+		res[i] = l.config.Settings
+	}
+	return res, nil
+}
+
+func (l *languageServer) RegisterCapability(ctxt context.Context, params *protocol.RegistrationParams) error {
+	defer absorbShutdownErr()
+	// Dynamic registrations are not supported, but there is no harm in a
+	// server registering for them
+	l.Logf("RegisterCapability: %v", pretty.Sprint(params))
+	return nil
+}
+
+func (l *languageServer) UnregisterCapability(ctxt context.Context, params *protocol.UnregistrationParams) error {
+	defer absorbShutdownErr()
+	l.Logf("UnregisterCapability: %v", pretty.Sprint(params))
+	return nil
+}
+
+// Progress logs the progress of the language server, which is not shown: the
+// statusline and progress popups reflect gopls alone
+func (l *languageServer) Progress(ctxt context.Context, params *protocol.ProgressParams) error {
+	defer absorbShutdownErr()
+	l.Logf("Progress callback: %v", pretty.Sprint(params))
+	return nil
+}
+
+// WorkDoneProgressCreate is logged, for the reason given for Progress
+func (l *languageServer) WorkDoneProgressCreate(ctxt context.Context, params *protocol.WorkDoneProgressCreateParams) error {
+	defer absorbShutdownErr()
+	l.Logf("WorkDoneProgressCreate callback: %v", pretty.Sprint(params))
+	return nil
+}
+
+// ShowMessage shows the message of the language server, naming it
+func (l *languageServer) ShowMessage(ctxt context.Context, params *protocol.ShowMessageParams) error {
+	defer absorbShutdownErr()
+	l.Logf("ShowMessage callback: %v", pretty.Sprint(params))
+	l.showMessage(params.Type, fmt.Sprintf("language server %v: %v", l.name, params.Message))
+	return nil
+}
+
+func (l *languageServer) LogMessage(ctxt context.Context, params *protocol.LogMessageParams) error {
+	defer absorbShutdownErr()
+	l.Logf("LogMessage callback: %v", pretty.Sprint(params))
+	return nil
+}
+
+// goplsHandles reports whether the buffer with file name fn is synced with
+// gopls
+func goplsHandles(fn string) bool {
+	switch filepath.Base(fn) {
+	case "go.mod", "go.sum":
+		return true
+	}
+	return filepath.Ext(fn) == ".go"
+}
+
+// didOpen notifies the language servers of b that it has been opened. Errors
+// from language servers other than gopls are logged.
+func (v *vimstate) didOpen(b *types.Buffer) error {
+	var err error
+	if goplsHandles(b.Name) {
+		err = v.server.DidOpen(context.Background(), &protocol.DidOpenTextDocumentParams{
+			TextDocument: protocol.TextDocumentItem{
+				LanguageID: protocol.LanguageKind(detectLanguage(filename(b.URI())).String()),
+				URI:        protocol.DocumentURI(b.URI()),
+				Version:    b.Version,
+				Text:       string(b.Contents()),
+			},
+		})
+	}
+	for _, l := range v.languageServers {
+		if l.matches(b) && l.openClose() {
+			l.didOpen(b)
+		}
+	}
+	return err
+}
+
+func (l *languageServer) didOpen(b *types.Buffer) {
+	langID := l.config.LanguageID
+	if langID == "" {
+		langID = l.name
+	}
+	err := l.server.DidOpen(context.Background(), &protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{
+			LanguageID: protocol.LanguageKind(langID),
+			URI:        protocol.DocumentURI(b.URI()),
+			Version:    b.Version,
+			Text:       string(b.Contents()),
+		},
+	})
+	if err != nil {
+		l.Logf("failed to call DidOpen on %v: %v", b.Name, err)
+	}
+}
+
+// didChange notifies the language servers of b that its contents have
+// changed. changes are the changes sent to language servers that support
+// incremental sync; if nil, or for language servers that only support full
+// sync, the entire contents of b are sent. Errors from language servers other
+// than gopls are logged.
+func (v *vimstate) didChange(b *types.Buffer, changes []protocol.TextDocumentContentChangeEvent) error {
+	params := func(incremental bool) *protocol.DidChangeTextDocumentParams {
+		res := &protocol.DidChangeTextDocumentParams{
+			TextDocument: protocol.VersionedTextDocumentIdentifier{
+				TextDocumentIdentifier: b.ToTextDocumentIdentifier(),
+				Version:                b.Version,
+			},
+			ContentChanges: changes,
+		}
+		if !incremental || changes == nil {
+			res.ContentChanges = []protocol.TextDocumentContentChangeEvent{
+				{
+					Text: string(b.Contents()),
+				},
+			}
+		}
+		return res
+	}
+	var err error
+	if goplsHandles(b.Name) {
+		err = v.server.DidChange(context.Background(), params(true))
+	}
+	for _, l := range v.languageServers {
+		kind := l.syncKind()
+		if !l.matches(b) || kind == protocol.None {
+			continue
+		}
+		if lerr := l.server.DidChange(context.Background(), params(kind == protocol.Incremental)); lerr != nil {
+			l.Logf("failed to call DidChange on %v: %v", b.Name, lerr)
+		}
+	}
+	return err
+}
+
+// didClose notifies the language servers of b that it has been closed.
+// Errors from language servers other than gopls are logged.
+func (v *vimstate) didClose(b *types.Buffer) error {
+	params := &protocol.DidCloseTextDocumentParams{
+		TextDocument: b.ToTextDocumentIdentifier(),
+	}
+	var err error
+	if goplsHandles(b.Name) {
+		err = v.server.DidClose(context.Background(), params)
+	}
+	for _, l := range v.languageServers {
+		if l.matches(b) && l.openClose() {
+			l.didClose(b)
+		}
+	}
+	return err
+}
+
+func (l *languageServer) didClose(b *types.Buffer) {
+	err := l.server.DidClose(context.Background(), &protocol.DidCloseTextDocumentParams{
+		TextDocument: b.ToTextDocumentIdentifier(),
+	})
+	if err != nil {
+		l.Logf("failed to call DidClose on %v: %v", b.Name, err)
+	}
+}
+
+// didSave notifies the language servers of b that it has been saved. Errors
+// from language servers other than gopls are logged.
+func (v *vimstate) didSave(b *types.Buffer) error {
+	params := &protocol.DidSaveTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{
+			URI: protocol.DocumentURI(b.URI()),
+		},
+	}
+	var err error
+	if goplsHandles(b.Name) {
+		err = v.server.DidSave(context.Background(), params)
+	}
+	for _, l := range v.languageServers {
+		if !l.matches(b) {
+			continue
+		}
+		if lerr := l.server.DidSave(context.Background(), params); lerr != nil {
+			l.Logf("failed to call DidSave on %v: %v", b.Name, lerr)
+		}
+	}
+	return err
+}
+
+// hoverServer returns the language server that provides hover information for
+// the buffer b, or nil if there is none. gopls is the server for the buffers
+// it handles.
+func (v *vimstate) hoverServer(b *types.Buffer) protocol.Server {
+	if goplsHandles(b.Name) {
+		return v.server
+	}
+	for _, l := range v.languageServers {
+		if l.matches(b) && l.hasHover() {
+			return l.server
+		}
+	}
+	return nil
+}
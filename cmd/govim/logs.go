@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// logTail is the implementation of FunctionLogTail. Its arguments are the
+// path of a log, the byte offset of the first line that has not yet been read,
+// and the path of a file to which the lines added since are written. Only the
+// part of the log from the offset is read, and only complete lines are
+// written: a line that has not been completely written yet is written by a
+// later call. The lines are not returned directly because the Vim channel log
+// would then grow by the lines read from it on every call. It returns a dict
+// with the offset of the first line that has still not been read, and whether
+// the log was read from the start because it has been rotated: a log that is
+// smaller than the offset has been rotated.
+func (v *vimstate) logTail(args ...json.RawMessage) (interface{}, error) {
+	path := v.ParseString(args[0])
+	var offset int64
+	v.Parse(args[1], &offset)
+	dest := v.ParseString(args[2])
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %v: %v", path, err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %v: %v", path, err)
+	}
+	reset := fi.Size() < offset
+	if reset {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek to %v in %v: %v", offset, path, err)
+	}
+	byts, err := io.ReadAll(io.LimitReader(f, fi.Size()-offset))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %v: %v", path, err)
+	}
+	byts = byts[:bytes.LastIndexByte(byts, '\n')+1]
+	if err := os.WriteFile(dest, byts, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write %v: %v", dest, err)
+	}
+	res := struct {
+		Offset int64 `json:"offset"`
+		Reset  bool  `json:"reset"`
+	}{
+		Offset: offset + int64(len(byts)),
+		Reset:  reset,
+	}
+	return res, nil
+}
@@ -4,8 +4,8 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"go/parser"
 	"io"
 	"net"
 	"os"
@@ -17,18 +17,25 @@ import (
 	"time"
 
 	"github.com/govim/govim"
+	"github.com/govim/govim/bufsync"
 	"github.com/govim/govim/cmd/govim/config"
 	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools/jsonrpc2"
 	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
 	"github.com/govim/govim/cmd/govim/internal/types"
 	"github.com/govim/govim/cmd/govim/internal/vimconfig"
+	"github.com/govim/govim/internal/logging"
 	"github.com/govim/govim/internal/plugin"
+	"github.com/govim/govim/internal/recording"
+	"github.com/govim/govim/internal/traffic"
 	"github.com/govim/govim/testsetup"
 	"gopkg.in/tomb.v2"
 )
 
 const (
 	PluginPrefix = "GOVIM"
+
+	// logBackups is the number of rotated govim logs that are kept
+	logBackups = 3
 )
 
 // exposeTestAPI is a rather hacky but clean way of only exposing certain
@@ -91,24 +98,56 @@ func launch(goplspath string, in io.ReadCloser, out io.WriteCloser) error {
 	var logFile *os.File
 	var writers []io.Writer
 
+	// The log file comes last: io.MultiWriter stops at the first writer to
+	// fail, and the log file fails the Write for which it could not be rotated
+	if *fTail {
+		writers = append(writers, os.Stdout)
+	}
+
 	if d.logging["on"] {
 		logFile, err = d.createLogFile("govim")
 		if err != nil {
 			return err
 		}
-		defer logFile.Close()
-		writers = append(writers, logFile)
+		rf, err := logging.NewRotatingFile(logFile, d.logMaxSize, logBackups)
+		if err != nil {
+			logFile.Close()
+			return err
+		}
+		defer rf.Close()
+		d.logWriter = rf
+		writers = append(writers, rf)
 		if os.Getenv(testsetup.EnvTestSocket) != "" {
 			fmt.Fprintf(os.Stderr, "New connection will log to %v\n", logFile.Name())
 		}
 	}
 
-	if *fTail {
-		writers = append(writers, os.Stdout)
+	log := d.newLogger(io.MultiWriter(writers...))
+
+	neovim := getEnvVal(d.goplsEnv, config.EnvNeovim, "") == "true"
+
+	var vimIn io.Reader = in
+	var vimOut io.Writer = out
+	if getEnvVal(d.goplsEnv, config.EnvRecord, "") != "" {
+		if neovim {
+			return fmt.Errorf("%v is not supported with Neovim", config.EnvRecord)
+		}
+		wd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to determine working directory: %v", err)
+		}
+		vimIn, vimOut, err = d.record(wd, in, out)
+		if err != nil {
+			return err
+		}
+		defer d.recording.Close()
 	}
-	log := io.MultiWriter(writers...)
 
-	g, err := govim.NewGovim(d, in, out, log, logFile, &d.tomb)
+	newGovim := govim.NewGovim
+	if neovim {
+		newGovim = govim.NewNeovim
+	}
+	g, err := newGovim(d, vimIn, vimOut, log, logFile, &d.tomb)
 	if err != nil {
 		return fmt.Errorf("failed to create govim instance: %v", err)
 	}
@@ -117,6 +156,33 @@ func launch(goplspath string, in io.ReadCloser, out io.WriteCloser) error {
 	return d.tomb.Wait()
 }
 
+// record starts the recording of the messages exchanged with Vim via in and
+// out, and with gopls, to the file named by GOVIM_RECORD, returning the reader
+// and writer to use in their place. A relative file name is relative to wd,
+// the working directory of the session. The recording must be closed once the
+// session is over.
+func (g *govimplugin) record(wd string, in io.Reader, out io.Writer) (io.Reader, io.Writer, error) {
+	path := getEnvVal(g.goplsEnv, config.EnvRecord, "")
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(wd, path)
+	}
+	rec, err := recording.Create(path, recording.Header{
+		Time:             time.Now(),
+		WorkingDirectory: wd,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	g.recording = rec
+	return rec.TapReader(recording.Vim, in), rec.TapWriter(recording.Vim, out), nil
+}
+
+// newLogger returns a logger that writes the govim log to w in the configured
+// format and at the configured levels
+func (g *govimplugin) newLogger(w io.Writer) *logging.Logger {
+	return logging.New(w, g.logFormat, g.logLevels)
+}
+
 func (g *govimplugin) createLogFile(prefix string) (*os.File, error) {
 	var tf *os.File
 	var err error
@@ -149,7 +215,27 @@ type govimplugin struct {
 	// inherited from env var GOVIM_LOG.
 	logging map[string]bool
 
-	// tmpDir is the temp directory within which log files will be created
+	// logFormat, logLevels and logMaxSize configure the govim log. They are
+	// inherited from the env vars GOVIM_LOG_FORMAT, GOVIM_LOG_LEVEL and
+	// GOVIM_LOG_MAXSIZE respectively.
+	logFormat  logging.Format
+	logLevels  logging.Levels
+	logMaxSize int64
+
+	// logger is the govim instance's logger, set on Init. Use it in
+	// preference to Logf to log against a specific subsystem or level.
+	logger *logging.Logger
+
+	// traffic is the govim instance's recorder of Vim channel and LSP
+	// traffic, set on Init
+	traffic *traffic.Recorder
+
+	// recording, if set, records the messages exchanged with Vim and gopls.
+	// It is set from the env var GOVIM_RECORD.
+	recording *recording.Writer
+
+	// tmpDir is the temp directory within which log files and the socket dir
+	// will be created
 	tmpDir string
 
 	// goplsEnv is the environment with which to start gopls. This is
@@ -163,20 +249,75 @@ type govimplugin struct {
 	goplsStdin  io.WriteCloser
 	server      protocol.Server
 
+	// goplsStopped is closed when the running gopls instance is deliberately
+	// stopped, e.g. as part of a restart, and goplsExited is closed when the
+	// gopls process has exited. Both are recreated for each gopls instance.
+	// goplsStopped is nil once the instance has been stopped, until another
+	// instance is launched.
+	goplsStopped chan struct{}
+	goplsExited  chan struct{}
+
+	// goplsStarted is the time at which the running gopls instance was
+	// started, and goplsCrashes is the number of consecutive crashes of gopls
+	// instances that did not run for at least goplsCrashResetAfter. Both are
+	// only accessed on the vimstate thread once govim has initialised.
+	goplsStarted time.Time
+	goplsCrashes int
+
+	// workspaceFoldersLock protects access to workspaceFolders,
+	// removedWorkspaceFolders and modWatchers
+	workspaceFoldersLock sync.Mutex
+
+	// workspaceFolders are the current workspace folders. gopls is initialised
+	// with these folders, and notified of subsequent changes.
+	workspaceFolders []protocol.WorkspaceFolder
+
+	// removedWorkspaceFolders are the directories that have been explicitly
+	// removed as workspace folders, and are hence not added when a buffer is
+	// opened within them
+	removedWorkspaceFolders map[string]bool
+
+	// modWatchers are the file watchers of the workspace folders that are
+	// module or workspace roots, keyed by directory
+	modWatchers map[string]*modWatcher
+
 	isGui bool
 
+	// hasVirtualText indicates that Vim supports text properties with
+	// virtual text
+	hasVirtualText bool
+
 	tomb tomb.Tomb
 
-	modWatcher *modWatcher
+	// languageServersLock protects languageServers and languageServersGen,
+	// which are changed on the vimstate thread and when govim shuts down
+	languageServersLock sync.Mutex
+
+	// languageServers are the running language servers, other than gopls,
+	// configured via LanguageServers
+	languageServers []*languageServer
+
+	// languageServersGen is incremented whenever the language servers are
+	// stopped, such that a language server that was starting at the time is
+	// stopped once it has started
+	languageServersGen int
+
+	// languageFiletypes are the filetypes of the language servers configured
+	// via LanguageServers, whether or not they have started
+	languageFiletypes map[string]bool
 
 	// diagnosticsChangedLock protects access to rawDiagnostics,
-	// diagnosticsChanged, diagnosticsChangedQuickfix,
+	// languageServerDiagnostics, diagnosticsChanged, diagnosticsChangedQuickfix,
 	// diagnosticsChangedSigns and diagnosticsChangedHighlights
 	diagnosticsChangedLock sync.Mutex
 
 	// rawDiagnostics holds the current raw (LSP) diagnostics by URI
 	rawDiagnostics map[protocol.DocumentURI]*protocol.PublishDiagnosticsParams
 
+	// languageServerDiagnostics holds the current raw (LSP) diagnostics of
+	// each language server other than gopls, by server name and URI
+	languageServerDiagnostics map[string]map[protocol.DocumentURI]*protocol.PublishDiagnosticsParams
+
 	// diagnosticsChanged indicates that the new diagnostics are available
 	diagnosticsChanged bool
 
@@ -192,6 +333,10 @@ type govimplugin struct {
 	// when updating highlights
 	lastDiagnosticsHighlights *[]types.Diagnostic
 
+	// lastDiagnosticsVirtualText records the last diagnostics that were used
+	// when updating virtual text
+	lastDiagnosticsVirtualText *[]types.Diagnostic
+
 	// diagnosticsCache isn't inteded to be used directly since it might
 	// contain old data. Call diagnostics() to get the latest instead.
 	diagnosticsCache *[]types.Diagnostic
@@ -209,18 +354,30 @@ type govimplugin struct {
 	applyEditsCh   chan applyEditCall
 	applyEditsLock sync.Mutex
 
-	bufferUpdates chan *bufferUpdate
+	// buffersSync keeps the contents of the buffers in Vim whose names match
+	// goplsPatterns, or the patterns of a language server, in sync with
+	// vimstate.buffers
+	buffersSync *bufsync.Sync
 
 	// inShutdown is closed when govim is told to Shutdown
 	inShutdown chan struct{}
 
-	// socketDir is the temporary directory within which the parent-child
-	// socket file will be created
+	// socketDir is the temporary directory of this govim instance. It holds
+	// the parent-child socket file, unless the parent listens on TCP, and our
+	// other temporary files, such that reapStaleSocketDirs removes everything
+	// left behind by an instance that did not shut down cleanly.
 	socketDir string
 
+	// logWriter is the govim log file, if logging is on
+	logWriter *logging.RotatingFile
+
 	// socketListener is the parent-child listener
 	socketListener net.Listener
 
+	// parentToken is the per-session token that a child must present to the
+	// parent
+	parentToken string
+
 	// parentCallArgs represents the command that should be run to create a
 	// "child" instance of govim to communicate with its "parent" (the instance
 	// which responded to this function call)
@@ -231,9 +388,21 @@ func newplugin(goplspath string, goplsEnv []string, defaults, user *config.Confi
 	if goplsEnv == nil {
 		goplsEnv = os.Environ()
 	}
-	logging := make(map[string]bool)
+	logOn := make(map[string]bool)
 	for _, v := range strings.Split(getEnvVal(goplsEnv, config.EnvLog, "on"), ",") {
-		logging[v] = true
+		logOn[v] = true
+	}
+	logFormat, err := logging.ParseFormat(getEnvVal(goplsEnv, config.EnvLogFormat, "json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %v: %v", config.EnvLogFormat, err)
+	}
+	logLevels, err := logging.ParseLevels(getEnvVal(goplsEnv, config.EnvLogLevel, "debug"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %v: %v", config.EnvLogLevel, err)
+	}
+	logMaxSize, err := strconv.ParseInt(getEnvVal(goplsEnv, config.EnvLogMaxSize, "100"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %v: %v", config.EnvLogMaxSize, err)
 	}
 	tmpDir := getEnvVal(goplsEnv, "TMPDIR", os.TempDir())
 	if defaults == nil {
@@ -258,14 +427,20 @@ func newplugin(goplspath string, goplsEnv []string, defaults, user *config.Confi
 	d := plugin.NewDriver(PluginPrefix)
 	var emptyDiags []types.Diagnostic
 	res := &govimplugin{
-		logging:          logging,
-		tmpDir:           tmpDir,
-		rawDiagnostics:   make(map[protocol.DocumentURI]*protocol.PublishDiagnosticsParams),
-		goplsEnv:         goplsEnv,
-		goplspath:        goplspath,
-		Driver:           d,
-		inShutdown:       make(chan struct{}),
-		diagnosticsCache: &emptyDiags,
+		logging:                   logOn,
+		logFormat:                 logFormat,
+		logLevels:                 logLevels,
+		logMaxSize:                logMaxSize << 20,
+		tmpDir:                    tmpDir,
+		rawDiagnostics:            make(map[protocol.DocumentURI]*protocol.PublishDiagnosticsParams),
+		languageServerDiagnostics: make(map[string]map[protocol.DocumentURI]*protocol.PublishDiagnosticsParams),
+		goplsEnv:                  goplsEnv,
+		goplspath:                 goplspath,
+		Driver:                    d,
+		inShutdown:                make(chan struct{}),
+		removedWorkspaceFolders:   make(map[string]bool),
+		modWatchers:               make(map[string]*modWatcher),
+		diagnosticsCache:          &emptyDiags,
 		vimstate: &vimstate{
 			Driver:               d,
 			buffers:              make(map[int]*types.Buffer),
@@ -273,6 +448,7 @@ func newplugin(goplspath string, goplsEnv []string, defaults, user *config.Confi
 			config:               *defaults,
 			suggestedFixesPopups: make(map[int][]suggestedFix),
 			progressPopups:       make(map[protocol.ProgressToken]*types.ProgressPopup),
+			activeProgress:       make(map[protocol.ProgressToken]string),
 		},
 	}
 	res.vimstate.govimplugin = res
@@ -280,21 +456,53 @@ func newplugin(goplspath string, goplsEnv []string, defaults, user *config.Confi
 }
 
 func (g *govimplugin) Init(gg govim.Govim, errCh chan error) error {
+	g.errCh = errCh
+	g.Driver.Govim = gg
+
 	// Start the parent server first, because it establishes the command []string
 	// that forms the response to GOVIMParentCommand()
 	if err := g.startParentServer(); err != nil {
 		return err
 	}
 
-	g.errCh = errCh
-	g.Driver.Govim = gg
+	// A Govim other than the one created by govim.NewGovim, e.g. in tests,
+	// need not provide a logger or record traffic
+	if l, ok := gg.(interface{ Logger() *logging.Logger }); ok {
+		g.logger = l.Logger()
+	} else {
+		g.logger = g.newLogger(io.Discard)
+	}
+	if t, ok := gg.(interface{ Traffic() *traffic.Recorder }); ok {
+		g.traffic = t.Traffic()
+	} else {
+		g.traffic = traffic.NewRecorder(0)
+	}
+	// Exclude the calls made by the inspector and log windows themselves,
+	// otherwise every refresh would add to the traffic the inspector shows
+	for _, f := range []config.Function{config.FunctionInspectorEntries, config.FunctionInspectorStart, config.FunctionInspectorStop, config.FunctionLogTail} {
+		g.traffic.Exclude("function:" + PluginPrefix + string(f))
+	}
 	g.vimstate.Driver.Govim = gg.Scheduled()
 	g.vimstate.workingDirectory = g.ParseString(g.ChannelCall("getcwd", -1))
 	g.DefineFunction(string(config.FunctionBalloonExpr), []string{}, g.vimstate.balloonExpr)
-	g.DefineAutoCommand("", govim.Events{govim.EventBufUnload}, govim.Patterns{"*.go", "go.mod", "go.sum"}, false, g.vimstate.bufUnload, "eval(expand('<abuf>'))")
-	g.DefineAutoCommand("", govim.Events{govim.EventBufRead, govim.EventBufNewFile}, govim.Patterns{"*.go", "go.mod", "go.sum"}, false, g.vimstate.bufReadPost, exprAutocmdCurrBufInfo)
-	g.DefineAutoCommand("", govim.Events{govim.EventBufWritePre}, govim.Patterns{"*.go", "go.mod", "go.sum"}, false, g.vimstate.formatCurrentBuffer, "eval(expand('<abuf>'))")
-	g.DefineAutoCommand("", govim.Events{govim.EventBufWritePost}, govim.Patterns{"*.go", "go.mod", "go.sum"}, false, g.vimstate.bufWritePost, "eval(expand('<abuf>'))")
+	// The buffers tracked because of their filetype for the language servers
+	// other than gopls can have any name, hence bufUnload, which ignores the
+	// buffers that are not tracked, handles all buffers
+	g.DefineAutoCommand("", govim.Events{govim.EventBufUnload}, govim.Patterns{"*"}, false, g.vimstate.bufUnload, "eval(expand('<abuf>'))")
+	buffersSync, err := bufsync.New(gg, PluginPrefix+config.BufSyncName, bufsync.Config{
+		Patterns: goplsPatterns,
+		Parsers:  []bufsync.Parser{bufsync.GoParser{Mode: parser.AllErrors}},
+		Group:    strings.ToLower(PluginPrefix),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to sync buffers: %v", err)
+	}
+	g.buffersSync = buffersSync
+	g.buffersSync.OnChange(g.vimstate.bufferEvent)
+	g.DefineAutoCommand("", govim.Events{govim.EventBufWritePre}, goplsPatterns, false, g.vimstate.formatCurrentBuffer, "eval(expand('<abuf>'))")
+	g.DefineAutoCommand("", govim.Events{govim.EventBufWritePost}, goplsPatterns, false, g.vimstate.bufWritePost, "eval(expand('<abuf>'))")
+	g.DefineAutoCommand("", govim.Events{govim.EventBufWritePost}, govim.Patterns{"*"}, false, g.vimstate.languageBufWritePost, "eval(expand('<abuf>'))")
+	g.DefineAutoCommand("", govim.Events{govim.EventFileType}, govim.Patterns{"*"}, false, g.vimstate.bufFileType, "eval(expand('<abuf>'))", "expand('<amatch>')")
 	g.DefineAutoCommand("", govim.Events{govim.EventQuickFixCmdPre}, govim.Patterns{"*vimgrep*"}, false, g.vimstate.bufQuickFixCmdPre)
 	g.DefineAutoCommand("", govim.Events{govim.EventQuickFixCmdPost}, govim.Patterns{"*vimgrep*"}, false, g.vimstate.bufQuickFixCmdPost)
 	g.DefineFunction(string(config.FunctionComplete), []string{"findarg", "base"}, g.vimstate.complete)
@@ -303,12 +511,9 @@ func (g *govimplugin) Init(gg govim.Govim, errCh chan error) error {
 	g.DefineCommand(string(config.CommandSuggestedFixes), g.vimstate.suggestFixes, govim.NArgsZeroOrOne)
 	g.DefineCommand(string(config.CommandGoToPrevDef), g.vimstate.gotoPrevDef, govim.NArgsZeroOrOne, govim.CountN(1))
 	g.DefineFunction(string(config.FunctionHover), []string{}, g.vimstate.hover)
-	g.DefineAutoCommand("", govim.Events{govim.EventBufDelete}, govim.Patterns{"*.go", "go.mod", "go.sum"}, false, g.vimstate.bufDelete, "eval(expand('<abuf>'))")
-	g.DefineAutoCommand("", govim.Events{govim.EventBufWipeout}, govim.Patterns{"*.go", "go.mod", "go.sum"}, false, g.vimstate.bufWipeout, "eval(expand('<abuf>'))")
 	g.DefineCommand(string(config.CommandGoFmt), g.vimstate.gofmtCurrentBufferRange)
 	g.DefineCommand(string(config.CommandGoImports), g.vimstate.goimportsCurrentBufferRange)
 	g.DefineCommand(string(config.CommandQuickfixDiagnostics), g.vimstate.quickfixDiagnostics)
-	g.DefineFunction(string(config.FunctionBufChanged), []string{"bufnr", "start", "end", "added", "changes"}, g.vimstate.bufChanged)
 	g.DefineFunction(string(config.FunctionSetConfig), []string{"config"}, g.vimstate.setConfig)
 	g.ChannelExf(`call govim#config#Set("%vFunc", function("%v%v"))`, config.InternalFunctionPrefix, PluginPrefix, config.FunctionSetConfig)
 	g.DefineFunction(string(config.FunctionSetUserBusy), []string{"isBusy", "cursorPos"}, g.vimstate.setUserBusy)
@@ -320,14 +525,22 @@ func (g *govimplugin) Init(gg govim.Govim, errCh chan error) error {
 	g.DefineFunction(string(config.FunctionStringFnComplete), []string{"ArgLead", "CmdLine", "CursorPos"}, g.vimstate.stringfncomplete)
 	g.DefineCommand(string(config.CommandHighlightReferences), g.vimstate.highlightReferences)
 	g.DefineCommand(string(config.CommandClearReferencesHighlights), g.vimstate.clearReferencesHighlights)
-	g.DefineAutoCommand("", govim.Events{govim.EventCompleteDone}, govim.Patterns{"*.go", "go.mod", "go.sum"}, false, g.vimstate.completeDone, "eval(expand('<abuf>'))", "v:completed_item")
+	g.DefineAutoCommand("", govim.Events{govim.EventCompleteDone}, goplsPatterns, false, g.vimstate.completeDone, "eval(expand('<abuf>'))", "v:completed_item")
 	g.DefineFunction(string(config.FunctionParentCommand), []string{}, g.vimstate.parentCommand)
+	g.DefineFunction(string(config.FunctionInspectorEntries), []string{"since", "pattern"}, g.vimstate.inspectorEntries)
+	g.DefineFunction(string(config.FunctionInspectorStart), []string{"pattern"}, g.vimstate.inspectorStart)
+	g.DefineFunction(string(config.FunctionInspectorStop), []string{}, g.vimstate.inspectorStop)
+	g.DefineFunction(string(config.FunctionLogTail), []string{"path", "offset", "dest"}, g.vimstate.logTail)
 	g.DefineCommand(string(config.CommandExperimentalSignatureHelp), g.vimstate.signatureHelp)
 	g.DefineCommand(string(config.CommandFillStruct), g.vimstate.fillStruct)
 	g.DefineCommand(string(config.CommandGCDetails), g.vimstate.toggleGCDetails)
 	g.DefineCommand(string(config.CommandGoTest), g.vimstate.runGoTest, govim.RangeLine)
 	g.DefineFunction(string(config.FunctionProgressClosed), []string{"id", "selected"}, g.vimstate.progressClosed)
 	g.DefineCommand(string(config.CommandLastProgress), g.vimstate.openLastProgress)
+	g.DefineCommand(string(config.CommandDiagnosticNext), g.vimstate.diagnosticNext, govim.NArgsZeroOrMore, govim.CountN(1))
+	g.DefineCommand(string(config.CommandDiagnosticPrev), g.vimstate.diagnosticPrev, govim.NArgsZeroOrMore, govim.CountN(1))
+	g.DefineCommand(string(config.CommandRestartGopls), g.vimstate.restartGoplsCmd)
+	g.DefineCommand(string(config.CommandWorkspaceFolders), g.vimstate.workspaceFoldersCmd, govim.NArgsZeroOrMore, govim.CompleteDir)
 	g.defineHighlights()
 	if err := g.vimstate.signDefine(); err != nil {
 		return fmt.Errorf("failed to define signs: %v", err)
@@ -337,15 +550,19 @@ func (g *govimplugin) Init(gg govim.Govim, errCh chan error) error {
 	}
 	g.DefineFunction(string(config.FunctionMotion), []string{"direction", "target"}, g.vimstate.motion)
 
-	g.startProcessBufferUpdates()
-
 	g.InitTestAPI()
 
 	g.isGui = g.ParseInt(g.ChannelExpr(`has("gui_running")`)) == 1
+	g.hasVirtualText = g.ParseInt(g.ChannelExpr(`has("patch-9.0.0067")`)) == 1
 
 	if err := g.startGopls(); err != nil {
 		return err
 	}
+	if err := g.vimstate.startLanguageServers(); err != nil {
+		return fmt.Errorf("failed to start language servers: %v", err)
+	}
+
+	g.vimstate.updateStatusline()
 
 	return nil
 }
@@ -387,47 +604,68 @@ func goModSpecPath(wd string) (string, error) {
 
 // Shutdown implements the govim.Plugin Shutdown method.
 //
-// TODO: because we do not trigger a shutdown from Vim (we simply close the
-// channel and then exit Vim without waiting for govim to complete its
-// shutdown) we are not guaranteed that this method actually runs when Vim
-// exits. Hence any temporary files/directories that we (or another process)
-// have created might not actually get removed. We should trigger a proper
-// shutdown sequence from Vim that ultimately calls this method before closing
-// the channel; this is a similar protocol to that used in LSP. For more details
-// see: github.com/govim/govim/issues/842
-func (g *govimplugin) Shutdown() error {
+// Vim requests a shutdown on VimLeavePre and waits (with a timeout) for this
+// method to return before closing the channel; this is a similar protocol to
+// that used in LSP. Hence by the time Vim exits, gopls and any other language
+// servers have been asked to Shutdown and Exit (and are killed if they fail
+// to do so), and our temporary files and directories have been removed. When
+// Vim gives up waiting, or govim crashes, the temporary files left behind are
+// removed by reapStaleSocketDirs when govim next starts. For more details see
+// github.com/govim/govim/issues/842
+func (g *govimplugin) Shutdown() (err error) {
 	close(g.inShutdown)
-	close(g.bufferUpdates)
 
-	// Tidy up the parent-child socket listener
+	// The log is committed to disk last of all, because Vim might not wait
+	// for us to exit once we return.
+	defer func() {
+		if err != nil {
+			g.Logf("shutdown failed: %v", err)
+		}
+		if g.logWriter != nil {
+			g.logWriter.Sync()
+		}
+	}()
+
+	// Tidy up the parent-child socket listener. Failures to do so are
+	// reported, but do not prevent the servers from being stopped.
+	var errs []error
 	if err := g.socketListener.Close(); err != nil {
-		return fmt.Errorf("failed to close the parent-child socket listener: %v", err)
-	}
-	os.RemoveAll(g.socketDir) // see note above
-
-	// TODO: remove this workaround for golang.org/issue/45476. We should not
-	// have to set a deadline for our call to Shutdown.  500ms is the longest a
-	// user should be expected to wait before "forcing" the shutdown sequence.
-	// Because of golang.org/issue/45476, the shorter we make this timeout the
-	// greater the likelihood that gopls will leave $TMPDIR artefacts lying
-	// around, and not have properly tidied up after itself.
-	ctxt, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
-	defer cancel()
-	if err := g.server.Shutdown(ctxt); err != nil && !errors.Is(err, context.DeadlineExceeded) {
-		return fmt.Errorf("failed to call gopls Shutdown: %v", err)
-	}
-	// As the initiator of the connection to gopls, complete shutdown by closing
-	// stdin to the process we started.
-	if err := g.goplsStdin.Close(); err != nil {
-		return fmt.Errorf("failed to close gopls stdin: %v", err)
-	}
-
-	// Shutdown the filewatcher
-	if g.modWatcher != nil {
-		if err := g.modWatcher.close(); err != nil {
-			return fmt.Errorf("failed to close file watcher: %v", err)
+		errs = append(errs, fmt.Errorf("failed to close the parent-child socket listener: %v", err))
+	}
+	if err := os.RemoveAll(g.socketDir); err != nil {
+		errs = append(errs, fmt.Errorf("failed to remove the socket dir: %v", err))
+	}
+
+	// Take the filewatchers under the lock, but release it before stopping
+	// the servers: a server might still call back into govim, for example
+	// for the workspace folders, whilst being stopped.
+	g.workspaceFoldersLock.Lock()
+	modWatchers := g.modWatchers
+	g.modWatchers = make(map[string]*modWatcher)
+	g.workspaceFoldersLock.Unlock()
+
+	// Because of golang.org/issue/45476, gopls might not properly tidy up
+	// after itself if we do not give it the chance to Exit. gopls and the
+	// other language servers are stopped concurrently, to stay within the
+	// time that Vim waits for us to shut down.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		g.stopGopls()
+	}()
+	stopLanguageServers(g.takeLanguageServers())
+	wg.Wait()
+
+	// Shutdown the filewatchers
+	for _, mw := range modWatchers {
+		if err := mw.close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close file watcher: %v", err))
 		}
 	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
 	return nil
 }
 
@@ -457,6 +695,11 @@ func (g *govimplugin) defineHighlights() {
 
 		fmt.Sprintf("highlight default %s cterm=none gui=italic ctermfg=%d guifg=#8a8a8a", config.HighlightHoverDiagSrc, diagSrcColor),
 
+		fmt.Sprintf("highlight default link %s %s", config.HighlightVirtualTextErr, config.HighlightHoverErr),
+		fmt.Sprintf("highlight default link %s %s", config.HighlightVirtualTextWarn, config.HighlightHoverWarn),
+		fmt.Sprintf("highlight default link %s %s", config.HighlightVirtualTextInfo, config.HighlightHoverInfo),
+		fmt.Sprintf("highlight default link %s %s", config.HighlightVirtualTextHint, config.HighlightHoverHint),
+
 		fmt.Sprintf("highlight default %s term=reverse cterm=reverse gui=reverse", config.HighlightReferences),
 
 		fmt.Sprintf("highlight default link %s PMenu", config.HighlightSignature),
@@ -13,12 +13,15 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
 
 	"github.com/creack/pty"
+	"github.com/govim/govim"
 	"github.com/govim/govim/cmd/govim/config"
+	"github.com/govim/govim/internal/recording"
 	"github.com/govim/govim/testdriver"
 	"github.com/govim/govim/testsetup"
 	"github.com/rogpeppe/go-internal/goproxytest"
@@ -40,9 +43,12 @@ func TestMain(m *testing.M) {
 		os.Setenv(testsetup.EnvTestPathEnv, os.Getenv("PATH"))
 	}
 	os.Exit(testscript.RunMain(m, map[string]func() int{
-		"vim":         testdriver.Vim,
-		"vimexprwait": testdriver.VimExprWait,
-		"execvim":     execvim,
+		"vim":            testdriver.Vim,
+		"vimexprwait":    testdriver.VimExprWait,
+		"execvim":        execvim,
+		"fakelangserver": fakeLanguageServer,
+		"govim":          main1,
+		"stubgopls":      testdriver.StubGopls,
 	}))
 }
 
@@ -104,6 +110,7 @@ func TestScripts(t *testing.T) {
 					"sleep":       testdriver.Sleep,
 					"errlogmatch": testdriver.ErrLogMatch,
 					"envsubst":    testdriver.EnvSubst,
+					"replay":      testdriver.Replay,
 				},
 				Condition: testdriver.Condition,
 				Setup: func(e *testscript.Env) error {
@@ -121,6 +128,9 @@ func TestScripts(t *testing.T) {
 						"HOME="+home,
 						"GOPATH="+filepath.Join(home, "gopath"),
 						"PLUGIN_PATH="+govimPath,
+						// The text format is easier to match with errlogmatch,
+						// but a test can override it via govim_env.json
+						config.EnvLogFormat+"=text",
 					)
 					if workdir != "" {
 						e.Vars = append(e.Vars, "GOVIM_LOGFILE_TMPL=%v")
@@ -165,6 +175,23 @@ func TestScripts(t *testing.T) {
 						return fmt.Errorf("failed to read Vim config from %v: %v", vimConfigPath, err)
 					}
 
+					// Extra environment variables for govim (if present) are
+					// per-test, for settings that can only be made via the
+					// environment
+					govimEnvPath := filepath.Join(e.WorkDir, "govim_env.json")
+					var govimEnv map[string]string
+					if err := readConfig(govimEnvPath, &govimEnv); err != nil {
+						return fmt.Errorf("failed to read govim env from %v: %v", govimEnvPath, err)
+					}
+					var govimEnvNames []string
+					for name := range govimEnv {
+						govimEnvNames = append(govimEnvNames, name)
+					}
+					sort.Strings(govimEnvNames)
+					for _, name := range govimEnvNames {
+						e.Vars = append(e.Vars, name+"="+govimEnv[name])
+					}
+
 					defaultsPath := filepath.Join("testdata", entry.Name(), "default_config.json")
 					var user, defaults *config.Config
 					if err = readConfig(defaultsPath, &defaults); err != nil {
@@ -190,18 +217,39 @@ func TestScripts(t *testing.T) {
 					if err != nil {
 						return err
 					}
+					e.Values[testdriver.KeyReplayPlugin] = testdriver.ReplayPlugin(func(replayGopls string, env []string) (govim.Plugin, error) {
+						if replayGopls == "" {
+							replayGopls = goplsPath
+						}
+						return newplugin(replayGopls, append(append([]string(nil), e.Vars...), env...), defaults, user)
+					})
 
+					record := getEnvVal(d.goplsEnv, config.EnvRecord, "") != ""
 					config := &testdriver.Config{
 						Name:           filepath.Base(e.WorkDir),
 						GovimPath:      govimPath,
 						ReadLog:        errLog,
-						Log:            io.MultiWriter(outputs...),
+						Log:            d.newLogger(io.MultiWriter(outputs...)),
 						TestHomePath:   home,
 						TestPluginPath: testPluginPath,
 						Env:            e,
 						Plugin:         d,
 						Vim:            vimConfig,
 					}
+					if record {
+						config.Tap = func(in io.Reader, out io.Writer) (io.Reader, io.Writer, error) {
+							return d.record(e.WorkDir, in, out)
+						}
+						config.RecordCommand = func(cmd json.RawMessage) {
+							d.recording.Record(recording.Driver, recording.Recv, cmd)
+						}
+						// Registered before td.Close, and so run after it
+						e.Defer(func() {
+							if d.recording != nil {
+								d.recording.Close()
+							}
+						})
+					}
 					td, err := testdriver.NewTestDriver(config)
 					if err != nil {
 						return fmt.Errorf("failed to create new driver: %v", err)
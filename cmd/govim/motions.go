@@ -42,13 +42,13 @@ func (v *vimstate) motion(args ...json.RawMessage) (interface{}, error) {
 	}
 
 	// Now ensure we block for the result of any in-flight parse
-	if b.ASTWait == nil {
-		return nil, fmt.Errorf("got motion request before buffer had loaded?")
+	fset, astFile, err := b.AST()
+	if err != nil {
+		return nil, err
 	}
-	<-b.ASTWait
 
 	var file *token.File
-	b.Fset.Iterate(func(f *token.File) bool {
+	fset.Iterate(func(f *token.File) bool {
 		if f.Name() == b.Name {
 			file = f
 			return false
@@ -77,15 +77,15 @@ func (v *vimstate) motion(args ...json.RawMessage) (interface{}, error) {
 		resolv = func(n ast.Node) token.Pos {
 			// The user sees themselves as being at the end when the cursor is
 			// before the closing brace, not after. Hence adjust backwards
-			tf := b.Fset.File(pos)
+			tf := fset.File(pos)
 			tfe := token.Pos(tf.Base() + tf.Size())
 			if n.End() > tfe {
 				// Work around https://github.com/golang/go/issues/33649
 				return tfe
 			}
-			offset := b.Fset.File(pos).Offset(n.End())
+			offset := fset.File(pos).Offset(n.End())
 			_, size := utf8.DecodeLastRune(b.Contents()[:offset])
-			return b.Fset.File(pos).Pos(offset - size)
+			return fset.File(pos).Pos(offset - size)
 		}
 	case "File.Decls.Pos()":
 		resolv = func(n ast.Node) token.Pos {
@@ -99,8 +99,8 @@ func (v *vimstate) motion(args ...json.RawMessage) (interface{}, error) {
 	var targetNode ast.Node
 	switch dir {
 	case "next":
-		for i := 0; i < len(b.AST.Decls); i++ {
-			d := b.AST.Decls[i]
+		for i := 0; i < len(astFile.Decls); i++ {
+			d := astFile.Decls[i]
 			resolved := resolv(d)
 			if !resolved.IsValid() {
 				// We can't complete the motion because of an invalid target position.
@@ -114,8 +114,8 @@ func (v *vimstate) motion(args ...json.RawMessage) (interface{}, error) {
 			}
 		}
 	case "prev":
-		for i := len(b.AST.Decls) - 1; i >= 0; i-- {
-			d := b.AST.Decls[i]
+		for i := len(astFile.Decls) - 1; i >= 0; i-- {
+			d := astFile.Decls[i]
 			resolved := resolv(d)
 			if !resolved.IsValid() {
 				// We can't complete the motion because of an invalid target position.
@@ -132,7 +132,7 @@ func (v *vimstate) motion(args ...json.RawMessage) (interface{}, error) {
 
 	if targetNode != nil {
 		v.ChannelEx("normal! m'")
-		position := b.Fset.Position(resolv(targetNode))
+		position := fset.Position(resolv(targetNode))
 		v.ChannelCall("cursor", position.Line, position.Column)
 	}
 	return nil, nil
@@ -1,59 +1,158 @@
 package main
 
 import (
-	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
-	"flag"
 	"fmt"
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/govim/govim"
-	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
+	"github.com/govim/govim/cmd/govim/config"
 	"github.com/kr/pretty"
 )
 
+const (
+	// socketDirPrefix is the prefix of the temp directories created by
+	// startParentServer
+	socketDirPrefix = ".govim-parent-child-"
+
+	// socketDirPidFile is the file within a socket dir that records the pid
+	// of the govim instance that created it
+	socketDirPidFile = "pid"
+
+	// socketDirGracePeriod is the age after which a socket dir without a pid
+	// file is considered stale. A socket dir created by an older version of
+	// govim has no pid file; nor, briefly, does one that is being created.
+	socketDirGracePeriod = time.Minute
+
+	// parentHandshakeTimeout is the time within which a child must send its
+	// token and args having connected to the parent
+	parentHandshakeTimeout = 10 * time.Second
+)
+
 // startParentServer is called during the init phase of cmd/govim. It starts a
-// Unix Domain Sockets server to allow for communication from a child instance
+// Unix Domain Sockets server, or a TCP server if EnvVarParentListen is set,
+// to allow for communication from a child instance
 func (g *govimplugin) startParentServer() error {
-	td, err := os.MkdirTemp("", ".govim-parent-child-*")
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return fmt.Errorf("failed to generate parent token: %v", err)
+	}
+	g.parentToken = hex.EncodeToString(token)
+	if err := g.createSocketDir(); err != nil {
+		return err
+	}
+	var addr string
+	if listen := getEnvVal(g.goplsEnv, string(config.EnvVarParentListen), ""); listen != "" {
+		l, err := net.Listen("tcp", listen)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %v: %v", listen, err)
+		}
+		g.socketListener = l
+		addr = parentTCPPrefix + advertisedAddr(l.Addr().(*net.TCPAddr))
+	} else {
+		socketFile, err := g.listenUnix()
+		if err != nil {
+			return err
+		}
+		addr = socketFile
+	}
+	g.Logf("parent listening on %v", addr)
+	g.parentCallArgs = []string{
+		os.Args[0],
+		"-parent",
+		addr,
+		"-parenttoken",
+		g.parentToken,
+	}
+	g.tomb.Go(g.runParentServer)
+	return nil
+}
+
+// advertisedAddr returns the address that children should use to connect to
+// a parent listening on addr. If addr is an unspecified address, e.g.
+// 0.0.0.0, the hostname is used instead.
+func advertisedAddr(addr *net.TCPAddr) string {
+	if addr.IP.IsUnspecified() {
+		if host, err := os.Hostname(); err == nil {
+			return net.JoinHostPort(host, strconv.Itoa(addr.Port))
+		}
+	}
+	return addr.String()
+}
+
+// createSocketDir reaps stale socket dirs, and then creates the socket dir of
+// this instance. The socket dir is created regardless of the parent-child
+// transport, because it also holds our other temporary files.
+func (g *govimplugin) createSocketDir() error {
+	g.reapStaleSocketDirs()
+	td, err := os.MkdirTemp(g.tmpDir, socketDirPrefix+"*")
 	if err != nil {
 		return fmt.Errorf("failed to create a temp dir for the socket file: %v", err)
 	}
-	socketFile := filepath.Join(td, "socket")
 	g.socketDir = td
-	// TODO: support TCP?
+	pid := strconv.Itoa(os.Getpid())
+	if err := os.WriteFile(filepath.Join(td, socketDirPidFile), []byte(pid), 0666); err != nil {
+		return fmt.Errorf("failed to write pid file: %v", err)
+	}
+	return nil
+}
+
+// listenUnix starts listening on a Unix Domain Socket within the socket dir,
+// returning the path of the socket file
+func (g *govimplugin) listenUnix() (string, error) {
+	socketFile := filepath.Join(g.socketDir, "socket")
 	l, err := net.Listen("unix", socketFile)
 	if err != nil {
-		return fmt.Errorf("failed to listen on %v: %v", socketFile, err)
+		return "", fmt.Errorf("failed to listen on %v: %v", socketFile, err)
 	}
 	g.socketListener = l
-	g.parentCallArgs = []string{
-		os.Args[0],
-		"-parent",
-		socketFile,
+	return socketFile, nil
+}
+
+// reapStaleSocketDirs removes the socket dirs left behind by govim instances
+// that did not shut down cleanly, i.e. those whose process is no longer
+// running. Failures are logged rather than returned: reaping is only a best
+// effort.
+func (g *govimplugin) reapStaleSocketDirs() {
+	dirs, err := filepath.Glob(filepath.Join(g.tmpDir, socketDirPrefix+"*"))
+	if err != nil {
+		g.Logf("failed to list socket dirs: %v", err)
+		return
+	}
+	for _, dir := range dirs {
+		byts, err := os.ReadFile(filepath.Join(dir, socketDirPidFile))
+		if err != nil {
+			fi, err := os.Stat(dir)
+			if err != nil || time.Since(fi.ModTime()) < socketDirGracePeriod {
+				continue
+			}
+		} else if pid, err := strconv.Atoi(strings.TrimSpace(string(byts))); err == nil && processAlive(pid) {
+			continue
+		}
+		g.Logf("removing stale socket dir %v", dir)
+		if err := os.RemoveAll(dir); err != nil {
+			g.Logf("failed to remove stale socket dir %v: %v", dir, err)
+		}
 	}
-	g.tomb.Go(g.runParentServer)
-	return nil
 }
 
 func (g *govimplugin) runParentServer() error {
 	for {
-		// We assume for now that we will only ever receive sequential calls from
-		// a single client, given the main use-case is fzf calling this in
-		// response to a user typing input into the Symbol fuzzy finder.
-		//
-		// If we need to change this assumption we will need to think carefully
-		// about sequencing, especially if we start to allow govim commands a la
-		// testdriver instead of just pass through calls to gopls endpoints that
-		// are known to be side-effect free.
-		//
-		// Again we keep things simple for now to assume that each accepted
-		// connection will handle exactly one request, and therefore send exactly
-		// one response.
+		// Each accepted connection handles exactly one request, and therefore
+		// sends exactly one response. Connections are handled concurrently, so
+		// that a slow (or stalled) child does not block other children. This is
+		// safe because the methods available to a child either pass through to
+		// gopls endpoints that are known to be side-effect free, or access
+		// vimstate via the event queue.
 		conn, err := g.socketListener.Accept()
 		if err != nil {
 			// TODO: any more definite way of determining that we have safely
@@ -64,9 +163,12 @@ func (g *govimplugin) runParentServer() error {
 		req := &parentReq{
 			govimplugin: g,
 		}
-		if err := req.handle(conn); err != nil {
-			g.Logf("child-parent request failed: %v", err)
-		}
+		g.tomb.Go(func() error {
+			if err := req.handle(conn); err != nil {
+				g.Logf("child-parent request failed: %v", err)
+			}
+			return nil
+		})
 	}
 	g.Logf("Child-parent listener shutdown")
 	return nil
@@ -158,7 +260,7 @@ type encodeError error
 // handle responds to the client returning a nil-nil error
 // only in the case of a fatal communication error with the client
 func (p *parentReq) handle(conn net.Conn) (retErr error) {
-	p.Logf("handling new connection from child")
+	p.Logf("handling new connection from child at %v", conn.RemoteAddr())
 	p.conn = conn
 	p.enc = json.NewEncoder(conn)
 	p.dec = json.NewDecoder(conn)
@@ -209,10 +311,25 @@ func (p *parentReq) handle(conn net.Conn) (retErr error) {
 
 // Run implements Command.Run()
 func (p *parentReq) Run() {
+	// A child that connects must promptly identify itself, else it would
+	// hold on to its connection indefinitely
+	p.conn.SetReadDeadline(time.Now().Add(parentHandshakeTimeout))
+	var token string
+	if err := p.dec.Decode(&token); err != nil {
+		p.Errorf("failed to decode token: %v", err)
+	}
+	// The args are read before the token is checked. Otherwise we might close
+	// the connection on a child that is still writing them, which would see
+	// a broken connection instead of the reason it was rejected.
 	var args []string
-	if err := p.dec.Decode(&args); err != nil {
-		p.Errorf("failed to decode args slice: %v", err)
+	argsErr := p.dec.Decode(&args)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(p.parentToken)) != 1 {
+		p.Errorf("invalid token; use the command returned by GOVIMParentCommand()")
 	}
+	if argsErr != nil {
+		p.Errorf("failed to decode args slice: %v", argsErr)
+	}
+	p.conn.SetReadDeadline(time.Time{})
 	p.Logf("parentReq got args: %v", pretty.Sprint(args))
 
 	// At this point args will be something like
@@ -225,145 +342,27 @@ func (p *parentReq) Run() {
 		p.Errorf("expected command")
 	}
 	cmdStr := commandName(args[0])
-
-	var cmd Command
-	switch cmdStr {
-	case cmdNameGopls:
-		cmd = newGoplsCmd(p, args[1:])
-	default:
-		p.Errorf("unknown command: %v", cmdStr)
-	}
-	cmd.Run()
-}
-
-// goplsCmd is a sub Command of parentReq responsible for handling requests
-// against the LSP/gopls API
-type goplsCmd struct {
-	*parentReq
-	fs *flag.FlagSet
-}
-
-// *goplsCmd implemenets Command
-var _ Command = (*goplsCmd)(nil)
-
-func newGoplsCmd(parent *parentReq, args []string) *goplsCmd {
-	g := &goplsCmd{
-		parentReq: parent,
-	}
-	g.fs = flag.NewFlagSet("gopls", flag.ContinueOnError)
-	if err := g.fs.Parse(args); err != nil {
-		g.Errorf("failed to parse args [%v]: %v", strings.Join(args, " "), err)
-	}
-	return g
-}
-
-// Errorf implements Command.Errorf
-func (g *goplsCmd) Errorf(format string, args ...interface{}) {
-	g.parentReq.Errorf("gopls: "+format, args...)
-}
-
-// Run implements Command.Run()
-func (g *goplsCmd) Run() {
-	args := g.fs.Args()
-	g.Logf("goplsCmd got args: %v", pretty.Sprint(args))
-	if len(args) == 0 {
-		g.Errorf("expected method")
-	}
-	cmdStr := goplsMethodName(args[0])
-	var cmd Command
-	switch cmdStr {
-	case methodGoplsSymbol:
-		cmd = newGoplsSymbolCmd(g, args[1:])
-	default:
-		g.Errorf("unknown method: %v", cmdStr)
-	}
-	cmd.Run()
-}
-
-// goplsSymbolCmd is a sub Command of goplsCmd responsible for handling a call
-// to the gopls Symbol method
-type goplsSymbolCmd struct {
-	*goplsCmd
-	fs        *flag.FlagSet
-	fQuickfix *bool
-	fRel      *bool
-}
-
-func newGoplsSymbolCmd(parent *goplsCmd, args []string) *goplsSymbolCmd {
-	g := &goplsSymbolCmd{
-		goplsCmd: parent,
-	}
-	g.fs = flag.NewFlagSet("goplsSymbol", flag.ContinueOnError)
-	g.fQuickfix = g.fs.Bool("quickfix", false, "format output in quickfix style")
-	g.fRel = g.fs.Bool("rel", false, "output filenames relative to the working directory (only with -quickfix)")
-	if err := g.fs.Parse(args); err != nil {
-		g.Errorf("failed to parse args [%v]: %v", strings.Join(args, " "), err)
+	newCmd, ok := parentCommands[cmdStr]
+	if !ok {
+		var known []string
+		for name := range parentCommands {
+			known = append(known, string(name))
+		}
+		sort.Strings(known)
+		p.Errorf("unknown command %v; known commands are: %v", cmdStr, strings.Join(known, ", "))
 	}
-	return g
-}
-
-// Errorf implements Command.Errorf
-func (g *goplsSymbolCmd) Errorf(format string, args ...interface{}) {
-	g.goplsCmd.Errorf("symbol: "+format, args...)
+	newCmd(p, args[1:]).Run()
 }
 
-// Run implements Command.Run()
-func (g *goplsSymbolCmd) Run() {
-	g.Logf("goplsSymbolCmd got args: %v", pretty.Sprint(g.fs.Args()))
-	if len(g.fs.Args()) == 0 {
-		// no error - simply not results
-		return
-	}
-	query := strings.Join(g.fs.Args(), " ")
-	symbolReq := &protocol.WorkspaceSymbolParams{
-		Query: query,
-	}
-	symbolResp, err := g.server.Symbol(context.Background(), symbolReq)
-	if err != nil {
-		g.Errorf("failed to call gopls.Symbol: %v", err)
-	}
-
-	if !*g.fQuickfix {
-		// we return the raw LSP response. It's highly unlikely this would ever
-		// be useful because the locations are in terms of UTF16 code points.
-		//
-		// See https://github.com/golang/go/issues/38274
-		g.EncodeStdout(symbolResp)
-		return
-	}
+// parentCommands are the top-level commands that a child can run, keyed by
+// name. Commands add themselves via registerParentCommand.
+var parentCommands = make(map[commandName]func(p *parentReq, args []string) Command)
 
-	var qfs []quickfixEntry
-	var qferr error
-	v := g.vimstate
-	done := make(chan struct{})
-	// Note at this point we can't schedule something in Vim because Vim is most likely
-	// blocked waiting on an external command, e.g. fzf. So instead we enqueue a call
-	g.Enqueue(func(_g govim.Govim) error {
-		defer func() {
-			if recover() == nil {
-				close(done)
-			}
-		}()
-		for _, si := range symbolResp {
-			qf, err := v.locationToQuickfix(si.Location, *g.fRel)
-			if err != nil {
-				qferr = err
-				return nil
-			}
-			qf.Text = si.Name
-			qfs = append(qfs, qf)
-		}
-		return nil
-	})
-	<-done
-
-	if qferr != nil {
-		g.Errorf("failed to convert locations to quickfix entires: %v", qferr)
-	}
-
-	if *g.fQuickfix {
-		for _, q := range qfs {
-			g.PrintfStdout("%v:%v:%v: %v\n", q.Filename, q.Lnum, q.Col, q.Text)
-		}
+// registerParentCommand registers the constructor of the top-level command
+// name. It is intended to be called from init functions.
+func registerParentCommand(name commandName, newCmd func(p *parentReq, args []string) Command) {
+	if _, ok := parentCommands[name]; ok {
+		panic(fmt.Errorf("parent command %v registered twice", name))
 	}
+	parentCommands[name] = newCmd
 }
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/govim/govim"
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
+	"github.com/govim/govim/cmd/govim/internal/types"
+	"github.com/kr/pretty"
+)
+
+func init() {
+	registerParentCommand(cmdNameGopls, func(p *parentReq, args []string) Command {
+		return newGoplsCmd(p, args)
+	})
+}
+
+// goplsCmd is a sub Command of parentReq responsible for handling requests
+// against the LSP/gopls API
+type goplsCmd struct {
+	*parentReq
+	fs *flag.FlagSet
+}
+
+// *goplsCmd implemenets Command
+var _ Command = (*goplsCmd)(nil)
+
+func newGoplsCmd(parent *parentReq, args []string) *goplsCmd {
+	g := &goplsCmd{
+		parentReq: parent,
+	}
+	g.fs = flag.NewFlagSet("gopls", flag.ContinueOnError)
+	if err := g.fs.Parse(args); err != nil {
+		g.Errorf("failed to parse args [%v]: %v", strings.Join(args, " "), err)
+	}
+	return g
+}
+
+// Errorf implements Command.Errorf
+func (g *goplsCmd) Errorf(format string, args ...interface{}) {
+	g.parentReq.Errorf("gopls: "+format, args...)
+}
+
+// Run implements Command.Run()
+func (g *goplsCmd) Run() {
+	args := g.fs.Args()
+	g.Logf("goplsCmd got args: %v", pretty.Sprint(args))
+	if len(args) == 0 {
+		g.Errorf("expected method")
+	}
+	cmdStr := goplsMethodName(args[0])
+	newCmd, ok := goplsMethods[cmdStr]
+	if !ok {
+		var known []string
+		for name := range goplsMethods {
+			known = append(known, string(name))
+		}
+		sort.Strings(known)
+		g.Errorf("unknown method %v; known methods are: %v", cmdStr, strings.Join(known, ", "))
+	}
+	newCmd(g, args[1:]).Run()
+}
+
+// goplsMethods are the methods of the gopls command, keyed by name. Methods
+// add themselves via registerGoplsMethod.
+var goplsMethods = make(map[goplsMethodName]func(g *goplsCmd, args []string) Command)
+
+// registerGoplsMethod registers the constructor of the gopls method name. It
+// is intended to be called from init functions.
+func registerGoplsMethod(name goplsMethodName, newCmd func(g *goplsCmd, args []string) Command) {
+	if _, ok := goplsMethods[name]; ok {
+		panic(fmt.Errorf("gopls method %v registered twice", name))
+	}
+	goplsMethods[name] = newCmd
+}
+
+// enqueue runs fn on the vimstate thread, waiting for it to complete. Note
+// we can't schedule something in Vim because Vim is most likely blocked
+// waiting on an external command, e.g. fzf. So instead we enqueue a call.
+// An error returned by fn is reported to the child.
+func (g *goplsCmd) enqueue(fn func(v *vimstate) error) {
+	done := make(chan error, 1)
+	g.Enqueue(func(govim.Govim) error {
+		var err error
+		defer func() {
+			done <- err
+		}()
+		err = fn(g.vimstate)
+		return nil
+	})
+	if err := <-done; err != nil {
+		g.Errorf("%v", err)
+	}
+}
+
+// goplsServer returns the current gopls server. The server is read on the
+// vimstate thread, because a restart of gopls replaces it.
+func (g *goplsCmd) goplsServer() protocol.Server {
+	var res protocol.Server
+	g.enqueue(func(v *vimstate) error {
+		res = v.server
+		return nil
+	})
+	return res
+}
+
+// Output formats supported by gopls methods. Each method supports a subset of
+// these formats.
+const (
+	formatJSON     = "json"
+	formatQuickfix = "quickfix"
+	formatSARIF    = "sarif"
+	formatText     = "text"
+)
+
+// formatFlag is a flag.Value for the -format flag of a gopls method that
+// only accepts the formats supported by that method
+type formatFlag struct {
+	val   string
+	valid []string
+}
+
+// newFormatFlag defines a -format flag on fs that accepts one of formats, the
+// first of which is the default
+func newFormatFlag(fs *flag.FlagSet, formats ...string) *formatFlag {
+	f := &formatFlag{
+		val:   formats[0],
+		valid: formats,
+	}
+	fs.Var(f, "format", "output format: "+strings.Join(formats, ", "))
+	return f
+}
+
+func (f *formatFlag) String() string {
+	return f.val
+}
+
+func (f *formatFlag) Set(s string) error {
+	for _, v := range f.valid {
+		if s == v {
+			f.val = s
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown format %q; must be one of: %v", s, strings.Join(f.valid, ", "))
+}
+
+// positionJSON is a position within a file. Line, Col and Char are 1-based.
+// Col is the byte index within the (UTF-8 encoded) line, as used by Vim, and
+// Char is the Unicode code point index within the line. Offset is the
+// 0-based byte offset within the file.
+type positionJSON struct {
+	Line   int `json:"line"`
+	Col    int `json:"col"`
+	Char   int `json:"char"`
+	Offset int `json:"offset"`
+}
+
+func positionJSONFromPoint(p types.Point) (positionJSON, error) {
+	line, err := p.Buffer().Line(p.Line())
+	if err != nil {
+		return positionJSON{}, fmt.Errorf("position invalid in %v: %v", p.Buffer().Name, err)
+	}
+	prefix := line
+	if i := p.Col() - 1; i < len(line) {
+		prefix = line[:i]
+	}
+	return positionJSON{
+		Line:   p.Line(),
+		Col:    p.Col(),
+		Char:   utf8.RuneCountInString(prefix) + 1,
+		Offset: p.Offset(),
+	}, nil
+}
+
+// locationJSON is the JSON representation of a location output by gopls
+// methods
+type locationJSON struct {
+	Filename string       `json:"filename"`
+	Start    positionJSON `json:"start"`
+	End      positionJSON `json:"end"`
+}
+
+// locationToJSON converts loc to its JSON representation, resolving positions
+// against the current contents of the buffer for the file if it is loaded
+func (v *vimstate) locationToJSON(loc protocol.Location, rel bool) (locationJSON, error) {
+	var res locationJSON
+	buf, err := v.bufferForPath(loc.URI.Path())
+	if err != nil {
+		return res, err
+	}
+	res.Filename, err = v.reportPath(buf.Name, rel)
+	if err != nil {
+		return res, err
+	}
+	for _, p := range []struct {
+		pos  protocol.Position
+		dest *positionJSON
+	}{
+		{loc.Range.Start, &res.Start},
+		{loc.Range.End, &res.End},
+	} {
+		point, err := types.PointFromPosition(buf, p.pos)
+		if err != nil {
+			return res, fmt.Errorf("failed to resolve position in %v: %v", buf.Name, err)
+		}
+		if *p.dest, err = positionJSONFromPoint(point); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// reportPath returns fn relative to the working directory of Vim if rel is
+// set, else fn
+func (v *vimstate) reportPath(fn string, rel bool) (string, error) {
+	if !rel {
+		return fn, nil
+	}
+	res, err := filepath.Rel(v.workingDirectory, fn)
+	if err != nil {
+		return "", fmt.Errorf("failed to call filepath.Rel(%q, %q): %v", v.workingDirectory, fn, err)
+	}
+	return res, nil
+}
+
+// parseFileArg resolves the file argument fn of a gopls method, relative to
+// the working directory of Vim, to the buffer for that file. If the file is
+// loaded in Vim the buffer has its current, possibly unsaved, contents.
+func (v *vimstate) parseFileArg(fn string) (*types.Buffer, error) {
+	if !filepath.IsAbs(fn) {
+		fn = filepath.Join(v.workingDirectory, fn)
+	}
+	return v.bufferForPath(filepath.Clean(fn))
+}
+
+// parsePositionArg resolves a position argument of a gopls method, of the
+// form file:line:col, to a point. line and col are 1-based, and col is a
+// byte index, as is the case in Vim and in quickfix output.
+func (v *vimstate) parsePositionArg(arg string) (types.Point, error) {
+	errorf := func(format string, args ...interface{}) (types.Point, error) {
+		return types.Point{}, fmt.Errorf("invalid position %q: "+format, append([]interface{}{arg}, args...)...)
+	}
+	// The filename can itself contain colons, hence we work from the end
+	ci := strings.LastIndex(arg, ":")
+	if ci == -1 {
+		return errorf("expected file:line:col")
+	}
+	li := strings.LastIndex(arg[:ci], ":")
+	if li == -1 {
+		return errorf("expected file:line:col")
+	}
+	line, err := strconv.Atoi(arg[li+1 : ci])
+	if err != nil {
+		return errorf("invalid line: %v", err)
+	}
+	col, err := strconv.Atoi(arg[ci+1:])
+	if err != nil {
+		return errorf("invalid col: %v", err)
+	}
+	b, err := v.parseFileArg(arg[:li])
+	if err != nil {
+		return types.Point{}, err
+	}
+	p, err := types.PointFromVim(b, line, col)
+	if err != nil {
+		return errorf("%v", err)
+	}
+	return p, nil
+}
+
+// outputLocations outputs locs in format, which is either formatJSON or
+// formatQuickfix. Locations are sorted by filename, line and column.
+func (g *goplsCmd) outputLocations(locs []protocol.Location, format string, rel bool) {
+	res := []locationJSON{}
+	var qfs []quickfixEntry
+	g.enqueue(func(v *vimstate) error {
+		for _, loc := range locs {
+			l, err := v.locationToJSON(loc, rel)
+			if err != nil {
+				return err
+			}
+			res = append(res, l)
+			qf, err := v.locationToQuickfix(loc, rel)
+			if err != nil {
+				return err
+			}
+			qfs = append(qfs, qf)
+		}
+		return nil
+	})
+	sort.SliceStable(res, func(i, j int) bool {
+		lhs, rhs := res[i], res[j]
+		if lhs.Filename != rhs.Filename {
+			return lhs.Filename < rhs.Filename
+		}
+		return lhs.Start.Offset < rhs.Start.Offset
+	})
+	sort.SliceStable(qfs, func(i, j int) bool {
+		lhs, rhs := qfs[i], qfs[j]
+		if lhs.Filename != rhs.Filename {
+			return lhs.Filename < rhs.Filename
+		}
+		if lhs.Lnum != rhs.Lnum {
+			return lhs.Lnum < rhs.Lnum
+		}
+		return lhs.Col < rhs.Col
+	})
+	switch format {
+	case formatJSON:
+		g.EncodeStdout(res)
+	case formatQuickfix:
+		for _, q := range qfs {
+			g.PrintfStdout("%v:%v:%v: %v\n", q.Filename, q.Lnum, q.Col, q.Text)
+		}
+	}
+}
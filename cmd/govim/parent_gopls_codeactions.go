@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strings"
+
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
+	"github.com/kr/pretty"
+)
+
+const methodGoplsCodeActions goplsMethodName = "CodeActions"
+
+func init() {
+	registerGoplsMethod(methodGoplsCodeActions, func(g *goplsCmd, args []string) Command {
+		return newGoplsCodeActionsCmd(g, args)
+	})
+}
+
+// goplsCodeActionsCmd is a sub Command of goplsCmd responsible for listing
+// the code actions available at a position via the gopls CodeAction method.
+// The code actions are listed, not applied.
+type goplsCodeActionsCmd struct {
+	*goplsCmd
+	fs      *flag.FlagSet
+	fFormat *formatFlag
+	fRel    *bool
+	fOnly   *string
+}
+
+func newGoplsCodeActionsCmd(parent *goplsCmd, args []string) *goplsCodeActionsCmd {
+	g := &goplsCodeActionsCmd{
+		goplsCmd: parent,
+	}
+	g.fs = flag.NewFlagSet("goplsCodeActions", flag.ContinueOnError)
+	g.fFormat = newFormatFlag(g.fs, formatJSON, formatQuickfix)
+	g.fRel = g.fs.Bool("rel", false, "output filenames relative to the working directory")
+	g.fOnly = g.fs.String("only", "", "comma-separated list of the kinds of code action to list, e.g. quickfix,refactor")
+	if err := g.fs.Parse(args); err != nil {
+		g.Errorf("failed to parse args [%v]: %v", strings.Join(args, " "), err)
+	}
+	return g
+}
+
+// Errorf implements Command.Errorf
+func (g *goplsCodeActionsCmd) Errorf(format string, args ...interface{}) {
+	g.goplsCmd.Errorf("codeactions: "+format, args...)
+}
+
+// codeActionJSON is the JSON representation of a code action output by the
+// gopls CodeActions method. Disabled is the reason the code action is
+// disabled, if it is.
+type codeActionJSON struct {
+	Title     string `json:"title"`
+	Kind      string `json:"kind"`
+	Preferred bool   `json:"preferred"`
+	Disabled  string `json:"disabled,omitempty"`
+}
+
+// Run implements Command.Run()
+func (g *goplsCodeActionsCmd) Run() {
+	g.Logf("goplsCodeActionsCmd got args: %v", pretty.Sprint(g.fs.Args()))
+	if len(g.fs.Args()) != 1 {
+		g.Errorf("expected a single file:line:col argument")
+	}
+	var params protocol.CodeActionParams
+	var qf quickfixEntry
+	g.enqueue(func(v *vimstate) error {
+		p, err := v.parsePositionArg(g.fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		params.TextDocument = p.Buffer().ToTextDocumentIdentifier()
+		params.Range = protocol.Range{Start: p.ToPosition(), End: p.ToPosition()}
+		// As is the case for suggested fixes, the diagnostics that cover the
+		// line of the position are those for which quick fixes are offered
+		line := p.ToPosition().Line
+		v.diagnosticsChangedLock.Lock()
+		if diags, ok := v.rawDiagnostics[params.TextDocument.URI]; ok {
+			for _, d := range diags.Diagnostics {
+				if line >= d.Range.Start.Line && line <= d.Range.End.Line {
+					params.Context.Diagnostics = append(params.Context.Diagnostics, d)
+				}
+			}
+		}
+		v.diagnosticsChangedLock.Unlock()
+		qf.Filename, err = v.reportPath(p.Buffer().Name, *g.fRel)
+		qf.Lnum = p.Line()
+		qf.Col = p.Col()
+		return err
+	})
+	if *g.fOnly != "" {
+		for _, k := range strings.Split(*g.fOnly, ",") {
+			params.Context.Only = append(params.Context.Only, protocol.CodeActionKind(strings.TrimSpace(k)))
+		}
+	}
+	res, err := g.goplsServer().CodeAction(context.Background(), &params)
+	if err != nil {
+		g.Errorf("failed to call gopls.CodeAction: %v", err)
+	}
+
+	actions := []codeActionJSON{}
+	for _, ca := range res {
+		a := codeActionJSON{
+			Title:     ca.Title,
+			Kind:      string(ca.Kind),
+			Preferred: ca.IsPreferred,
+		}
+		if ca.Disabled != nil {
+			a.Disabled = ca.Disabled.Reason
+		}
+		actions = append(actions, a)
+	}
+	switch g.fFormat.String() {
+	case formatJSON:
+		g.EncodeStdout(actions)
+	case formatQuickfix:
+		for _, a := range actions {
+			g.PrintfStdout("%v:%v:%v: %v (%v)\n", qf.Filename, qf.Lnum, qf.Col, a.Title, a.Kind)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
+	"github.com/govim/govim/cmd/govim/internal/types"
+	"github.com/kr/pretty"
+)
+
+const methodGoplsDiagnostics goplsMethodName = "Diagnostics"
+
+func init() {
+	registerGoplsMethod(methodGoplsDiagnostics, func(g *goplsCmd, args []string) Command {
+		return newGoplsDiagnosticsCmd(g, args)
+	})
+}
+
+// goplsDiagnosticsCmd is a sub Command of goplsCmd responsible for dumping
+// the current diagnostics reported by gopls
+type goplsDiagnosticsCmd struct {
+	*goplsCmd
+	fs      *flag.FlagSet
+	fFormat *formatFlag
+	fRel    *bool
+}
+
+func newGoplsDiagnosticsCmd(parent *goplsCmd, args []string) *goplsDiagnosticsCmd {
+	g := &goplsDiagnosticsCmd{
+		goplsCmd: parent,
+	}
+	g.fs = flag.NewFlagSet("goplsDiagnostics", flag.ContinueOnError)
+	g.fFormat = newFormatFlag(g.fs, formatJSON, formatSARIF, formatQuickfix)
+	g.fRel = g.fs.Bool("rel", false, "output filenames relative to the working directory")
+	if err := g.fs.Parse(args); err != nil {
+		g.Errorf("failed to parse args [%v]: %v", strings.Join(args, " "), err)
+	}
+	return g
+}
+
+// Errorf implements Command.Errorf
+func (g *goplsDiagnosticsCmd) Errorf(format string, args ...interface{}) {
+	g.goplsCmd.Errorf("diagnostics: "+format, args...)
+}
+
+// diagnosticJSON is the JSON representation of a diagnostic output by the
+// gopls Diagnostics method
+type diagnosticJSON struct {
+	Filename string       `json:"filename"`
+	Source   string       `json:"source"`
+	Severity string       `json:"severity"`
+	Message  string       `json:"message"`
+	Start    positionJSON `json:"start"`
+	End      positionJSON `json:"end"`
+}
+
+// Run implements Command.Run()
+func (g *goplsDiagnosticsCmd) Run() {
+	g.Logf("goplsDiagnosticsCmd got args: %v", pretty.Sprint(g.fs.Args()))
+	if len(g.fs.Args()) > 0 {
+		g.Errorf("unexpected arguments: %v", strings.Join(g.fs.Args(), " "))
+	}
+	// diags is a "flat" representation of the diagnostics, sorted by
+	// filename, line and column, from which each of the formats is derived.
+	diags := []diagnosticJSON{}
+	g.enqueue(func(v *vimstate) error {
+		for _, d := range *v.diagnostics() {
+			fn, err := v.reportPath(d.Filename, *g.fRel)
+			if err != nil {
+				return err
+			}
+			start, err := positionJSONFromPoint(d.Range.Start)
+			if err != nil {
+				return fmt.Errorf("failed to convert diagnostics: %v", err)
+			}
+			end, err := positionJSONFromPoint(d.Range.End)
+			if err != nil {
+				return fmt.Errorf("failed to convert diagnostics: %v", err)
+			}
+			diags = append(diags, diagnosticJSON{
+				Filename: fn,
+				Source:   d.Source,
+				Severity: severityString(d.Severity),
+				Message:  d.Text,
+				Start:    start,
+				End:      end,
+			})
+		}
+		return nil
+	})
+
+	switch g.fFormat.String() {
+	case formatJSON:
+		g.EncodeStdout(diags)
+	case formatSARIF:
+		g.EncodeStdout(diagnosticsToSARIF(diags, *g.fRel))
+	case formatQuickfix:
+		for _, d := range diags {
+			msg := strings.ReplaceAll(d.Message, "\n", " ")
+			g.PrintfStdout("%v:%v:%v: %v: %v\n", d.Filename, d.Start.Line, d.Start.Col, d.Severity, msg)
+		}
+	}
+}
+
+func severityString(s types.Severity) string {
+	for n, sev := range severityNames {
+		if sev == s {
+			return n
+		}
+	}
+	return fmt.Sprintf("severity(%d)", s)
+}
+
+// SARIF (Static Analysis Results Interchange Format) is described in
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html. Only the
+// subset of the format required to report diagnostics is defined here.
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool       sarifTool     `json:"tool"`
+	ColumnKind string        `json:"columnKind"`
+	Results    []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId,omitempty"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+// sarifLevel maps a severity name to a SARIF result level
+var sarifLevel = map[string]string{
+	"error":   "error",
+	"warning": "warning",
+	"info":    "note",
+	"hint":    "note",
+}
+
+// diagnosticsToSARIF converts diags to a SARIF log with a single run. Columns
+// are reported as Unicode code points. Filenames are reported as file URIs,
+// or as relative references when rel is set.
+func diagnosticsToSARIF(diags []diagnosticJSON, rel bool) sarifLog {
+	results := []sarifResult{}
+	for _, d := range diags {
+		uri := filepath.ToSlash(d.Filename)
+		if !rel {
+			uri = string(protocol.URIFromPath(d.Filename))
+		}
+		level, ok := sarifLevel[d.Severity]
+		if !ok {
+			level = "none"
+		}
+		results = append(results, sarifResult{
+			RuleID:  d.Source,
+			Level:   level,
+			Message: sarifMessage{Text: d.Message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: uri},
+					Region: sarifRegion{
+						StartLine:   d.Start.Line,
+						StartColumn: d.Start.Char,
+						EndLine:     d.End.Line,
+						EndColumn:   d.End.Char,
+					},
+				},
+			}},
+		})
+	}
+	return sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "gopls",
+				InformationURI: "https://pkg.go.dev/golang.org/x/tools/gopls",
+			}},
+			ColumnKind: "unicodeCodePoints",
+			Results:    results,
+		}},
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
+	"github.com/kr/pretty"
+)
+
+const methodGoplsDocumentSymbol goplsMethodName = "DocumentSymbol"
+
+func init() {
+	registerGoplsMethod(methodGoplsDocumentSymbol, func(g *goplsCmd, args []string) Command {
+		return newGoplsDocumentSymbolCmd(g, args)
+	})
+}
+
+// goplsDocumentSymbolCmd is a sub Command of goplsCmd responsible for
+// handling a call to the gopls DocumentSymbol method
+type goplsDocumentSymbolCmd struct {
+	*goplsCmd
+	fs      *flag.FlagSet
+	fFormat *formatFlag
+	fRel    *bool
+}
+
+func newGoplsDocumentSymbolCmd(parent *goplsCmd, args []string) *goplsDocumentSymbolCmd {
+	g := &goplsDocumentSymbolCmd{
+		goplsCmd: parent,
+	}
+	g.fs = flag.NewFlagSet("goplsDocumentSymbol", flag.ContinueOnError)
+	g.fFormat = newFormatFlag(g.fs, formatJSON, formatQuickfix)
+	g.fRel = g.fs.Bool("rel", false, "output filenames relative to the working directory")
+	if err := g.fs.Parse(args); err != nil {
+		g.Errorf("failed to parse args [%v]: %v", strings.Join(args, " "), err)
+	}
+	return g
+}
+
+// Errorf implements Command.Errorf
+func (g *goplsDocumentSymbolCmd) Errorf(format string, args ...interface{}) {
+	g.goplsCmd.Errorf("documentsymbol: "+format, args...)
+}
+
+// documentSymbolJSON is the JSON representation of a symbol output by the
+// gopls DocumentSymbol method
+type documentSymbolJSON struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	locationJSON
+}
+
+// symbolKindNames are the names of the protocol.SymbolKind values
+var symbolKindNames = map[protocol.SymbolKind]string{
+	protocol.File:          "file",
+	protocol.Module:        "module",
+	protocol.Namespace:     "namespace",
+	protocol.Package:       "package",
+	protocol.Class:         "class",
+	protocol.Method:        "method",
+	protocol.Property:      "property",
+	protocol.Field:         "field",
+	protocol.Constructor:   "constructor",
+	protocol.Enum:          "enum",
+	protocol.Interface:     "interface",
+	protocol.Function:      "function",
+	protocol.Variable:      "variable",
+	protocol.Constant:      "constant",
+	protocol.String:        "string",
+	protocol.Number:        "number",
+	protocol.Boolean:       "boolean",
+	protocol.Array:         "array",
+	protocol.Object:        "object",
+	protocol.Key:           "key",
+	protocol.Null:          "null",
+	protocol.EnumMember:    "enummember",
+	protocol.Struct:        "struct",
+	protocol.Event:         "event",
+	protocol.Operator:      "operator",
+	protocol.TypeParameter: "typeparameter",
+}
+
+// Run implements Command.Run()
+func (g *goplsDocumentSymbolCmd) Run() {
+	g.Logf("goplsDocumentSymbolCmd got args: %v", pretty.Sprint(g.fs.Args()))
+	if len(g.fs.Args()) != 1 {
+		g.Errorf("expected a single file argument")
+	}
+	var params protocol.DocumentSymbolParams
+	g.enqueue(func(v *vimstate) error {
+		b, err := v.parseFileArg(g.fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		params.TextDocument = b.ToTextDocumentIdentifier()
+		return nil
+	})
+	res, err := g.goplsServer().DocumentSymbol(context.Background(), &params)
+	if err != nil {
+		g.Errorf("failed to call gopls.DocumentSymbol: %v", err)
+	}
+	// We do not advertise support for hierarchical document symbols, hence
+	// the result is a flat list of symbol information.
+	var syms []protocol.SymbolInformation
+	byts, err := json.Marshal(res)
+	if err != nil {
+		g.Errorf("failed to marshal result: %v", err)
+	}
+	if err := json.Unmarshal(byts, &syms); err != nil {
+		g.Errorf("failed to unmarshal result as symbol information: %v", err)
+	}
+
+	out := []documentSymbolJSON{}
+	var qfs []quickfixEntry
+	g.enqueue(func(v *vimstate) error {
+		for _, s := range syms {
+			l, err := v.locationToJSON(s.Location, *g.fRel)
+			if err != nil {
+				return fmt.Errorf("failed to convert symbol %v: %v", s.Name, err)
+			}
+			kind, ok := symbolKindNames[s.Kind]
+			if !ok {
+				kind = fmt.Sprintf("kind(%d)", s.Kind)
+			}
+			out = append(out, documentSymbolJSON{
+				Name:         s.Name,
+				Kind:         kind,
+				locationJSON: l,
+			})
+			qfs = append(qfs, quickfixEntry{
+				Filename: l.Filename,
+				Lnum:     l.Start.Line,
+				Col:      l.Start.Col,
+				Text:     kind + " " + s.Name,
+			})
+		}
+		return nil
+	})
+	switch g.fFormat.String() {
+	case formatJSON:
+		g.EncodeStdout(out)
+	case formatQuickfix:
+		for _, q := range qfs {
+			g.PrintfStdout("%v:%v:%v: %v\n", q.Filename, q.Lnum, q.Col, q.Text)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strings"
+
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
+	"github.com/kr/pretty"
+)
+
+const methodGoplsHover goplsMethodName = "Hover"
+
+func init() {
+	registerGoplsMethod(methodGoplsHover, func(g *goplsCmd, args []string) Command {
+		return newGoplsHoverCmd(g, args)
+	})
+}
+
+// goplsHoverCmd is a sub Command of goplsCmd responsible for handling a call
+// to the gopls Hover method
+type goplsHoverCmd struct {
+	*goplsCmd
+	fs      *flag.FlagSet
+	fFormat *formatFlag
+}
+
+func newGoplsHoverCmd(parent *goplsCmd, args []string) *goplsHoverCmd {
+	g := &goplsHoverCmd{
+		goplsCmd: parent,
+	}
+	g.fs = flag.NewFlagSet("goplsHover", flag.ContinueOnError)
+	g.fFormat = newFormatFlag(g.fs, formatJSON, formatText)
+	if err := g.fs.Parse(args); err != nil {
+		g.Errorf("failed to parse args [%v]: %v", strings.Join(args, " "), err)
+	}
+	return g
+}
+
+// Errorf implements Command.Errorf
+func (g *goplsHoverCmd) Errorf(format string, args ...interface{}) {
+	g.goplsCmd.Errorf("hover: "+format, args...)
+}
+
+// hoverJSON is the JSON representation of the result of the gopls Hover
+// method. Kind is the kind of markup used by Value.
+type hoverJSON struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Run implements Command.Run()
+func (g *goplsHoverCmd) Run() {
+	g.Logf("goplsHoverCmd got args: %v", pretty.Sprint(g.fs.Args()))
+	if len(g.fs.Args()) != 1 {
+		g.Errorf("expected a single file:line:col argument")
+	}
+	var params protocol.HoverParams
+	g.enqueue(func(v *vimstate) error {
+		p, err := v.parsePositionArg(g.fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		params.TextDocument = p.Buffer().ToTextDocumentIdentifier()
+		params.Position = p.ToPosition()
+		return nil
+	})
+	res, err := g.goplsServer().Hover(context.Background(), &params)
+	if err != nil {
+		g.Errorf("failed to call gopls.Hover: %v", err)
+	}
+	var hov hoverJSON
+	if res != nil {
+		hov = hoverJSON{
+			Kind:  string(res.Contents.Kind),
+			Value: res.Contents.Value,
+		}
+	}
+	switch g.fFormat.String() {
+	case formatJSON:
+		g.EncodeStdout(hov)
+	case formatText:
+		if hov.Value != "" {
+			g.PrintfStdout("%v\n", strings.TrimRight(hov.Value, "\n"))
+		}
+	}
+}
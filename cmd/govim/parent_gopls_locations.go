@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strings"
+
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
+	"github.com/kr/pretty"
+)
+
+const (
+	methodGoplsDefinition     goplsMethodName = "Definition"
+	methodGoplsReferences     goplsMethodName = "References"
+	methodGoplsImplementation goplsMethodName = "Implementation"
+)
+
+func init() {
+	registerGoplsMethod(methodGoplsDefinition, func(g *goplsCmd, args []string) Command {
+		return newGoplsLocationsCmd(g, methodGoplsDefinition, args, func(l *goplsLocationsCmd, pos protocol.TextDocumentPositionParams) ([]protocol.Location, error) {
+			return l.goplsServer().Definition(context.Background(), &protocol.DefinitionParams{
+				TextDocumentPositionParams: pos,
+			})
+		})
+	})
+	registerGoplsMethod(methodGoplsReferences, func(g *goplsCmd, args []string) Command {
+		return newGoplsLocationsCmd(g, methodGoplsReferences, args, func(l *goplsLocationsCmd, pos protocol.TextDocumentPositionParams) ([]protocol.Location, error) {
+			return l.goplsServer().References(context.Background(), &protocol.ReferenceParams{
+				TextDocumentPositionParams: pos,
+				Context: protocol.ReferenceContext{
+					IncludeDeclaration: *l.fDeclaration,
+				},
+			})
+		})
+	})
+	registerGoplsMethod(methodGoplsImplementation, func(g *goplsCmd, args []string) Command {
+		return newGoplsLocationsCmd(g, methodGoplsImplementation, args, func(l *goplsLocationsCmd, pos protocol.TextDocumentPositionParams) ([]protocol.Location, error) {
+			return l.goplsServer().Implementation(context.Background(), &protocol.ImplementationParams{
+				TextDocumentPositionParams: pos,
+			})
+		})
+	})
+}
+
+// goplsLocationsCmd is a sub Command of goplsCmd responsible for handling a
+// call to one of the gopls methods that take a position and return a list of
+// locations: Definition, References and Implementation
+type goplsLocationsCmd struct {
+	*goplsCmd
+	method  goplsMethodName
+	call    func(l *goplsLocationsCmd, pos protocol.TextDocumentPositionParams) ([]protocol.Location, error)
+	fs      *flag.FlagSet
+	fFormat *formatFlag
+	fRel    *bool
+
+	// fDeclaration is only used by References
+	fDeclaration *bool
+}
+
+func newGoplsLocationsCmd(parent *goplsCmd, method goplsMethodName, args []string, call func(*goplsLocationsCmd, protocol.TextDocumentPositionParams) ([]protocol.Location, error)) *goplsLocationsCmd {
+	g := &goplsLocationsCmd{
+		goplsCmd: parent,
+		method:   method,
+		call:     call,
+	}
+	g.fs = flag.NewFlagSet("gopls"+string(method), flag.ContinueOnError)
+	g.fFormat = newFormatFlag(g.fs, formatJSON, formatQuickfix)
+	g.fRel = g.fs.Bool("rel", false, "output filenames relative to the working directory")
+	if method == methodGoplsReferences {
+		g.fDeclaration = g.fs.Bool("declaration", false, "include the declaration of the referenced identifier")
+	}
+	if err := g.fs.Parse(args); err != nil {
+		g.Errorf("failed to parse args [%v]: %v", strings.Join(args, " "), err)
+	}
+	return g
+}
+
+// Errorf implements Command.Errorf
+func (g *goplsLocationsCmd) Errorf(format string, args ...interface{}) {
+	g.goplsCmd.Errorf(strings.ToLower(string(g.method))+": "+format, args...)
+}
+
+// Run implements Command.Run()
+func (g *goplsLocationsCmd) Run() {
+	g.Logf("goplsLocationsCmd(%v) got args: %v", g.method, pretty.Sprint(g.fs.Args()))
+	if len(g.fs.Args()) != 1 {
+		g.Errorf("expected a single file:line:col argument")
+	}
+	var pos protocol.TextDocumentPositionParams
+	g.enqueue(func(v *vimstate) error {
+		p, err := v.parsePositionArg(g.fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		pos.TextDocument = p.Buffer().ToTextDocumentIdentifier()
+		pos.Position = p.ToPosition()
+		return nil
+	})
+	locs, err := g.call(g, pos)
+	if err != nil {
+		g.Errorf("failed to call gopls.%v: %v", g.method, err)
+	}
+	g.outputLocations(locs, g.fFormat.String(), *g.fRel)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
+	"github.com/kr/pretty"
+)
+
+const methodGoplsRename goplsMethodName = "Rename"
+
+func init() {
+	registerGoplsMethod(methodGoplsRename, func(g *goplsCmd, args []string) Command {
+		return newGoplsRenameCmd(g, args)
+	})
+}
+
+// goplsRenameCmd is a sub Command of goplsCmd responsible for handling a call
+// to the gopls Rename method. Only dry runs are supported: the edits that the
+// rename would make are output, not applied.
+type goplsRenameCmd struct {
+	*goplsCmd
+	fs      *flag.FlagSet
+	fFormat *formatFlag
+	fRel    *bool
+	fDryRun *bool
+}
+
+func newGoplsRenameCmd(parent *goplsCmd, args []string) *goplsRenameCmd {
+	g := &goplsRenameCmd{
+		goplsCmd: parent,
+	}
+	g.fs = flag.NewFlagSet("goplsRename", flag.ContinueOnError)
+	g.fFormat = newFormatFlag(g.fs, formatJSON, formatQuickfix)
+	g.fRel = g.fs.Bool("rel", false, "output filenames relative to the working directory")
+	g.fDryRun = g.fs.Bool("dry-run", false, "output the edits that the rename would make instead of applying them (required)")
+	if err := g.fs.Parse(args); err != nil {
+		g.Errorf("failed to parse args [%v]: %v", strings.Join(args, " "), err)
+	}
+	return g
+}
+
+// Errorf implements Command.Errorf
+func (g *goplsRenameCmd) Errorf(format string, args ...interface{}) {
+	g.goplsCmd.Errorf("rename: "+format, args...)
+}
+
+// textEditJSON is the JSON representation of an edit output by the gopls
+// Rename method
+type textEditJSON struct {
+	locationJSON
+	NewText string `json:"newText"`
+}
+
+// Run implements Command.Run()
+func (g *goplsRenameCmd) Run() {
+	g.Logf("goplsRenameCmd got args: %v", pretty.Sprint(g.fs.Args()))
+	if !*g.fDryRun {
+		// Applying edits requires Vim, which is most likely blocked waiting on
+		// this command
+		g.Errorf("only -dry-run is supported; use the GOVIMRename command to apply a rename")
+	}
+	if len(g.fs.Args()) != 2 {
+		g.Errorf("expected file:line:col and new name arguments")
+	}
+	params := protocol.RenameParams{
+		NewName: g.fs.Arg(1),
+	}
+	g.enqueue(func(v *vimstate) error {
+		p, err := v.parsePositionArg(g.fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		params.TextDocument = p.Buffer().ToTextDocumentIdentifier()
+		params.Position = p.ToPosition()
+		return nil
+	})
+	res, err := g.goplsServer().Rename(context.Background(), &params)
+	if err != nil {
+		g.Errorf("failed to call gopls.Rename: %v", err)
+	}
+	var locs []protocol.Location
+	var newTexts []string
+	if res != nil {
+		for _, c := range res.DocumentChanges {
+			if c.TextDocumentEdit == nil {
+				g.Errorf("file renaming not supported")
+			}
+			for _, e := range protocol.AsTextEdits(c.TextDocumentEdit.Edits) {
+				locs = append(locs, protocol.Location{
+					URI:   c.TextDocumentEdit.TextDocument.URI,
+					Range: e.Range,
+				})
+				newTexts = append(newTexts, e.NewText)
+			}
+		}
+	}
+	if g.fFormat.String() == formatQuickfix {
+		g.outputLocations(locs, formatQuickfix, *g.fRel)
+		return
+	}
+	edits := []textEditJSON{}
+	g.enqueue(func(v *vimstate) error {
+		for i, loc := range locs {
+			l, err := v.locationToJSON(loc, *g.fRel)
+			if err != nil {
+				return fmt.Errorf("failed to convert edit: %v", err)
+			}
+			edits = append(edits, textEditJSON{
+				locationJSON: l,
+				NewText:      newTexts[i],
+			})
+		}
+		return nil
+	})
+	sort.SliceStable(edits, func(i, j int) bool {
+		lhs, rhs := edits[i], edits[j]
+		if lhs.Filename != rhs.Filename {
+			return lhs.Filename < rhs.Filename
+		}
+		return lhs.Start.Offset < rhs.Start.Offset
+	})
+	g.EncodeStdout(edits)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strings"
+
+	"github.com/govim/govim"
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
+	"github.com/kr/pretty"
+)
+
+const methodGoplsSymbol goplsMethodName = "Symbol"
+
+func init() {
+	registerGoplsMethod(methodGoplsSymbol, func(g *goplsCmd, args []string) Command {
+		return newGoplsSymbolCmd(g, args)
+	})
+}
+
+// goplsSymbolCmd is a sub Command of goplsCmd responsible for handling a call
+// to the gopls Symbol method
+type goplsSymbolCmd struct {
+	*goplsCmd
+	fs        *flag.FlagSet
+	fQuickfix *bool
+	fRel      *bool
+}
+
+func newGoplsSymbolCmd(parent *goplsCmd, args []string) *goplsSymbolCmd {
+	g := &goplsSymbolCmd{
+		goplsCmd: parent,
+	}
+	g.fs = flag.NewFlagSet("goplsSymbol", flag.ContinueOnError)
+	g.fQuickfix = g.fs.Bool("quickfix", false, "format output in quickfix style")
+	g.fRel = g.fs.Bool("rel", false, "output filenames relative to the working directory (only with -quickfix)")
+	if err := g.fs.Parse(args); err != nil {
+		g.Errorf("failed to parse args [%v]: %v", strings.Join(args, " "), err)
+	}
+	return g
+}
+
+// Errorf implements Command.Errorf
+func (g *goplsSymbolCmd) Errorf(format string, args ...interface{}) {
+	g.goplsCmd.Errorf("symbol: "+format, args...)
+}
+
+// Run implements Command.Run()
+func (g *goplsSymbolCmd) Run() {
+	g.Logf("goplsSymbolCmd got args: %v", pretty.Sprint(g.fs.Args()))
+	if len(g.fs.Args()) == 0 {
+		// no error - simply not results
+		return
+	}
+	query := strings.Join(g.fs.Args(), " ")
+	symbolReq := &protocol.WorkspaceSymbolParams{
+		Query: query,
+	}
+	symbolResp, err := g.goplsServer().Symbol(context.Background(), symbolReq)
+	if err != nil {
+		g.Errorf("failed to call gopls.Symbol: %v", err)
+	}
+
+	if !*g.fQuickfix {
+		// we return the raw LSP response. It's highly unlikely this would ever
+		// be useful because the locations are in terms of UTF16 code points.
+		//
+		// See https://github.com/golang/go/issues/38274
+		g.EncodeStdout(symbolResp)
+		return
+	}
+
+	var qfs []quickfixEntry
+	var qferr error
+	v := g.vimstate
+	done := make(chan struct{})
+	// Note at this point we can't schedule something in Vim because Vim is most likely
+	// blocked waiting on an external command, e.g. fzf. So instead we enqueue a call
+	g.Enqueue(func(_g govim.Govim) error {
+		defer func() {
+			if recover() == nil {
+				close(done)
+			}
+		}()
+		for _, si := range symbolResp {
+			qf, err := v.locationToQuickfix(si.Location, *g.fRel)
+			if err != nil {
+				qferr = err
+				return nil
+			}
+			qf.Text = si.Name
+			qfs = append(qfs, qf)
+		}
+		return nil
+	})
+	<-done
+
+	if qferr != nil {
+		g.Errorf("failed to convert locations to quickfix entires: %v", qferr)
+	}
+
+	if *g.fQuickfix {
+		for _, q := range qfs {
+			g.PrintfStdout("%v:%v:%v: %v\n", q.Filename, q.Lnum, q.Col, q.Text)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package main
+
+import "os"
+
+// processAlive reports whether the process with the given pid is running
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
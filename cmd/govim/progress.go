@@ -9,6 +9,7 @@ import (
 	"github.com/govim/govim"
 	"github.com/govim/govim/cmd/govim/config"
 	"github.com/govim/govim/cmd/govim/internal/types"
+	"github.com/govim/govim/vimfn"
 )
 
 const progressMaxHeight = 10
@@ -25,54 +26,61 @@ func (v *vimstate) handleProgress(popup *types.ProgressPopup, kind, title, messa
 	}
 	switch kind {
 	case "begin":
-		w := v.ParseInt(v.ChannelCall("winwidth", 0))
+		fn := vimfn.New(v.Driver.Govim)
+		w, err := fn.WinWidth(0)
+		if err != nil {
+			return fmt.Errorf("failed to get window width: %v", err)
+		}
 
 		popup.LinePos = 1
-		opts := map[string]interface{}{
-			"pos":       "topright",
-			"line":      popup.LinePos,
-			"col":       w,
-			"padding":   []int{0, 1, 0, 1},
-			"wrap":      false,
-			"close":     "click",
-			"title":     title,
-			"zindex":    300, // same as popup_notification()
-			"mapping":   0,
-			"border":    []string{},
-			"minwidth":  40,
-			"maxwidth":  40,
-			"maxheight": progressMaxHeight,
-			"firstline": firstline,
-			"scrollbar": false,
-			"callback":  "g:GOVIM" + config.FunctionProgressClosed,
+		no := false
+		opts := vimfn.PopupOptions{
+			Pos:       "topright",
+			Line:      popup.LinePos,
+			Col:       w,
+			Padding:   []int{0, 1, 0, 1},
+			Wrap:      &no,
+			Close:     "click",
+			Title:     title,
+			ZIndex:    300, // same as popup_notification()
+			Mapping:   &no,
+			Border:    []int{},
+			MinWidth:  40,
+			MaxWidth:  40,
+			MaxHeight: progressMaxHeight,
+			FirstLine: &firstline,
+			Scrollbar: &no,
+			Callback:  "g:GOVIM" + string(config.FunctionProgressClosed),
+		}
+		popup.ID, err = fn.PopupCreate(lines, opts)
+		if err != nil {
+			return fmt.Errorf("failed to create progress popup: %v", err)
 		}
-		popup.ID = v.ParseInt(v.ChannelCall("popup_create", lines, opts))
 		v.lastProgressText = &popup.Text
 	case "report":
-		opts := map[string]interface{}{
-			"firstline": firstline,
-		}
 		v.BatchStart()
-		v.BatchChannelCall("popup_settext", popup.ID, lines)
-		v.BatchChannelCall("popup_setoptions", popup.ID, opts)
+		b := vimfn.NewBatch(v.currBatch)
+		b.PopupSetText(popup.ID, lines)
+		b.PopupSetOptions(popup.ID, vimfn.PopupOptions{FirstLine: &firstline})
 		v.MustBatchEnd()
 	case "end":
-		opts := map[string]interface{}{
-			"time":      3000, // close after 3 seconds, as popup_notification()
-			"firstline": firstline,
+		opts := vimfn.PopupOptions{
+			Time:      3000, // close after 3 seconds, as popup_notification()
+			FirstLine: &firstline,
 		}
 		if popup.Initiator == types.GoTest {
 			// gopls could run several go test invocations within the same progress
 			// so we must parse the entire output, otherwise we could have relied on
 			// deltas only and update in both "report" and "end".
 			if hl := v.testOutputToHighlight(popup.Text.String()); hl != "" {
-				opts["highlight"] = hl
-				opts["borderhighlight"] = []string{string(hl)}
+				opts.Highlight = string(hl)
+				opts.BorderHighlight = []string{string(hl)}
 			}
 		}
 		v.BatchStart()
-		v.BatchChannelCall("popup_settext", popup.ID, lines)
-		v.BatchChannelCall("popup_setoptions", popup.ID, opts)
+		b := vimfn.NewBatch(v.currBatch)
+		b.PopupSetText(popup.ID, lines)
+		b.PopupSetOptions(popup.ID, opts)
 		v.MustBatchEnd()
 	}
 
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools/jsonrpc2"
+	"github.com/govim/govim/internal/recording"
+)
+
+// recordingStream is a jsonrpc2.Stream that records the messages exchanged
+// with gopls
+type recordingStream struct {
+	jsonrpc2.Stream
+	rec *recording.Writer
+}
+
+func (r recordingStream) Read(ctx context.Context) (jsonrpc2.Message, int64, error) {
+	msg, n, err := r.Stream.Read(ctx)
+	if err == nil {
+		r.record(recording.Recv, msg)
+	}
+	return msg, n, err
+}
+
+func (r recordingStream) Write(ctx context.Context, msg jsonrpc2.Message) (int64, error) {
+	r.record(recording.Send, msg)
+	return r.Stream.Write(ctx, msg)
+}
+
+func (r recordingStream) record(d recording.Direction, msg jsonrpc2.Message) {
+	// The message has just been decoded or is about to be encoded by the
+	// underlying stream, so failing to marshal it here is not expected
+	if byts, err := json.Marshal(msg); err == nil {
+		r.rec.Record(recording.Gopls, d, byts)
+	}
+}
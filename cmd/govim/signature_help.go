@@ -56,9 +56,12 @@ func (v *vimstate) signatureHelp(flags govim.CommandFlags, args ...string) error
 	}
 
 	// Use our locally parsed AST to find where to place this signature
-	<-b.ASTWait
+	fset, astFile, err := b.AST()
+	if err != nil {
+		return err
+	}
 	var file *token.File
-	b.Fset.Iterate(func(f *token.File) bool {
+	fset.Iterate(func(f *token.File) bool {
 		if f.Name() == b.Name {
 			file = f
 			return false
@@ -70,7 +73,7 @@ func (v *vimstate) signatureHelp(flags govim.CommandFlags, args ...string) error
 		return fmt.Errorf("failed to convert Vim point to Pos: %v", err)
 	}
 	var callExpr *ast.CallExpr
-	path, _ := astutil.PathEnclosingInterval(b.AST, pos, pos)
+	path, _ := astutil.PathEnclosingInterval(astFile, pos, pos)
 	if path == nil {
 		return fmt.Errorf("cannot find node enclosing position")
 	}
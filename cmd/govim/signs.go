@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 
+	"github.com/govim/govim"
 	"github.com/govim/govim/cmd/govim/config"
 	"github.com/govim/govim/cmd/govim/internal/types"
 )
@@ -117,7 +118,7 @@ func (v *vimstate) updateSigns(force bool) error {
 
 	v.BatchStart()
 	defer v.BatchCancelIfNotEnded()
-	v.BatchAssertChannelCall(AssertIsZero(), "sign_unplace", signGroup)
+	v.BatchAssertChannelCall(govim.AssertIsZero(), "sign_unplace", signGroup)
 	var placeList []placeDict
 	for _, f := range diags {
 		if f.Buf == -1 {
@@ -144,7 +145,7 @@ func (v *vimstate) updateSigns(force bool) error {
 		// Suppress E158 "Invalid buffer name" when placing signs since we might, in a rare race
 		// case, try to place signs into a buffer that was just closed. Note that vim already accept
 		// sign_placelist() calls with line numbers outside the buffer without throwing any error.
-		v.BatchAssertChannelCall(AssertIsErrorOrNil("^Vim(let):E158:"), "sign_placelist", placeList)
+		v.BatchAssertChannelCall(govim.AssertIsErrorOrNil("^Vim(let):E158:"), "sign_placelist", placeList)
 	}
 	v.MustBatchEnd()
 
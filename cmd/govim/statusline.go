@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+
+	"github.com/govim/govim/cmd/govim/config"
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
+)
+
+// statusline is the state of govim and gopls that is pushed to Vim for use
+// in statuslines. It is read from Vim via GOVIMStatusline(), which is defined
+// in plugin/govim.vim.
+type statusline struct {
+	// Workspace holds the number of diagnostics per severity name (see
+	// severityNames) across the workspace
+	Workspace map[string]int `json:"workspace"`
+
+	// Buffers holds the number of diagnostics per severity name, keyed by
+	// buffer number. Buffers without diagnostics are omitted.
+	Buffers map[string]map[string]int `json:"buffers"`
+
+	// Busy indicates that gopls is reporting progress for at least one
+	// ongoing task
+	Busy bool `json:"busy"`
+
+	// View is the root directory of the workspace folder gopls was started
+	// with, or empty if govim was not started in a module or workspace
+	View string `json:"view"`
+
+	// LastProgressTitle is the title of the most recently begun progress
+	LastProgressTitle string `json:"lastProgressTitle"`
+}
+
+// newSeverityCounts returns a map of severity name to a zero count
+func newSeverityCounts() map[string]int {
+	res := make(map[string]int)
+	for n := range severityNames {
+		res[n] = 0
+	}
+	return res
+}
+
+// trackProgress records the state of the progress identified by token, for
+// the purposes of the statusline, and pushes any change to Vim.
+func (v *vimstate) trackProgress(token protocol.ProgressToken, kind, title string) {
+	switch kind {
+	case "begin":
+		v.activeProgress[token] = title
+		v.lastProgressTitle = title
+	case "end":
+		delete(v.activeProgress, token)
+	}
+	v.updateStatusline()
+}
+
+// updateStatusline pushes the statusline state to Vim if it differs from the
+// state last pushed, in which case Vim fires the User autocommand
+// GOVIMStatusChanged.
+func (v *vimstate) updateStatusline() {
+	st := statusline{
+		Workspace:         newSeverityCounts(),
+		Buffers:           make(map[string]map[string]int),
+		Busy:              len(v.activeProgress) > 0,
+		View:              v.view,
+		LastProgressTitle: v.lastProgressTitle,
+	}
+	for _, d := range *v.diagnostics() {
+		sev := severityString(d.Severity)
+		st.Workspace[sev]++
+		if d.Buf < 0 {
+			continue
+		}
+		bufnr := strconv.Itoa(d.Buf)
+		counts, ok := st.Buffers[bufnr]
+		if !ok {
+			counts = newSeverityCounts()
+			st.Buffers[bufnr] = counts
+		}
+		counts[sev]++
+	}
+	if v.lastStatusline != nil && reflect.DeepEqual(*v.lastStatusline, st) {
+		return
+	}
+	v.lastStatusline = &st
+	v.ChannelCall(v.Prefix()+string(config.FunctionSetStatusline), st)
+}
@@ -116,7 +116,7 @@ func (v *vimstate) badBatch(args ...json.RawMessage) (interface{}, error) {
 func (v *vimstate) assertFailedBatch(args ...json.RawMessage) (interface{}, error) {
 	v.BatchStart()
 	defer v.BatchCancelIfNotEnded()
-	v.BatchAssertChannelExprf(AssertIsZero(), "1")
+	v.BatchAssertChannelExprf(govim.AssertIsZero(), "1")
 	res := v.MustBatchEnd()
 	return res, nil
 }
@@ -137,7 +137,7 @@ func (v *vimstate) ignoreErrorInBatch(args ...json.RawMessage) (interface{}, err
 	v.Parse(args[0], &fail)
 	v.BatchStart()
 	defer v.BatchCancelIfNotEnded()
-	assert := AssertIsErrorOrNil("E971: Property type number does not exist")
+	assert := govim.AssertIsErrorOrNil("E971: Property type number does not exist")
 	if fail {
 		var props = struct {
 			Length int    `json:"length"`
@@ -2,7 +2,6 @@ package main
 
 import (
 	"bytes"
-	"context"
 	"fmt"
 	"os"
 	"sort"
@@ -10,7 +9,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/govim/govim/cmd/govim/config"
+	"github.com/govim/govim"
 	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
 	"github.com/govim/govim/cmd/govim/internal/types"
 )
@@ -110,7 +109,7 @@ func (v *vimstate) applyProtocolTextEdits(b *types.Buffer, edits []protocol.Text
 	// to write.  This is inherently racey... because theorectically the file
 	// might in the meantime have been created by another instance of
 	// govim.... We reduce that risk using the time above
-	tf, err := os.CreateTemp("", strconv.FormatInt(time.Now().UnixNano(), 10))
+	tf, err := os.CreateTemp(v.socketDir, strconv.FormatInt(time.Now().UnixNano(), 10))
 	if err != nil {
 		return fmt.Errorf("failed to create temp undo file: %v", err)
 	}
@@ -127,45 +126,23 @@ func (v *vimstate) applyProtocolTextEdits(b *types.Buffer, edits []protocol.Text
 	preEventIgnore := v.ParseString(v.ChannelExpr("&eventignore"))
 	v.ChannelEx("set eventignore=all")
 	defer v.ChannelExf("set eventignore=%v", preEventIgnore)
-	v.ChannelCall("listener_remove", b.Listener)
-	defer func() {
-		b.Listener = v.ParseInt(v.ChannelCall("listener_add", v.Prefix()+string(config.FunctionEnrichDelta), b.Num))
-	}()
-	v.BatchStart()
-	for _, e := range changes {
-		switch e.call {
-		case "setbufline":
-			v.BatchAssertChannelCall(AssertIsZero(), e.call, b.Num, e.start, e.lines[0])
-		case "deletebufline":
-			v.BatchAssertChannelCall(AssertIsZero(), e.call, b.Num, e.start, e.end)
-		case "appendbufline":
-			v.BatchAssertChannelCall(AssertIsZero(), e.call, b.Num, e.start, e.lines)
-		default:
-			panic(fmt.Errorf("unknown change type: %v", e.call))
+	// The edits are made in a single batch without the listener on the
+	// buffer, the new contents of which are then synced as one change,
+	// notifying gopls via bufferEvent
+	return v.buffersSync.Edit(v.Driver.Govim, b.Num, func(batch *govim.Batch) {
+		for _, e := range changes {
+			switch e.call {
+			case "setbufline":
+				batch.AssertChannelCall(govim.AssertIsZero(), e.call, b.Num, e.start, e.lines[0])
+			case "deletebufline":
+				batch.AssertChannelCall(govim.AssertIsZero(), e.call, b.Num, e.start, e.end)
+			case "appendbufline":
+				batch.AssertChannelCall(govim.AssertIsZero(), e.call, b.Num, e.start, e.lines)
+			default:
+				panic(fmt.Errorf("unknown change type: %v", e.call))
+			}
 		}
-	}
-	v.BatchAssertChannelCall(AssertIsZero(), "listener_flush", b.Num)
-	newContentsRes := v.BatchChannelExprf(`join(getbufline(%v, 0, "$"), "\n")."\n"`, b.Num)
-	v.MustBatchEnd()
-
-	var newContents string
-	v.Parse(newContentsRes(), &newContents)
-	b.SetContents([]byte(newContents))
-	v.triggerBufferASTUpdate(b)
-	b.Version++
-	params := &protocol.DidChangeTextDocumentParams{
-		TextDocument: protocol.VersionedTextDocumentIdentifier{
-			TextDocumentIdentifier: b.ToTextDocumentIdentifier(),
-			Version:                b.Version,
-		},
-		ContentChanges: []protocol.TextDocumentContentChangeEvent{
-			{
-				Text: newContents,
-			},
-		},
-	}
-
-	return v.server.DidChange(context.Background(), params)
+	})
 }
 
 // sortEdits orders edits by (start, end) offset.
@@ -16,23 +16,6 @@ import (
 	"github.com/govim/govim/cmd/govim/internal/types"
 )
 
-const (
-	exprAutocmdCurrBufInfo = `{"Num": eval(expand('<abuf>')), "Name": fnamemodify(bufname(eval(expand('<abuf>'))),':p'), "Contents": join(getbufline(eval(expand('<abuf>')), 0, "$"), "\n")."\n", "Loaded": bufloaded(eval(expand('<abuf>')))}`
-)
-
-// currentBufferInfo is a helper function to unmarshal autocmd current
-// buffer details from expr
-func (v *vimstate) currentBufferInfo(expr json.RawMessage) *types.Buffer {
-	var buf struct {
-		Num      int
-		Name     string
-		Contents string
-		Loaded   int
-	}
-	v.Parse(expr, &buf)
-	return types.NewBuffer(buf.Num, buf.Name, []byte(buf.Contents), buf.Loaded == 1)
-}
-
 // cursorPos returns the current cursor position cp. If the current buffer
 // (i.e. the buffer behind the window in which the cursor is currently found)
 // is being tracked by govim then cp.Point will be set, otherwise it will be
@@ -97,20 +80,10 @@ func (v *vimstate) parseCursorPos(r json.RawMessage) (types.CursorPosition, erro
 }
 
 func (v *vimstate) locationToQuickfix(loc protocol.Location, rel bool) (qf quickfixEntry, err error) {
-	var buf *types.Buffer
-	for _, b := range v.buffers {
-		if b.Loaded && b.URI() == loc.URI {
-			buf = b
-		}
-	}
 	fn := loc.URI.Path()
-	if buf == nil {
-		byts, err := os.ReadFile(fn)
-		if err != nil {
-			return qf, fmt.Errorf("failed to read contents of %v: %v", fn, err)
-		}
-		// create a temp buffer
-		buf = types.NewBuffer(-1, fn, byts, false)
+	buf, err := v.bufferForPath(fn)
+	if err != nil {
+		return qf, err
 	}
 	// make fn relative for reporting purposes
 	if rel {
@@ -136,6 +109,23 @@ func (v *vimstate) locationToQuickfix(loc protocol.Location, rel bool) (qf quick
 	return qf, nil
 }
 
+// bufferForPath returns the loaded buffer for the file fn or, if there is
+// none, a temporary buffer with the contents of fn on disk
+func (v *vimstate) bufferForPath(fn string) (*types.Buffer, error) {
+	uri := protocol.URIFromPath(fn)
+	for _, b := range v.buffers {
+		if b.Loaded && b.URI() == uri {
+			return b, nil
+		}
+	}
+	byts, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contents of %v: %v", fn, err)
+	}
+	// create a temp buffer
+	return types.NewBuffer(-1, fn, byts, false), nil
+}
+
 // populateQuickfix populates and opens a quickfix window with a sorted
 // slice of locations. If shift is true the first element of the slice
 // will be skipped.
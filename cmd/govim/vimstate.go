@@ -7,6 +7,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/govim/govim"
 	"github.com/govim/govim/cmd/govim/config"
 	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
 	"github.com/govim/govim/cmd/govim/internal/types"
@@ -44,7 +45,7 @@ type vimstate struct {
 	popupWinID int
 
 	// currBatch represents the batch we are collecting
-	currBatch *batch
+	currBatch *govim.Batch
 
 	// lastCompleteResults is the last set of error diagnostics we set as quickfix
 	// entries. We use this in order to retain the index in the quickfix list when the
@@ -61,6 +62,11 @@ type vimstate struct {
 	// TODO: handle changes to current working directory during runtime
 	workingDirectory string
 
+	// virtualTexts is the diagnostic virtual text currently added, keyed by
+	// buffer number. It is used to only update buffers whose virtual text
+	// has changed.
+	virtualTexts map[int][]virtualText
+
 	// currentReferences is the range of each LSP documentHighlights under the cursor
 	// It is used to avoid updating the text property when the cursor is moved within the
 	// existing highlights.
@@ -83,6 +89,22 @@ type vimstate struct {
 	// popup.
 	lastProgressText *strings.Builder
 
+	// activeProgress is the set of progress tokens for which gopls has sent a
+	// begin but not yet an end, mapped to their titles. Unlike progressPopups
+	// it is maintained regardless of ExperimentalProgressPopups, and is used to
+	// determine whether gopls is busy.
+	activeProgress map[protocol.ProgressToken]string
+
+	// lastProgressTitle is the title of the most recently begun progress
+	lastProgressTitle string
+
+	// view is the root directory of the workspace folder gopls was started
+	// with
+	view string
+
+	// lastStatusline is the statusline state most recently pushed to Vim
+	lastStatusline *statusline
+
 	// vimgrepPendingBufs contain buffers read during a vimgrep quickfix command,
 	// keyed by buffer number.
 	// The purpose is to avoid sending DidOpen/DidClose notifications to gopls
@@ -155,6 +177,20 @@ func (v *vimstate) setConfig(args ...json.RawMessage) (interface{}, error) {
 		}
 	}
 
+	if !vimconfig.EqualBool(v.config.ExperimentalVirtualTextDiagnostics, preConfig.ExperimentalVirtualTextDiagnostics) {
+		if v.config.ExperimentalVirtualTextDiagnostics == nil || !*v.config.ExperimentalVirtualTextDiagnostics {
+			// ExperimentalVirtualTextDiagnostics is now not on - remove existing virtual text
+			v.removeVirtualText()
+		} else {
+			if !v.hasVirtualText {
+				v.Logf("ExperimentalVirtualTextDiagnostics requires Vim v9.0.0067 or later")
+			}
+			if err := v.updateVirtualText(true); err != nil {
+				return nil, fmt.Errorf("failed to update diagnostic virtual text: %v", err)
+			}
+		}
+	}
+
 	if !vimconfig.EqualBool(v.config.HighlightReferences, preConfig.HighlightReferences) {
 		if v.config.HighlightReferences == nil || !*v.config.HighlightReferences {
 			// HighlightReferences is now not on - remove existing text properties
@@ -169,12 +205,37 @@ func (v *vimstate) setConfig(args ...json.RawMessage) (interface{}, error) {
 	// v.server will be nil when we are Init()-ing govim. The init process
 	// triggers a "manual" call of govim#config#Set() and hence this function
 	// gets called before we have even started gopls.
-	var err error
-	if v.server != nil {
-		err = v.server.DidChangeConfiguration(context.Background(), &protocol.DidChangeConfigurationParams{})
+	if v.server == nil {
+		return nil, nil
 	}
-
-	return nil, err
+	live, init := preConfig.Changes(&v.config)
+	if len(live) == 0 && len(init) == 0 {
+		return nil, nil
+	}
+	for _, f := range live {
+		if f == "LanguageServers" {
+			if err := v.restartLanguageServers(); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if len(init) > 0 {
+		// Changes to fields that are sent as part of the Initialize call
+		// require a restart of gopls. The new instance will pick up the live
+		// changes via its Configuration callback.
+		if err := v.restartGopls(); err != nil {
+			return nil, err
+		}
+	} else if err := v.server.DidChangeConfiguration(context.Background(), &protocol.DidChangeConfigurationParams{}); err != nil {
+		return nil, err
+	}
+	msg := "govim: applied config changes: " + strings.Join(append(live, init...), ", ")
+	if len(init) > 0 {
+		msg += " (restarted gopls)"
+	}
+	v.ChannelExf("echom %q", msg)
+	return nil, nil
 }
 
 func (v *vimstate) popupSelection(args ...json.RawMessage) (interface{}, error) {
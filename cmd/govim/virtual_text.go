@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/govim/govim/cmd/govim/internal/types"
+)
+
+// virtualTextMaxLen is the maximum number of characters of a diagnostic
+// message that is shown as virtual text. Longer messages are truncated.
+const virtualTextMaxLen = 80
+
+// virtualTextSeverities are the severities for which virtual text property
+// types are defined, in order of decreasing severity.
+var virtualTextSeverities = []types.Severity{types.SeverityErr, types.SeverityWarn, types.SeverityInfo, types.SeverityHint}
+
+// virtualText is a diagnostic message shown as virtual text at the end of a
+// line in a buffer.
+type virtualText struct {
+	Line     int
+	Text     string
+	Severity types.Severity
+}
+
+// virtualTextAddDict is the representation of arguments used in vim's
+// prop_add() when adding virtual text
+type virtualTextAddDict struct {
+	Type        string `json:"type"`
+	Text        string `json:"text"`
+	TextAlign   string `json:"text_align"`
+	TextPadding int    `json:"text_padding_left,omitempty"`
+	BufNr       int    `json:"bufnr"`
+}
+
+// updateVirtualText ensures that Vim shows the message of the most severe
+// diagnostic of each line as virtual text. Only buffers whose virtual text
+// differs from what was previously added are updated, unless force is set.
+func (v *vimstate) updateVirtualText(force bool) error {
+	if !v.hasVirtualText || v.config.ExperimentalVirtualTextDiagnostics == nil || !*v.config.ExperimentalVirtualTextDiagnostics {
+		return nil
+	}
+	diagsRef := v.diagnostics()
+	work := v.lastDiagnosticsVirtualText != diagsRef
+	v.lastDiagnosticsVirtualText = diagsRef
+	if !force && !work {
+		return nil
+	}
+
+	// Pick the most severe diagnostic per line. Diagnostics are sorted by
+	// position so the first diagnostic on a line wins a tie.
+	lines := make(map[int]map[int]virtualText)
+	for _, d := range *diagsRef {
+		if d.Buf < 0 {
+			continue
+		}
+		if buf, ok := v.buffers[d.Buf]; !ok || !buf.Loaded {
+			continue
+		}
+		if _, ok := types.SeverityVirtualTextHighlight[d.Severity]; !ok {
+			return fmt.Errorf("failed to find virtual text highlight for severity %v", d.Severity)
+		}
+		bl, ok := lines[d.Buf]
+		if !ok {
+			bl = make(map[int]virtualText)
+			lines[d.Buf] = bl
+		}
+		l := d.Range.Start.Line()
+		if prev, ok := bl[l]; ok && prev.Severity <= d.Severity {
+			continue
+		}
+		bl[l] = virtualText{
+			Line:     l,
+			Text:     virtualTextMessage(d.Text),
+			Severity: d.Severity,
+		}
+	}
+	want := make(map[int][]virtualText)
+	for bufnr, bl := range lines {
+		vts := make([]virtualText, 0, len(bl))
+		for _, vt := range bl {
+			vts = append(vts, vt)
+		}
+		sort.Slice(vts, func(i, j int) bool {
+			return vts[i].Line < vts[j].Line
+		})
+		want[bufnr] = vts
+	}
+
+	v.BatchStart()
+	defer v.BatchCancelIfNotEnded()
+	for bufnr, buf := range v.buffers {
+		if !buf.Loaded {
+			continue // vim removes properties when a buffer is unloaded
+		}
+		have, placed := v.virtualTexts[bufnr]
+		if !force && equalVirtualTexts(have, want[bufnr]) {
+			continue
+		}
+		if placed || force {
+			v.batchRemoveVirtualText(bufnr)
+		}
+		for _, vt := range want[bufnr] {
+			v.BatchAssertChannelCall(assertPropAdd, "prop_add",
+				vt.Line,
+				0,
+				virtualTextAddDict{
+					Type:        string(types.SeverityVirtualTextHighlight[vt.Severity]),
+					Text:        vt.Text,
+					TextAlign:   "after",
+					TextPadding: 2,
+					BufNr:       bufnr,
+				},
+			)
+		}
+	}
+	v.MustBatchEnd()
+	v.virtualTexts = want
+	return nil
+}
+
+// removeVirtualText removes all diagnostic virtual text, regardless of
+// configuration setting.
+func (v *vimstate) removeVirtualText() {
+	var didStart bool
+	if didStart = v.BatchStartIfNeeded(); didStart {
+		defer v.BatchCancelIfNotEnded()
+	}
+	for bufnr, buf := range v.buffers {
+		if !buf.Loaded {
+			continue // vim removes properties when a buffer is unloaded
+		}
+		v.batchRemoveVirtualText(bufnr)
+	}
+	v.virtualTexts = nil
+	v.lastDiagnosticsVirtualText = nil
+	if didStart {
+		v.MustBatchEnd()
+	}
+}
+
+// batchRemoveVirtualText adds calls to the current batch that remove all
+// diagnostic virtual text from buffer bufnr. Virtual text properties are
+// assigned negative IDs by Vim, hence they are removed by type.
+func (v *vimstate) batchRemoveVirtualText(bufnr int) {
+	for _, s := range virtualTextSeverities {
+		v.BatchChannelCall("prop_remove", struct {
+			Type  string `json:"type"`
+			BufNr int    `json:"bufnr"`
+			All   int    `json:"all"`
+		}{string(types.SeverityVirtualTextHighlight[s]), bufnr, 1})
+	}
+}
+
+// virtualTextMessage returns the first line of msg, truncated to
+// virtualTextMaxLen characters.
+func virtualTextMessage(msg string) string {
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		msg = msg[:i]
+	}
+	msg = strings.TrimSpace(msg)
+	if utf8.RuneCountInString(msg) <= virtualTextMaxLen {
+		return msg
+	}
+	r := []rune(msg)
+	return string(r[:virtualTextMaxLen-1]) + "…"
+}
+
+func equalVirtualTexts(a, b []virtualText) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/govim/govim"
 	"github.com/govim/govim/cmd/govim/internal/fswatcher"
 	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
+	"github.com/govim/govim/internal/logging"
 )
 
 type modWatcher struct {
@@ -31,7 +32,7 @@ func (m *modWatcher) close() error { return m.watcher.Close() }
 // module identified by gomodpath
 func newModWatcher(plug *govimplugin, gomodpath string) (*modWatcher, error) {
 	infof := func(format string, args ...interface{}) {
-		plug.Logf("file watcher event: "+format, args...)
+		plug.logger.Debugf(logging.Watcher, "file watcher event: "+format, args...)
 	}
 
 	dirpath := filepath.Dir(gomodpath)
@@ -81,7 +82,7 @@ func (m *modWatcher) watch() {
 				return
 			}
 			// TODO: handle this case better
-			m.Logf("***** file watcher error: %v", err)
+			m.logger.Errorf(logging.Watcher, "file watcher error: %v", err)
 		}
 	}
 }
@@ -145,7 +146,7 @@ func ofInterest(path string) bool {
 func (v *vimstate) handleEvent(event fswatcher.Event) error {
 	// We are handling a filesystem event... so the best we can do is log errors
 	errf := func(format string, args ...interface{}) {
-		v.Logf("**** handleEvent error: "+format, args...)
+		v.logger.Warnf(logging.Watcher, "handleEvent error: "+format, args...)
 	}
 
 	var changeType protocol.FileChangeType
@@ -172,7 +173,7 @@ func (v *vimstate) handleEvent(event fswatcher.Event) error {
 	if err != nil {
 		errf("failed to call server.DidChangeWatchedFiles: %v", err)
 	}
-	v.Logf("handleEvent: handled %v", event)
+	v.logger.Debugf(logging.Watcher, "handleEvent: handled %v", event)
 	return nil
 }
 
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/govim/govim"
+	"github.com/govim/govim/cmd/govim/internal/golang_org_x_tools_gopls/protocol"
+	"github.com/govim/govim/cmd/govim/internal/types"
+)
+
+// addWorkspaceFolder adds dir to the set of workspace folders, and starts a
+// modWatcher for it if dir is the root of a module or workspace. If gopls is
+// running it is notified of the new folder. addWorkspaceFolder returns false
+// if dir is already a workspace folder.
+func (g *govimplugin) addWorkspaceFolder(dir string) (bool, error) {
+	uri := string(protocol.URIFromPath(dir))
+	g.workspaceFoldersLock.Lock()
+	for _, f := range g.workspaceFolders {
+		if f.URI == uri {
+			g.workspaceFoldersLock.Unlock()
+			return false, nil
+		}
+	}
+	folder := protocol.WorkspaceFolder{
+		URI:  uri,
+		Name: filepath.Base(dir),
+	}
+	g.workspaceFolders = append(g.workspaceFolders, folder)
+	delete(g.removedWorkspaceFolders, dir)
+	g.workspaceFoldersLock.Unlock()
+
+	for _, fn := range []string{"go.work", "go.mod"} {
+		spec := filepath.Join(dir, fn)
+		if _, err := os.Stat(spec); err != nil {
+			continue
+		}
+		mw, err := newModWatcher(g, spec)
+		if err != nil {
+			return true, fmt.Errorf("failed to create modWatcher for %v: %v", spec, err)
+		}
+		g.workspaceFoldersLock.Lock()
+		g.modWatchers[dir] = mw
+		g.workspaceFoldersLock.Unlock()
+		break
+	}
+
+	if g.server == nil {
+		// gopls will be initialised with the folder
+		return true, nil
+	}
+	params := &protocol.DidChangeWorkspaceFoldersParams{
+		Event: protocol.WorkspaceFoldersChangeEvent{
+			Added: []protocol.WorkspaceFolder{folder},
+		},
+	}
+	if err := g.server.DidChangeWorkspaceFolders(context.Background(), params); err != nil {
+		return true, fmt.Errorf("failed to add workspace folder %v: %v", dir, err)
+	}
+	return true, nil
+}
+
+// removeWorkspaceFolder removes dir from the set of workspace folders, closing
+// its modWatcher (if any) and notifying gopls. A folder that has been removed
+// is not added again when a buffer is opened within it, only when it is
+// explicitly added via addWorkspaceFolder. removeWorkspaceFolder returns false
+// if dir is not a workspace folder.
+func (g *govimplugin) removeWorkspaceFolder(dir string) (bool, error) {
+	uri := string(protocol.URIFromPath(dir))
+	g.workspaceFoldersLock.Lock()
+	var folder *protocol.WorkspaceFolder
+	for i, f := range g.workspaceFolders {
+		if f.URI == uri {
+			folder = &f
+			g.workspaceFolders = append(g.workspaceFolders[:i:i], g.workspaceFolders[i+1:]...)
+			break
+		}
+	}
+	if folder == nil {
+		g.workspaceFoldersLock.Unlock()
+		return false, nil
+	}
+	g.removedWorkspaceFolders[dir] = true
+	mw := g.modWatchers[dir]
+	delete(g.modWatchers, dir)
+	g.workspaceFoldersLock.Unlock()
+
+	if mw != nil {
+		if err := mw.close(); err != nil {
+			return true, fmt.Errorf("failed to close file watcher for %v: %v", dir, err)
+		}
+	}
+	params := &protocol.DidChangeWorkspaceFoldersParams{
+		Event: protocol.WorkspaceFoldersChangeEvent{
+			Removed: []protocol.WorkspaceFolder{*folder},
+		},
+	}
+	if err := g.server.DidChangeWorkspaceFolders(context.Background(), params); err != nil {
+		return true, fmt.Errorf("failed to remove workspace folder %v: %v", dir, err)
+	}
+	return true, nil
+}
+
+// ensureWorkspaceFolder adds the root of the module containing buffer b as a
+// workspace folder, unless it is already covered by an existing workspace
+// folder: either the module root itself or a folder containing a go.work
+// file. Buffers outside of a module, and modules whose workspace folder has
+// been explicitly removed, are ignored.
+func (v *vimstate) ensureWorkspaceFolder(b *types.Buffer) error {
+	root := moduleRoot(filepath.Dir(b.URI().Path()))
+	if root == "" {
+		return nil
+	}
+	v.workspaceFoldersLock.Lock()
+	covered := v.removedWorkspaceFolders[root]
+	for _, f := range v.workspaceFolders {
+		dir := protocol.DocumentURI(f.URI).Path()
+		if dir == root {
+			covered = true
+			break
+		}
+		if _, err := os.Stat(filepath.Join(dir, "go.work")); err == nil && withinDir(root, dir) {
+			covered = true
+			break
+		}
+	}
+	v.workspaceFoldersLock.Unlock()
+	if covered {
+		return nil
+	}
+	v.Logf("adding workspace folder %v for buffer %v", root, b.Num)
+	_, err := v.addWorkspaceFolder(root)
+	return err
+}
+
+// workspaceFoldersCmd lists the current workspace folders when called
+// without arguments. Otherwise the first argument must be either "add" or
+// "remove", followed by the directories to add to or remove from the set of
+// workspace folders. Relative directories are resolved against Vim's current
+// directory.
+func (v *vimstate) workspaceFoldersCmd(flags govim.CommandFlags, args ...string) error {
+	if len(args) == 0 {
+		v.workspaceFoldersLock.Lock()
+		var dirs []string
+		for _, f := range v.workspaceFolders {
+			dirs = append(dirs, protocol.DocumentURI(f.URI).Path())
+		}
+		v.workspaceFoldersLock.Unlock()
+		v.ChannelExf("echo %q", strings.Join(dirs, "\n"))
+		return nil
+	}
+	var fn func(string) (bool, error)
+	var none string
+	switch args[0] {
+	case "add":
+		fn, none = v.addWorkspaceFolder, "%v is already a workspace folder"
+	case "remove":
+		fn, none = v.removeWorkspaceFolder, "%v is not a workspace folder"
+	default:
+		return fmt.Errorf("unknown subcommand %q; must be add or remove", args[0])
+	}
+	if len(args) == 1 {
+		return fmt.Errorf("%v requires at least one directory", args[0])
+	}
+	wd := v.ParseString(v.ChannelCall("getcwd", -1))
+	for _, dir := range args[1:] {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(wd, dir)
+		}
+		dir = filepath.Clean(dir)
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			return fmt.Errorf("%v is not a directory", dir)
+		}
+		changed, err := fn(dir)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			v.ChannelExf("echom %q", fmt.Sprintf(none, dir))
+		}
+	}
+	return nil
+}
+
+// moduleRoot returns the directory of the go.mod file nearest to dir, walking
+// up the directory tree, or an empty string if there is none.
+func moduleRoot(dir string) string {
+	for {
+		if fi, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil && !fi.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// withinDir reports whether path is dir or is contained within dir
+func withinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
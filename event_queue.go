@@ -1,6 +1,7 @@
 package govim
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -12,35 +13,91 @@ type eventQueueInst struct {
 var _ Govim = eventQueueInst{}
 
 func (e eventQueueInst) ChannelRedraw(force bool) error {
+	if err := e.checkNotInBatch("ChannelRedraw"); err != nil {
+		return err
+	}
 	ch := make(scheduledCallback)
 	err := e.govimImpl.channelRedrawImpl(ch, force)
 	return e.handleUserQError(ch, err, channelRedrawErrMsg, force)
 }
 
 func (e eventQueueInst) ChannelEx(expr string) error {
+	if err := e.checkNotInBatch("ChannelEx"); err != nil {
+		return err
+	}
 	ch := make(scheduledCallback)
 	err := e.govimImpl.channelExImpl(ch, expr)
 	return e.handleUserQError(ch, err, channelExErrMsg, expr)
 }
 
 func (e eventQueueInst) ChannelNormal(expr string) error {
+	if err := e.checkNotInBatch("ChannelNormal"); err != nil {
+		return err
+	}
 	ch := make(scheduledCallback)
 	err := e.govimImpl.channelNormalImpl(ch, expr)
 	return e.handleUserQError(ch, err, channelNormalErrMsg, expr)
 }
 
 func (e eventQueueInst) ChannelExpr(expr string) (json.RawMessage, error) {
+	if err := e.checkNotInBatch("ChannelExpr"); err != nil {
+		return nil, err
+	}
 	ch := make(scheduledCallback)
 	err := e.govimImpl.channelExprImpl(ch, expr)
 	return e.handleUserQValueAndError(ch, err, channelExprErrMsg, expr)
 }
 
 func (e eventQueueInst) ChannelCall(fn string, args ...interface{}) (json.RawMessage, error) {
+	if err := e.checkNotInBatch("ChannelCall"); err != nil {
+		return nil, err
+	}
 	ch := make(scheduledCallback)
 	err := e.govimImpl.channelCallImpl(ch, fn, args...)
 	return e.handleUserQValueAndError(ch, err, channelCallErrMsg, fn, args)
 }
 
+func (e eventQueueInst) ChannelExCtx(ctx context.Context, expr string) *Future {
+	if err := e.checkNotInBatch("ChannelExCtx"); err != nil {
+		return e.newFuture(true, "").start(ctx, err)
+	}
+	return e.channelExCtx(ctx, true, expr)
+}
+
+func (e eventQueueInst) ChannelExprCtx(ctx context.Context, expr string) *Future {
+	if err := e.checkNotInBatch("ChannelExprCtx"); err != nil {
+		return e.newFuture(true, "").start(ctx, err)
+	}
+	return e.channelExprCtx(ctx, true, expr)
+}
+
+func (e eventQueueInst) ChannelCallCtx(ctx context.Context, fn string, args ...interface{}) *Future {
+	if err := e.checkNotInBatch("ChannelCallCtx"); err != nil {
+		return e.newFuture(true, "").start(ctx, err)
+	}
+	return e.channelCallCtx(ctx, true, fn, args...)
+}
+
+// Batch implements Govim.Batch. Only one batch of the event queue can be
+// open at a time.
+func (e eventQueueInst) Batch() (*Batch, error) {
+	if e.queueBatch != nil {
+		return nil, ErrInBatch
+	}
+	b := newBatch(e, func() {
+		e.queueBatch = nil
+	})
+	e.queueBatch = b
+	return b, nil
+}
+
+func (e eventQueueInst) checkNotInBatch(method string) error {
+	if e.queueBatch != nil {
+		return fmt.Errorf("cannot call %v: %w", method, ErrInBatch)
+	}
+	return nil
+}
+
 func (e eventQueueInst) Scheduled() Govim {
 	return e
 }
@@ -0,0 +1,174 @@
+package govim
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Future is the eventual result of a call to Vim made by one of the Ctx
+// variants of the channel methods, e.g. ChannelCallCtx. Many calls can be in
+// flight at once: the call is sent to Vim when the Future is created, and the
+// Future resolves when Vim responds, when the context of the call is done, or
+// when govim shuts down.
+type Future struct {
+	g *govimImpl
+
+	// scheduled indicates the call was made from the event queue, in which
+	// case Result yields the event queue whilst it waits
+	scheduled bool
+
+	// id is the id of the call in callbackResps. It is set by callVim, under
+	// callbackRespsLock.
+	id int
+
+	// errFormat and errArgs describe the error returned when Vim reports that
+	// the call failed; the error reported by Vim is appended to errArgs
+	errFormat string
+	errArgs   []interface{}
+
+	done chan struct{}
+	once sync.Once
+	val  json.RawMessage
+	err  error
+}
+
+func (f *Future) isCallback() {}
+
+func (g *govimImpl) newFuture(scheduled bool, format string, args ...interface{}) *Future {
+	return &Future{
+		g:         g,
+		scheduled: scheduled,
+		errFormat: format,
+		errArgs:   append([]interface{}{}, args...),
+		done:      make(chan struct{}),
+	}
+}
+
+// Done returns a channel that is closed when the Future has resolved. Calls
+// made from the event queue should instead wait via Result, which allows
+// Vim to call govim whilst the call is in progress.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Result waits for the Future to resolve and returns the result of the call.
+// If the context of the call is done before Vim responds, the error is that
+// of the context.
+func (f *Future) Result() (json.RawMessage, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	default:
+	}
+	if !f.scheduled {
+		<-f.done
+		return f.val, f.err
+	}
+	// Yield the event queue whilst we wait, and then take it back via a
+	// piece of work that, in the same way as the delivery of a response to
+	// a scheduledCallback, does not itself flush the queue.
+	g := f.g
+	select {
+	case <-g.tomb.Dying():
+		return nil, ErrShuttingDown
+	case g.flushEvents <- struct{}{}:
+	}
+	select {
+	case <-g.tomb.Dying():
+		return nil, ErrShuttingDown
+	case <-f.done:
+	}
+	resumed := make(chan struct{})
+	g.eventQueue.Add(func() error {
+		close(resumed)
+		return nil
+	})
+	select {
+	case <-g.tomb.Dying():
+		return nil, ErrShuttingDown
+	case <-resumed:
+	}
+	return f.val, f.err
+}
+
+// resolve resolves the Future. Only the first call has any effect.
+func (f *Future) resolve(val json.RawMessage, err error) {
+	f.once.Do(func() {
+		f.val, f.err = val, err
+		close(f.done)
+	})
+}
+
+// resolveResp resolves the Future with a response from Vim
+func (f *Future) resolveResp(resp callbackResp) {
+	if resp.errString != "" {
+		f.resolve(nil, fmt.Errorf(f.errFormat, append(f.errArgs, resp.errString)...))
+		return
+	}
+	f.resolve(resp.val, nil)
+}
+
+// start resolves the Future with err if the call could not be made, and
+// otherwise watches ctx, cancelling the call if ctx is done before Vim
+// responds
+func (f *Future) start(ctx context.Context, err error) *Future {
+	if err != nil {
+		f.resolve(nil, err)
+		return f
+	}
+	g := f.g
+	go func() {
+		select {
+		case <-f.done:
+		case <-ctx.Done():
+			g.cancelCall(f)
+			f.resolve(nil, ctx.Err())
+		case <-g.tomb.Dying():
+			f.resolve(nil, ErrShuttingDown)
+		}
+	}()
+	return f
+}
+
+// cancelCall releases the callbackResps entry of f, if Vim has not yet
+// responded, noting that any later response to the call is to be dropped
+func (g *govimImpl) cancelCall(f *Future) {
+	g.callbackRespsLock.Lock()
+	defer g.callbackRespsLock.Unlock()
+	if ch, ok := g.callbackResps[f.id]; ok && ch == callback(f) {
+		delete(g.callbackResps, f.id)
+		g.cancelledCalls[f.id] = struct{}{}
+	}
+}
+
+// ChannelExCtx implements Govim.ChannelExCtx
+func (g *govimImpl) ChannelExCtx(ctx context.Context, expr string) *Future {
+	return g.channelExCtx(ctx, false, expr)
+}
+
+func (g *govimImpl) channelExCtx(ctx context.Context, scheduled bool, expr string) *Future {
+	f := g.newFuture(scheduled, channelExErrMsg, expr)
+	return f.start(ctx, g.channelExImpl(f, expr))
+}
+
+// ChannelExprCtx implements Govim.ChannelExprCtx
+func (g *govimImpl) ChannelExprCtx(ctx context.Context, expr string) *Future {
+	return g.channelExprCtx(ctx, false, expr)
+}
+
+func (g *govimImpl) channelExprCtx(ctx context.Context, scheduled bool, expr string) *Future {
+	f := g.newFuture(scheduled, channelExprErrMsg, expr)
+	return f.start(ctx, g.channelExprImpl(f, expr))
+}
+
+// ChannelCallCtx implements Govim.ChannelCallCtx
+func (g *govimImpl) ChannelCallCtx(ctx context.Context, fn string, args ...interface{}) *Future {
+	return g.channelCallCtx(ctx, false, fn, args...)
+}
+
+func (g *govimImpl) channelCallCtx(ctx context.Context, scheduled bool, fn string, args ...interface{}) *Future {
+	f := g.newFuture(scheduled, channelCallErrMsg, fn, args)
+	return f.start(ctx, g.channelCallImpl(f, fn, args...))
+}
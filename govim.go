@@ -2,6 +2,7 @@
 package govim
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,15 +11,17 @@ import (
 	"runtime"
 	"runtime/debug"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
-	"time"
 	"unicode"
 	"unicode/utf8"
 
+	"github.com/govim/govim/internal/logging"
 	"github.com/govim/govim/internal/queue"
-	"github.com/kr/pretty"
+	"github.com/govim/govim/internal/traffic"
+	"golang.org/x/mod/semver"
 	"gopkg.in/tomb.v2"
 )
 
@@ -29,6 +32,9 @@ const (
 	funcHandlePref     = "function:"
 	commHandlePref     = "command:"
 	autoCommHandlePref = "autocommand:"
+
+	// trafficSize is the number of messages kept by the traffic recorder
+	trafficSize = 2000
 )
 
 var (
@@ -38,13 +44,15 @@ var (
 type Flavor uint
 
 const (
-	FlavorVim  Flavor = iota // vim
-	FlavorGvim               // gvim
+	FlavorVim    Flavor = iota // vim
+	FlavorGvim                 // gvim
+	FlavorNeovim               // neovim
 )
 
 var Flavors = []Flavor{
 	FlavorVim,
 	FlavorGvim,
+	FlavorNeovim,
 }
 
 // callbackResp is the container for a response from a call to callVim. If the
@@ -76,6 +84,26 @@ type Govim interface {
 	// ChannelRedraw performs a redraw in Vim
 	ChannelRedraw(force bool) error
 
+	// ChannelExCtx executes a ex command in Vim, returning a Future for the
+	// result. The call is cancelled if ctx is done before Vim responds.
+	ChannelExCtx(ctx context.Context, expr string) *Future
+
+	// ChannelExprCtx evaluates and returns the result of expr in Vim,
+	// returning a Future for the result. The call is cancelled if ctx is done
+	// before Vim responds.
+	ChannelExprCtx(ctx context.Context, expr string) *Future
+
+	// ChannelCallCtx evaluates and returns the result of call in Vim,
+	// returning a Future for the result. The call is cancelled if ctx is done
+	// before Vim responds.
+	ChannelCallCtx(ctx context.Context, fn string, args ...interface{}) *Future
+
+	// Batch starts a batch of calls that are made in Vim in a single round
+	// trip when the batch is ended. For the Govim instance of the event
+	// queue, it is an error, ErrInBatch, to start a batch or to make other
+	// calls to Vim whilst a batch is open.
+	Batch() (*Batch, error)
+
 	// DefineFunction defines the named function in Vim. name must begin with a capital
 	// letter. params is the parameters that will be used in the Vim function delcaration.
 	// If params is nil, then "..." is assumed.
@@ -101,9 +129,19 @@ type Govim interface {
 	// between the user and protocol aspects of govim.
 	DoProto(f func() error) error
 
-	// Viewport returns the active Vim viewport
+	// Viewport returns the active Vim viewport. Whilst there are
+	// subscribers to changes in the viewport, see OnViewportChange, this is
+	// the viewport as last pushed by Vim; otherwise Vim is asked for it.
 	Viewport() (Viewport, error)
 
+	// OnViewportChange subscribes f to changes in the viewport, including
+	// the position of the cursor. Rather than govim asking for the viewport,
+	// Vim pushes changes to it as they happen, debounced, and govim keeps the
+	// viewport cached; f is called via the event queue with the viewport
+	// once it has changed. An error returned by f is logged. cancel ends the
+	// subscription; once no subscriptions remain, Vim stops pushing changes.
+	OnViewportChange(f func(g Govim, vp Viewport) error) (cancel func(), err error)
+
 	// Errorf raises a formatted fatal error
 	Errorf(format string, args ...interface{})
 
@@ -140,13 +178,16 @@ type Govim interface {
 }
 
 type govimImpl struct {
-	in      *json.Decoder
-	out     *json.Encoder
-	log     io.Writer
-	logFile *os.File
+	// transport carries the messages exchanged with Vim
+	transport transport
+	logger    *logging.Logger
+	logFile   *os.File
+
+	// traffic records the messages exchanged with Vim
+	traffic *traffic.Recorder
 
-	// outLock synchronises access to out to ensure we have non-overlapping
-	// sending of messages
+	// outLock synchronises access to transport to ensure we have
+	// non-overlapping sending of messages
 	outLock sync.Mutex
 
 	funcHandlers     map[string]handler
@@ -163,12 +204,35 @@ type govimImpl struct {
 	callbackResps     map[int]callback
 	callbackRespsLock sync.Mutex
 
+	// cancelledCalls are the ids of calls to Vim that were cancelled before
+	// Vim responded; late responses to them are dropped. Guarded by
+	// callbackRespsLock.
+	cancelledCalls map[int]struct{}
+
 	scheduleVimNextID  int
 	scheduledCalls     map[int]func(Govim) error
 	scheduledCallsLock sync.Mutex
 
 	autocmdNextID int
 
+	// viewport is the viewport cached on behalf of the subscribers to
+	// changes in it, viewportSubs, and returned by Viewport, once the subscription with Vim has been
+	// made, and viewportPending the changes that arrive before then. All are
+	// guarded by viewportLock. viewportCallLock serialises subscribing with
+	// Vim and unsubscribing again.
+	viewportCallLock   sync.Mutex
+	viewportLock       sync.Mutex
+	viewport           *Viewport
+	viewportPending    []viewportDelta
+	viewportSubs       map[int]viewportSubscriber
+	viewportNextID     int
+	viewportSubscribed bool
+
+	// queueBatch is the open batch of the event queue instance, if any. It
+	// is only accessed from the event queue, and cancelled by
+	// runEventQueue should a piece of work leave it open.
+	queueBatch *Batch
+
 	loaded      chan struct{}
 	initialized chan struct{}
 
@@ -198,12 +262,23 @@ type unscheduledCallback chan callbackResp
 
 func (u unscheduledCallback) isCallback() {}
 
+// NewGovim creates a new govim instance for plug that communicates with Vim
+// via in and out. If log is a *logging.Logger it is used as is; otherwise
+// messages for all subsystems are written to log in the text format.
 func NewGovim(plug Plugin, in io.Reader, out io.Writer, log io.Writer, logFile *os.File, t *tomb.Tomb) (Govim, error) {
+	return newGovim(plug, newJSONTransport(in, out), log, logFile, t), nil
+}
+
+func newGovim(plug Plugin, tr transport, log io.Writer, logFile *os.File, t *tomb.Tomb) *govimImpl {
+	logger, ok := log.(*logging.Logger)
+	if !ok {
+		logger = logging.New(log, logging.Text, logging.Levels{Default: logging.Debug})
+	}
 	g := &govimImpl{
-		in:      json.NewDecoder(in),
-		out:     json.NewEncoder(out),
-		log:     log,
-		logFile: logFile,
+		transport: tr,
+		logger:    logger,
+		logFile:   logFile,
+		traffic:   traffic.NewRecorder(trafficSize),
 
 		funcHandlers: make(map[string]handler),
 
@@ -216,16 +291,20 @@ func NewGovim(plug Plugin, in io.Reader, out io.Writer, log io.Writer, logFile *
 
 		flushEvents: make(chan struct{}),
 
-		callVimNextID: 1,
-		callbackResps: make(map[int]callback),
+		callVimNextID:  1,
+		callbackResps:  make(map[int]callback),
+		cancelledCalls: make(map[int]struct{}),
 
 		scheduleVimNextID: 1,
 		scheduledCalls:    make(map[int]func(Govim) error),
 
+		viewportSubs: make(map[int]viewportSubscriber),
+
 		instanceID: fmt.Sprintf("#%d", atomic.AddUint64(&uniqueID, 1)),
 	}
+	logger.SetInstanceID(g.instanceID)
 
-	return g, nil
+	return g
 }
 
 func (g *govimImpl) Scheduled() Govim {
@@ -277,7 +356,7 @@ func (g *govimImpl) goHandleShutdown(f func() error) {
 			}
 		}()
 		if err := f(); err != nil && err != ErrShuttingDown {
-			g.Logf("** Tomb returned error: %v", err)
+			g.logger.Errorf(logging.Govim, "** Tomb returned error: %v", err)
 			return err
 		}
 		return nil
@@ -298,12 +377,12 @@ func (g *govimImpl) load() error {
 	if g.logFile != nil {
 		g.ChannelEx(`let s:govim_logfile="` + g.logFile.Name() + `"`)
 	}
-	g.Logf("Go version %v", runtime.Version())
+	g.logger.Infof(logging.Govim, "Go version %v", runtime.Version())
 
 	if bi, ok := debug.ReadBuildInfo(); ok {
-		g.Logf("Build info: %v", pretty.Sprint(bi))
+		g.logger.Infof(logging.Govim, "Build info: %v", logging.Pretty(bi))
 	} else {
-		g.Logf("No build info available")
+		g.logger.Infof(logging.Govim, "No build info available")
 	}
 
 	if g.plugin != nil {
@@ -314,24 +393,13 @@ func (g *govimImpl) load() error {
 		})
 
 		err := g.DoProto(func() error {
-			var details struct {
-				Version     string
-				VersionLong int
-				GuiRunning  int
-			}
-
-			v, err := g.ChannelExpr(`{"VersionLong": exists("v:versionlong")?v:versionlong:-1, "GuiRunning": has("gui_running")}`)
-			if err != nil {
+			if err := g.detectFlavorVersion(); err != nil {
 				return err
 			}
-			g.decodeJSON(v, &details)
-			g.version = ParseVersionLong(details.VersionLong)
-			if details.GuiRunning == 1 {
-				g.flavor = FlavorGvim
-			} else {
-				g.flavor = FlavorVim
+			g.logger.Infof(logging.Govim, "Loaded against %v %v\n", g.flavor, g.version)
+			if err := g.enableVim9(); err != nil {
+				return err
 			}
-			g.Logf("Loaded against %v %v\n", g.flavor, g.version)
 
 			return g.plugin.Init(g, g.pluginErrCh)
 		})
@@ -354,6 +422,64 @@ func (g *govimImpl) load() error {
 	return nil
 }
 
+// detectFlavorVersion determines the flavor and version of the editor to
+// which we are connected. A Neovim instance is known to be such from the
+// outset; its version is that reported by api_info().
+func (g *govimImpl) detectFlavorVersion() error {
+	if g.flavor == FlavorNeovim {
+		var version struct {
+			Major, Minor, Patch int
+		}
+		v, err := g.ChannelExpr(`api_info().version`)
+		if err != nil {
+			return err
+		}
+		g.decodeJSON(v, &version)
+		g.version = fmt.Sprintf("v%v.%v.%v", version.Major, version.Minor, version.Patch)
+		return nil
+	}
+
+	var details struct {
+		Version     string
+		VersionLong int
+		GuiRunning  int
+	}
+
+	v, err := g.ChannelExpr(`{"VersionLong": exists("v:versionlong")?v:versionlong:-1, "GuiRunning": has("gui_running")}`)
+	if err != nil {
+		return err
+	}
+	g.decodeJSON(v, &details)
+	g.version = ParseVersionLong(details.VersionLong)
+	if details.GuiRunning == 1 {
+		g.flavor = FlavorGvim
+	} else {
+		g.flavor = FlavorVim
+	}
+	return nil
+}
+
+// minVim9Version is the earliest version of Vim against which govim uses
+// Vim9 script. It must match the v:version >= 900 guard around the Vim9
+// script in plugin/govim.vim: the Vim9 script of the 8.2 patches that have
+// it is not that of Vim 9.
+const minVim9Version = "v9.0.0"
+
+// enableVim9 switches plugin/govim.vim to defining functions as Vim9 def
+// functions, and to sending changes in buffers as notifications, if the Vim
+// to which we are connected supports it. It must be called before the plugin
+// defines any functions.
+func (g *govimImpl) enableVim9() error {
+	if g.flavor == FlavorNeovim || semver.Compare(g.version, minVim9Version) < 0 {
+		return nil
+	}
+	if _, err := g.ChannelCall("s:enableVim9"); err != nil {
+		return fmt.Errorf("failed to enable Vim9 script: %v", err)
+	}
+	g.logger.Infof(logging.Govim, "Using Vim9 script")
+	return nil
+}
+
 // funcHandler returns the
 func (g *govimImpl) funcHandler(name string) (string, interface{}) {
 	g.funcHandlersLock.Lock()
@@ -418,7 +544,7 @@ func (g *govimImpl) run() error {
 
 	// the read loop
 	for {
-		g.Logf("run: waiting to read a JSON message\n")
+		g.logVimEventf("run: waiting to read a JSON message\n")
 		id, msg := g.readJSONMsg()
 		g.logVimEventf("recvJSONMsg: [%v] %s\n", id, msg)
 		args := g.parseJSONArgSlice(msg)
@@ -439,14 +565,27 @@ func (g *govimImpl) run() error {
 				errString: msg,
 				val:       val,
 			}
+			var respErr error
+			if msg != "" {
+				respErr = errors.New(msg)
+			}
+			g.traffic.Response(logging.Channel, traffic.Recv, strconv.Itoa(id), respErr, val)
 			g.callbackRespsLock.Lock()
 			ch, ok := g.callbackResps[id]
 			delete(g.callbackResps, id)
+			_, cancelled := g.cancelledCalls[id]
+			delete(g.cancelledCalls, id)
 			g.callbackRespsLock.Unlock()
 			if !ok {
+				if cancelled {
+					g.logVimEventf("run: dropping response for cancelled callback %v\n", id)
+					continue
+				}
 				g.errProto("run: received response for callback %v, but not response chan defined", id)
 			}
 			switch ch := ch.(type) {
+			case *Future:
+				ch.resolveResp(toSend)
 			case scheduledCallback:
 				g.eventQueue.Add(func() error {
 					select {
@@ -471,6 +610,7 @@ func (g *govimImpl) run() error {
 		case "function":
 			fname := g.parseString(args[0])
 			fargs := args[1:]
+			g.traffic.Request(logging.Channel, traffic.Recv, strconv.Itoa(id), fname, fargs)
 			fname, f := g.funcHandler(fname)
 			var line1, line2 int
 			var call func() (interface{}, error)
@@ -534,16 +674,17 @@ func (g *govimImpl) run() error {
 				}()
 				if err != nil {
 					errStr := fmt.Sprintf("got error whilst handling %v: %v", fname, err)
-					g.Logf(errStr)
+					g.logger.Errorf(logging.Govim, "%v", errStr)
 					resp[0] = errStr
 				} else {
 					resp[1] = res
 				}
-				g.sendJSONMsg(id, resp)
+				g.respond(id, resp)
 				return nil
 			})
 		case "schedule":
 			schedId := g.parseInt(args[0])
+			g.traffic.Request(logging.Channel, traffic.Recv, strconv.Itoa(id), "schedule", schedId)
 			g.scheduledCallsLock.Lock()
 			f, ok := g.scheduledCalls[schedId]
 			if !ok {
@@ -569,12 +710,18 @@ func (g *govimImpl) run() error {
 				}()
 				if err != nil {
 					errStr := fmt.Sprintf("got error whilst handling scheduled callback %v: %v", schedId, err)
-					g.Logf(errStr)
+					g.logger.Errorf(logging.Govim, "%v", errStr)
 					resp[0] = errStr
 				}
-				g.sendJSONMsg(id, resp)
+				g.respond(id, resp)
 				return nil
 			})
+		case "viewport":
+			// A notification of a change in the viewport; there is no response
+			var d viewportDelta
+			g.decodeJSON(args[0], &d)
+			g.traffic.Notification(logging.Channel, traffic.Recv, "viewport", d)
+			g.applyViewportDelta(d)
 		case "log":
 			var is []interface{}
 			for _, a := range args {
@@ -582,23 +729,35 @@ func (g *govimImpl) run() error {
 				g.decodeJSON(a, &i)
 				is = append(is, i)
 			}
-			fmt.Fprintln(g.log, is...)
+			g.traffic.Notification(logging.Channel, traffic.Recv, "log", is)
+			g.logger.Debugf(logging.Channel, "%v", strings.TrimSuffix(fmt.Sprintln(is...), "\n"))
 		case "shutdown":
+			g.traffic.Request(logging.Channel, traffic.Recv, strconv.Itoa(id), "shutdown", nil)
 			g.eventQueue.Add(func() error {
 				resp := [2]interface{}{"", ""}
 				err := g.plugin.Shutdown()
 				if err != nil {
 					errStr := fmt.Sprintf("got error whilst handling shutdown: %v", err)
-					g.Logf(errStr)
+					g.logger.Errorf(logging.Govim, "%v", errStr)
 					resp[0] = errStr
 				}
-				g.sendJSONMsg(id, resp)
+				g.respond(id, resp)
 				return nil
 			})
 		}
 	}
 }
 
+// respond sends resp in response to the request from Vim identified by id
+func (g *govimImpl) respond(id int, resp [2]interface{}) {
+	var err error
+	if s, _ := resp[0].(string); s != "" {
+		err = errors.New(s)
+	}
+	g.traffic.Response(logging.Channel, traffic.Send, strconv.Itoa(id), err, resp[1])
+	g.sendJSONMsg(id, resp)
+}
+
 func (g *govimImpl) runEventQueue() error {
 	q := g.eventQueue
 GetWork:
@@ -620,6 +779,13 @@ GetWork:
 			return ErrShuttingDown
 		case <-g.flushEvents:
 		}
+		// The work has either finished or is waiting on Vim, which it
+		// cannot do with a batch open. A batch that the work left open
+		// would fail the calls of all the work that follows.
+		if b := g.queueBatch; b != nil {
+			g.logger.Errorf(logging.Govim, "cancelling batch of %v calls left open on the event queue", b.Len())
+			b.Cancel()
+		}
 	}
 }
 
@@ -850,7 +1016,15 @@ func (g *govimImpl) callVim(ch callback, typ string, vs ...interface{}) error {
 	id := g.callVimNextID
 	g.callVimNextID++
 	g.callbackResps[id] = ch
+	if f, ok := ch.(*Future); ok {
+		f.id = id
+	}
 	g.callbackRespsLock.Unlock()
+	method := typ
+	if len(vs) > 0 && typ == "call" && g.traffic.Recording() {
+		method += fmt.Sprintf(" %v", vs[0])
+	}
+	g.traffic.Request(logging.Channel, traffic.Send, strconv.Itoa(id), method, vs)
 	args := []interface{}{id, typ}
 	args = append(args, vs...)
 	g.sendJSONMsg(0, args)
@@ -862,16 +1036,15 @@ func (g *govimImpl) callVim(ch callback, typ string, vs ...interface{}) error {
 // more specific in our return type. See
 // https://vimhelp.org/channel.txt.html#channel-use for more details.
 func (g *govimImpl) readJSONMsg() (int, json.RawMessage) {
-	var msg [2]json.RawMessage
-	if err := g.in.Decode(&msg); err != nil {
+	id, msg, err := g.transport.read()
+	if err != nil {
 		if err == io.EOF {
 			// explicitly setting underlying here
 			panic(errProto{underlying: err})
 		}
 		g.errProto("failed to read JSON msg: %v", err)
 	}
-	i := g.parseInt(msg[0])
-	return i, msg[1]
+	return id, msg
 }
 
 // parseJSONArgSlice is a low-level protocol primitive for parsing a slice of
@@ -900,18 +1073,16 @@ func (g *govimImpl) parseInt(m json.RawMessage) int {
 
 // sendJSONMsg is a low-level protocol primitive for sending a JSON msg that will be
 // understood by Vim. See https://vimhelp.org/channel.txt.html#channel-use
-func (g *govimImpl) sendJSONMsg(p1, p2 interface{}, ps ...interface{}) {
-	msg := []interface{}{p1, p2}
-	msg = append(msg, ps...)
+func (g *govimImpl) sendJSONMsg(id int, payload interface{}) {
 	// TODO: could use a multi-writer here
-	logMsg, err := json.Marshal(msg)
+	logMsg, err := json.Marshal([]interface{}{id, payload})
 	if err != nil {
 		g.errProto("failed to create log message: %v", err)
 	}
 	g.logVimEventf("sendJSONMsg: %s\n", logMsg)
 	g.outLock.Lock()
 	defer g.outLock.Unlock()
-	if err := g.out.Encode(msg); err != nil {
+	if err := g.transport.write(id, payload); err != nil {
 		panic(ErrShuttingDown)
 	}
 }
@@ -940,18 +1111,26 @@ func (g *govimImpl) Errorf(format string, args ...interface{}) {
 	g.tomb.Kill(fmt.Errorf(format+"\n%s", args...))
 }
 
+// logVimEventf logs a message about the channel between Vim and govim
 func (g *govimImpl) logVimEventf(format string, args ...interface{}) {
-	g.Logf("vim start =======================\n"+format+"vim end =======================\n", args...)
+	g.logger.Debugf(logging.Channel, format, args...)
 }
 
 func (g *govimImpl) Logf(format string, args ...interface{}) {
-	s := fmt.Sprintf(format, args...)
-	if s[len(s)-1] == '\n' {
-		s = s[:len(s)-1]
-	}
-	t := time.Now().Format("2006-01-02T15:04:05.000000")
-	s = strings.Replace(s, "\n", "\n"+t+"_"+g.instanceID+": ", -1)
-	fmt.Fprint(g.log, t+"_"+g.instanceID+": "+s+"\n")
+	g.logger.Debugf(logging.Govim, format, args...)
+}
+
+// Traffic returns the recorder of the messages exchanged between Vim and the
+// govim instance. Plugins within this module use it to record their own
+// traffic, e.g. with gopls.
+func (g *govimImpl) Traffic() *traffic.Recorder {
+	return g.traffic
+}
+
+// Logger returns the logger used by the govim instance, allowing plugins
+// within this module to log against a specific subsystem and level.
+func (g *govimImpl) Logger() *logging.Logger {
+	return g.logger
 }
 
 func (g *govimImpl) Version() string {
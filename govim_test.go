@@ -5,6 +5,7 @@
 package govim_test
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -15,9 +16,12 @@ import (
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/govim/govim"
+	"github.com/govim/govim/bufsync"
 	"github.com/govim/govim/internal/plugin"
+	"github.com/govim/govim/popup"
 	"github.com/govim/govim/testdriver"
 	"github.com/govim/govim/testsetup"
 	"github.com/rogpeppe/go-internal/testscript"
@@ -31,7 +35,8 @@ func TestMain(m *testing.M) {
 	// Snapshot PATH since it is prepended by testscript.
 	os.Setenv(testsetup.EnvTestPathEnv, os.Getenv("PATH"))
 	os.Exit(testscript.RunMain(m, map[string]func() int{
-		"vim": testdriver.Vim,
+		"vim":         testdriver.Vim,
+		"vimexprwait": testdriver.VimExprWait,
 	}))
 }
 
@@ -172,6 +177,22 @@ func TestScripts(t *testing.T) {
 type testplugin struct {
 	plugin.Driver
 	*testpluginvim
+
+	// cursor is the cursor position in the viewport most recently pushed
+	// to the plugin, and stopViewport ends the subscription to changes in
+	// the viewport
+	cursorLock   sync.Mutex
+	cursor       govim.Cursor
+	stopViewport func()
+
+	// popups and buffers are nil under Neovim, which has neither popups nor
+	// listeners
+	popups  *popup.Manager
+	buffers *bufsync.Sync
+
+	// picked is the item most recently picked from the picker of PickFruit
+	pickedLock sync.Mutex
+	picked     string
 }
 
 type testpluginvim struct {
@@ -205,6 +226,34 @@ func (t *testplugin) Init(g govim.Govim, errCh chan error) (err error) {
 	t.DefineFunction("Func2", []string{}, t.func2)
 	t.DefineFunction("TriggerUnscheduled", []string{}, t.triggerUnscheduled)
 	t.DefineFunction("VersionCheck", []string{}, t.versionCheck)
+	t.DefineFunction("Pipelined", []string{}, t.pipelined)
+	t.DefineFunction("TimedOut", []string{}, t.timedOut)
+	t.DefineFunction("LastCursor", []string{}, t.lastCursor)
+	t.DefineFunction("StopViewport", []string{}, t.stopViewportChanges)
+	t.DefineFunction("PickFruit", []string{}, t.pickFruit)
+	t.DefineFunction("LastPicked", []string{}, t.lastPicked)
+	t.DefineFunction("SyncedBuffer", []string{}, t.syncedBuffer)
+	t.DefineFunction("ListenBad", []string{}, t.listenBad)
+	if g.Flavor() != govim.FlavorNeovim {
+		if t.popups, err = popup.NewManager(g, "TestPopup"); err != nil {
+			return err
+		}
+		t.buffers, err = bufsync.New(g, "TestSync", bufsync.Config{
+			Patterns: govim.Patterns{"*.go"},
+			Parsers:  []bufsync.Parser{bufsync.GoParser{}},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	t.stopViewport, err = g.OnViewportChange(t.viewportChanged)
+	return err
+}
+
+func (t *testplugin) viewportChanged(g govim.Govim, vp govim.Viewport) error {
+	t.cursorLock.Lock()
+	defer t.cursorLock.Unlock()
+	t.cursor = vp.Cursor
 	return nil
 }
 
@@ -286,3 +335,80 @@ func (t *testpluginvim) triggerUnscheduled(args ...json.RawMessage) (interface{}
 func (t *testpluginvim) versionCheck(args ...json.RawMessage) (interface{}, error) {
 	return fmt.Sprintf("%v %v", t.Flavor(), t.Version()), nil
 }
+
+func (t *testpluginvim) pipelined(args ...json.RawMessage) (interface{}, error) {
+	// Have several calls in flight at once, one of which calls back into
+	// govim, and wait for them in the reverse order
+	ctx := context.Background()
+	futures := []*govim.Future{
+		t.ChannelExprCtx(ctx, "1+1"),
+		t.ChannelCallCtx(ctx, "Func2"),
+		t.ChannelExCtx(ctx, "let g:pipelined = 1"),
+	}
+	var parts []string
+	for i := len(futures) - 1; i >= 0; i-- {
+		res, err := futures[i].Result()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, string(res))
+	}
+	return strings.Join(parts, " "), nil
+}
+
+func (t *testpluginvim) timedOut(args ...json.RawMessage) (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := t.ChannelExCtx(ctx, "sleep 1").Result()
+	return fmt.Sprint(err), nil
+}
+
+func (t *testpluginvim) lastCursor(args ...json.RawMessage) (interface{}, error) {
+	t.cursorLock.Lock()
+	defer t.cursorLock.Unlock()
+	return fmt.Sprintf("%v:%v:%v", t.cursor.BufNr, t.cursor.Line, t.cursor.Col), nil
+}
+
+func (t *testpluginvim) stopViewportChanges(args ...json.RawMessage) (interface{}, error) {
+	t.stopViewport()
+	return nil, nil
+}
+
+func (t *testpluginvim) pickFruit(args ...json.RawMessage) (interface{}, error) {
+	_, err := t.popups.NewPicker(t.Govim, popup.PickerConfig{
+		Items: []string{"apple", "banana", "cherry"},
+		OnSelect: func(g govim.Govim, index int) error {
+			t.pickedLock.Lock()
+			defer t.pickedLock.Unlock()
+			t.picked = []string{"apple", "banana", "cherry"}[index]
+			return nil
+		},
+	})
+	return nil, err
+}
+
+func (t *testpluginvim) lastPicked(args ...json.RawMessage) (interface{}, error) {
+	t.pickedLock.Lock()
+	defer t.pickedLock.Unlock()
+	return t.picked, nil
+}
+
+// listenBad adds a listener to the current buffer that sends changes to Bad,
+// which fails
+func (t *testpluginvim) listenBad(args ...json.RawMessage) (interface{}, error) {
+	t.ChannelCall("s:listenerAdd", "Bad", t.ParseInt(t.ChannelExpr("bufnr()")))
+	return nil, nil
+}
+
+func (t *testpluginvim) syncedBuffer(args ...json.RawMessage) (interface{}, error) {
+	b := t.buffers.Buffer(t.ParseInt(t.ChannelExpr("bufnr()")))
+	if b == nil {
+		return nil, fmt.Errorf("buffer is not synced")
+	}
+	parsed, err := b.Parsed()
+	if err != nil {
+		return nil, err
+	}
+	f := parsed.(*bufsync.GoFile).File
+	return fmt.Sprintf("v%v %v %v %q", b.Version, f.Name.Name, len(f.Decls), b.Contents()), nil
+}
@@ -0,0 +1,242 @@
+package govimtest
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/govim/govim"
+)
+
+// autocmd is an autocommand defined by govim
+type autocmd struct {
+	handler string
+	def     string
+
+	group    string
+	events   []string
+	patterns []string
+	nested   bool
+	exprs    []string
+}
+
+// acContext is the context in which an autocommand runs, as per
+// expand("<abuf>"), expand("<afile>") and expand("<amatch>")
+type acContext struct {
+	buf   *buffer
+	file  string
+	match string
+}
+
+// events maps the lower case names of the events known to govim to their
+// canonical names
+var events = func() map[string]string {
+	res := make(map[string]string)
+	for e := govim.Event(0); ; e++ {
+		s := e.String()
+		if strings.HasPrefix(s, "Event(") {
+			break
+		}
+		res[strings.ToLower(s)] = canonicalEvent(s)
+	}
+	return res
+}()
+
+// canonicalEvent returns the name of the event of which e is an alias, or e
+func canonicalEvent(e string) string {
+	switch e {
+	case "BufRead":
+		return "BufReadPost"
+	case "BufWrite":
+		return "BufWritePre"
+	case "BufCreate":
+		return "BufAdd"
+	}
+	return e
+}
+
+// lookupEvent returns the canonical name of the event e
+func lookupEvent(e string) (string, error) {
+	c, ok := events[strings.ToLower(e)]
+	if !ok {
+		return "", fmt.Errorf("E216: No such group or event: %v", e)
+	}
+	return c, nil
+}
+
+// defineAutocmd defines the autocommand with handler, as per
+// s:defineAutoCommand in plugin/govim.vim. def is of the form "[group]
+// events patterns [nested]".
+func (v *Vim) defineAutocmd(handler, def string, exprs []string) error {
+	fields := strings.Fields(def)
+	ac := &autocmd{
+		handler: handler,
+		def:     def,
+		exprs:   exprs,
+	}
+	if len(fields) > 0 {
+		if _, err := lookupEvent(strings.Split(fields[0], ",")[0]); err != nil {
+			ac.group, fields = fields[0], fields[1:]
+		}
+	}
+	if len(fields) > 0 && fields[len(fields)-1] == "nested" {
+		ac.nested, fields = true, fields[:len(fields)-1]
+	}
+	if len(fields) != 2 {
+		return fmt.Errorf("invalid autocommand definition %q", def)
+	}
+	for _, e := range strings.Split(fields[0], ",") {
+		c, err := lookupEvent(e)
+		if err != nil {
+			return err
+		}
+		ac.events = append(ac.events, c)
+	}
+	ac.patterns = strings.Split(fields[1], ",")
+	v.autocmds = append(v.autocmds, ac)
+	return nil
+}
+
+// fire fires the event for the buffer b, running the autocommands of group
+// (any group if group is "") whose patterns match. match defaults to the
+// name of b or, for the FileType event, its filetype.
+//
+// As in plugin/govim.vim, autocommands only call govim once it has completed
+// its initialisation.
+func (v *Vim) fire(event, group string, b *buffer, match string) error {
+	if v.noautocmd > 0 || v.status != "initcomplete" {
+		return nil
+	}
+	event, err := lookupEvent(event)
+	if err != nil {
+		return err
+	}
+	file := v.relName(b)
+	if match == "" {
+		match = b.name
+		if event == "FileType" {
+			match, _ = b.options["filetype"].(string)
+		}
+	} else if event != "FileType" && event != "User" {
+		file, match = match, v.abs(match)
+	}
+	var errs errList
+	for _, ac := range append([]*autocmd{}, v.autocmds...) {
+		if group != "" && ac.group != group || !ac.matches(event, match) {
+			continue
+		}
+		errs.add(v.runAutocmd(ac, &acContext{buf: b, file: file, match: match}))
+	}
+	return errs.err()
+}
+
+func (ac *autocmd) matches(event, match string) bool {
+	found := false
+	for _, e := range ac.events {
+		found = found || e == event
+	}
+	if !found {
+		return false
+	}
+	for _, p := range ac.patterns {
+		name := match
+		if !strings.Contains(p, "/") {
+			name = filepath.Base(match)
+		}
+		if ok, _ := filepath.Match(p, name); ok || p == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// runAutocmd calls govim for ac in the context c. Unless ac is nested,
+// events are not fired whilst govim handles the call.
+func (v *Vim) runAutocmd(ac *autocmd, c *acContext) error {
+	prev := v.ac
+	v.ac = c
+	defer func() {
+		v.ac = prev
+	}()
+	exprVals := []interface{}{}
+	for _, e := range ac.exprs {
+		val, err := v.eval(e)
+		if err != nil {
+			return err
+		}
+		exprVals = append(exprVals, val)
+	}
+	if !ac.nested {
+		v.noautocmd++
+		defer func() {
+			v.noautocmd--
+		}()
+	}
+	_, err := v.request("function", ac.handler, ac.def, exprVals)
+	return err
+}
+
+// doautocmd implements :doautocmd and :doautoall, whose argument is of the
+// form "[<nomodeline>] [group] {event} [fname]". For :doautoall the event is
+// fired for each loaded buffer, with that buffer current.
+func (v *Vim) doautocmd(arg string, all bool) error {
+	fields := strings.Fields(arg)
+	if len(fields) > 0 && fields[0] == "<nomodeline>" {
+		fields = fields[1:]
+	}
+	var group string
+	if len(fields) > 0 {
+		if _, err := lookupEvent(fields[0]); err != nil {
+			group, fields = fields[0], fields[1:]
+		}
+	}
+	if len(fields) == 0 {
+		return fmt.Errorf("E216: No such group or event: %v", arg)
+	}
+	event := fields[0]
+	var match string
+	if len(fields) > 1 {
+		match = strings.Join(fields[1:], " ")
+	}
+	if !all {
+		return v.fire(event, group, v.win.buf, match)
+	}
+	if _, err := lookupEvent(event); err != nil {
+		return err
+	}
+	var errs errList
+	for _, b := range append([]*buffer{}, v.bufs...) {
+		if !b.loaded {
+			continue
+		}
+		errs.add(v.withBuffer(b, func() error {
+			return v.fire(event, group, b, match)
+		}))
+	}
+	return errs.err()
+}
+
+// doautoall fires event in group for each loaded buffer
+func (v *Vim) doautoall(group, event string) error {
+	return v.doautocmd(group+" "+event, true)
+}
+
+// withBuffer runs f with b temporarily the buffer of the window
+func (v *Vim) withBuffer(b *buffer, f func() error) error {
+	prev, lnum, col := v.win.buf, v.win.lnum, v.win.col
+	if prev == b {
+		return f()
+	}
+	v.win.buf, v.win.lnum, v.win.col = b, b.lnum, b.col
+	v.clampCursor()
+	defer func() {
+		b.lnum, b.col = v.win.lnum, v.win.col
+		for _, o := range v.bufs {
+			if o == prev {
+				v.win.buf, v.win.lnum, v.win.col = prev, lnum, col
+				v.clampCursor()
+			}
+		}
+	}()
+	return f()
+}
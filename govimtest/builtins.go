@@ -0,0 +1,1121 @@
+package govimtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// builtin is a builtin function that takes between min and max arguments;
+// a max of -1 means any number
+type builtin struct {
+	min, max int
+	f        func(v *Vim, args []interface{}) (interface{}, error)
+}
+
+// builtins are the builtin functions implemented by the fake Vim, and the
+// functions defined by plugin/govim.vim that govim calls. It is populated by
+// init to avoid an initialisation cycle.
+var builtins map[string]builtin
+
+// call calls the function name, which is either defined by govim or a
+// builtin
+func (v *Vim) call(name string, args []interface{}) (interface{}, error) {
+	if f, ok := v.funcs[name]; ok {
+		return v.callFunction(f, args)
+	}
+	b, ok := builtins[name]
+	if !ok {
+		return nil, fmt.Errorf("E117: Unknown function: %v", name)
+	}
+	if len(args) < b.min {
+		return nil, fmt.Errorf("E119: Not enough arguments for function: %v", name)
+	}
+	if b.max >= 0 && len(args) > b.max {
+		return nil, fmt.Errorf("E118: Too many arguments for function: %v", name)
+	}
+	// Pad optional arguments with nil
+	if b.max > len(args) {
+		args = append(append([]interface{}{}, args...), make([]interface{}, b.max-len(args))...)
+	}
+	return b.f(v, args)
+}
+
+func init() {
+	builtins = map[string]builtin{
+		// plugin/govim.vim
+		"s:schedule": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			v.scheduleBacklog = append(v.scheduleBacklog, toNumber(args[0]))
+			return 0, nil
+		}},
+		"s:batchCall": {1, 1, (*Vim).batchCall},
+		"s:buildCurrentViewport": {0, 0, func(v *Vim, args []interface{}) (interface{}, error) {
+			return v.viewport(), nil
+		}},
+		"s:subscribeViewport": {0, 0, func(v *Vim, args []interface{}) (interface{}, error) {
+			v.pushedViewport = v.viewport()
+			return v.pushedViewport, nil
+		}},
+		// The fake makes no distinction between legacy and Vim9 script
+		"s:enableVim9": {0, 0, func(v *Vim, args []interface{}) (interface{}, error) {
+			return 0, nil
+		}},
+		"s:popupCreate": {3, 3, func(v *Vim, args []interface{}) (interface{}, error) {
+			id, err := popupCreator(nil)(v, args[:2])
+			if err != nil {
+				return nil, err
+			}
+			v.popup(id.(int)).keyFilter = toString(args[2])
+			return id, nil
+		}},
+		"GOVIMPluginStatus": {0, -1, func(v *Vim, args []interface{}) (interface{}, error) {
+			return v.status, nil
+		}},
+		"s:listenerAdd": {2, 2, func(v *Vim, args []interface{}) (interface{}, error) {
+			b, err := v.mustBuffer(args[1])
+			if err != nil {
+				return nil, err
+			}
+			v.listenerTargets[toString(args[0])] = true
+			return v.addListener(b, "s:enrichDelta", args[0]), nil
+		}},
+		"s:enrichDelta": {6, 6, func(v *Vim, args []interface{}) (interface{}, error) {
+			b, err := v.mustBuffer(args[1])
+			if err != nil {
+				return nil, err
+			}
+			changes, _ := args[5].([]interface{})
+			for _, c := range changes {
+				c := c.(map[string]interface{})
+				c["lines"] = getLines(b, toNumber(c["lnum"]), toNumber(c["end"])-1+toNumber(c["added"]))
+			}
+			return v.call(toString(args[0]), args[1:])
+		}},
+
+		// Values
+		"add": {2, 2, func(v *Vim, args []interface{}) (interface{}, error) {
+			l, err := listArg(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return append(l, args[1]), nil
+		}},
+		"copy":     {1, 1, func(v *Vim, args []interface{}) (interface{}, error) { return deepCopy(args[0]), nil }},
+		"deepcopy": {1, 2, func(v *Vim, args []interface{}) (interface{}, error) { return deepCopy(args[0]), nil }},
+		"empty":    {1, 1, func(v *Vim, args []interface{}) (interface{}, error) { return boolNum(isEmpty(args[0])), nil }},
+		"eval":     {1, 1, func(v *Vim, args []interface{}) (interface{}, error) { return v.eval(toString(args[0])) }},
+		"extend":   {2, 3, builtinExtend},
+		"function": {1, 3, func(v *Vim, args []interface{}) (interface{}, error) { return toString(args[0]), nil }},
+		"get":      {2, 3, builtinGet},
+		"has_key":  {2, 2, builtinHasKey},
+		"index":    {2, 4, builtinIndex},
+		"join":     {1, 2, builtinJoin},
+		"keys":     {1, 1, builtinKeys},
+		"len":      {1, 1, builtinLen},
+		"range":    {1, 3, builtinRange},
+		"split":    {1, 3, builtinSplit},
+		"str2nr":   {1, 2, func(v *Vim, args []interface{}) (interface{}, error) { return toNumber(toString(args[0])), nil }},
+		"string":   {1, 1, func(v *Vim, args []interface{}) (interface{}, error) { return vimString(args[0]), nil }},
+		"strlen":   {1, 1, func(v *Vim, args []interface{}) (interface{}, error) { return len(toString(args[0])), nil }},
+		"tolower":  {1, 1, func(v *Vim, args []interface{}) (interface{}, error) { return strings.ToLower(toString(args[0])), nil }},
+		"toupper":  {1, 1, func(v *Vim, args []interface{}) (interface{}, error) { return strings.ToUpper(toString(args[0])), nil }},
+		"trim": {1, 3, func(v *Vim, args []interface{}) (interface{}, error) {
+			return strings.TrimSpace(toString(args[0])), nil
+		}},
+		"type":   {1, 1, builtinType},
+		"values": {1, 1, builtinValues},
+		"json_encode": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			byts, err := json.Marshal(args[0])
+			return string(byts), err
+		}},
+		"json_decode": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			return fromJSON([]byte(toString(args[0])))
+		}},
+
+		// Vim itself
+		"ch_log":  {1, 2, func(v *Vim, args []interface{}) (interface{}, error) { return 0, nil }},
+		"exists":  {1, 1, func(v *Vim, args []interface{}) (interface{}, error) { return v.exists(toString(args[0])), nil }},
+		"execute": {1, 2, (*Vim).builtinExecute},
+		"expand":  {1, 3, func(v *Vim, args []interface{}) (interface{}, error) { return v.expand(toString(args[0])) }},
+		"filereadable": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			fi, err := os.Stat(v.abs(toString(args[0])))
+			return boolNum(err == nil && !fi.IsDir()), nil
+		}},
+		"fnamemodify": {2, 2, func(v *Vim, args []interface{}) (interface{}, error) {
+			return v.fnameModify(toString(args[0]), toString(args[1])), nil
+		}},
+		"getcwd":    {0, 2, func(v *Vim, args []interface{}) (interface{}, error) { return v.dir, nil }},
+		"has":       {1, 2, func(v *Vim, args []interface{}) (interface{}, error) { return boolNum(has(toString(args[0]))), nil }},
+		"tabpagenr": {0, 1, func(v *Vim, args []interface{}) (interface{}, error) { return 1, nil }},
+
+		// Buffers
+		"appendbufline": {3, 3, func(v *Vim, args []interface{}) (interface{}, error) {
+			b, err := v.mustBuffer(args[0])
+			if err != nil {
+				return nil, err
+			}
+			return 0, v.appendLines(b, v.lnum(b, args[1]), textArg(args[2]))
+		}},
+		"append": {2, 2, func(v *Vim, args []interface{}) (interface{}, error) {
+			b := v.win.buf
+			if err := v.appendLines(b, v.lnum(b, args[0]), textArg(args[1])); err != nil {
+				return 1, nil
+			}
+			return 0, nil
+		}},
+		"bufadd": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			name := toString(args[0])
+			b := v.bufferByName(name)
+			if b == nil {
+				b = v.newBuffer(v.abs(name))
+				b.listed = false
+			}
+			return b.nr, nil
+		}},
+		"bufexists": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) { return boolNum(v.buffer(args[0]) != nil), nil }},
+		"buflisted": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			b := v.buffer(args[0])
+			return boolNum(b != nil && b.listed), nil
+		}},
+		"bufload": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			b, err := v.mustBuffer(args[0])
+			if err != nil || b.loaded {
+				return 0, err
+			}
+			return 0, v.load(b)
+		}},
+		"bufloaded": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			b := v.buffer(args[0])
+			return boolNum(b != nil && b.loaded), nil
+		}},
+		"bufname": {0, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			b := v.win.buf
+			if args[0] != nil {
+				b = v.buffer(args[0])
+			}
+			if b == nil || b.name == "" {
+				return "", nil
+			}
+			return v.relName(b), nil
+		}},
+		"bufnr": {0, 2, func(v *Vim, args []interface{}) (interface{}, error) {
+			if args[0] == nil {
+				return v.win.buf.nr, nil
+			}
+			b := v.buffer(args[0])
+			if b == nil && truthy(args[1]) {
+				if name, ok := args[0].(string); ok {
+					b = v.newBuffer(v.abs(name))
+					b.listed = false
+				}
+			}
+			if b == nil {
+				return -1, nil
+			}
+			return b.nr, nil
+		}},
+		"bufwinid": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			if v.buffer(args[0]) == v.win.buf {
+				return v.win.id, nil
+			}
+			return -1, nil
+		}},
+		"bufwinnr": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			if v.buffer(args[0]) == v.win.buf {
+				return 1, nil
+			}
+			return -1, nil
+		}},
+		"deletebufline": {2, 3, func(v *Vim, args []interface{}) (interface{}, error) {
+			b, err := v.mustBuffer(args[0])
+			if err != nil {
+				return nil, err
+			}
+			first := v.lnum(b, args[1])
+			last := first
+			if args[2] != nil {
+				last = v.lnum(b, args[2])
+			}
+			if err := v.deleteLines(b, first, last); err != nil {
+				return 1, nil
+			}
+			return 0, nil
+		}},
+		"getbufinfo": {0, 1, (*Vim).getbufinfo},
+		"getbufline": {2, 3, func(v *Vim, args []interface{}) (interface{}, error) {
+			b := v.buffer(args[0])
+			if b == nil {
+				return []interface{}{}, nil
+			}
+			first := v.lnum(b, args[1])
+			last := first
+			if args[2] != nil {
+				last = v.lnum(b, args[2])
+			}
+			return getLines(b, first, last), nil
+		}},
+		"getbufoneline": {2, 2, func(v *Vim, args []interface{}) (interface{}, error) {
+			b := v.buffer(args[0])
+			if b == nil {
+				return "", nil
+			}
+			lnum := v.lnum(b, args[1])
+			if lnum < 1 || lnum > len(b.lines) {
+				return "", nil
+			}
+			return b.lines[lnum-1], nil
+		}},
+		"getbufvar": {2, 3, func(v *Vim, args []interface{}) (interface{}, error) {
+			b := v.buffer(args[0])
+			if b == nil {
+				return args[2], nil
+			}
+			name := toString(args[1])
+			switch {
+			case name == "":
+				return b.vars, nil
+			case name == "changedtick":
+				return b.changedtick, nil
+			case strings.HasPrefix(name, "&"):
+				val, err := v.bufOption(b, name[1:])
+				if err != nil {
+					return args[2], nil
+				}
+				return val, nil
+			}
+			val, ok := b.vars[name]
+			if !ok {
+				return args[2], nil
+			}
+			return val, nil
+		}},
+		"getline": {1, 2, func(v *Vim, args []interface{}) (interface{}, error) {
+			b := v.win.buf
+			first := v.lnum(b, args[0])
+			if args[1] != nil {
+				return getLines(b, first, v.lnum(b, args[1])), nil
+			}
+			if first < 1 || first > len(b.lines) {
+				return "", nil
+			}
+			return b.lines[first-1], nil
+		}},
+		"setbufline": {3, 3, func(v *Vim, args []interface{}) (interface{}, error) {
+			b, err := v.mustBuffer(args[0])
+			if err != nil {
+				return nil, err
+			}
+			if err := v.setLines(b, v.lnum(b, args[1]), textArg(args[2])); err != nil {
+				return 1, nil
+			}
+			return 0, nil
+		}},
+		"setbufvar": {3, 3, func(v *Vim, args []interface{}) (interface{}, error) {
+			b, err := v.mustBuffer(args[0])
+			if err != nil {
+				return nil, err
+			}
+			name := toString(args[1])
+			if strings.HasPrefix(name, "&") {
+				return 0, v.setBufOption(b, name[1:], args[2])
+			}
+			b.vars[name] = args[2]
+			return 0, nil
+		}},
+		"setline": {2, 2, func(v *Vim, args []interface{}) (interface{}, error) {
+			b := v.win.buf
+			if err := v.setLines(b, v.lnum(b, args[0]), textArg(args[1])); err != nil {
+				return 1, nil
+			}
+			return 0, nil
+		}},
+
+		// The window and cursor
+		"col": {1, 2, func(v *Vim, args []interface{}) (interface{}, error) {
+			b := v.win.buf
+			switch a := args[0].(type) {
+			case string:
+				switch a {
+				case ".":
+					return v.win.col, nil
+				case "$":
+					return len(b.lines[v.win.lnum-1]) + 1, nil
+				}
+			case []interface{}:
+				if len(a) == 2 {
+					lnum := v.lnum(b, a[0])
+					if lnum < 1 || lnum > len(b.lines) {
+						return 0, nil
+					}
+					if a[1] == "$" {
+						return len(b.lines[lnum-1]) + 1, nil
+					}
+					return toNumber(a[1]), nil
+				}
+			}
+			return 0, nil
+		}},
+		"cursor": {1, 3, func(v *Vim, args []interface{}) (interface{}, error) {
+			lnum, col := args[0], args[1]
+			if l, ok := args[0].([]interface{}); ok {
+				if len(l) < 2 {
+					return -1, nil
+				}
+				lnum, col = l[0], l[1]
+			}
+			if n := v.lnum(v.win.buf, lnum); n > 0 {
+				v.win.lnum = n
+			}
+			if col != nil && toNumber(col) > 0 {
+				v.win.col = toNumber(col)
+			}
+			v.clampCursor()
+			return 0, nil
+		}},
+		"getcurpos": {0, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			return []interface{}{0, v.win.lnum, v.win.col, 0, v.win.col}, nil
+		}},
+		"getpos": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			switch toString(args[0]) {
+			case ".":
+				return []interface{}{0, v.win.lnum, v.win.col, 0}, nil
+			case "$":
+				return []interface{}{0, len(v.win.buf.lines), 0, 0}, nil
+			}
+			return []interface{}{0, 0, 0, 0}, nil
+		}},
+		"getwininfo": {0, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			if args[0] != nil && toNumber(args[0]) != v.win.id {
+				return []interface{}{}, nil
+			}
+			return []interface{}{v.winInfo()}, nil
+		}},
+		"line": {1, 2, func(v *Vim, args []interface{}) (interface{}, error) {
+			b := v.win.buf
+			switch a := toString(args[0]); a {
+			case ".", "$":
+				return v.lnum(b, a), nil
+			case "w0":
+				return 1, nil
+			case "w$":
+				return v.winInfo()["botline"], nil
+			}
+			return 0, nil
+		}},
+		"setpos": {2, 2, func(v *Vim, args []interface{}) (interface{}, error) {
+			pos, _ := args[1].([]interface{})
+			if toString(args[0]) != "." || len(pos) < 3 {
+				return -1, nil
+			}
+			v.win.lnum, v.win.col = toNumber(pos[1]), toNumber(pos[2])
+			v.clampCursor()
+			return 0, nil
+		}},
+		"win_execute": {2, 3, func(v *Vim, args []interface{}) (interface{}, error) {
+			if toNumber(args[0]) != v.win.id {
+				return nil, fmt.Errorf("E994: Not allowed in a popup window")
+			}
+			return v.builtinExecute(args[1:])
+		}},
+		"win_getid": {0, 2, func(v *Vim, args []interface{}) (interface{}, error) {
+			if args[0] != nil && toNumber(args[0]) != 1 {
+				return 0, nil
+			}
+			return v.win.id, nil
+		}},
+		"win_gotoid": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			return boolNum(toNumber(args[0]) == v.win.id), nil
+		}},
+		"win_id2win": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			return boolNum(toNumber(args[0]) == v.win.id), nil
+		}},
+		"winbufnr": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			n := toNumber(args[0])
+			if n == 0 || n == 1 || n == v.win.id {
+				return v.win.buf.nr, nil
+			}
+			if p := v.popup(n); p != nil {
+				return p.buf.nr, nil
+			}
+			return -1, nil
+		}},
+		"winheight": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) { return winHeight, nil }},
+		"winnr": {0, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			if args[0] == "#" {
+				return 0, nil
+			}
+			return 1, nil
+		}},
+		"winwidth": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) { return winWidth, nil }},
+
+		// Quickfix and location lists
+		"getloclist": {1, 2, func(v *Vim, args []interface{}) (interface{}, error) {
+			if n := toNumber(args[0]); n != 0 && n != 1 && n != v.win.id {
+				return []interface{}{}, nil
+			}
+			return v.win.loclist.get(args[1]), nil
+		}},
+		"getqflist": {0, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			return v.qf.get(args[0]), nil
+		}},
+		"setloclist": {2, 4, func(v *Vim, args []interface{}) (interface{}, error) {
+			if n := toNumber(args[0]); n != 0 && n != 1 && n != v.win.id {
+				return -1, nil
+			}
+			return v.setqflist(v.win.loclist, args[1:])
+		}},
+		"setqflist": {1, 3, func(v *Vim, args []interface{}) (interface{}, error) {
+			return v.setqflist(v.qf, args)
+		}},
+
+		// Text properties
+		"prop_add":         {3, 3, (*Vim).propAdd},
+		"prop_clear":       {1, 3, (*Vim).propClear},
+		"prop_list":        {1, 2, (*Vim).propList},
+		"prop_remove":      {1, 3, (*Vim).propRemove},
+		"prop_type_add":    {2, 2, (*Vim).propTypeAdd},
+		"prop_type_change": {2, 2, (*Vim).propTypeChange},
+		"prop_type_delete": {1, 2, (*Vim).propTypeDelete},
+		"prop_type_get":    {1, 2, (*Vim).propTypeGet},
+		"prop_type_list":   {0, 1, (*Vim).propTypeList},
+
+		// Signs
+		"sign_define":      {1, 2, (*Vim).signDefine},
+		"sign_getdefined":  {0, 1, (*Vim).signGetDefined},
+		"sign_getplaced":   {0, 2, (*Vim).signGetPlaced},
+		"sign_place":       {4, 5, (*Vim).signPlace},
+		"sign_placelist":   {1, 1, (*Vim).signPlaceList},
+		"sign_undefine":    {0, 1, (*Vim).signUndefine},
+		"sign_unplace":     {1, 2, (*Vim).signUnplace},
+		"sign_unplacelist": {1, 1, (*Vim).signUnplaceList},
+
+		// Popups
+		"popup_atcursor":     {2, 2, popupCreator(map[string]interface{}{"pos": "botleft", "line": "cursor-1", "col": "cursor", "moved": "WORD"})},
+		"popup_beval":        {2, 2, popupCreator(map[string]interface{}{"pos": "botleft", "line": "cursor-1", "col": "cursor", "moved": "WORD", "mousemoved": "WORD"})},
+		"popup_clear":        {0, 1, (*Vim).popupClear},
+		"popup_close":        {1, 2, (*Vim).popupClose},
+		"popup_create":       {2, 2, popupCreator(nil)},
+		"popup_dialog":       {2, 2, popupCreator(map[string]interface{}{"pos": "center", "zindex": 200, "border": []interface{}{}, "padding": []interface{}{}, "mapping": 0})},
+		"popup_findinfo":     {0, 0, func(v *Vim, args []interface{}) (interface{}, error) { return 0, nil }},
+		"popup_findpreview":  {0, 0, func(v *Vim, args []interface{}) (interface{}, error) { return 0, nil }},
+		"popup_getoptions":   {1, 1, (*Vim).popupGetOptions},
+		"popup_getpos":       {1, 1, (*Vim).popupGetPos},
+		"popup_hide":         {1, 1, popupVisibility(false)},
+		"popup_list":         {0, 0, (*Vim).popupList},
+		"popup_menu":         {2, 2, popupCreator(map[string]interface{}{"pos": "center", "zindex": 200, "wrap": 0, "border": []interface{}{}, "cursorline": 1, "padding": []interface{}{0, 1, 0, 1}, "mapping": 0})},
+		"popup_notification": {2, 2, popupCreator(map[string]interface{}{"line": 1, "col": 10, "minwidth": 20, "time": 3000, "tabpage": -1, "zindex": 300, "drag": 1, "highlight": "WarningMsg", "border": []interface{}{}, "padding": []interface{}{0, 1, 0, 1}})},
+		"popup_setoptions":   {2, 2, (*Vim).popupSetOptions},
+		"popup_settext":      {2, 2, (*Vim).popupSetText},
+		"popup_show":         {1, 1, popupVisibility(true)},
+
+		// Listeners
+		"listener_add": {1, 2, func(v *Vim, args []interface{}) (interface{}, error) {
+			b := v.win.buf
+			if args[1] != nil {
+				var err error
+				if b, err = v.mustBuffer(args[1]); err != nil {
+					return nil, err
+				}
+			}
+			return v.addListener(b, toString(args[0])), nil
+		}},
+		"listener_flush": {0, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			b := v.win.buf
+			if args[0] != nil {
+				var err error
+				if b, err = v.mustBuffer(args[0]); err != nil {
+					return nil, err
+				}
+			}
+			return 0, v.flushBufListeners(b)
+		}},
+		"listener_remove": {1, 1, func(v *Vim, args []interface{}) (interface{}, error) {
+			id := toNumber(args[0])
+			for i, l := range v.listeners {
+				if l.id == id {
+					v.listeners = append(v.listeners[:i], v.listeners[i+1:]...)
+					return 1, nil
+				}
+			}
+			return 0, nil
+		}},
+	}
+}
+
+// textArg returns the lines given by val, as per the {text} argument of
+// setline()
+func textArg(val interface{}) []string {
+	if l, ok := val.([]interface{}); ok {
+		res := make([]string, 0, len(l))
+		for _, e := range l {
+			res = append(res, toString(e))
+		}
+		return res
+	}
+	return []string{toString(val)}
+}
+
+// getLines returns the lines first to last of b, as per getbufline()
+func getLines(b *buffer, first, last int) []interface{} {
+	res := []interface{}{}
+	if first < 1 {
+		first = 1
+	}
+	if last > len(b.lines) {
+		last = len(b.lines)
+	}
+	for i := first; i <= last; i++ {
+		res = append(res, b.lines[i-1])
+	}
+	return res
+}
+
+func listArg(val interface{}) ([]interface{}, error) {
+	l, ok := val.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("E714: List required")
+	}
+	return l, nil
+}
+
+func dictArg(val interface{}) (map[string]interface{}, error) {
+	if val == nil {
+		return map[string]interface{}{}, nil
+	}
+	d, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("E715: Dictionary required")
+	}
+	return d, nil
+}
+
+func deepCopy(val interface{}) interface{} {
+	switch val := val.(type) {
+	case []interface{}:
+		res := make([]interface{}, len(val))
+		for i, e := range val {
+			res[i] = deepCopy(e)
+		}
+		return res
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(val))
+		for k, e := range val {
+			res[k] = deepCopy(e)
+		}
+		return res
+	}
+	return val
+}
+
+func isEmpty(val interface{}) bool {
+	switch val := val.(type) {
+	case nil:
+		return true
+	case string:
+		return val == ""
+	case []interface{}:
+		return len(val) == 0
+	case map[string]interface{}:
+		return len(val) == 0
+	}
+	return !truthy(val)
+}
+
+func builtinExtend(v *Vim, args []interface{}) (interface{}, error) {
+	switch a := args[0].(type) {
+	case []interface{}:
+		b, err := listArg(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return append(a, b...), nil
+	case map[string]interface{}:
+		b, err := dictArg(args[1])
+		if err != nil {
+			return nil, err
+		}
+		for k, e := range b {
+			if _, ok := a[k]; ok && args[2] == "keep" {
+				continue
+			}
+			a[k] = e
+		}
+		return a, nil
+	}
+	return nil, fmt.Errorf("E712: Argument of extend() must be a List or Dictionary")
+}
+
+func builtinGet(v *Vim, args []interface{}) (interface{}, error) {
+	switch c := args[0].(type) {
+	case []interface{}:
+		i := toNumber(args[1])
+		if i < 0 {
+			i += len(c)
+		}
+		if i >= 0 && i < len(c) {
+			return c[i], nil
+		}
+	case map[string]interface{}:
+		if e, ok := c[toString(args[1])]; ok {
+			return e, nil
+		}
+	default:
+		return nil, fmt.Errorf("E896: Argument of get() must be a List, Dictionary or Blob")
+	}
+	if args[2] == nil {
+		return 0, nil
+	}
+	return args[2], nil
+}
+
+func builtinHasKey(v *Vim, args []interface{}) (interface{}, error) {
+	d, err := dictArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	_, ok := d[toString(args[1])]
+	return boolNum(ok), nil
+}
+
+func builtinIndex(v *Vim, args []interface{}) (interface{}, error) {
+	l, err := listArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range l {
+		if vimString(e) == vimString(args[1]) {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+func builtinJoin(v *Vim, args []interface{}) (interface{}, error) {
+	l, err := listArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	sep := " "
+	if args[1] != nil {
+		sep = toString(args[1])
+	}
+	var strs []string
+	for _, e := range l {
+		strs = append(strs, echoString(e))
+	}
+	return strings.Join(strs, sep), nil
+}
+
+func builtinKeys(v *Vim, args []interface{}) (interface{}, error) {
+	d, err := dictArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	res := []interface{}{}
+	for _, k := range sortedKeys(d) {
+		res = append(res, k)
+	}
+	return res, nil
+}
+
+func builtinValues(v *Vim, args []interface{}) (interface{}, error) {
+	d, err := dictArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	res := []interface{}{}
+	for _, k := range sortedKeys(d) {
+		res = append(res, d[k])
+	}
+	return res, nil
+}
+
+func sortedKeys(d map[string]interface{}) []string {
+	var keys []string
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func builtinLen(v *Vim, args []interface{}) (interface{}, error) {
+	switch a := args[0].(type) {
+	case []interface{}:
+		return len(a), nil
+	case map[string]interface{}:
+		return len(a), nil
+	}
+	return len(toString(args[0])), nil
+}
+
+func builtinRange(v *Vim, args []interface{}) (interface{}, error) {
+	start, end, stride := 0, toNumber(args[0])-1, 1
+	if args[1] != nil {
+		start, end = toNumber(args[0]), toNumber(args[1])
+	}
+	if args[2] != nil {
+		stride = toNumber(args[2])
+	}
+	if stride == 0 {
+		return nil, fmt.Errorf("E726: Stride is zero")
+	}
+	res := []interface{}{}
+	for i := start; (stride > 0 && i <= end) || (stride < 0 && i >= end); i += stride {
+		res = append(res, i)
+	}
+	return res, nil
+}
+
+func builtinSplit(v *Vim, args []interface{}) (interface{}, error) {
+	s := toString(args[0])
+	pat := `\s+`
+	if args[1] != nil && args[1] != "" {
+		pat = toString(args[1])
+	}
+	re, err := regexp.Compile(pat)
+	if err != nil {
+		return nil, fmt.Errorf("E476: Invalid pattern %q: %v", pat, err)
+	}
+	keepEmpty := truthy(args[2])
+	res := []interface{}{}
+	parts := re.Split(s, -1)
+	for i, p := range parts {
+		if p == "" && !keepEmpty && (i == 0 || i == len(parts)-1 || args[1] == nil) {
+			continue
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+func builtinType(v *Vim, args []interface{}) (interface{}, error) {
+	switch args[0].(type) {
+	case int:
+		return 0, nil
+	case string:
+		return 1, nil
+	case []interface{}:
+		return 3, nil
+	case map[string]interface{}:
+		return 4, nil
+	case float64:
+		return 5, nil
+	case bool:
+		return 6, nil
+	}
+	return 7, nil
+}
+
+// builtinExecute runs the command or list of commands in args[0],
+// returning their output
+func (v *Vim) builtinExecute(args []interface{}) (interface{}, error) {
+	cmds := []string{toString(args[0])}
+	if l, ok := args[0].([]interface{}); ok {
+		cmds = textArg(l)
+	}
+	prev := v.capture
+	v.capture = new(strings.Builder)
+	defer func() {
+		v.capture = prev
+	}()
+	for _, c := range cmds {
+		if err := v.ex(c); err != nil {
+			return nil, err
+		}
+	}
+	return v.capture.String(), nil
+}
+
+// exists implements exists() for functions, commands, options, autocommands
+// and variables
+func (v *Vim) exists(name string) int {
+	switch {
+	case strings.HasPrefix(name, "*"):
+		_, defined := v.funcs[name[1:]]
+		_, builtin := builtins[name[1:]]
+		return boolNum(defined || builtin)
+	case strings.HasPrefix(name, ":"):
+		if _, ok := v.commands[name[1:]]; ok {
+			return 2
+		}
+		if lookupExCommand(name[1:]) == name[1:] {
+			return 2
+		}
+		return 0
+	case strings.HasPrefix(name, "&") || strings.HasPrefix(name, "+"):
+		_, err := v.option(name[1:])
+		return boolNum(err == nil)
+	case strings.HasPrefix(name, "$"):
+		_, ok := os.LookupEnv(name[1:])
+		return boolNum(ok)
+	case strings.HasPrefix(name, "#"):
+		return boolNum(v.autocmdExists(strings.Split(name[1:], "#")))
+	}
+	_, err := v.variable(name)
+	return boolNum(err == nil)
+}
+
+// autocmdExists reports whether an autocommand exists for parts, as per
+// exists("#group#event#pattern") and its shorter forms
+func (v *Vim) autocmdExists(parts []string) bool {
+	var group, event, pattern string
+	if _, err := lookupEvent(parts[0]); err != nil {
+		group, parts = parts[0], parts[1:]
+	}
+	if len(parts) > 0 {
+		var err error
+		if event, err = lookupEvent(parts[0]); err != nil {
+			return false
+		}
+	}
+	if len(parts) > 1 {
+		pattern = parts[1]
+	}
+	for _, ac := range v.autocmds {
+		if group != "" && ac.group != group {
+			continue
+		}
+		if event != "" && !contains(ac.events, event) {
+			continue
+		}
+		if pattern != "" && !contains(ac.patterns, pattern) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func contains(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// has implements has() for a fake Vim of version versionLong running in a
+// terminal on a Unix system
+func has(feature string) bool {
+	if strings.HasPrefix(feature, "patch-") {
+		var maj, min, patch int
+		if _, err := fmt.Sscanf(feature, "patch-%d.%d.%d", &maj, &min, &patch); err != nil {
+			return false
+		}
+		return maj*1000000+min*10000+patch <= versionLong
+	}
+	if strings.HasPrefix(feature, "patch") {
+		return toNumber(feature[len("patch"):]) <= versionLong%10000
+	}
+	switch feature {
+	case "autocmd", "balloon_eval_term", "channel", "eval", "float", "job",
+		"lambda", "listcmds", "popupwin", "quickfix", "signs", "syntax",
+		"terminal", "textprop", "timers", "unix", "vim9script":
+		return true
+	}
+	return false
+}
+
+// expand implements expand() for the current file and the files of
+// autocommands, with filename modifiers
+func (v *Vim) expand(s string) (interface{}, error) {
+	var name, mods string
+	switch {
+	case strings.HasPrefix(s, "%"):
+		name, mods = v.relName(v.win.buf), s[1:]
+		if v.win.buf.name == "" {
+			name = ""
+		}
+	case strings.HasPrefix(s, "<abuf>") || strings.HasPrefix(s, "<afile>") || strings.HasPrefix(s, "<amatch>"):
+		i := strings.Index(s, ">") + 1
+		if v.ac == nil {
+			return nil, fmt.Errorf("E495: No autocommand file name to substitute for %q", s[:i])
+		}
+		switch s[:i] {
+		case "<abuf>":
+			name = toString(v.ac.buf.nr)
+		case "<afile>":
+			name = v.ac.file
+		case "<amatch>":
+			name = v.ac.match
+		}
+		mods = s[i:]
+	default:
+		return s, nil
+	}
+	if name == "" {
+		return "", nil
+	}
+	return v.fnameModify(name, mods), nil
+}
+
+// fnameModify applies the filename modifiers mods, e.g. ":p:h", to name
+func (v *Vim) fnameModify(name, mods string) string {
+	for _, m := range strings.Split(mods, ":")[1:] {
+		switch m {
+		case "p":
+			name = v.abs(name)
+		case "h":
+			name = filepath.Dir(name)
+		case "t":
+			name = filepath.Base(name)
+		case "r":
+			name = strings.TrimSuffix(name, filepath.Ext(name))
+		case "e":
+			name = strings.TrimPrefix(filepath.Ext(name), ".")
+		case ".":
+			if rel, err := filepath.Rel(v.dir, v.abs(name)); err == nil && !strings.HasPrefix(rel, "..") {
+				name = rel
+			}
+		}
+	}
+	return name
+}
+
+// getbufinfo implements getbufinfo() for a buffer, or for all buffers
+// optionally restricted to those that are listed or loaded
+func (v *Vim) getbufinfo(args []interface{}) (interface{}, error) {
+	var bufs []*buffer
+	var listed, loaded bool
+	switch a := args[0].(type) {
+	case nil:
+		bufs = v.bufs
+	case map[string]interface{}:
+		bufs = v.bufs
+		listed, loaded = truthy(a["buflisted"]), truthy(a["bufloaded"])
+	default:
+		if b := v.buffer(a); b != nil {
+			bufs = []*buffer{b}
+		}
+	}
+	res := []interface{}{}
+	for _, b := range bufs {
+		if listed && !b.listed || loaded && !b.loaded {
+			continue
+		}
+		lnum := b.lnum
+		windows := []interface{}{}
+		if b == v.win.buf {
+			lnum = v.win.lnum
+			windows = append(windows, v.win.id)
+		}
+		var popups []interface{}
+		for _, p := range v.popups {
+			if p.buf == b {
+				popups = append(popups, p.id)
+			}
+		}
+		info := map[string]interface{}{
+			"bufnr":       b.nr,
+			"changed":     b.options["modified"],
+			"changedtick": b.changedtick,
+			"hidden":      boolNum(b.loaded && b != v.win.buf && popups == nil),
+			"lastused":    0,
+			"listed":      boolNum(b.listed),
+			"lnum":        lnum,
+			"linecount":   len(b.lines),
+			"loaded":      boolNum(b.loaded),
+			"name":        b.name,
+			"variables":   b.vars,
+			"windows":     windows,
+		}
+		if popups != nil {
+			info["popups"] = popups
+		}
+		if len(b.signs) > 0 {
+			info["signs"] = v.placedSigns(b, "*", 0, 0)
+		}
+		res = append(res, info)
+	}
+	return res, nil
+}
+
+// batchCall implements s:batchCall of plugin/govim.vim
+func (v *Vim) batchCall(args []interface{}) (interface{}, error) {
+	calls, err := listArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	results := []interface{}{}
+	failed := func(msg string) map[string]interface{} {
+		return map[string]interface{}{"results": results, "failed": len(results), "error": msg}
+	}
+	for _, c := range calls {
+		call, err := listArg(c)
+		if err != nil || len(call) < 3 {
+			return nil, fmt.Errorf("invalid batch call %v", vimString(c))
+		}
+		must, _ := call[1].([]interface{})
+		if len(must) != 2 {
+			return nil, fmt.Errorf("invalid batch assertion %v", vimString(call[1]))
+		}
+		mustArgs, _ := must[1].([]interface{})
+		check, err := mustCheck(toString(must[0]), mustArgs)
+		if err != nil {
+			return nil, err
+		}
+		var res interface{}
+		switch typ := toString(call[0]); typ {
+		case "call":
+			fn, fargs := toString(call[2]), call[3:]
+			res, err = v.call(fn, fargs)
+			if msg, ok := check(res, err); !ok {
+				return failed(fmt.Sprintf("failed to call %v(%v): %v", fn, vimString(fargs), msg)), nil
+			}
+		case "expr":
+			expr := toString(call[2])
+			res, err = v.eval(expr)
+			if msg, ok := check(res, err); !ok {
+				return failed(fmt.Sprintf("failed to eval %v: %v", expr, msg)), nil
+			}
+		default:
+			return nil, fmt.Errorf("Unknown batch type: %v", typ)
+		}
+		results = append(results, res)
+	}
+	return map[string]interface{}{"results": results, "failed": -1, "error": ""}, nil
+}
+
+// mustCheck returns the check of the must function name of
+// plugin/govim.vim, called with args
+func mustCheck(name string, args []interface{}) (func(res interface{}, err error) (string, bool), error) {
+	switch name {
+	case "s:mustNoError":
+		return func(res interface{}, err error) (string, bool) {
+			if err != nil {
+				return err.Error(), false
+			}
+			return "", true
+		}, nil
+	case "s:mustBeZero":
+		return func(res interface{}, err error) (string, bool) {
+			if err != nil {
+				return err.Error(), false
+			}
+			if compare(res, 0) != 0 {
+				return "got non-zero return value", false
+			}
+			return "", true
+		}, nil
+	case "s:mustBeErrorOrNil":
+		return func(res interface{}, err error) (string, bool) {
+			if err == nil {
+				return "", true
+			}
+			for _, a := range args {
+				p := toString(a)
+				if re, rerr := regexp.Compile(p); rerr == nil && re.MatchString(err.Error()) || rerr != nil && strings.Contains(err.Error(), p) {
+					return "", true
+				}
+			}
+			return err.Error(), false
+		}, nil
+	}
+	return nil, fmt.Errorf("E117: Unknown function: %v", name)
+}
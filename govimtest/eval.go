@@ -0,0 +1,786 @@
+package govimtest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The fake Vim evaluates a subset of VimScript expressions: number, float,
+// string, list and dict literals; g:, b: and v: variables; &option values;
+// calls of builtin functions, of the script-local functions of
+// plugin/govim.vim and of functions defined by govim; indexing and d.key
+// entries; the unary operators ! and -; the binary operators + - * / % . ..
+// == != < <= > >= && and ||; and the ternary operator.
+
+type parser struct {
+	v   *Vim
+	src string
+	pos int
+}
+
+// eval evaluates expr
+func (v *Vim) eval(expr string) (interface{}, error) {
+	p := &parser{v: v, src: expr}
+	res, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.src) {
+		return nil, fmt.Errorf("E15: Invalid expression: %q", expr)
+	}
+	return res, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("E15: Invalid expression: %q: %v", p.src, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t' || p.src[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+// consume skips whitespace and then tok if it is next
+func (p *parser) consume(tok string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], tok) {
+		p.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(tok string) error {
+	if !p.consume(tok) {
+		return p.errorf("expected %q at offset %v", tok, p.pos)
+	}
+	return nil
+}
+
+func (p *parser) expr() (interface{}, error) {
+	cond, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if !p.consume("?") {
+		return cond, nil
+	}
+	then, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	els, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if truthy(cond) {
+		return then, nil
+	}
+	return els, nil
+}
+
+func (p *parser) or() (interface{}, error) {
+	lhs, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("||") {
+		rhs, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		lhs = boolNum(truthy(lhs) || truthy(rhs))
+	}
+	return lhs, nil
+}
+
+func (p *parser) and() (interface{}, error) {
+	lhs, err := p.comparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("&&") {
+		rhs, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		lhs = boolNum(truthy(lhs) && truthy(rhs))
+	}
+	return lhs, nil
+}
+
+func (p *parser) comparison() (interface{}, error) {
+	lhs, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.consume(op) {
+			continue
+		}
+		// the case sensitivity of the comparison is ignored
+		if !p.consume("#") {
+			p.consume("?")
+		}
+		rhs, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		c := compare(lhs, rhs)
+		switch op {
+		case "==":
+			return boolNum(c == 0), nil
+		case "!=":
+			return boolNum(c != 0), nil
+		case "<=":
+			return boolNum(c <= 0), nil
+		case ">=":
+			return boolNum(c >= 0), nil
+		case "<":
+			return boolNum(c < 0), nil
+		default:
+			return boolNum(c > 0), nil
+		}
+	}
+	return lhs, nil
+}
+
+func (p *parser) additive() (interface{}, error) {
+	lhs, err := p.multiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		switch {
+		case p.consume(".."):
+			op = ".."
+		case p.consume("."):
+			op = ".."
+		case p.consume("+"):
+			op = "+"
+		case p.consume("-"):
+			op = "-"
+		default:
+			return lhs, nil
+		}
+		rhs, err := p.multiplicative()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "..":
+			lhs = toString(lhs) + toString(rhs)
+		case "+":
+			if l, ok := lhs.([]interface{}); ok {
+				r, ok := rhs.([]interface{})
+				if !ok {
+					return nil, p.errorf("cannot add %v to a list", rhs)
+				}
+				lhs = append(append([]interface{}{}, l...), r...)
+				continue
+			}
+			lhs = arith(lhs, rhs, func(a, b int) int { return a + b }, func(a, b float64) float64 { return a + b })
+		case "-":
+			lhs = arith(lhs, rhs, func(a, b int) int { return a - b }, func(a, b float64) float64 { return a - b })
+		}
+	}
+}
+
+func (p *parser) multiplicative() (interface{}, error) {
+	lhs, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op byte
+		switch {
+		case p.consume("*"):
+			op = '*'
+		case p.consume("/"):
+			op = '/'
+		case p.consume("%"):
+			op = '%'
+		default:
+			return lhs, nil
+		}
+		rhs, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case '*':
+			lhs = arith(lhs, rhs, func(a, b int) int { return a * b }, func(a, b float64) float64 { return a * b })
+		case '/', '%':
+			if _, isFloat := rhs.(float64); !isFloat && toNumber(rhs) == 0 {
+				return nil, p.errorf("division by zero")
+			}
+			if op == '/' {
+				lhs = arith(lhs, rhs, func(a, b int) int { return a / b }, func(a, b float64) float64 { return a / b })
+			} else {
+				lhs = toNumber(lhs) % toNumber(rhs)
+			}
+		}
+	}
+}
+
+func (p *parser) unary() (interface{}, error) {
+	switch {
+	case p.consume("!"):
+		v, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return boolNum(!truthy(v)), nil
+	case p.consume("-"):
+		v, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if f, ok := v.(float64); ok {
+			return -f, nil
+		}
+		return -toNumber(v), nil
+	}
+	return p.postfix()
+}
+
+func (p *parser) postfix() (interface{}, error) {
+	v, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if d, ok := v.(map[string]interface{}); ok && p.member() {
+			k := p.name()
+			e, ok := d[k]
+			if !ok {
+				return nil, fmt.Errorf("E716: Key not present in Dictionary: %q", k)
+			}
+			v = e
+			continue
+		}
+		// As in Vim, an index immediately follows the value: [ after
+		// whitespace starts another expression, e.g. in :echo
+		if p.pos == len(p.src) || p.src[p.pos] != '[' {
+			break
+		}
+		p.pos++
+		idx, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		switch c := v.(type) {
+		case []interface{}:
+			i := toNumber(idx)
+			if i < 0 {
+				i += len(c)
+			}
+			if i < 0 || i >= len(c) {
+				return nil, fmt.Errorf("E684: list index out of range: %v", idx)
+			}
+			v = c[i]
+		case map[string]interface{}:
+			k := toString(idx)
+			e, ok := c[k]
+			if !ok {
+				return nil, fmt.Errorf("E716: Key not present in Dictionary: %q", k)
+			}
+			v = e
+		case string:
+			i := toNumber(idx)
+			if i < 0 || i >= len(c) {
+				v = ""
+			} else {
+				v = c[i : i+1]
+			}
+		default:
+			return nil, p.errorf("cannot index %v", v)
+		}
+	}
+	return v, nil
+}
+
+func (p *parser) primary() (interface{}, error) {
+	p.skipSpace()
+	if p.pos == len(p.src) {
+		return nil, p.errorf("unexpected end of expression")
+	}
+	c := p.src[p.pos]
+	switch {
+	case c >= '0' && c <= '9':
+		return p.number()
+	case c == '\'':
+		return p.singleQuoted()
+	case c == '"':
+		return p.doubleQuoted()
+	case c == '[':
+		p.pos++
+		var res []interface{}
+		for !p.consume("]") {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, e)
+			if !p.consume(",") {
+				if err := p.expect("]"); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+		if res == nil {
+			res = []interface{}{}
+		}
+		return res, nil
+	case c == '{' || strings.HasPrefix(p.src[p.pos:], "#{"):
+		literal := c == '#'
+		p.pos += 1 + boolNum(literal)
+		res := make(map[string]interface{})
+		for !p.consume("}") {
+			var k interface{}
+			var err error
+			if literal {
+				k = p.name()
+			} else {
+				k, err = p.expr()
+			}
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			res[toString(k)] = e
+			if !p.consume(",") {
+				if err := p.expect("}"); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+		return res, nil
+	case c == '(':
+		p.pos++
+		e, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	case c == '&':
+		p.pos++
+		p.consume("l:")
+		name := p.name()
+		return p.v.option(name)
+	}
+	name := p.name()
+	if name == "" {
+		return nil, p.errorf("unexpected %q at offset %v", c, p.pos)
+	}
+	if strings.HasPrefix(p.src[p.pos:], "(") {
+		p.pos++
+		var args []interface{}
+		for !p.consume(")") {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, e)
+			if !p.consume(",") {
+				if err := p.expect(")"); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+		return p.v.call(name, args)
+	}
+	return p.v.variable(name)
+}
+
+// member reports whether the next token is the "." of an entry of a
+// dictionary, e.g. d.key, rather than the concatenation operator, and if so
+// consumes it
+func (p *parser) member() bool {
+	if p.pos+1 >= len(p.src) || p.src[p.pos] != '.' {
+		return false
+	}
+	if r := rune(p.src[p.pos+1]); !unicode.IsLetter(r) && r != '_' {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+// name parses a variable or function name, including its scope and any
+// autoload # separators
+func (p *parser) name() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.src) {
+		r := rune(p.src[p.pos])
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '#' {
+			p.pos++
+			continue
+		}
+		// a scope, e.g. g:
+		if r == ':' && p.pos == start+1 && strings.ContainsRune("gbwtslav", rune(p.src[start])) {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *parser) number() (interface{}, error) {
+	start := p.pos
+	for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == 'x' || p.src[p.pos] == 'X' ||
+		(p.src[p.pos] >= 'a' && p.src[p.pos] <= 'f') || (p.src[p.pos] >= 'A' && p.src[p.pos] <= 'F')) {
+		p.pos++
+	}
+	if p.pos+1 < len(p.src) && p.src[p.pos] == '.' && isDigit(p.src[p.pos+1]) {
+		p.pos++
+		for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+			p.pos++
+		}
+		return strconv.ParseFloat(p.src[start:p.pos], 64)
+	}
+	n, err := strconv.ParseInt(p.src[start:p.pos], 0, 64)
+	if err != nil {
+		return nil, p.errorf("invalid number %q", p.src[start:p.pos])
+	}
+	return int(n), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (p *parser) singleQuoted() (interface{}, error) {
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		if c != '\'' {
+			sb.WriteByte(c)
+			continue
+		}
+		if p.pos < len(p.src) && p.src[p.pos] == '\'' {
+			sb.WriteByte('\'')
+			p.pos++
+			continue
+		}
+		return sb.String(), nil
+	}
+	return nil, p.errorf("missing quote")
+}
+
+func (p *parser) doubleQuoted() (interface{}, error) {
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			if p.pos == len(p.src) {
+				return nil, p.errorf("missing quote")
+			}
+			e := p.src[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case 'r':
+				sb.WriteByte('\r')
+			case 'e':
+				sb.WriteByte(0x1b)
+			default:
+				sb.WriteByte(e)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return nil, p.errorf("missing quote")
+}
+
+// truthy reports whether v is true in a condition, as per Vim
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	}
+	return toNumber(v) != 0
+}
+
+func boolNum(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// toNumber converts v to a Number as per Vim, e.g. "12abc" is 12
+func toNumber(v interface{}) int {
+	switch v := v.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case bool:
+		return boolNum(v)
+	case string:
+		end := 0
+		if end < len(v) && v[end] == '-' {
+			end++
+		}
+		for end < len(v) && isDigit(v[end]) {
+			end++
+		}
+		n, _ := strconv.Atoi(v[:end])
+		return n
+	}
+	return 0
+}
+
+// toString converts v to a String as per Vim
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		if v {
+			return "v:true"
+		}
+		return "v:false"
+	case nil:
+		return "v:null"
+	}
+	return fmt.Sprint(v)
+}
+
+// compare compares a and b: numerically if either is a number, otherwise as
+// strings
+func compare(a, b interface{}) int {
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if aok && bok {
+		return strings.Compare(as, bs)
+	}
+	af, afok := a.(float64)
+	bf, bfok := b.(float64)
+	if afok || bfok {
+		if !afok {
+			af = float64(toNumber(a))
+		}
+		if !bfok {
+			bf = float64(toNumber(b))
+		}
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	}
+	if _, ok := a.([]interface{}); ok {
+		return boolNum(fmt.Sprint(a) != fmt.Sprint(b))
+	}
+	if _, ok := a.(map[string]interface{}); ok {
+		return boolNum(fmt.Sprint(a) != fmt.Sprint(b))
+	}
+	if a == nil || b == nil {
+		return boolNum(a != b)
+	}
+	an, bn := toNumber(a), toNumber(b)
+	switch {
+	case an < bn:
+		return -1
+	case an > bn:
+		return 1
+	}
+	return 0
+}
+
+func arith(a, b interface{}, i func(a, b int) int, f func(a, b float64) float64) interface{} {
+	af, afok := a.(float64)
+	bf, bfok := b.(float64)
+	if afok || bfok {
+		if !afok {
+			af = float64(toNumber(a))
+		}
+		if !bfok {
+			bf = float64(toNumber(b))
+		}
+		return f(af, bf)
+	}
+	return i(toNumber(a), toNumber(b))
+}
+
+// variable returns the value of the variable name. A name without a scope
+// is a global variable, as it is at the top level of a script.
+func (v *Vim) variable(name string) (interface{}, error) {
+	if strings.HasPrefix(name, "&") {
+		return v.option(strings.TrimPrefix(strings.TrimPrefix(strings.TrimPrefix(name, "&"), "l:"), "g:"))
+	}
+	switch name {
+	case "v:true":
+		return true, nil
+	case "v:false":
+		return false, nil
+	case "v:null", "v:none":
+		return nil, nil
+	case "v:version":
+		return versionLong / 10000, nil
+	case "v:versionlong":
+		return versionLong, nil
+	case "v:t_number":
+		return 0, nil
+	case "v:t_string":
+		return 1, nil
+	case "v:t_func":
+		return 2, nil
+	case "v:t_list":
+		return 3, nil
+	case "v:t_dict":
+		return 4, nil
+	case "v:t_float":
+		return 5, nil
+	case "v:t_bool":
+		return 6, nil
+	case "v:t_none":
+		return 7, nil
+	case "v:statusmsg", "v:errmsg", "v:warningmsg":
+		return "", nil
+	case "b:changedtick":
+		return v.win.buf.changedtick, nil
+	}
+	vars, n, err := v.scope(name)
+	if err != nil {
+		return nil, err
+	}
+	val, ok := vars[n]
+	if !ok {
+		return nil, fmt.Errorf("E121: Undefined variable: %v", name)
+	}
+	return val, nil
+}
+
+func (v *Vim) setVariable(name string, val interface{}) error {
+	if strings.HasPrefix(name, "&") {
+		n := strings.TrimPrefix(name, "&")
+		n = strings.TrimPrefix(strings.TrimPrefix(n, "l:"), "g:")
+		return v.setBufOption(v.win.buf, n, val)
+	}
+	if strings.HasPrefix(name, "v:") {
+		return fmt.Errorf("E46: Cannot change read-only variable %q", name)
+	}
+	vars, n, err := v.scope(name)
+	if err != nil {
+		return err
+	}
+	vars[n] = val
+	return nil
+}
+
+func (v *Vim) unsetVariable(name string) error {
+	vars, n, err := v.scope(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := vars[n]; !ok {
+		return fmt.Errorf("E108: No such variable: %q", name)
+	}
+	delete(vars, n)
+	return nil
+}
+
+// scope returns the variables of the scope of name, and the name within
+// that scope
+func (v *Vim) scope(name string) (map[string]interface{}, string, error) {
+	if len(name) > 2 && name[1] == ':' {
+		switch name[0] {
+		case 'g':
+			return v.gvars, name[2:], nil
+		case 'b':
+			return v.win.buf.vars, name[2:], nil
+		case 's':
+			return v.svars, name[2:], nil
+		}
+		return nil, "", fmt.Errorf("E121: Undefined variable: %v", name)
+	}
+	if name == "" {
+		return nil, "", fmt.Errorf("E15: Invalid expression: %q", name)
+	}
+	return v.gvars, name, nil
+}
+
+// vimString returns the string representation of val, as per string()
+func vimString(val interface{}) string {
+	switch val := val.(type) {
+	case string:
+		return "'" + strings.ReplaceAll(val, "'", "''") + "'"
+	case float64:
+		s := strconv.FormatFloat(val, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s
+	case []interface{}:
+		var elems []string
+		for _, e := range val {
+			elems = append(elems, vimString(e))
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case map[string]interface{}:
+		var keys []string
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var elems []string
+		for _, k := range keys {
+			elems = append(elems, vimString(k)+": "+vimString(val[k]))
+		}
+		return "{" + strings.Join(elems, ", ") + "}"
+	}
+	return toString(val)
+}
+
+// echoString returns val as displayed by :echo
+func echoString(val interface{}) string {
+	if s, ok := val.(string); ok {
+		return s
+	}
+	return vimString(val)
+}
@@ -0,0 +1,188 @@
+package govimtest_test
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExprPrecedence(t *testing.T) {
+	v, _ := newVim(t)
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"7 / 2", 3},
+		{"7 % 3", 1},
+		{"7.0 / 2", 3.5},
+		{"-2 * 3", -6},
+		{"!0 + 1", 2},
+		{"1 . 2 + 3", 15},
+		{"1 .. 2 * 3", "16"},
+		{"1 || 0 && 0", 1},
+		{"(1 || 0) && 0", 0},
+		{"1 + 1 == 2", 1},
+		{"0 ? 1 : 2", 2},
+		{"1 ? 0 ? 3 : 4 : 5", 4},
+		{"[1, 2] + [3]", []int{1, 2, 3}},
+		{"[10, 20, 30][1] + 1", 21},
+		{"{'a': {'b': 2}}.a.b * 3", 6},
+	}
+	for _, test := range tests {
+		res, err := v.Expr(test.expr)
+		check(t, test.expr, res, err, test.want)
+	}
+}
+
+func TestExprStrings(t *testing.T) {
+	v, _ := newVim(t)
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{`'it''s'`, "it's"},
+		{`'back\slash'`, `back\slash`},
+		{`'say "hi"'`, `say "hi"`},
+		{`"a\tb\n"`, "a\tb\n"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"it's"`, "it's"},
+		{`"x" . 'y'`, "xy"},
+		{`'' . ""`, ""},
+		{`"a" .. 1 .. 'b'`, "a1b"},
+	}
+	for _, test := range tests {
+		res, err := v.Expr(test.expr)
+		check(t, test.expr, res, err, test.want)
+	}
+}
+
+func TestExprComparisons(t *testing.T) {
+	v, _ := newVim(t)
+	tests := []struct {
+		expr string
+		want int
+	}{
+		{"1 == 1", 1},
+		{"1 != 1", 0},
+		{"2 > 1", 1},
+		{"2 >= 2", 1},
+		{"1 < 2", 1},
+		{"2 <= 1", 0},
+		{"2 > 1.5", 1},
+		{"1.5 == 1", 0},
+		{`"abc" == "abc"`, 1},
+		{`"abc" < "abd"`, 1},
+		{`"b" > "abc"`, 1},
+		{`"abc" ==# "ABC"`, 0},
+		{`"10" == 10`, 1},
+		{`"x" == 0`, 1},
+		{"[1, 2] == [1, 2]", 1},
+		{"[1, 2] != [2, 1]", 1},
+		{"{'a': 1} == {'a': 1}", 1},
+		{"{'a': 1} == {'a': 2}", 0},
+	}
+	for _, test := range tests {
+		res, err := v.Expr(test.expr)
+		check(t, test.expr, res, err, test.want)
+	}
+}
+
+func TestExprVariables(t *testing.T) {
+	v, _ := newVim(t)
+	for _, cmd := range []string{
+		"edit main.go",
+		"let g:x = 1",
+		"let x += 2",
+		"let b:y = 'buf'",
+		"let b:y .= 'fer'",
+		"let g:l = [1]",
+		"let g:l += [2]",
+		"let g:d = {'k': 'v'}",
+	} {
+		if err := v.Ex(cmd); err != nil {
+			t.Fatalf("%v: %v", cmd, err)
+		}
+	}
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		{"g:x", 3},
+		{"x", 3},
+		{"b:y", "buffer"},
+		{"g:l", []int{1, 2}},
+		{"g:d.k", "v"},
+		{"g:d['k']", "v"},
+		{"&filetype", "go"},
+		{"&ft", "go"},
+		{"b:changedtick > 0", 1},
+		{"v:true", true},
+		{"[v:null]", []interface{}{nil}},
+		{"exists('g:x')", 1},
+		{"exists('w:x')", 0},
+	}
+	for _, test := range tests {
+		res, err := v.Expr(test.expr)
+		check(t, test.expr, res, err, test.want)
+	}
+
+	if err := v.Ex("unlet g:x"); err != nil {
+		t.Fatal(err)
+	}
+	errTests := []struct {
+		what string
+		run  func() error
+		want string
+	}{
+		{"x after unlet", func() error { _, err := v.Expr("x"); return err }, "E121"},
+		{"w: variable", func() error { _, err := v.Expr("w:foo"); return err }, "E121"},
+		{"let v:", func() error { return v.Ex("let v:foo = 1") }, "E46"},
+		{"unlet missing", func() error { return v.Ex("unlet g:x") }, "E108"},
+		{"unlet! missing", func() error { return v.Ex("unlet! g:x") }, ""},
+	}
+	for _, test := range errTests {
+		checkErr(t, test.what, test.run(), test.want)
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	v, _ := newVim(t)
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"", "E15"},
+		{"1 +", "E15"},
+		{"(1", "E15"},
+		{"'abc", "E15"},
+		{`"abc`, "E15"},
+		{"1 2", "E15"},
+		{"1 ? 2", "E15"},
+		{"1 / 0", "division by zero"},
+		{"1 % 0", "division by zero"},
+		{"[1, 2][5]", "E684"},
+		{"{'a': 1}.b", "E716"},
+		{"{'a': 1}['b']", "E716"},
+		{"undefined", "E121"},
+		{"nosuchfunc()", "E117"},
+	}
+	for _, test := range tests {
+		_, err := v.Expr(test.expr)
+		checkErr(t, test.expr, err, test.want)
+	}
+}
+
+// checkErr checks that err contains want, or that err is nil if want is
+// empty
+func checkErr(t *testing.T, what string, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Errorf("%v: got error %v; want none", what, err)
+	case want != "" && (err == nil || !strings.Contains(err.Error(), want)):
+		t.Errorf("%v: got error %v; want %v", what, err, want)
+	}
+}
@@ -0,0 +1,688 @@
+package govimtest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// modifiers maps the names of the command modifiers to their value in
+// <mods>, which is "" for those modifiers that plugin/govim.vim does not
+// pass to govim
+var modifiers = map[string]string{
+	"aboveleft":    "aboveleft",
+	"belowright":   "belowright",
+	"botright":     "botright",
+	"browse":       "browse",
+	"confirm":      "confirm",
+	"hide":         "hide",
+	"keepalt":      "keepalt",
+	"keepjumps":    "keepjumps",
+	"keepmarks":    "keepmarks",
+	"keeppatterns": "keeppatterns",
+	"leftabove":    "leftabove",
+	"lockmarks":    "lockmarks",
+	"noautocmd":    "",
+	"noswapfile":   "noswapfile",
+	"rightbelow":   "rightbelow",
+	"sil":          "silent",
+	"silent":       "silent",
+	"tab":          "tab",
+	"topleft":      "topleft",
+	"unsilent":     "",
+	"verbose":      "verbose",
+	"vertical":     "vertical",
+}
+
+// exCommands are the names of the Ex commands implemented by the fake Vim,
+// with the length of their shortest abbreviation
+var exCommands = []struct {
+	name string
+	min  int
+}{
+	{"augroup", 3},
+	{"buffer", 1},
+	{"bdelete", 2},
+	{"bwipeout", 2},
+	{"call", 3},
+	{"delete", 1},
+	{"doautoall", 7},
+	{"doautocmd", 2},
+	{"echo", 2},
+	{"echoerr", 5},
+	{"echomsg", 5},
+	{"echon", 5},
+	{"edit", 1},
+	{"execute", 3},
+	{"filetype", 5},
+	{"highlight", 2},
+	{"let", 3},
+	{"messages", 3},
+	{"normal", 4},
+	{"redraw", 4},
+	{"redrawstatus", 7},
+	{"set", 2},
+	{"setglobal", 4},
+	{"setlocal", 4},
+	{"syntax", 2},
+	{"throw", 2},
+	{"unlet", 3},
+	{"update", 2},
+	{"write", 1},
+}
+
+// boolOptions are the options that are toggled rather than shown by :set
+// {option}
+var boolOptions = map[string]bool{
+	"autoread":        true,
+	"ballooneval":     true,
+	"balloonevalterm": true,
+	"buflisted":       true,
+	"expandtab":       true,
+	"hidden":          true,
+	"ignorecase":      true,
+	"modifiable":      true,
+	"modified":        true,
+	"number":          true,
+	"readonly":        true,
+	"relativenumber":  true,
+	"smartcase":       true,
+	"swapfile":        true,
+	"wrapscan":        true,
+}
+
+// exCmd is a parsed Ex command line
+type exCmd struct {
+	line string
+
+	mods       []string
+	silentBang bool
+	noautocmd  bool
+
+	// rangeCount is the number of line numbers given in the range, as per
+	// <range>
+	rangeCount   int
+	line1, line2 int
+
+	name string
+	bang bool
+	arg  string
+}
+
+// ex executes the Ex command line
+func (v *Vim) ex(line string) error {
+	c, err := v.parseEx(line)
+	if err != nil || c == nil {
+		return err
+	}
+	if c.noautocmd {
+		v.noautocmd++
+		defer func() {
+			v.noautocmd--
+		}()
+	}
+	err = v.runEx(c)
+	if c.silentBang {
+		return nil
+	}
+	return err
+}
+
+func (v *Vim) parseEx(line string) (*exCmd, error) {
+	s := strings.TrimLeft(line, " \t:")
+	if s == "" || s[0] == '"' {
+		return nil, nil
+	}
+	c := &exCmd{line: line}
+	for {
+		w := leadingWord(s, false)
+		mod, ok := modifiers[w]
+		if !ok {
+			break
+		}
+		s = s[len(w):]
+		switch w {
+		case "sil", "silent":
+			if strings.HasPrefix(s, "!") {
+				c.silentBang = true
+				s = s[1:]
+			}
+		case "noautocmd":
+			c.noautocmd = true
+		}
+		if mod != "" {
+			c.mods = append(c.mods, mod)
+		}
+		s = strings.TrimLeft(s, " \t")
+	}
+	s, err := v.parseRange(c, s)
+	if err != nil {
+		return nil, err
+	}
+	s = strings.TrimLeft(s, " \t")
+	c.name = leadingWord(s, true)
+	s = s[len(c.name):]
+	if strings.HasPrefix(s, "!") {
+		c.bang = true
+		s = s[1:]
+	}
+	c.arg = strings.TrimSpace(s)
+	if c.name == "" && c.arg != "" {
+		return nil, fmt.Errorf("E492: Not an editor command: %v", strings.TrimSpace(line))
+	}
+	return c, nil
+}
+
+// leadingWord returns the letters at the start of s. If digits is set, as
+// for the name of a user command, digits are also allowed after the first
+// letter.
+func leadingWord(s string, digits bool) string {
+	for i, r := range s {
+		if !unicode.IsLetter(r) && !(digits && i > 0 && unicode.IsUpper(rune(s[0])) && unicode.IsDigit(r)) {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// parseRange parses the range at the start of s into c, returning the rest
+// of s. Line numbers, ".", "$" and "%" are supported, with offsets.
+func (v *Vim) parseRange(c *exCmd, s string) (string, error) {
+	last := len(v.win.buf.lines)
+	if strings.HasPrefix(s, "%") {
+		c.rangeCount, c.line1, c.line2 = 2, 1, last
+		return s[1:], nil
+	}
+	var addrs []int
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			break
+		}
+		var addr int
+		switch {
+		case s[0] == '.':
+			addr, s = v.win.lnum, s[1:]
+		case s[0] == '$':
+			addr, s = last, s[1:]
+		case s[0] == '\'':
+			return "", fmt.Errorf("E20: Mark not set")
+		case isDigit(s[0]):
+			i := 0
+			for i < len(s) && isDigit(s[i]) {
+				i++
+			}
+			addr, s = toNumber(s[:i]), s[i:]
+		case s[0] == '+' || s[0] == '-':
+			addr = v.win.lnum
+		default:
+			if len(addrs) > 0 {
+				return "", fmt.Errorf("E14: Invalid address")
+			}
+			return s, nil
+		}
+		for len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+			sign := 1
+			if s[0] == '-' {
+				sign = -1
+			}
+			i := 1
+			for i < len(s) && isDigit(s[i]) {
+				i++
+			}
+			off := 1
+			if i > 1 {
+				off = toNumber(s[1:i])
+			}
+			addr, s = addr+sign*off, s[i:]
+		}
+		if addr < 0 || addr > last {
+			return "", fmt.Errorf("E16: Invalid range")
+		}
+		addrs = append(addrs, addr)
+		if len(s) == 0 || (s[0] != ',' && s[0] != ';') {
+			break
+		}
+		s = s[1:]
+	}
+	switch len(addrs) {
+	case 0:
+	case 1:
+		c.rangeCount, c.line1, c.line2 = 1, addrs[0], addrs[0]
+	default:
+		c.rangeCount, c.line1, c.line2 = 2, addrs[len(addrs)-2], addrs[len(addrs)-1]
+		if c.line1 > c.line2 {
+			return "", fmt.Errorf("E493: Backwards range given")
+		}
+	}
+	return s, nil
+}
+
+// lookupExCommand returns the full name of the Ex command name, which may be
+// abbreviated, or "" if there is no such command
+func lookupExCommand(name string) string {
+	for _, c := range exCommands {
+		if len(name) >= c.min && strings.HasPrefix(c.name, name) {
+			return c.name
+		}
+	}
+	return ""
+}
+
+func (v *Vim) runEx(c *exCmd) error {
+	if c.name == "" {
+		// A range alone moves the cursor
+		v.win.lnum = c.line2
+		v.clampCursor()
+		return nil
+	}
+	if unicode.IsUpper(rune(c.name[0])) {
+		return v.userCommand(c)
+	}
+	name := lookupExCommand(c.name)
+	if c.rangeCount > 0 && name != "call" && name != "delete" {
+		return fmt.Errorf("E481: No range allowed")
+	}
+	switch name {
+	case "augroup", "filetype", "highlight", "redraw", "redrawstatus", "syntax":
+	case "buffer":
+		b, err := v.mustBuffer(bufArg(c.arg))
+		if err != nil {
+			return err
+		}
+		return v.visit(b)
+	case "bdelete", "bwipeout":
+		bufs := []*buffer{v.win.buf}
+		if c.arg != "" {
+			bufs = nil
+			for _, f := range strings.Fields(c.arg) {
+				b, err := v.mustBuffer(bufArg(f))
+				if err != nil {
+					return err
+				}
+				bufs = append(bufs, b)
+			}
+		}
+		var errs errList
+		for _, b := range bufs {
+			if b.options["modified"] == 1 && !c.bang {
+				return fmt.Errorf("E89: No write since last change for buffer %v (add ! to override)", b.nr)
+			}
+			errs.add(v.wipe(b, name == "bdelete"))
+		}
+		return errs.err()
+	case "call":
+		if c.rangeCount > 0 {
+			v.callRange = &[2]int{c.line1, c.line2}
+			defer func() {
+				v.callRange = nil
+			}()
+		}
+		_, err := v.eval(c.arg)
+		return err
+	case "delete":
+		first, last := v.win.lnum, v.win.lnum
+		if c.rangeCount > 0 {
+			first, last = c.line1, c.line2
+		}
+		return v.deleteLines(v.win.buf, first, last)
+	case "doautoall", "doautocmd":
+		return v.doautocmd(c.arg, name == "doautoall")
+	case "echo", "echoerr", "echomsg", "echon":
+		vals, err := v.evalList(c.arg)
+		if err != nil {
+			return err
+		}
+		var strs []string
+		for _, val := range vals {
+			strs = append(strs, echoString(val))
+		}
+		sep := " "
+		if name == "echon" {
+			sep = ""
+		}
+		msg := strings.Join(strs, sep)
+		if name == "echoerr" {
+			return errors.New(msg)
+		}
+		v.say(msg, name == "echomsg")
+	case "edit":
+		return v.edit(c.arg, c.bang)
+	case "execute":
+		vals, err := v.evalList(c.arg)
+		if err != nil {
+			return err
+		}
+		var strs []string
+		for _, val := range vals {
+			strs = append(strs, toString(val))
+		}
+		return v.ex(strings.Join(strs, " "))
+	case "let":
+		return v.let(c.arg)
+	case "messages":
+		for _, m := range v.messages {
+			v.say(m, false)
+		}
+	case "normal":
+		return fmt.Errorf("govimtest: :normal is not supported")
+	case "set", "setglobal", "setlocal":
+		return v.set(c.arg)
+	case "throw":
+		val, err := v.eval(c.arg)
+		if err != nil {
+			return err
+		}
+		return errors.New(toString(val))
+	case "unlet":
+		for _, n := range strings.Fields(c.arg) {
+			if err := v.unsetVariable(n); err != nil && !c.bang {
+				return err
+			}
+		}
+	case "update", "write":
+		b := v.win.buf
+		if c.arg != "" {
+			if b.name != "" {
+				return fmt.Errorf("govimtest: writing to another file is not supported")
+			}
+			b.name = v.abs(c.arg)
+		}
+		if name == "update" && b.options["modified"] == 0 {
+			return nil
+		}
+		return v.write(b)
+	default:
+		return fmt.Errorf("E492: Not an editor command: %v", strings.TrimSpace(c.line))
+	}
+	return nil
+}
+
+// bufArg returns the buffer number in arg as a number, and any other arg,
+// a buffer name, as is
+func bufArg(arg string) interface{} {
+	if arg != "" && toString(toNumber(arg)) == arg {
+		return toNumber(arg)
+	}
+	return arg
+}
+
+// visit makes b the buffer of the window, loading it if necessary, as per
+// :buffer
+func (v *Vim) visit(b *buffer) error {
+	if b == v.win.buf {
+		return nil
+	}
+	var errs errList
+	errs.add(v.enter(b))
+	if !b.loaded {
+		errs.add(v.load(b))
+	}
+	errs.add(v.fire("BufEnter", "", b, ""))
+	errs.add(v.fire("BufWinEnter", "", b, ""))
+	return errs.err()
+}
+
+// evalList evaluates the whitespace-separated expressions in s
+func (v *Vim) evalList(s string) ([]interface{}, error) {
+	p := &parser{v: v, src: s}
+	var res []interface{}
+	for {
+		p.skipSpace()
+		if p.pos == len(p.src) {
+			return res, nil
+		}
+		val, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, val)
+	}
+}
+
+// let implements :let {var} = {expr}, and the variants with +=, -= and .=
+func (v *Vim) let(arg string) error {
+	i := strings.Index(arg, "=")
+	if i < 1 {
+		return fmt.Errorf("E15: Invalid expression: %q", arg)
+	}
+	lhs := strings.TrimSpace(arg[:i])
+	var op string
+	for _, o := range []string{"..", ".", "+", "-"} {
+		if strings.HasSuffix(lhs, o) {
+			op, lhs = o, strings.TrimSpace(strings.TrimSuffix(lhs, o))
+			break
+		}
+	}
+	val, err := v.eval(arg[i+1:])
+	if err != nil {
+		return err
+	}
+	if op != "" {
+		cur, err := v.variable(lhs)
+		if err != nil {
+			return err
+		}
+		switch op {
+		case ".", "..":
+			val = toString(cur) + toString(val)
+		case "+":
+			if l, ok := cur.([]interface{}); ok {
+				r, _ := val.([]interface{})
+				val = append(append([]interface{}{}, l...), r...)
+			} else {
+				val = arith(cur, val, func(a, b int) int { return a + b }, func(a, b float64) float64 { return a + b })
+			}
+		case "-":
+			val = arith(cur, val, func(a, b int) int { return a - b }, func(a, b float64) float64 { return a - b })
+		}
+	}
+	return v.setVariable(lhs, val)
+}
+
+// set implements :set and friends, all of which behave the same given there
+// is only a single window
+func (v *Vim) set(arg string) error {
+	b := v.win.buf
+	for _, a := range strings.Fields(arg) {
+		if i := strings.IndexAny(a, "=:"); i > 0 {
+			name, val := a[:i], a[i+1:]
+			var op byte
+			if c := name[len(name)-1]; c == '+' || c == '-' || c == '^' {
+				op, name = c, name[:len(name)-1]
+			}
+			cur, err := v.bufOption(b, name)
+			if err != nil {
+				return err
+			}
+			var newVal interface{} = val
+			if n, ok := cur.(int); ok {
+				switch op {
+				case '+':
+					newVal = n + toNumber(val)
+				case '-':
+					newVal = n - toNumber(val)
+				case '^':
+					newVal = n * toNumber(val)
+				}
+			} else if op != 0 {
+				newVal = commaListOp(cur.(string), op, val)
+			}
+			if err := v.setBufOption(b, name, newVal); err != nil {
+				return err
+			}
+			continue
+		}
+		name, val := a, interface{}(1)
+		switch {
+		case strings.HasSuffix(a, "?"):
+			name = strings.TrimSuffix(a, "?")
+			cur, err := v.bufOption(b, name)
+			if err != nil {
+				return err
+			}
+			v.say(fmt.Sprintf("  %v=%v", optionName(name), toString(cur)), false)
+			continue
+		case strings.HasSuffix(a, "&"):
+			name = optionName(strings.TrimSuffix(a, "&"))
+			if d, ok := bufferOptions[name]; ok {
+				val = d
+			} else {
+				val = defaultOptions()[name]
+			}
+		case strings.HasSuffix(a, "!"):
+			name = strings.TrimSuffix(a, "!")
+			cur, err := v.bufOption(b, name)
+			if err != nil {
+				return err
+			}
+			val = boolNum(!truthy(cur))
+		case strings.HasPrefix(a, "inv") && boolOptions[optionName(a[3:])]:
+			name = a[3:]
+			cur, _ := v.bufOption(b, name)
+			val = boolNum(!truthy(cur))
+		case strings.HasPrefix(a, "no") && boolOptions[optionName(a[2:])]:
+			name, val = a[2:], 0
+		case !boolOptions[optionName(a)]:
+			cur, err := v.bufOption(b, name)
+			if err != nil {
+				return err
+			}
+			v.say(fmt.Sprintf("  %v=%v", optionName(name), toString(cur)), false)
+			continue
+		}
+		if err := v.setBufOption(b, name, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commaListOp applies the :set operator op to the comma-separated list
+// option value cur and the item val
+func commaListOp(cur string, op byte, val string) string {
+	var items []string
+	if cur != "" {
+		items = strings.Split(cur, ",")
+	}
+	switch op {
+	case '+':
+		items = append(items, val)
+	case '^':
+		items = append([]string{val}, items...)
+	case '-':
+		var res []string
+		for _, i := range items {
+			if i != val {
+				res = append(res, i)
+			}
+		}
+		items = res
+	}
+	return strings.Join(items, ",")
+}
+
+// userCommand runs the command c defined by govim, passing the same flags
+// and arguments as the command defined by s:defineCommand in
+// plugin/govim.vim
+func (v *Vim) userCommand(c *exCmd) error {
+	attrs, ok := v.commands[c.name]
+	if !ok {
+		return fmt.Errorf("E492: Not an editor command: %v", strings.TrimSpace(c.line))
+	}
+	flags := map[string]interface{}{"mods": strings.Join(c.mods, " ")}
+	var args []interface{}
+	switch nargs, _ := attrs["nargs"].(string); nargs {
+	case "", "-nargs=0":
+		if c.arg != "" {
+			return fmt.Errorf("E488: Trailing characters: %v", c.arg)
+		}
+	case "-nargs=1", "-nargs=?":
+		if c.arg == "" && nargs == "-nargs=1" {
+			return fmt.Errorf("E471: Argument required")
+		}
+		if c.arg != "" {
+			args = append(args, c.arg)
+		}
+	case "-nargs=+", "-nargs=*":
+		if c.arg == "" && nargs == "-nargs=+" {
+			return fmt.Errorf("E471: Argument required")
+		}
+		for _, a := range fargs(c.arg) {
+			args = append(args, a)
+		}
+	}
+	rangeAttr, hasRange := attrs["range"]
+	countAttr, hasCount := attrs["count"]
+	if c.rangeCount > 0 && !hasRange && !hasCount {
+		return fmt.Errorf("E481: No range allowed")
+	}
+	if hasRange {
+		line1, line2 := v.win.lnum, v.win.lnum
+		switch {
+		case c.rangeCount > 0:
+			line1, line2 = c.line1, c.line2
+		case rangeAttr == "-range=%":
+			line1, line2 = 1, len(v.win.buf.lines)
+		}
+		flags["line1"] = line1
+		flags["line2"] = line2
+		flags["range"] = c.rangeCount
+	}
+	if hasCount {
+		count := toNumber(strings.TrimPrefix(toString(countAttr), "-count="))
+		if c.rangeCount > 0 {
+			count = c.line2
+		}
+		flags["count"] = count
+	}
+	var bang bool
+	general, _ := attrs["general"].([]interface{})
+	for _, a := range general {
+		switch a {
+		case "-bang":
+			bang = true
+			flags["bang"] = ""
+			if c.bang {
+				flags["bang"] = "!"
+			}
+		case "-register":
+			flags["register"] = ""
+		}
+	}
+	if c.bang && !bang {
+		return fmt.Errorf("E477: No ! allowed")
+	}
+	_, err := v.request(append([]interface{}{"function", "command:" + c.name, flags}, args...)...)
+	return err
+}
+
+// fargs splits arg into arguments as per <f-args>: at whitespace, other than
+// that escaped with a backslash
+func fargs(arg string) []string {
+	var res []string
+	var sb strings.Builder
+	inArg := false
+	for i := 0; i < len(arg); i++ {
+		switch c := arg[i]; {
+		case c == '\\' && i+1 < len(arg) && (arg[i+1] == ' ' || arg[i+1] == '\t' || arg[i+1] == '\\'):
+			i++
+			sb.WriteByte(arg[i])
+			inArg = true
+		case c == ' ' || c == '\t':
+			if inArg {
+				res = append(res, sb.String())
+				sb.Reset()
+				inArg = false
+			}
+		default:
+			sb.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		res = append(res, sb.String())
+	}
+	return res
+}
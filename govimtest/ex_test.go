@@ -0,0 +1,113 @@
+package govimtest_test
+
+import (
+	"testing"
+)
+
+func TestExRanges(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want []string
+		err  string
+	}{
+		{"1delete", []string{"", "func main() {}"}, ""},
+		{"2,$delete", []string{"package main"}, ""},
+		{"%delete", []string{""}, ""},
+		{".delete", []string{"", "func main() {}"}, ""},
+		{"$-1delete", []string{"package main", "func main() {}"}, ""},
+		{"1+1,3delete", []string{"package main"}, ""},
+		{"3,1delete", nil, "E493"},
+		{"5delete", nil, "E16"},
+		{"'zdelete", nil, "E20"},
+		{"1,2echo 1", nil, "E481"},
+	}
+	for _, test := range tests {
+		v, _ := newVim(t)
+		if err := v.Ex("edit main.go"); err != nil {
+			t.Fatal(err)
+		}
+		err := v.Ex(test.cmd)
+		checkErr(t, test.cmd, err, test.err)
+		if test.err != "" {
+			continue
+		}
+		res, err := v.Call("getline", 1, "$")
+		check(t, test.cmd, res, err, test.want)
+	}
+}
+
+func TestExCommands(t *testing.T) {
+	v, _ := newVim(t)
+	if err := v.Ex("edit main.go"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		cmd  string
+		expr string
+		want interface{}
+	}{
+		{"let g:a = 1 + 2", "g:a", 3},
+		{"let g:a -= 1", "g:a", 2},
+		{"let g:s = 'a'", "g:s", "a"},
+		{"let g:s ..= 'b'", "g:s", "ab"},
+		{"execute 'let g:e =' 4 '* 2'", "g:e", 8},
+		{"exe \"let g:e = 'x'\"", "g:e", "x"},
+		{"  :let g:w = 1", "g:w", 1},
+		{"set shiftwidth=4", "&shiftwidth", 4},
+		{"set sw+=2", "&shiftwidth", 6},
+		{"set sw-=1", "&shiftwidth", 5},
+		{"set sw^=2", "&shiftwidth", 10},
+		{"setlocal expandtab", "&expandtab", 1},
+		{"set noexpandtab", "&expandtab", 0},
+		{"set invexpandtab", "&expandtab", 1},
+		{"set expandtab!", "&expandtab", 0},
+		{"set sw&", "&shiftwidth", 8},
+		{"set completeopt=menu", "&completeopt", "menu"},
+		{"set completeopt+=popup", "&completeopt", "menu,popup"},
+		{"set completeopt-=menu", "&completeopt", "popup"},
+		{"set completeopt^=longest", "&completeopt", "longest,popup"},
+		{"", "execute('echo 1 \"a\" [2]')", "\n1 a [2]"},
+		{"", "execute('echo [1, 2] [0]')", "\n[1, 2] [0]"},
+		{"", "execute('set sw?')", "\n  shiftwidth=8"},
+		{"\" a comment", "g:a", 2},
+	}
+	for _, test := range tests {
+		if err := v.Ex(test.cmd); err != nil {
+			t.Fatalf("%v: %v", test.cmd, err)
+		}
+		res, err := v.Expr(test.expr)
+		check(t, test.cmd+": "+test.expr, res, err, test.want)
+	}
+}
+
+func TestExErrors(t *testing.T) {
+	v, _ := newVim(t)
+	if err := v.Ex("edit main.go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Ex("call setline(1, 'changed')"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"nosuchcommand", "E492"},
+		{"le g:x = 1", "E492"},
+		{"let", "E15"},
+		{"let g:x 1", "E15"},
+		{"let g:x = ", "E15"},
+		{"call nosuchfunc()", "E117"},
+		{"throw 'oops'", "oops"},
+		{"echoerr 'bad' 1", "bad 1"},
+		{"bdelete", "E89"},
+		{"bwipeout", "E89"},
+		{"Greet", "E471"},
+		{"Nosuch", "E492"},
+		{"silent! throw 'ignored'", ""},
+		{"unlet! g:nosuch", ""},
+	}
+	for _, test := range tests {
+		checkErr(t, test.cmd, v.Ex(test.cmd), test.want)
+	}
+}
@@ -0,0 +1,615 @@
+// Package govimtest provides a fake Vim for unit testing govim plugins.
+//
+// The fake Vim speaks the channel protocol of plugin/govim.vim to a govim
+// instance in the same process: a plugin is loaded, defines its functions,
+// commands and autocommands, and handles calls exactly as it would against a
+// real Vim. Rather than running Vim, the fake implements a subset of Vim's
+// builtin functions and Ex commands against an in-memory model of buffers
+// and a single window: buffer lines and variables, options, the cursor,
+// quickfix and location lists, text properties, signs, popups and
+// listeners. A test drives a plugin via the functions, commands and
+// autocommands it defines, and inspects the resulting state via the same
+// builtins the plugin uses:
+//
+//	v := govimtest.NewT(t, myplugin.New(), govimtest.Config{Dir: dir})
+//	if err := v.Ex("edit main.go"); err != nil {
+//		t.Fatal(err)
+//	}
+//	if err := v.Ex("MyPluginFormat"); err != nil {
+//		t.Fatal(err)
+//	}
+//	lines, err := v.Call("getline", 1, "$")
+//
+// Anything outside that subset, e.g. :normal or a call of a builtin that is
+// not implemented, fails with an error, as does a call of an unknown
+// function in Vim.
+package govimtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/govim/govim"
+	"gopkg.in/tomb.v2"
+)
+
+// versionLong is the value of v:versionlong in the fake Vim, i.e. v9.1.0
+const versionLong = 9010000
+
+// ErrClosed is returned by the methods of a Vim that has been closed, or
+// whose govim instance has stopped.
+var ErrClosed = errors.New("govimtest: Vim closed")
+
+// Config is the configuration of a fake Vim
+type Config struct {
+	// Dir is the directory relative to which file names are resolved, e.g.
+	// by :edit. It defaults to the current directory.
+	Dir string
+
+	// Log is where the govim instance logs. If Log is nil the log is
+	// discarded.
+	Log io.Writer
+}
+
+// Vim is a fake Vim to which a govim plugin is connected
+type Vim struct {
+	govim govim.Govim
+	tomb  tomb.Tomb
+	dir   string
+
+	// in carries messages to govim, and out messages from govim
+	in  *io.PipeWriter
+	out *io.PipeReader
+	enc *json.Encoder
+
+	// calls are the calls govim makes of Vim, responses the responses to the
+	// requests Vim makes of govim, and work the functions run on behalf of
+	// the methods of Vim. All three are handled by loop, the only goroutine
+	// that accesses the state that follows.
+	calls     chan json.RawMessage
+	responses chan response
+	work      chan func()
+
+	// status is the status of govim as reported by GOVIMPluginStatus()
+	status string
+
+	nextRequestID   int
+	scheduleBacklog []int
+
+	// funcs, commands and autocmds are those defined by govim
+	funcs    map[string]*function
+	commands map[string]map[string]interface{}
+	autocmds []*autocmd
+
+	// ac is the context of the autocommand being executed, if any, and
+	// noautocmd is non-zero whilst autocommands are suppressed
+	ac        *acContext
+	noautocmd int
+
+	// callRange is the range given to :call, if any
+	callRange *[2]int
+
+	gvars   map[string]interface{}
+	svars   map[string]interface{}
+	options map[string]interface{}
+
+	// messages is the message history, and capture collects the output of
+	// the commands run by execute()
+	messages []string
+	capture  *strings.Builder
+
+	bufs      []*buffer
+	nextBufNr int
+	win       *window
+	nextWinID int
+
+	qf        *qfList
+	propTypes map[string]map[string]interface{}
+	signDefs  map[string]map[string]interface{}
+	popups    []*popup
+
+	listeners      []*listener
+	nextListenerID int
+	flushing       bool
+
+	// listenerTargets are the functions to which changes are sent by
+	// listeners, as per s:listenerTargets in plugin/govim.vim
+	listenerTargets map[string]bool
+
+	// pushedViewport is the viewport as last pushed to govim, once govim has
+	// subscribed to changes in it
+	pushedViewport map[string]interface{}
+}
+
+// response is a response from govim to a request made by Vim
+type response struct {
+	id      int
+	payload json.RawMessage
+}
+
+// function is a function defined by govim
+type function struct {
+	name    string
+	params  []string
+	isRange bool
+}
+
+// New starts a fake Vim and connects plug to it via a new govim instance.
+// New returns once the plugin has been initialised, i.e. once its Init
+// method has returned and Vim has been told initialisation is complete.
+func New(plug govim.Plugin, c Config) (*Vim, error) {
+	dir := c.Dir
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine current directory: %v", err)
+		}
+		dir = wd
+	}
+	log := c.Log
+	if log == nil {
+		log = io.Discard
+	}
+	govimIn, in := io.Pipe()
+	out, govimOut := io.Pipe()
+	v := &Vim{
+		dir:       dir,
+		in:        in,
+		out:       out,
+		enc:       json.NewEncoder(in),
+		calls:     make(chan json.RawMessage),
+		responses: make(chan response),
+		work:      make(chan func()),
+		status:    "loading",
+
+		nextRequestID: 1,
+
+		funcs:    make(map[string]*function),
+		commands: make(map[string]map[string]interface{}),
+
+		gvars:   make(map[string]interface{}),
+		svars:   make(map[string]interface{}),
+		options: defaultOptions(),
+
+		nextBufNr: 1,
+		nextWinID: 1000,
+
+		qf:        &qfList{},
+		propTypes: make(map[string]map[string]interface{}),
+		signDefs:  make(map[string]map[string]interface{}),
+
+		nextListenerID:  1,
+		listenerTargets: map[string]bool{"GOVIM_internal_BufChanged": true},
+	}
+	buf := v.newBuffer("")
+	buf.loaded = true
+	v.win = &window{id: v.newWinID(), buf: buf, lnum: 1, col: 1, loclist: &qfList{}}
+
+	g, err := govim.NewGovim(plug, govimIn, govimOut, log, nil, &v.tomb)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create govim instance: %v", err)
+	}
+	v.govim = g
+	v.tomb.Go(v.read)
+	v.tomb.Go(v.loop)
+	v.tomb.Go(g.Run)
+	go func() {
+		// govim reads EOF once in is closed, and the writes of either side
+		// fail once out is closed
+		<-v.tomb.Dying()
+		in.Close()
+		out.Close()
+	}()
+
+	select {
+	case <-g.Initialized():
+		return v, nil
+	case <-v.tomb.Dying():
+		err := v.tomb.Wait()
+		if err == nil {
+			err = ErrClosed
+		}
+		return nil, fmt.Errorf("failed to initialise plugin: %v", err)
+	}
+}
+
+// NewT is New for a test: it fails t if the fake Vim cannot be started, and
+// closes the fake Vim when t finishes, failing t on an error from Close. Dir
+// defaults to a new temporary directory rather than the current directory.
+func NewT(t testing.TB, plug govim.Plugin, c Config) *Vim {
+	t.Helper()
+	if c.Dir == "" {
+		c.Dir = t.TempDir()
+	}
+	v, err := New(plug, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := v.Close(); err != nil {
+			t.Errorf("failed to close: %v", err)
+		}
+	})
+	return v
+}
+
+// Close shuts down the plugin as Vim does when it exits, and then stops the
+// govim instance. It returns the first error from either.
+func (v *Vim) Close() error {
+	err := v.do(func() error {
+		_, err := v.request("shutdown")
+		return err
+	})
+	if err == ErrClosed {
+		err = nil
+	}
+	v.tomb.Kill(nil)
+	if werr := v.tomb.Wait(); err == nil {
+		err = werr
+	}
+	return err
+}
+
+// Govim returns the govim instance to which the plugin is connected
+func (v *Vim) Govim() govim.Govim {
+	return v.govim
+}
+
+// Ex executes the Ex command cmd, as if typed by the user. A command
+// defined by the plugin is handled by the plugin before Ex returns.
+func (v *Vim) Ex(cmd string) error {
+	return v.do(func() error {
+		return v.ex(cmd)
+	})
+}
+
+// Expr evaluates expr, returning the JSON-encoded result
+func (v *Vim) Expr(expr string) (json.RawMessage, error) {
+	var res interface{}
+	err := v.do(func() (err error) {
+		res, err = v.eval(expr)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(res)
+}
+
+// Call calls the function fn with args, returning the JSON-encoded result.
+// fn is either a builtin or a function defined by the plugin. Each value in
+// args is passed to fn as it would be from govim, i.e. as its JSON
+// encoding.
+func (v *Vim) Call(fn string, args ...interface{}) (json.RawMessage, error) {
+	if args == nil {
+		args = []interface{}{}
+	}
+	byts, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode args: %v", err)
+	}
+	vargs, err := fromJSON(byts)
+	if err != nil {
+		return nil, err
+	}
+	var res interface{}
+	err = v.do(func() (err error) {
+		res, err = v.call(fn, vargs.([]interface{}))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(res)
+}
+
+// PopupKey types key, in the <> notation of keytrans(), in the popup with
+// id, as if the popup had focus, returning whether the key was consumed. The
+// popup must have been created by s:popupCreate() with a filter.
+func (v *Vim) PopupKey(id int, key string) (bool, error) {
+	var consumed bool
+	err := v.do(func() error {
+		p := v.popup(id)
+		if p == nil {
+			return fmt.Errorf("E993: Window %v is not a popup window", id)
+		}
+		if p.keyFilter == "" {
+			return fmt.Errorf("popup %v has no filter", id)
+		}
+		res, err := v.call(p.keyFilter, []interface{}{id, key})
+		consumed = truthy(res)
+		return err
+	})
+	return consumed, err
+}
+
+// do runs f in the loop, returning its result
+func (v *Vim) do(f func() error) error {
+	done := make(chan error, 1)
+	select {
+	case <-v.tomb.Dying():
+		return ErrClosed
+	case v.work <- func() { done <- f() }:
+	}
+	// Once in the loop f runs to completion, even if govim stops
+	return <-done
+}
+
+// read reads messages from govim: calls of Vim are passed to the loop via
+// calls, and responses to requests via responses.
+func (v *Vim) read() error {
+	dec := json.NewDecoder(v.out)
+	for {
+		var msg [2]json.RawMessage
+		if err := dec.Decode(&msg); err != nil {
+			select {
+			case <-v.tomb.Dying():
+				return nil
+			default:
+			}
+			return fmt.Errorf("failed to read message from govim: %v", err)
+		}
+		var id int
+		if err := json.Unmarshal(msg[0], &id); err != nil {
+			return fmt.Errorf("failed to decode id of message from govim: %v", err)
+		}
+		if id == 0 {
+			select {
+			case <-v.tomb.Dying():
+				return nil
+			case v.calls <- msg[1]:
+			}
+			continue
+		}
+		select {
+		case <-v.tomb.Dying():
+			return nil
+		case v.responses <- response{id: id, payload: msg[1]}:
+		}
+	}
+}
+
+// loop handles calls from govim and work from the methods of Vim, in the
+// same way as Vim's main loop handles channel messages and user input. It is
+// once the loop is idle that scheduled work is run.
+func (v *Vim) loop() error {
+	for {
+		select {
+		case <-v.tomb.Dying():
+			return nil
+		case msg := <-v.calls:
+			if err := v.handle(msg); err != nil {
+				return err
+			}
+		case r := <-v.responses:
+			return fmt.Errorf("received response %s to request %v that is not pending", r.payload, r.id)
+		case f := <-v.work:
+			f()
+		}
+		v.drainScheduleBacklog()
+		// Changes in the viewport are pushed once the loop is idle, which
+		// takes the place of the debounce in plugin/govim.vim
+		v.pushViewport()
+	}
+}
+
+func (v *Vim) drainScheduleBacklog() {
+	for len(v.scheduleBacklog) > 0 {
+		id := v.scheduleBacklog[0]
+		v.scheduleBacklog = v.scheduleBacklog[1:]
+		if _, err := v.request("schedule", id); err != nil {
+			v.say(err.Error(), true)
+		}
+	}
+}
+
+// send sends a message to govim
+func (v *Vim) send(id int, payload interface{}) error {
+	if err := v.enc.Encode([]interface{}{id, payload}); err != nil {
+		return ErrClosed
+	}
+	return nil
+}
+
+// request makes a request of govim, returning the value of its response.
+// Calls made by govim whilst handling the request are handled before
+// request returns.
+func (v *Vim) request(args ...interface{}) (interface{}, error) {
+	// As in plugin/govim.vim, govim is only ever called with the buffer
+	// changes flushed, other than by the handlers for those changes
+	if len(args) < 2 || args[0] != "function" || !v.listenerTargets[strings.TrimPrefix(toString(args[1]), "function:")] {
+		if err := v.flushListeners(); err != nil {
+			return nil, err
+		}
+	}
+	id := v.nextRequestID
+	v.nextRequestID++
+	if err := v.send(id, args); err != nil {
+		return nil, err
+	}
+	for {
+		select {
+		case <-v.tomb.Dying():
+			return nil, ErrClosed
+		case msg := <-v.calls:
+			if err := v.handle(msg); err != nil {
+				v.tomb.Kill(err)
+				return nil, err
+			}
+		case r := <-v.responses:
+			if r.id != id {
+				err := fmt.Errorf("received response to request %v whilst waiting for %v", r.id, id)
+				v.tomb.Kill(err)
+				return nil, err
+			}
+			var resp []json.RawMessage
+			if err := json.Unmarshal(r.payload, &resp); err != nil || len(resp) != 2 {
+				err := fmt.Errorf("invalid response %s to request %v", r.payload, id)
+				v.tomb.Kill(err)
+				return nil, err
+			}
+			var errString string
+			if err := json.Unmarshal(resp[0], &errString); err != nil {
+				return nil, fmt.Errorf("invalid error in response %s to request %v", r.payload, id)
+			}
+			if errString != "" {
+				return nil, errors.New(errString)
+			}
+			return fromJSON(resp[1])
+		}
+	}
+}
+
+// handle handles a call from govim, and sends the response
+func (v *Vim) handle(msg json.RawMessage) error {
+	val, err := fromJSON(msg)
+	if err != nil {
+		return err
+	}
+	args, ok := val.([]interface{})
+	if !ok || len(args) < 2 {
+		return fmt.Errorf("invalid call from govim: %s", msg)
+	}
+	id, typ, args := args[0], toString(args[1]), args[2:]
+	resp := []interface{}{""}
+	res, err := v.handleCall(typ, args)
+	if err != nil {
+		resp[0] = fmt.Sprintf("Caught %v in govimtest", vimString(err.Error()))
+	} else if typ == "expr" || typ == "call" {
+		resp = append(resp, res)
+	}
+	return v.send(0, []interface{}{"callback", id, resp})
+}
+
+func (v *Vim) handleCall(typ string, args []interface{}) (interface{}, error) {
+	arg := func(i int) interface{} {
+		if i < len(args) {
+			return args[i]
+		}
+		return nil
+	}
+	switch typ {
+	case "loaded":
+		v.status = "loaded"
+	case "initcomplete":
+		v.status = "initcomplete"
+		for _, e := range []string{"BufRead", "FileType"} {
+			if err := v.doautoall("govim", e); err != nil {
+				return nil, err
+			}
+		}
+	case "function", "rangefunction":
+		var params []string
+		ps, _ := arg(1).([]interface{})
+		for _, p := range ps {
+			params = append(params, toString(p))
+		}
+		name := toString(arg(0))
+		v.funcs[name] = &function{name: name, params: params, isRange: typ == "rangefunction"}
+	case "command":
+		attrs, _ := arg(1).(map[string]interface{})
+		v.commands[toString(arg(0))] = attrs
+	case "autocmd":
+		var exprs []string
+		es, _ := arg(2).([]interface{})
+		for _, e := range es {
+			exprs = append(exprs, toString(e))
+		}
+		return nil, v.defineAutocmd(toString(arg(0)), toString(arg(1)), exprs)
+	case "redraw":
+	case "ex":
+		return nil, v.ex(toString(arg(0)))
+	case "normal":
+		return nil, fmt.Errorf("govimtest: :normal is not supported")
+	case "expr":
+		return v.eval(toString(arg(0)))
+	case "call":
+		return v.call(toString(arg(0)), args[1:])
+	case "currentViewport":
+		return v.viewport(), nil
+	case "error":
+		return nil, errors.New(toString(arg(0)))
+	default:
+		return nil, fmt.Errorf("unknown callback function type %v", typ)
+	}
+	return nil, nil
+}
+
+// callFunction calls f, defined by govim, with args
+func (v *Vim) callFunction(f *function, args []interface{}) (interface{}, error) {
+	var named int
+	variadic := false
+	for _, p := range f.params {
+		if p == "..." {
+			variadic = true
+		} else {
+			named++
+		}
+	}
+	if len(args) < named {
+		return nil, fmt.Errorf("E119: Not enough arguments for function: %v", f.name)
+	}
+	if !variadic && len(args) > named {
+		return nil, fmt.Errorf("E118: Too many arguments for function: %v", f.name)
+	}
+	if args == nil {
+		args = []interface{}{}
+	}
+	if !f.isRange {
+		return v.request("function", "function:"+f.name, args)
+	}
+	line1, line2 := v.win.lnum, v.win.lnum
+	if v.callRange != nil {
+		line1, line2 = v.callRange[0], v.callRange[1]
+		v.callRange = nil
+	}
+	return v.request("function", "function:"+f.name, line1, line2, args)
+}
+
+// say outputs msg, adding it to the message history if history is set
+func (v *Vim) say(msg string, history bool) {
+	if v.capture != nil {
+		v.capture.WriteString("\n" + msg)
+	}
+	if history {
+		v.messages = append(v.messages, msg)
+	}
+}
+
+// fromJSON decodes the JSON value m as Vim would, with numbers decoded as
+// int or float64
+func fromJSON(m []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(m))
+	dec.UseNumber()
+	var i interface{}
+	if err := dec.Decode(&i); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON value %s: %v", m, err)
+	}
+	return fromJSONValue(i), nil
+}
+
+func fromJSONValue(i interface{}) interface{} {
+	switch i := i.(type) {
+	case json.Number:
+		if n, err := i.Int64(); err == nil {
+			return int(n)
+		}
+		f, _ := i.Float64()
+		return f
+	case []interface{}:
+		for j, v := range i {
+			i[j] = fromJSONValue(v)
+		}
+	case map[string]interface{}:
+		for k, v := range i {
+			i[k] = fromJSONValue(v)
+		}
+	}
+	return i
+}
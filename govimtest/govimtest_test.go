@@ -0,0 +1,280 @@
+package govimtest_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/govim/govim"
+	"github.com/govim/govim/govimtest"
+)
+
+// plugin is a plugin that defines a function, a range function, a command
+// and an autocommand, recording the calls made of each
+type plugin struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (p *plugin) record(format string, args ...interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, fmt.Sprintf(format, args...))
+}
+
+func (p *plugin) Calls() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string{}, p.calls...)
+}
+
+func (p *plugin) Init(g govim.Govim, errCh chan error) error {
+	if err := g.DefineFunction("Hello", []string{"name"}, func(g govim.Govim, args ...json.RawMessage) (interface{}, error) {
+		var name string
+		if err := json.Unmarshal(args[0], &name); err != nil {
+			return nil, err
+		}
+		return "Hello, " + name, nil
+	}); err != nil {
+		return err
+	}
+	if err := g.DefineRangeFunction("Lines", nil, func(g govim.Govim, line1, line2 int, args ...json.RawMessage) (interface{}, error) {
+		return g.ChannelCall("getline", line1, line2)
+	}); err != nil {
+		return err
+	}
+	if err := g.DefineCommand("Greet", func(g govim.Govim, flags govim.CommandFlags, args ...string) error {
+		p.record("Greet %v %v", *flags.Bang, args)
+		return g.ChannelEx("call setline(1, " + fmt.Sprintf("%q", strings.Join(args, " ")) + ")")
+	}, govim.NArgsOneOrMore, govim.AttrBang); err != nil {
+		return err
+	}
+	return g.DefineAutoCommand("", govim.Events{govim.EventBufRead}, govim.Patterns{"*.go"}, false, func(g govim.Govim, args ...json.RawMessage) error {
+		var bufnr int
+		if err := json.Unmarshal(args[0], &bufnr); err != nil {
+			return err
+		}
+		p.record("BufRead %v", bufnr)
+		return nil
+	}, "eval(expand('<abuf>'))")
+}
+
+func (p *plugin) Shutdown() error {
+	p.record("Shutdown")
+	return nil
+}
+
+func newVim(t *testing.T) (*govimtest.Vim, *plugin) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	p := new(plugin)
+	return govimtest.NewT(t, p, govimtest.Config{Dir: dir}), p
+}
+
+// check decodes the result res of a call of Vim into a value of the type of
+// want, and compares the two
+func check(t *testing.T, what string, res json.RawMessage, err error, want interface{}) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%v: %v", what, err)
+	}
+	got := reflect.New(reflect.TypeOf(want))
+	if err := json.Unmarshal(res, got.Interface()); err != nil {
+		t.Fatalf("%v: failed to decode %s: %v", what, res, err)
+	}
+	if !reflect.DeepEqual(got.Elem().Interface(), want) {
+		t.Errorf("%v: got %#v; want %#v", what, got.Elem().Interface(), want)
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	v, _ := newVim(t)
+	res, err := v.Call("Hello", "world")
+	check(t, "Hello", res, err, "Hello, world")
+
+	if _, err := v.Call("Hello"); err == nil || !strings.Contains(err.Error(), "E119") {
+		t.Errorf("got error %v calling Hello without arguments; want E119", err)
+	}
+	if err := v.Ex("edit main.go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Ex("1,2call Lines()"); err != nil {
+		t.Fatal(err)
+	}
+	res, err = v.Expr("Lines()")
+	check(t, "Lines()", res, err, []string{"package main"})
+}
+
+func TestCommandsAndAutocommands(t *testing.T) {
+	v, p := newVim(t)
+	if err := v.Ex("edit main.go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Ex("Greet! big world"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := v.Call("getline", 1, "$")
+	check(t, "getline", res, err, []string{"big world", "", "func main() {}"})
+
+	if err := v.Ex("Greet"); err == nil || !strings.Contains(err.Error(), "E471") {
+		t.Errorf("got error %v running Greet without arguments; want E471", err)
+	}
+	want := []string{"BufRead 2", "Greet true [big world]"}
+	if got := p.Calls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got calls %q; want %q", got, want)
+	}
+}
+
+func TestBuffers(t *testing.T) {
+	v, _ := newVim(t)
+	if err := v.Ex("edit main.go"); err != nil {
+		t.Fatal(err)
+	}
+	g := v.Govim()
+	if _, err := g.ChannelCall("appendbufline", "main.go", "$", []string{"", "func f() {}"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := g.ChannelExpr("[bufnr(), line('$'), &modified, &filetype, bufname()]")
+	check(t, "buffer", res, err, []interface{}{2.0, 5.0, 1.0, "go", "main.go"})
+
+	if err := v.Ex("edit"); err == nil || !strings.Contains(err.Error(), "E37") {
+		t.Errorf("got error %v reloading a modified buffer; want E37", err)
+	}
+}
+
+func TestSignsAndTextProperties(t *testing.T) {
+	v, _ := newVim(t)
+	if err := v.Ex("edit main.go"); err != nil {
+		t.Fatal(err)
+	}
+	g := v.Govim()
+	b, err := g.Batch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.AssertChannelCall(govim.AssertIsZero(), "sign_define", "error", map[string]interface{}{"text": ">>"})
+	b.ChannelCall("sign_place", 0, "g", "error", "%", map[string]interface{}{"lnum": 3})
+	b.AssertChannelCall(govim.AssertIsZero(), "prop_type_add", "err", map[string]interface{}{"highlight": "Error"})
+	b.AssertChannelCall(govim.AssertIsZero(), "prop_add", 3, 6, map[string]interface{}{"type": "err", "length": 4, "id": 7})
+	if _, err := b.End(); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Ex("1delete"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := v.Call("sign_getplaced", "%", map[string]interface{}{"group": "*"})
+	check(t, "sign_getplaced", res, err, []map[string]interface{}{{
+		"bufnr": 2.0,
+		"signs": []interface{}{map[string]interface{}{"id": 1.0, "group": "g", "name": "error", "lnum": 2.0, "priority": 10.0}},
+	}})
+	res, err = v.Call("prop_list", 2)
+	check(t, "prop_list", res, err, []map[string]interface{}{{
+		"col": 6.0, "length": 4.0, "id": 7.0, "type": "err", "type_bufnr": 0.0, "start": 1.0, "end": 1.0,
+	}})
+	if _, err := v.Call("prop_add", 1, 1, map[string]interface{}{"type": "warn"}); err == nil || !strings.Contains(err.Error(), "E971") {
+		t.Errorf("got error %v adding a property of an unknown type; want E971", err)
+	}
+}
+
+func TestQuickfixAndPopups(t *testing.T) {
+	v, _ := newVim(t)
+	g := v.Govim()
+	items := []map[string]interface{}{{"filename": "main.go", "lnum": 3, "col": 6, "text": "oops"}}
+	if _, err := g.ChannelCall("setqflist", items, "r"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := v.Expr("getqflist()[0].text")
+	check(t, "getqflist", res, err, "oops")
+	res, err = v.Call("getqflist", map[string]interface{}{"size": 1, "title": 1})
+	check(t, "getqflist", res, err, map[string]interface{}{"size": 1.0, "title": ":setqflist()"})
+
+	res, err = g.ChannelCall("popup_create", []string{"one", "three"}, map[string]interface{}{"line": 2, "col": 4})
+	check(t, "popup_create", res, err, 1001)
+	res, err = v.Call("popup_getpos", 1001)
+	check(t, "popup_getpos", res, err, map[string]interface{}{
+		"line": 2.0, "col": 4.0, "width": 5.0, "height": 2.0,
+		"core_line": 2.0, "core_col": 4.0, "core_width": 5.0, "core_height": 2.0,
+		"firstline": 1.0, "lastline": 2.0, "scrollbar": 0.0, "visible": 1.0,
+	})
+	if _, err := v.Call("popup_close", 1001); err != nil {
+		t.Fatal(err)
+	}
+	res, err = v.Call("popup_list")
+	check(t, "popup_list", res, err, []int{})
+}
+
+func TestSchedule(t *testing.T) {
+	v, _ := newVim(t)
+	done, err := v.Govim().Schedule(func(g govim.Govim) error {
+		return g.ChannelEx("let g:scheduled = 1")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	res, err := v.Expr("g:scheduled")
+	check(t, "g:scheduled", res, err, 1)
+}
+
+func TestClose(t *testing.T) {
+	dir := t.TempDir()
+	p := new(plugin)
+	v, err := govimtest.New(p, govimtest.Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := p.Calls(), []string{"Shutdown"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got calls %q; want %q", got, want)
+	}
+	if err := v.Ex("echo 1"); err != govimtest.ErrClosed {
+		t.Errorf("got error %v after Close; want %v", err, govimtest.ErrClosed)
+	}
+}
+
+func TestViewportChanges(t *testing.T) {
+	v, _ := newVim(t)
+	changes := make(chan govim.Viewport, 10)
+	cancel, err := v.Govim().OnViewportChange(func(g govim.Govim, vp govim.Viewport) error {
+		changes <- vp
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Ex("edit main.go"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Call("cursor", 3, 6); err != nil {
+		t.Fatal(err)
+	}
+	want := govim.Cursor{BufNr: 2, Line: 3, Col: 6}
+	for {
+		vp := <-changes
+		if vp.Cursor != want {
+			continue
+		}
+		if vp.Current.BufNr != 2 || len(vp.Windows) != 1 || vp.Windows[0].WinID != vp.Current.WinID {
+			t.Errorf("got viewport %+v; want the one window, showing buffer 2", vp)
+		}
+		// Whilst subscribed, Viewport returns the viewport last pushed
+		if got, err := v.Govim().Viewport(); err != nil || !reflect.DeepEqual(got, vp) {
+			t.Errorf("got viewport %+v, %v whilst subscribed; want %+v", got, err, vp)
+		}
+		break
+	}
+	cancel()
+	if got, err := v.Govim().Viewport(); err != nil || got.Cursor != want {
+		t.Errorf("got viewport %+v, %v once unsubscribed; want cursor %+v", got, err, want)
+	}
+}
@@ -0,0 +1,839 @@
+package govimtest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// window height and width are those of a window in the default 80x24
+// terminal, less the status and command lines
+const (
+	winHeight = 22
+	winWidth  = 80
+)
+
+type buffer struct {
+	nr   int
+	name string // an absolute path, or "" for an unnamed buffer
+
+	lines          []string
+	loaded, listed bool
+
+	vars        map[string]interface{}
+	options     map[string]interface{}
+	changedtick int
+
+	// lnum and col are the position of the cursor when the buffer was last
+	// current
+	lnum, col int
+
+	props []*textProp
+	signs []*sign
+
+	// changes are the changes that have yet to be flushed to listeners
+	changes []interface{}
+}
+
+type window struct {
+	id        int
+	buf       *buffer
+	lnum, col int
+	loclist   *qfList
+}
+
+type textProp struct {
+	typ  string
+	id   int
+	lnum int
+	col  int
+
+	// endLnum and endCol are the position just after the end of the
+	// property
+	endLnum int
+	endCol  int
+}
+
+type sign struct {
+	id       int
+	group    string
+	name     string
+	lnum     int
+	priority int
+}
+
+type qfList struct {
+	title       string
+	items       []interface{}
+	idx         int
+	context     interface{}
+	changedtick int
+}
+
+type popup struct {
+	id      int
+	buf     *buffer
+	options map[string]interface{}
+	hidden  bool
+
+	// ownBuf is set if buf was created for the popup, and is wiped when the
+	// popup is closed
+	ownBuf bool
+
+	// keyFilter is the function to which s:popupCreate() passes the keys
+	// typed in the popup, if any; see PopupKey
+	keyFilter string
+}
+
+type listener struct {
+	id       int
+	buf      *buffer
+	callback string
+
+	// args are bound to callback, as per function() with {arglist}
+	args []interface{}
+}
+
+// bufferOptions are the buffer-local options and their default values.
+// Other options are global, including those that are local to a window in
+// Vim, given there is only a single window.
+var bufferOptions = map[string]interface{}{
+	"bufhidden":    "",
+	"buflisted":    1,
+	"buftype":      "",
+	"expandtab":    0,
+	"fileencoding": "",
+	"fileformat":   "unix",
+	"filetype":     "",
+	"formatexpr":   "",
+	"modifiable":   1,
+	"modified":     0,
+	"omnifunc":     "",
+	"readonly":     0,
+	"shiftwidth":   8,
+	"softtabstop":  0,
+	"swapfile":     1,
+	"syntax":       "",
+	"tabstop":      8,
+	"textwidth":    0,
+}
+
+func defaultOptions() map[string]interface{} {
+	return map[string]interface{}{
+		"autoread":        0,
+		"ballooneval":     0,
+		"balloonevalterm": 0,
+		"cmdheight":       1,
+		"columns":         winWidth,
+		"completeopt":     "menu,preview",
+		"encoding":        "utf-8",
+		"hidden":          0,
+		"ignorecase":      0,
+		"lines":           winHeight + 2,
+		"mouse":           "",
+		"number":          0,
+		"relativenumber":  0,
+		"signcolumn":      "auto",
+		"smartcase":       0,
+		"statusline":      "",
+		"ttymouse":        "",
+		"updatetime":      4000,
+		"wrapscan":        1,
+	}
+}
+
+// optionAliases maps the short names of options to their full names
+var optionAliases = map[string]string{
+	"bevalterm": "balloonevalterm",
+	"beval":     "ballooneval",
+	"bh":        "bufhidden",
+	"bl":        "buflisted",
+	"bt":        "buftype",
+	"ch":        "cmdheight",
+	"co":        "columns",
+	"cot":       "completeopt",
+	"enc":       "encoding",
+	"et":        "expandtab",
+	"fenc":      "fileencoding",
+	"fex":       "formatexpr",
+	"ff":        "fileformat",
+	"ft":        "filetype",
+	"hid":       "hidden",
+	"ic":        "ignorecase",
+	"ma":        "modifiable",
+	"mod":       "modified",
+	"nu":        "number",
+	"ofu":       "omnifunc",
+	"rnu":       "relativenumber",
+	"ro":        "readonly",
+	"scl":       "signcolumn",
+	"scs":       "smartcase",
+	"sts":       "softtabstop",
+	"stl":       "statusline",
+	"sw":        "shiftwidth",
+	"swf":       "swapfile",
+	"syn":       "syntax",
+	"ts":        "tabstop",
+	"tw":        "textwidth",
+	"ut":        "updatetime",
+	"ws":        "wrapscan",
+}
+
+func optionName(name string) string {
+	if full, ok := optionAliases[name]; ok {
+		return full
+	}
+	return name
+}
+
+// option returns the value of the option name for the current buffer
+func (v *Vim) option(name string) (interface{}, error) {
+	return v.bufOption(v.win.buf, name)
+}
+
+func (v *Vim) bufOption(b *buffer, name string) (interface{}, error) {
+	name = optionName(name)
+	if _, ok := bufferOptions[name]; ok {
+		return b.options[name], nil
+	}
+	if val, ok := v.options[name]; ok {
+		return val, nil
+	}
+	return nil, fmt.Errorf("E113: Unknown option: %v", name)
+}
+
+// setBufOption sets the option name for b, firing the FileType event when
+// the filetype is set
+func (v *Vim) setBufOption(b *buffer, name string, val interface{}) error {
+	name = optionName(name)
+	cur, err := v.bufOption(b, name)
+	if err != nil {
+		return err
+	}
+	if _, ok := cur.(int); ok {
+		val = toNumber(val)
+	} else {
+		val = toString(val)
+	}
+	if _, ok := bufferOptions[name]; !ok {
+		v.options[name] = val
+		return nil
+	}
+	b.options[name] = val
+	if name == "filetype" && val != "" {
+		return v.fire("FileType", "", b, val.(string))
+	}
+	return nil
+}
+
+func (v *Vim) newBuffer(name string) *buffer {
+	b := &buffer{
+		nr:      v.nextBufNr,
+		name:    name,
+		lines:   []string{""},
+		listed:  true,
+		vars:    make(map[string]interface{}),
+		options: make(map[string]interface{}),
+		lnum:    1,
+		col:     1,
+	}
+	for k, val := range bufferOptions {
+		b.options[k] = val
+	}
+	v.nextBufNr++
+	v.bufs = append(v.bufs, b)
+	return b
+}
+
+func (v *Vim) newWinID() int {
+	id := v.nextWinID
+	v.nextWinID++
+	return id
+}
+
+// abs returns the absolute path of the file name
+func (v *Vim) abs(name string) string {
+	if name == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(v.dir, name)
+}
+
+// relName returns the name of b as Vim displays it, i.e. relative to the
+// current directory where possible
+func (v *Vim) relName(b *buffer) string {
+	if rel, err := filepath.Rel(v.dir, b.name); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return b.name
+}
+
+// buffer returns the buffer identified by val, as per the {buf} argument of
+// Vim's builtins: a number is a buffer number, "" or "%" is the current
+// buffer, "$" the last buffer, and any other string a buffer name. It
+// returns nil if there is no such buffer.
+func (v *Vim) buffer(val interface{}) *buffer {
+	if s, ok := val.(string); ok {
+		switch s {
+		case "", "%":
+			return v.win.buf
+		case "$":
+			return v.bufs[len(v.bufs)-1]
+		}
+		if b := v.bufferByName(s); b != nil {
+			return b
+		}
+		if n := toNumber(s); n == 0 || toString(n) != s {
+			return nil
+		}
+	}
+	n := toNumber(val)
+	for _, b := range v.bufs {
+		if b.nr == n {
+			return b
+		}
+	}
+	return nil
+}
+
+func (v *Vim) bufferByName(name string) *buffer {
+	if name == "" {
+		return nil
+	}
+	path := v.abs(name)
+	for _, b := range v.bufs {
+		if b.name == path {
+			return b
+		}
+	}
+	return nil
+}
+
+// mustBuffer is as buffer, but returns an error if there is no such buffer
+func (v *Vim) mustBuffer(val interface{}) (*buffer, error) {
+	b := v.buffer(val)
+	if b == nil {
+		return nil, fmt.Errorf("E158: Invalid buffer name: %v", toString(val))
+	}
+	return b, nil
+}
+
+// lnum returns the line number of b given by val, as per the {lnum}
+// argument of Vim's builtins
+func (v *Vim) lnum(b *buffer, val interface{}) int {
+	switch val {
+	case ".":
+		if b == v.win.buf {
+			return v.win.lnum
+		}
+		return b.lnum
+	case "$":
+		return len(b.lines)
+	}
+	return toNumber(val)
+}
+
+// setLines sets the lines of b starting at lnum, appending those lines that
+// are beyond the last line
+func (v *Vim) setLines(b *buffer, lnum int, lines []string) error {
+	if lnum < 1 || lnum > len(b.lines)+1 {
+		return fmt.Errorf("E966: Invalid line number: %v", lnum)
+	}
+	if err := checkModifiable(b); err != nil {
+		return err
+	}
+	n := 0
+	for ; n < len(lines) && lnum+n <= len(b.lines); n++ {
+		b.lines[lnum+n-1] = lines[n]
+	}
+	if n > 0 {
+		v.changed(b, lnum, lnum+n, 0)
+	}
+	if n < len(lines) {
+		return v.appendLines(b, len(b.lines), lines[n:])
+	}
+	return nil
+}
+
+// appendLines appends lines to b after line after
+func (v *Vim) appendLines(b *buffer, after int, lines []string) error {
+	if after < 0 || after > len(b.lines) {
+		return fmt.Errorf("E966: Invalid line number: %v", after)
+	}
+	if err := checkModifiable(b); err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	n := len(lines)
+	b.lines = append(b.lines[:after], append(append([]string{}, lines...), b.lines[after:]...)...)
+	for _, p := range b.props {
+		if p.lnum > after {
+			p.lnum += n
+		}
+		if p.endLnum > after {
+			p.endLnum += n
+		}
+	}
+	for _, s := range b.signs {
+		if s.lnum > after {
+			s.lnum += n
+		}
+	}
+	if b == v.win.buf && v.win.lnum > after {
+		v.win.lnum += n
+	}
+	v.changed(b, after+1, after+1, n)
+	return nil
+}
+
+// deleteLines deletes the lines first to last of b
+func (v *Vim) deleteLines(b *buffer, first, last int) error {
+	if first < 1 || last < first || last > len(b.lines) {
+		return fmt.Errorf("E966: Invalid line number: %v", first)
+	}
+	if err := checkModifiable(b); err != nil {
+		return err
+	}
+	n := last - first + 1
+	b.lines = append(b.lines[:first-1], b.lines[last:]...)
+	if len(b.lines) == 0 {
+		b.lines = []string{""}
+	}
+	var props []*textProp
+	for _, p := range b.props {
+		switch {
+		case p.lnum >= first && p.endLnum <= last:
+			continue
+		case p.lnum > last:
+			p.lnum -= n
+		case p.lnum >= first:
+			p.lnum, p.col = first, 1
+		}
+		switch {
+		case p.endLnum > last:
+			p.endLnum -= n
+		case p.endLnum >= first:
+			p.endLnum, p.endCol = first-1, len(b.lines[first-2])+1
+		}
+		props = append(props, p)
+	}
+	b.props = props
+	for _, s := range b.signs {
+		switch {
+		case s.lnum > last:
+			s.lnum -= n
+		case s.lnum >= first:
+			s.lnum = first
+		}
+		if s.lnum > len(b.lines) {
+			s.lnum = len(b.lines)
+		}
+	}
+	if b == v.win.buf {
+		switch {
+		case v.win.lnum > last:
+			v.win.lnum -= n
+		case v.win.lnum >= first:
+			v.win.lnum = first
+		}
+		if v.win.lnum > len(b.lines) {
+			v.win.lnum = len(b.lines)
+		}
+		v.clampCursor()
+	}
+	v.changed(b, first, last+1, -n)
+	return nil
+}
+
+func checkModifiable(b *buffer) error {
+	if b.options["modifiable"] == 0 {
+		return fmt.Errorf("E21: Cannot make changes, 'modifiable' is off")
+	}
+	return nil
+}
+
+// changed records a change to the lines lnum up to end of b, which added
+// lines (or deleted them if added is negative)
+func (v *Vim) changed(b *buffer, lnum, end, added int) {
+	b.changedtick++
+	b.options["modified"] = 1
+	for _, l := range v.listeners {
+		if l.buf == b {
+			b.changes = append(b.changes, map[string]interface{}{
+				"lnum":  lnum,
+				"end":   end,
+				"added": added,
+				"col":   1,
+			})
+			break
+		}
+	}
+}
+
+// addListener adds a listener for changes in b that calls callback, with
+// args before the arguments of a listener, returning the id of the listener
+func (v *Vim) addListener(b *buffer, callback string, args ...interface{}) int {
+	l := &listener{id: v.nextListenerID, buf: b, callback: callback, args: args}
+	v.nextListenerID++
+	v.listeners = append(v.listeners, l)
+	return l.id
+}
+
+// flushListeners calls the listeners of the buffers that have changes
+// pending
+func (v *Vim) flushListeners() error {
+	for _, b := range v.bufs {
+		if err := v.flushBufListeners(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Vim) flushBufListeners(b *buffer) error {
+	if v.flushing || len(b.changes) == 0 {
+		return nil
+	}
+	changes := b.changes
+	b.changes = nil
+	start, end, added := len(b.lines)+1, 0, 0
+	for _, c := range changes {
+		c := c.(map[string]interface{})
+		if l := c["lnum"].(int); l < start {
+			start = l
+		}
+		if e := c["end"].(int); e > end {
+			end = e
+		}
+		added += c["added"].(int)
+	}
+	v.flushing = true
+	defer func() {
+		v.flushing = false
+	}()
+	for _, l := range append([]*listener{}, v.listeners...) {
+		if l.buf != b {
+			continue
+		}
+		args := append(append([]interface{}{}, l.args...), b.nr, start, end, added, changes)
+		if _, err := v.call(l.callback, args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Vim) clampCursor() {
+	b := v.win.buf
+	if v.win.lnum < 1 {
+		v.win.lnum = 1
+	}
+	if v.win.lnum > len(b.lines) {
+		v.win.lnum = len(b.lines)
+	}
+	if l := len(b.lines[v.win.lnum-1]); v.win.col > l {
+		v.win.col = l
+	}
+	if v.win.col < 1 {
+		v.win.col = 1
+	}
+}
+
+// enter makes b the buffer of the window
+func (v *Vim) enter(b *buffer) error {
+	old := v.win.buf
+	if old == b {
+		return nil
+	}
+	var errs errList
+	errs.add(v.fire("BufLeave", "", old, ""))
+	errs.add(v.fire("BufWinLeave", "", old, ""))
+	old.lnum, old.col = v.win.lnum, v.win.col
+	v.win.buf = b
+	v.win.lnum, v.win.col = b.lnum, b.col
+	v.clampCursor()
+	return errs.err()
+}
+
+// edit edits the file name in the window, as per :edit. An empty name is
+// the file of the current buffer, which is reloaded; force discards any
+// changes to it. A buffer that is abandoned is hidden, as if 'hidden' were
+// set.
+func (v *Vim) edit(name string, force bool) error {
+	var b *buffer
+	if name == "" {
+		b = v.win.buf
+		if b.name == "" {
+			return fmt.Errorf("E32: No file name")
+		}
+	} else {
+		b = v.bufferByName(name)
+	}
+	var errs errList
+	if b == nil {
+		b = v.newBuffer(v.abs(name))
+		errs.add(v.fire("BufNew", "", b, ""))
+		errs.add(v.fire("BufAdd", "", b, ""))
+	}
+	reload := !b.loaded
+	if b == v.win.buf && b.loaded {
+		if b.options["modified"] == 1 && !force {
+			return fmt.Errorf("E37: No write since last change (add ! to override)")
+		}
+		reload = true
+	}
+	errs.add(v.enter(b))
+	if reload {
+		errs.add(v.load(b))
+	}
+	errs.add(v.fire("BufEnter", "", b, ""))
+	errs.add(v.fire("BufWinEnter", "", b, ""))
+	return errs.err()
+}
+
+// load loads the file of b
+func (v *Vim) load(b *buffer) error {
+	var errs errList
+	byts, err := os.ReadFile(b.name)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("E484: Can't open file %v", b.name)
+	}
+	lines := []string{""}
+	if err == nil {
+		errs.add(v.fire("BufReadPre", "", b, ""))
+		if s := strings.TrimSuffix(string(byts), "\n"); s != "" {
+			lines = strings.Split(s, "\n")
+		}
+	}
+	// As in Vim, listeners are not told of the lines being replaced when a
+	// loaded buffer is read again, but b:changedtick is incremented
+	b.lines = lines
+	b.changedtick++
+	b.loaded = true
+	b.options["modified"] = 0
+	v.clampCursor()
+	if err == nil {
+		errs.add(v.fire("BufReadPost", "", b, ""))
+	} else {
+		errs.add(v.fire("BufNewFile", "", b, ""))
+	}
+	errs.add(v.setBufOption(b, "filetype", detectFiletype(b.name)))
+	return errs.err()
+}
+
+// detectFiletype returns the filetype of the file name, as per Vim's
+// filetype detection for the files of interest to govim plugins
+func detectFiletype(name string) string {
+	switch filepath.Base(name) {
+	case "go.mod":
+		return "gomod"
+	case "go.sum":
+		return "gosum"
+	case "go.work":
+		return "gowork"
+	}
+	switch filepath.Ext(name) {
+	case ".go":
+		return "go"
+	case ".vim":
+		return "vim"
+	case ".md":
+		return "markdown"
+	case ".txt":
+		return "text"
+	case ".json":
+		return "json"
+	case ".sh":
+		return "sh"
+	}
+	return ""
+}
+
+// write writes b to its file
+func (v *Vim) write(b *buffer) error {
+	if b.name == "" {
+		return fmt.Errorf("E32: No file name")
+	}
+	var errs errList
+	errs.add(v.fire("BufWritePre", "", b, ""))
+	if err := os.WriteFile(b.name, []byte(strings.Join(b.lines, "\n")+"\n"), 0666); err != nil {
+		return fmt.Errorf("E212: Can't open file for writing: %v", err)
+	}
+	b.options["modified"] = 0
+	errs.add(v.fire("BufWritePost", "", b, ""))
+	return errs.err()
+}
+
+// wipe wipes out b, making another buffer current if b is current. If
+// unlist is set b is instead unloaded and unlisted, as per :bdelete.
+func (v *Vim) wipe(b *buffer, unlist bool) error {
+	var errs errList
+	if b == v.win.buf {
+		var next *buffer
+		for _, o := range v.bufs {
+			if o != b && o.listed {
+				next = o
+				break
+			}
+		}
+		if next == nil {
+			next = v.newBuffer("")
+			next.loaded = true
+		}
+		errs.add(v.visit(next))
+	}
+	errs.add(v.fire("BufUnload", "", b, ""))
+	errs.add(v.fire("BufDelete", "", b, ""))
+	var ls []*listener
+	for _, l := range v.listeners {
+		if l.buf != b {
+			ls = append(ls, l)
+		}
+	}
+	v.listeners = ls
+	b.changes = nil
+	if unlist {
+		b.loaded, b.listed = false, false
+		b.lines = []string{""}
+		b.props, b.signs = nil, nil
+		return errs.err()
+	}
+	errs.add(v.fire("BufWipeout", "", b, ""))
+	for i, o := range v.bufs {
+		if o == b {
+			v.bufs = append(v.bufs[:i], v.bufs[i+1:]...)
+			break
+		}
+	}
+	return errs.err()
+}
+
+// winInfo returns the information about the window as per getwininfo()
+func (v *Vim) winInfo() map[string]interface{} {
+	botline := winHeight
+	if l := len(v.win.buf.lines); l < botline {
+		botline = l
+	}
+	return map[string]interface{}{
+		"winnr":     1,
+		"botline":   botline,
+		"height":    winHeight,
+		"bufnr":     v.win.buf.nr,
+		"winbar":    0,
+		"width":     winWidth,
+		"tabnr":     1,
+		"quickfix":  0,
+		"topline":   1,
+		"loclist":   0,
+		"wincol":    1,
+		"winrow":    1,
+		"winid":     v.win.id,
+		"terminal":  0,
+		"textoff":   0,
+		"variables": map[string]interface{}{},
+	}
+}
+
+// viewport returns the current viewport as per s:buildCurrentViewport()
+func (v *Vim) viewport() map[string]interface{} {
+	w := v.winInfo()
+	delete(w, "variables")
+	return map[string]interface{}{
+		"Current": w,
+		"Windows": []interface{}{w},
+		"Cursor": map[string]interface{}{
+			"bufnr": v.win.buf.nr,
+			"line":  v.win.lnum,
+			"col":   v.win.col,
+		},
+	}
+}
+
+// pushViewport pushes to govim what has changed in the viewport since it was
+// last pushed, as per s:pushViewport(), once govim has subscribed to changes.
+// As with ch_sendexpr(), a failure to send is not reported.
+func (v *Vim) pushViewport() {
+	if v.pushedViewport == nil {
+		return
+	}
+	prev, cur := v.pushedViewport, v.viewport()
+	v.pushedViewport = cur
+	delta := make(map[string]interface{})
+	if vimString(cur["Current"]) != vimString(prev["Current"]) {
+		delta["Windows"] = cur["Windows"]
+	}
+	if vimString(cur["Cursor"]) != vimString(prev["Cursor"]) {
+		delta["Cursor"] = cur["Cursor"]
+	}
+	if len(delta) == 0 {
+		return
+	}
+	// The push is a notification, to which govim does not respond
+	id := v.nextRequestID
+	v.nextRequestID++
+	v.send(id, []interface{}{"viewport", delta})
+}
+
+// propsOnLine returns the text properties of b on line lnum, as per
+// prop_list()
+func propsOnLine(b *buffer, lnum int, withLnum bool) []interface{} {
+	var ps []*textProp
+	for _, p := range b.props {
+		if p.lnum <= lnum && p.endLnum >= lnum {
+			ps = append(ps, p)
+		}
+	}
+	sort.SliceStable(ps, func(i, j int) bool {
+		return startCol(ps[i], lnum) < startCol(ps[j], lnum)
+	})
+	res := []interface{}{}
+	for _, p := range ps {
+		col := startCol(p, lnum)
+		end := len(b.lines[lnum-1]) + 2
+		if p.endLnum == lnum {
+			end = p.endCol
+		}
+		d := map[string]interface{}{
+			"col":        col,
+			"length":     end - col,
+			"id":         p.id,
+			"type":       p.typ,
+			"type_bufnr": 0,
+			"start":      boolNum(p.lnum == lnum),
+			"end":        boolNum(p.endLnum == lnum),
+		}
+		if withLnum {
+			d["lnum"] = lnum
+		}
+		res = append(res, d)
+	}
+	return res
+}
+
+func startCol(p *textProp, lnum int) int {
+	if p.lnum == lnum {
+		return p.col
+	}
+	return 1
+}
+
+// errList collects the errors of a sequence of steps that all run, e.g. the
+// autocommands fired by :edit, and reports the first
+type errList []error
+
+func (e *errList) add(err error) {
+	if err != nil {
+		*e = append(*e, err)
+	}
+}
+
+func (e errList) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e[0]
+}
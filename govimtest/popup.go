@@ -0,0 +1,206 @@
+package govimtest
+
+import (
+	"fmt"
+)
+
+// popupCreator returns the builtin that creates a popup with the options
+// defaults, as per popup_create() and its variants
+func popupCreator(defaults map[string]interface{}) func(v *Vim, args []interface{}) (interface{}, error) {
+	return func(v *Vim, args []interface{}) (interface{}, error) {
+		opts, err := dictArg(args[1])
+		if err != nil {
+			return nil, err
+		}
+		p := &popup{
+			id:      v.newWinID(),
+			options: make(map[string]interface{}),
+		}
+		for k, val := range defaults {
+			p.options[k] = deepCopy(val)
+		}
+		for k, val := range opts {
+			p.options[k] = deepCopy(val)
+		}
+		if _, ok := args[0].(int); ok {
+			b, err := v.mustBuffer(args[0])
+			if err != nil {
+				return nil, err
+			}
+			p.buf = b
+		} else {
+			b := v.newBuffer("")
+			b.listed, b.loaded = false, true
+			b.options["buftype"] = "popup"
+			b.options["swapfile"] = 0
+			b.lines = popupLines(args[0])
+			p.buf, p.ownBuf = b, true
+		}
+		v.popups = append(v.popups, p)
+		return p.id, nil
+	}
+}
+
+// popupLines returns the lines given by the {what} argument of
+// popup_create()
+func popupLines(what interface{}) []string {
+	l, ok := what.([]interface{})
+	if !ok {
+		return []string{toString(what)}
+	}
+	var lines []string
+	for _, e := range l {
+		// A line may be a dictionary with text and properties
+		if d, ok := e.(map[string]interface{}); ok {
+			e = d["text"]
+		}
+		lines = append(lines, toString(e))
+	}
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+	return lines
+}
+
+// popup returns the popup with id, or nil if there is none
+func (v *Vim) popup(id int) *popup {
+	for _, p := range v.popups {
+		if p.id == id {
+			return p
+		}
+	}
+	return nil
+}
+
+func (v *Vim) popupClose(args []interface{}) (interface{}, error) {
+	result := args[1]
+	if result == nil {
+		result = 0
+	}
+	return 0, v.closePopup(toNumber(args[0]), result)
+}
+
+// closePopup closes the popup with id, calling its callback with result
+func (v *Vim) closePopup(id int, result interface{}) error {
+	p := v.popup(id)
+	if p == nil {
+		return nil
+	}
+	for i, o := range v.popups {
+		if o == p {
+			v.popups = append(v.popups[:i], v.popups[i+1:]...)
+			break
+		}
+	}
+	if p.ownBuf {
+		for i, b := range v.bufs {
+			if b == p.buf {
+				v.bufs = append(v.bufs[:i], v.bufs[i+1:]...)
+				break
+			}
+		}
+	}
+	if cb, ok := p.options["callback"].(string); ok && cb != "" {
+		if _, err := v.call(cb, []interface{}{id, result}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *Vim) popupClear(args []interface{}) (interface{}, error) {
+	var errs errList
+	for _, p := range append([]*popup{}, v.popups...) {
+		errs.add(v.closePopup(p.id, -1))
+	}
+	return 0, errs.err()
+}
+
+func (v *Vim) popupSetText(args []interface{}) (interface{}, error) {
+	if p := v.popup(toNumber(args[0])); p != nil {
+		p.buf.lines = popupLines(args[1])
+	}
+	return 0, nil
+}
+
+func (v *Vim) popupSetOptions(args []interface{}) (interface{}, error) {
+	opts, err := dictArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	p := v.popup(toNumber(args[0]))
+	if p == nil {
+		return nil, fmt.Errorf("E993: Window %v is not a popup window", toNumber(args[0]))
+	}
+	for k, val := range opts {
+		p.options[k] = deepCopy(val)
+	}
+	return 0, nil
+}
+
+func (v *Vim) popupGetOptions(args []interface{}) (interface{}, error) {
+	p := v.popup(toNumber(args[0]))
+	if p == nil {
+		return map[string]interface{}{}, nil
+	}
+	return deepCopy(p.options), nil
+}
+
+func (v *Vim) popupGetPos(args []interface{}) (interface{}, error) {
+	p := v.popup(toNumber(args[0]))
+	if p == nil {
+		return map[string]interface{}{}, nil
+	}
+	line, col := 1, 1
+	if l, ok := p.options["line"].(int); ok {
+		line = l
+	}
+	if c, ok := p.options["col"].(int); ok {
+		col = c
+	}
+	width := toNumber(p.options["minwidth"])
+	for _, l := range p.buf.lines {
+		if len(l) > width {
+			width = len(l)
+		}
+	}
+	if m, ok := p.options["maxwidth"].(int); ok && width > m {
+		width = m
+	}
+	height := len(p.buf.lines)
+	return map[string]interface{}{
+		"line":        line,
+		"col":         col,
+		"width":       width,
+		"height":      height,
+		"core_line":   line,
+		"core_col":    col,
+		"core_width":  width,
+		"core_height": height,
+		"firstline":   1,
+		"lastline":    height,
+		"scrollbar":   0,
+		"visible":     boolNum(!p.hidden),
+	}, nil
+}
+
+// popupVisibility returns the builtin that shows or hides a popup, as per
+// popup_show() and popup_hide()
+func popupVisibility(visible bool) func(v *Vim, args []interface{}) (interface{}, error) {
+	return func(v *Vim, args []interface{}) (interface{}, error) {
+		p := v.popup(toNumber(args[0]))
+		if p == nil {
+			return nil, fmt.Errorf("E993: Window %v is not a popup window", toNumber(args[0]))
+		}
+		p.hidden = !visible
+		return 0, nil
+	}
+}
+
+func (v *Vim) popupList(args []interface{}) (interface{}, error) {
+	res := []interface{}{}
+	for _, p := range v.popups {
+		res = append(res, p.id)
+	}
+	return res, nil
+}
@@ -0,0 +1,174 @@
+package govimtest
+
+import (
+	"fmt"
+	"sort"
+)
+
+// qfWhat are the properties of a quickfix list returned by getqflist() for
+// {"all": 1}
+var qfWhat = []string{"changedtick", "context", "id", "idx", "items", "nr", "size", "title", "winid"}
+
+// setqflist implements setqflist() and setloclist() for the list q, given
+// the arguments {list} [, {action} [, {what}]]
+func (v *Vim) setqflist(q *qfList, args []interface{}) (interface{}, error) {
+	list, err := listArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	action := " "
+	if len(args) > 1 && args[1] != nil {
+		action = toString(args[1])
+	}
+	var what map[string]interface{}
+	if len(args) > 2 && args[2] != nil {
+		if what, err = dictArg(args[2]); err != nil {
+			return nil, err
+		}
+		if items, ok := what["items"]; ok {
+			if list, err = listArg(items); err != nil {
+				return nil, err
+			}
+		} else {
+			list = nil
+		}
+	}
+	var items []interface{}
+	for _, i := range list {
+		item, err := dictArg(i)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v.qfItem(item))
+	}
+	switch action {
+	case "a":
+		q.items = append(q.items, items...)
+	case "r", " ", "":
+		if what == nil || list != nil {
+			q.items, q.idx = items, 0
+		}
+	case "f":
+		q.items, q.idx = nil, 0
+	default:
+		return nil, fmt.Errorf("E927: Invalid action: '%v'", action)
+	}
+	if what == nil {
+		q.title = ":setqflist()"
+	}
+	if t, ok := what["title"]; ok {
+		q.title = toString(t)
+	}
+	if c, ok := what["context"]; ok {
+		q.context = c
+	}
+	if i, ok := what["idx"]; ok {
+		q.idx = toNumber(i) - 1
+	}
+	if q.idx >= len(q.items) {
+		q.idx = len(q.items) - 1
+	}
+	if q.idx < 0 {
+		q.idx = 0
+	}
+	q.changedtick++
+	return 0, nil
+}
+
+// qfItem returns the quickfix entry for item as per getqflist(). An entry
+// for a file name refers to the buffer for that file, which is added if
+// need be.
+func (v *Vim) qfItem(item map[string]interface{}) map[string]interface{} {
+	res := map[string]interface{}{
+		"bufnr":    0,
+		"module":   "",
+		"lnum":     0,
+		"end_lnum": 0,
+		"col":      0,
+		"end_col":  0,
+		"vcol":     0,
+		"nr":       0,
+		"pattern":  "",
+		"text":     "",
+		"type":     "",
+		"valid":    0,
+	}
+	for _, k := range []string{"bufnr", "lnum", "end_lnum", "col", "end_col", "vcol", "nr"} {
+		if val, ok := item[k]; ok {
+			res[k] = toNumber(val)
+		}
+	}
+	for _, k := range []string{"module", "pattern", "text", "type"} {
+		if val, ok := item[k]; ok {
+			res[k] = toString(val)
+		}
+	}
+	if v.buffer(res["bufnr"]) == nil || res["bufnr"] == 0 {
+		res["bufnr"] = 0
+		if name, ok := item["filename"]; ok && toString(name) != "" {
+			b := v.bufferByName(toString(name))
+			if b == nil {
+				b = v.newBuffer(v.abs(toString(name)))
+				b.listed = false
+			}
+			res["bufnr"] = b.nr
+		}
+	}
+	valid := res["bufnr"] != 0 || res["lnum"] != 0
+	if val, ok := item["valid"]; ok {
+		valid = truthy(val)
+	}
+	res["valid"] = boolNum(valid)
+	return res
+}
+
+// get implements getqflist() and getloclist() for q. If what is nil the
+// entries of q are returned, otherwise a dictionary of the properties
+// requested by what.
+func (q *qfList) get(what interface{}) interface{} {
+	items := []interface{}{}
+	for _, i := range q.items {
+		items = append(items, deepCopy(i))
+	}
+	w, ok := what.(map[string]interface{})
+	if !ok {
+		return items
+	}
+	var keys []string
+	if truthy(w["all"]) {
+		keys = qfWhat
+	} else {
+		for k := range w {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	}
+	res := make(map[string]interface{})
+	for _, k := range keys {
+		switch k {
+		case "changedtick":
+			res[k] = q.changedtick
+		case "context":
+			res[k] = q.context
+			if q.context == nil {
+				res[k] = ""
+			}
+		case "id", "nr":
+			res[k] = 1
+		case "idx":
+			res[k] = q.idx + 1
+			if len(q.items) == 0 {
+				res[k] = 0
+			}
+		case "items":
+			res[k] = items
+		case "size":
+			res[k] = len(q.items)
+		case "title":
+			res[k] = q.title
+		case "winid":
+			res[k] = 0
+		}
+	}
+	return res
+}
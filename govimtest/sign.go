@@ -0,0 +1,282 @@
+package govimtest
+
+import (
+	"fmt"
+	"sort"
+)
+
+// defaultSignPriority is the priority of a sign placed without one
+const defaultSignPriority = 10
+
+func (v *Vim) signDefine(args []interface{}) (interface{}, error) {
+	if l, ok := args[0].([]interface{}); ok {
+		res := []interface{}{}
+		for _, d := range l {
+			d, err := dictArg(d)
+			if err != nil {
+				return nil, err
+			}
+			v.defineSign(toString(d["name"]), d)
+			res = append(res, 0)
+		}
+		return res, nil
+	}
+	attrs, err := dictArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	v.defineSign(toString(args[0]), attrs)
+	return 0, nil
+}
+
+func (v *Vim) defineSign(name string, attrs map[string]interface{}) {
+	d, ok := v.signDefs[name]
+	if !ok {
+		d = make(map[string]interface{})
+		v.signDefs[name] = d
+	}
+	for k, val := range attrs {
+		if k != "name" {
+			d[k] = deepCopy(val)
+		}
+	}
+}
+
+func (v *Vim) signUndefine(args []interface{}) (interface{}, error) {
+	switch a := args[0].(type) {
+	case nil:
+		v.signDefs = make(map[string]map[string]interface{})
+	case []interface{}:
+		res := []interface{}{}
+		for _, n := range a {
+			res = append(res, v.undefineSign(toString(n)))
+		}
+		return res, nil
+	default:
+		return v.undefineSign(toString(a)), nil
+	}
+	return 0, nil
+}
+
+func (v *Vim) undefineSign(name string) int {
+	if _, ok := v.signDefs[name]; !ok {
+		return -1
+	}
+	delete(v.signDefs, name)
+	return 0
+}
+
+func (v *Vim) signGetDefined(args []interface{}) (interface{}, error) {
+	var names []string
+	if args[0] != nil {
+		names = []string{toString(args[0])}
+	} else {
+		for n := range v.signDefs {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+	}
+	res := []interface{}{}
+	for _, n := range names {
+		d, ok := v.signDefs[n]
+		if !ok {
+			continue
+		}
+		s := deepCopy(d).(map[string]interface{})
+		s["name"] = n
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+func (v *Vim) signPlace(args []interface{}) (interface{}, error) {
+	dict, err := dictArg(args[4])
+	if err != nil {
+		return nil, err
+	}
+	b, err := v.mustBuffer(args[3])
+	if err != nil {
+		return nil, err
+	}
+	return v.placeSign(b, toNumber(args[0]), toString(args[1]), toString(args[2]), dict)
+}
+
+// placeSign places the sign name with id in group in b, or updates the sign
+// with id if it is already placed. An id of zero allocates a new id.
+func (v *Vim) placeSign(b *buffer, id int, group, name string, dict map[string]interface{}) (int, error) {
+	if _, ok := v.signDefs[name]; !ok {
+		return -1, fmt.Errorf("E155: Unknown sign: %v", name)
+	}
+	if id == 0 {
+		for _, s := range b.signs {
+			if s.group == group && s.id > id {
+				id = s.id
+			}
+		}
+		id++
+	}
+	for _, s := range b.signs {
+		if s.id == id && s.group == group {
+			s.name = name
+			if l, ok := dict["lnum"]; ok {
+				s.lnum = v.lnum(b, l)
+			}
+			if p, ok := dict["priority"]; ok {
+				s.priority = toNumber(p)
+			}
+			return id, nil
+		}
+	}
+	lnum := v.lnum(b, dict["lnum"])
+	if lnum < 1 || lnum > len(b.lines) {
+		return -1, fmt.Errorf("E885: Not possible to change sign %v", name)
+	}
+	priority := defaultSignPriority
+	if p, ok := dict["priority"]; ok {
+		priority = toNumber(p)
+	}
+	b.signs = append(b.signs, &sign{id: id, group: group, name: name, lnum: lnum, priority: priority})
+	return id, nil
+}
+
+func (v *Vim) signPlaceList(args []interface{}) (interface{}, error) {
+	list, err := listArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	res := []interface{}{}
+	for _, d := range list {
+		d, err := dictArg(d)
+		if err != nil {
+			return nil, err
+		}
+		b, err := v.mustBuffer(d["buffer"])
+		if err != nil {
+			return nil, err
+		}
+		id, err := v.placeSign(b, toNumber(d["id"]), toString(d["group"]), toString(d["name"]), d)
+		if err != nil {
+			v.say(err.Error(), true)
+		}
+		res = append(res, id)
+	}
+	return res, nil
+}
+
+func (v *Vim) signUnplace(args []interface{}) (interface{}, error) {
+	dict, err := dictArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return v.unplaceSigns(toString(args[0]), dict)
+}
+
+// unplaceSigns removes the signs in group ("*" for all groups), restricted
+// to those of the "buffer" and with the "id" given by dict
+func (v *Vim) unplaceSigns(group string, dict map[string]interface{}) (int, error) {
+	bufs := v.bufs
+	if b, ok := dict["buffer"]; ok {
+		buf, err := v.mustBuffer(b)
+		if err != nil {
+			return -1, err
+		}
+		bufs = []*buffer{buf}
+	}
+	_, hasID := dict["id"]
+	id := toNumber(dict["id"])
+	for _, b := range bufs {
+		var keep []*sign
+		for _, s := range b.signs {
+			if (group == "*" || s.group == group) && (!hasID || s.id == id) {
+				continue
+			}
+			keep = append(keep, s)
+		}
+		b.signs = keep
+	}
+	return 0, nil
+}
+
+func (v *Vim) signUnplaceList(args []interface{}) (interface{}, error) {
+	list, err := listArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	res := []interface{}{}
+	for _, d := range list {
+		d, err := dictArg(d)
+		if err != nil {
+			return nil, err
+		}
+		r, err := v.unplaceSigns(toString(d["group"]), d)
+		if err != nil {
+			v.say(err.Error(), true)
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+func (v *Vim) signGetPlaced(args []interface{}) (interface{}, error) {
+	dict, err := dictArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	var bufs []*buffer
+	if args[0] != nil {
+		b, err := v.mustBuffer(args[0])
+		if err != nil {
+			return nil, err
+		}
+		bufs = []*buffer{b}
+	} else {
+		for _, b := range v.bufs {
+			if len(b.signs) > 0 {
+				bufs = append(bufs, b)
+			}
+		}
+	}
+	group := toString(dict["group"])
+	var lnum int
+	if l, ok := dict["lnum"]; ok {
+		lnum = v.lnum(bufs[0], l)
+	}
+	res := []interface{}{}
+	for _, b := range bufs {
+		res = append(res, map[string]interface{}{
+			"bufnr": b.nr,
+			"signs": v.placedSigns(b, group, toNumber(dict["id"]), lnum),
+		})
+	}
+	return res, nil
+}
+
+// placedSigns returns the signs placed in b in group ("*" for all groups),
+// restricted to those with id and on line lnum if they are non-zero, as per
+// sign_getplaced(). Signs are ordered by line number and then by
+// descending priority.
+func (v *Vim) placedSigns(b *buffer, group string, id, lnum int) []interface{} {
+	var signs []*sign
+	for _, s := range b.signs {
+		if (group == "*" || s.group == group) && (id == 0 || s.id == id) && (lnum == 0 || s.lnum == lnum) {
+			signs = append(signs, s)
+		}
+	}
+	sort.SliceStable(signs, func(i, j int) bool {
+		if signs[i].lnum != signs[j].lnum {
+			return signs[i].lnum < signs[j].lnum
+		}
+		return signs[i].priority > signs[j].priority
+	})
+	res := []interface{}{}
+	for _, s := range signs {
+		res = append(res, map[string]interface{}{
+			"id":       s.id,
+			"group":    s.group,
+			"name":     s.name,
+			"lnum":     s.lnum,
+			"priority": s.priority,
+		})
+	}
+	return res
+}
@@ -0,0 +1,229 @@
+package govimtest
+
+import (
+	"fmt"
+	"sort"
+)
+
+// propBuffer returns the buffer given by the "bufnr" entry of props, or the
+// current buffer
+func (v *Vim) propBuffer(props map[string]interface{}) (*buffer, error) {
+	if n, ok := props["bufnr"]; ok {
+		return v.mustBuffer(toNumber(n))
+	}
+	return v.win.buf, nil
+}
+
+func (v *Vim) propTypeAdd(args []interface{}) (interface{}, error) {
+	name := toString(args[0])
+	props, err := dictArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := v.propTypes[name]; ok {
+		return nil, fmt.Errorf("E969: Property type %v already defined", name)
+	}
+	v.propTypes[name] = deepCopy(props).(map[string]interface{})
+	return 0, nil
+}
+
+func (v *Vim) propTypeChange(args []interface{}) (interface{}, error) {
+	name := toString(args[0])
+	props, err := dictArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	t, ok := v.propTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("E971: Property type %v does not exist", name)
+	}
+	for k, val := range props {
+		t[k] = deepCopy(val)
+	}
+	return 0, nil
+}
+
+func (v *Vim) propTypeDelete(args []interface{}) (interface{}, error) {
+	delete(v.propTypes, toString(args[0]))
+	return 0, nil
+}
+
+func (v *Vim) propTypeGet(args []interface{}) (interface{}, error) {
+	t, ok := v.propTypes[toString(args[0])]
+	if !ok {
+		return map[string]interface{}{}, nil
+	}
+	return deepCopy(t), nil
+}
+
+func (v *Vim) propTypeList(args []interface{}) (interface{}, error) {
+	var names []string
+	for n := range v.propTypes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	res := []interface{}{}
+	for _, n := range names {
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+func (v *Vim) propAdd(args []interface{}) (interface{}, error) {
+	props, err := dictArg(args[2])
+	if err != nil {
+		return nil, err
+	}
+	b, err := v.propBuffer(props)
+	if err != nil {
+		return nil, err
+	}
+	typ := toString(props["type"])
+	if _, ok := v.propTypes[typ]; !ok {
+		return nil, fmt.Errorf("E971: Property type %v does not exist", typ)
+	}
+	if _, ok := props["text"]; ok {
+		return nil, fmt.Errorf("govimtest: virtual text properties are not supported")
+	}
+	lnum, col := toNumber(args[0]), toNumber(args[1])
+	if lnum < 1 || lnum > len(b.lines) {
+		return nil, fmt.Errorf("E966: Invalid line number: %v", lnum)
+	}
+	if col < 1 || col > len(b.lines[lnum-1])+1 {
+		return nil, fmt.Errorf("E964: Invalid column number: %v", col)
+	}
+	p := &textProp{
+		typ:     typ,
+		id:      toNumber(props["id"]),
+		lnum:    lnum,
+		col:     col,
+		endLnum: lnum,
+		endCol:  col,
+	}
+	if l, ok := props["length"]; ok {
+		p.endCol = col + toNumber(l)
+	} else {
+		if l, ok := props["end_lnum"]; ok {
+			p.endLnum = toNumber(l)
+		}
+		if c, ok := props["end_col"]; ok {
+			p.endCol = toNumber(c)
+		}
+	}
+	if p.endLnum < lnum || p.endLnum > len(b.lines) {
+		return nil, fmt.Errorf("E966: Invalid line number: %v", p.endLnum)
+	}
+	if p.endCol < 1 || p.endLnum == lnum && p.endCol < col {
+		return nil, fmt.Errorf("E964: Invalid column number: %v", p.endCol)
+	}
+	b.props = append(b.props, p)
+	return 0, nil
+}
+
+func (v *Vim) propClear(args []interface{}) (interface{}, error) {
+	props, err := dictArg(args[2])
+	if err != nil {
+		return nil, err
+	}
+	b, err := v.propBuffer(props)
+	if err != nil {
+		return nil, err
+	}
+	first := toNumber(args[0])
+	last := first
+	if args[1] != nil {
+		last = toNumber(args[1])
+	}
+	var keep []*textProp
+	for _, p := range b.props {
+		if p.lnum < first || p.lnum > last {
+			keep = append(keep, p)
+		}
+	}
+	b.props = keep
+	return 0, nil
+}
+
+func (v *Vim) propList(args []interface{}) (interface{}, error) {
+	props, err := dictArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	b, err := v.propBuffer(props)
+	if err != nil {
+		return nil, err
+	}
+	lnum := toNumber(args[0])
+	if lnum < 1 || lnum > len(b.lines) {
+		return []interface{}{}, nil
+	}
+	e, ok := props["end_lnum"]
+	if !ok {
+		return propsOnLine(b, lnum, false), nil
+	}
+	end := toNumber(e)
+	if end < 0 || end > len(b.lines) {
+		end = len(b.lines)
+	}
+	res := []interface{}{}
+	for l := lnum; l <= end; l++ {
+		res = append(res, propsOnLine(b, l, true)...)
+	}
+	return res, nil
+}
+
+func (v *Vim) propRemove(args []interface{}) (interface{}, error) {
+	props, err := dictArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	b, err := v.propBuffer(props)
+	if err != nil {
+		return nil, err
+	}
+	_, hasID := props["id"]
+	_, hasType := props["type"]
+	_, hasTypes := props["types"]
+	if !hasID && !hasType && !hasTypes {
+		return nil, fmt.Errorf("E968: Need at least one of 'id' or 'type'")
+	}
+	types := make(map[string]bool)
+	if hasType {
+		types[toString(props["type"])] = true
+	}
+	if ts, ok := props["types"].([]interface{}); ok {
+		for _, t := range ts {
+			types[toString(t)] = true
+		}
+	}
+	first, last := 1, len(b.lines)
+	if args[1] != nil {
+		first, last = toNumber(args[1]), toNumber(args[1])
+		if args[2] != nil {
+			last = toNumber(args[2])
+		}
+	}
+	matches := func(p *textProp) bool {
+		if p.lnum < first || p.lnum > last {
+			return false
+		}
+		idMatch := hasID && p.id == toNumber(props["id"])
+		typeMatch := (hasType || hasTypes) && types[p.typ]
+		if truthy(props["both"]) {
+			return idMatch && typeMatch
+		}
+		return idMatch || typeMatch
+	}
+	all := truthy(props["all"])
+	var keep []*textProp
+	removed := 0
+	for _, p := range b.props {
+		if (all || removed == 0) && matches(p) {
+			removed++
+			continue
+		}
+		keep = append(keep, p)
+	}
+	b.props = keep
+	return removed, nil
+}
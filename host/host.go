@@ -0,0 +1,377 @@
+// Package host provides a govim.Plugin that hosts several independent plugins
+// within a single govim process. The hosted plugins share the one channel to
+// Vim and the one event queue, but are otherwise isolated from each other:
+// each defines functions and commands under its own prefix, and a panic or
+// fatal error in one plugin stops only that plugin.
+//
+// A program that hosts plugins passes the Host to govim.NewGovim in place of
+// a single Plugin:
+//
+//	h, err := host.New(
+//		host.Plugin{Prefix: "Foo", Plugin: foo.New()},
+//		host.Plugin{Prefix: "Bar", Plugin: bar.New()},
+//	)
+//	if err != nil {
+//		...
+//	}
+//	g, err := govim.NewGovim(h, in, out, log, nil, &tomb)
+package host
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/govim/govim"
+	"github.com/govim/govim/internal/logging"
+	"github.com/govim/govim/internal/traffic"
+)
+
+// Plugin is a plugin hosted by a Host
+type Plugin struct {
+	// Prefix is the prefix with which the names of the functions and commands
+	// the plugin defines must begin, in the same way as the prefix of a
+	// plugin.Driver. It must begin with a capital letter.
+	Prefix string
+
+	govim.Plugin
+}
+
+// Host is a govim.Plugin that hosts several plugins
+type Host struct {
+	plugins []*hosted
+
+	// g is the Govim instance passed to Init, and logger its logger, if it
+	// has one
+	g      govim.Govim
+	logger *logging.Logger
+
+	// done is closed when the Host is shut down
+	done chan struct{}
+}
+
+var _ govim.Plugin = (*Host)(nil)
+
+// New returns a Host for plugins. The prefixes of the plugins must be unique,
+// and no prefix may be a prefix of another.
+func New(plugins ...Plugin) (*Host, error) {
+	h := &Host{
+		done: make(chan struct{}),
+	}
+	for i, p := range plugins {
+		if p.Plugin == nil {
+			return nil, fmt.Errorf("plugin %v has a nil Plugin", i)
+		}
+		if r, _ := utf8.DecodeRuneInString(p.Prefix); !unicode.IsUpper(r) {
+			return nil, fmt.Errorf("plugin prefix %q must begin with a capital letter", p.Prefix)
+		}
+		for _, q := range h.plugins {
+			if strings.HasPrefix(p.Prefix, q.prefix) || strings.HasPrefix(q.prefix, p.Prefix) {
+				return nil, fmt.Errorf("plugin prefixes %q and %q overlap", q.prefix, p.Prefix)
+			}
+		}
+		h.plugins = append(h.plugins, &hosted{
+			h:      h,
+			prefix: p.Prefix,
+			plugin: p.Plugin,
+			errCh:  make(chan error),
+		})
+	}
+	return h, nil
+}
+
+// Init implements govim.Plugin.Init. Each hosted plugin is initialised in
+// turn; a plugin that fails to initialise is stopped, and the others
+// continue to run.
+func (h *Host) Init(g govim.Govim, errCh chan error) error {
+	h.g = g
+	if l, ok := g.(interface{ Logger() *logging.Logger }); ok {
+		h.logger = l.Logger()
+	}
+	for _, p := range h.plugins {
+		go p.watchErrors()
+		err := p.protect(func() error {
+			return p.plugin.Init(p.wrap(g), p.errCh)
+		})
+		if err != nil {
+			p.stop(fmt.Errorf("failed to initialise: %v", err))
+			continue
+		}
+		p.lock.Lock()
+		p.initialised = true
+		p.lock.Unlock()
+	}
+	return nil
+}
+
+// Shutdown implements govim.Plugin.Shutdown, shutting down each hosted plugin
+// that has not already stopped
+func (h *Host) Shutdown() error {
+	close(h.done)
+	var errs []string
+	for _, p := range h.plugins {
+		if err := p.shutdown(); err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", p.prefix, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to shut down plugins: %v", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (h *Host) errorf(format string, args ...interface{}) {
+	if h.logger != nil {
+		h.logger.Errorf(logging.Govim, format, args...)
+		return
+	}
+	h.g.Logf(format, args...)
+}
+
+// hosted is the state of a hosted plugin
+type hosted struct {
+	h      *Host
+	prefix string
+	plugin govim.Plugin
+	errCh  chan error
+
+	lock sync.Mutex
+
+	// initialised indicates that the Init of the plugin succeeded, and so
+	// its Shutdown is to be called
+	initialised bool
+
+	// stopped is the reason the plugin was stopped, if it has been
+	stopped error
+
+	// shutDown indicates the Shutdown of the plugin has been called
+	shutDown bool
+}
+
+// watchErrors stops the plugin when it reports an error via its error
+// channel, in the same way as govim stops when a plugin it runs directly
+// does so. The channel is drained until the Host is shut down so that the
+// plugin never blocks sending to it.
+func (p *hosted) watchErrors() {
+	for {
+		select {
+		case <-p.h.done:
+			return
+		case err := <-p.errCh:
+			if err != nil {
+				p.stop(fmt.Errorf("reported error: %v", err))
+			}
+		}
+	}
+}
+
+// protect calls f, converting a panic into an error. govim.ErrShuttingDown
+// is re-panicked, because it is the means by which govim unwinds calls that
+// are in progress when it shuts down.
+func (p *hosted) protect(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if r == govim.ErrShuttingDown {
+				panic(r)
+			}
+			err = fmt.Errorf("panic: %v", r)
+			p.h.errorf("plugin %v panicked: %v\n%s", p.prefix, r, debug.Stack())
+			p.stop(err)
+		}
+	}()
+	return f()
+}
+
+// stoppedErr returns the error with which calls into a stopped plugin fail,
+// or nil if the plugin has not stopped
+func (p *hosted) stoppedErr() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.stopped != nil {
+		return fmt.Errorf("plugin %v has stopped: %v", p.prefix, p.stopped)
+	}
+	return nil
+}
+
+// stop stops the plugin for reason. The plugin is then shut down via the
+// event queue, and the functions, commands and autocommands it defined fail
+// when called.
+func (p *hosted) stop(reason error) {
+	p.lock.Lock()
+	if p.stopped != nil {
+		p.lock.Unlock()
+		return
+	}
+	p.stopped = reason
+	p.lock.Unlock()
+	p.h.errorf("plugin %v stopped: %v", p.prefix, reason)
+	p.h.g.Enqueue(func(govim.Govim) error {
+		if err := p.shutdown(); err != nil {
+			p.h.errorf("plugin %v failed to shut down: %v", p.prefix, err)
+		}
+		return nil
+	})
+}
+
+// shutdown calls the Shutdown of the plugin, if the plugin was initialised
+// and Shutdown has not already been called
+func (p *hosted) shutdown() error {
+	p.lock.Lock()
+	if !p.initialised || p.shutDown {
+		p.lock.Unlock()
+		return nil
+	}
+	p.shutDown = true
+	p.lock.Unlock()
+	return p.protect(p.plugin.Shutdown)
+}
+
+// checkName checks that name, the name of a function or command being
+// defined by the plugin, begins with the prefix of the plugin
+func (p *hosted) checkName(kind, name string) error {
+	if !strings.HasPrefix(name, p.prefix) {
+		return fmt.Errorf("plugin %v cannot define %v %q: name must begin with %q", p.prefix, kind, name, p.prefix)
+	}
+	return nil
+}
+
+// wrap returns a Govim that makes calls via g on behalf of the plugin
+func (p *hosted) wrap(g govim.Govim) govim.Govim {
+	if pg, ok := g.(pluginGovim); ok {
+		g = pg.Govim
+	}
+	return pluginGovim{Govim: g, p: p}
+}
+
+// pluginGovim is the Govim instance of a hosted plugin. It isolates the
+// plugin from the others by enforcing its prefix, and by recovering from
+// panics in the callbacks it defines and the work it schedules.
+type pluginGovim struct {
+	govim.Govim
+	p *hosted
+}
+
+func (g pluginGovim) DefineFunction(name string, params []string, f govim.VimFunction) error {
+	if err := g.p.checkName("function", name); err != nil {
+		return err
+	}
+	return g.Govim.DefineFunction(name, params, func(vg govim.Govim, args ...json.RawMessage) (res interface{}, err error) {
+		if err := g.p.stoppedErr(); err != nil {
+			return nil, err
+		}
+		err = g.p.protect(func() error {
+			res, err = f(g.p.wrap(vg), args...)
+			return err
+		})
+		return
+	})
+}
+
+func (g pluginGovim) DefineRangeFunction(name string, params []string, f govim.VimRangeFunction) error {
+	if err := g.p.checkName("function", name); err != nil {
+		return err
+	}
+	return g.Govim.DefineRangeFunction(name, params, func(vg govim.Govim, line1, line2 int, args ...json.RawMessage) (res interface{}, err error) {
+		if err := g.p.stoppedErr(); err != nil {
+			return nil, err
+		}
+		err = g.p.protect(func() error {
+			res, err = f(g.p.wrap(vg), line1, line2, args...)
+			return err
+		})
+		return
+	})
+}
+
+func (g pluginGovim) DefineCommand(name string, f govim.VimCommandFunction, attrs ...govim.CommAttr) error {
+	if err := g.p.checkName("command", name); err != nil {
+		return err
+	}
+	return g.Govim.DefineCommand(name, func(vg govim.Govim, flags govim.CommandFlags, args ...string) error {
+		if err := g.p.stoppedErr(); err != nil {
+			return err
+		}
+		return g.p.protect(func() error {
+			return f(g.p.wrap(vg), flags, args...)
+		})
+	}, attrs...)
+}
+
+// DefineAutoCommand defines an autocommand in the augroup group, which
+// defaults, as for a plugin.Driver, to the lower-cased prefix of the plugin
+func (g pluginGovim) DefineAutoCommand(group string, events govim.Events, patts govim.Patterns, nested bool, f govim.VimAutoCommandFunction, exprs ...string) error {
+	if group == "" {
+		group = strings.ToLower(g.p.prefix)
+	}
+	return g.Govim.DefineAutoCommand(group, events, patts, nested, func(vg govim.Govim, args ...json.RawMessage) error {
+		if err := g.p.stoppedErr(); err != nil {
+			return err
+		}
+		return g.p.protect(func() error {
+			return f(g.p.wrap(vg), args...)
+		})
+	}, exprs...)
+}
+
+// OnViewportChange subscribes f to changes in the viewport. f is not called
+// once the plugin has stopped, and a panic in f stops only the plugin.
+func (g pluginGovim) OnViewportChange(f func(govim.Govim, govim.Viewport) error) (func(), error) {
+	return g.Govim.OnViewportChange(func(vg govim.Govim, vp govim.Viewport) error {
+		if g.p.stoppedErr() != nil {
+			return nil
+		}
+		return g.p.protect(func() error {
+			return f(g.p.wrap(vg), vp)
+		})
+	})
+}
+
+func (g pluginGovim) Scheduled() govim.Govim {
+	return g.p.wrap(g.Govim.Scheduled())
+}
+
+func (g pluginGovim) Enqueue(f func(govim.Govim) error) chan struct{} {
+	return g.Govim.Enqueue(g.work(f))
+}
+
+func (g pluginGovim) Schedule(f func(govim.Govim) error) (chan struct{}, error) {
+	return g.Govim.Schedule(g.work(f))
+}
+
+// work wraps f, a piece of work for the event queue, so that it does not run
+// once the plugin has stopped, and so that a panic stops only the plugin
+func (g pluginGovim) work(f func(govim.Govim) error) func(govim.Govim) error {
+	return func(vg govim.Govim) error {
+		if g.p.stoppedErr() != nil {
+			return nil
+		}
+		err := g.p.protect(func() error {
+			return f(g.p.wrap(vg))
+		})
+		if err != nil {
+			g.p.stop(err)
+		}
+		return nil
+	}
+}
+
+// Logger returns the logger of the underlying Govim instance, for the plugins
+// within this module that expect it
+func (g pluginGovim) Logger() *logging.Logger {
+	return g.Govim.(interface{ Logger() *logging.Logger }).Logger()
+}
+
+// Traffic returns the traffic recorder of the underlying Govim instance, for
+// the plugins within this module that expect it
+func (g pluginGovim) Traffic() *traffic.Recorder {
+	return g.Govim.(interface{ Traffic() *traffic.Recorder }).Traffic()
+}
+
+// Errorf stops the plugin, rather than govim as a whole
+func (g pluginGovim) Errorf(format string, args ...interface{}) {
+	g.p.stop(fmt.Errorf(format, args...))
+}
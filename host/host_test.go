@@ -0,0 +1,128 @@
+package host
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/govim/govim"
+)
+
+// fakeGovim records the functions defined via it, and runs work
+// synchronously
+type fakeGovim struct {
+	govim.Govim
+	funcs map[string]govim.VimFunction
+}
+
+func newFakeGovim() *fakeGovim {
+	return &fakeGovim{funcs: make(map[string]govim.VimFunction)}
+}
+
+func (f *fakeGovim) DefineFunction(name string, params []string, fn govim.VimFunction) error {
+	if _, ok := f.funcs[name]; ok {
+		return fmt.Errorf("function already defined with name %q", name)
+	}
+	f.funcs[name] = fn
+	return nil
+}
+
+func (f *fakeGovim) Enqueue(fn func(govim.Govim) error) chan struct{} {
+	done := make(chan struct{})
+	fn(f)
+	close(done)
+	return done
+}
+
+func (f *fakeGovim) Scheduled() govim.Govim { return f }
+
+func (f *fakeGovim) Logf(format string, args ...interface{}) {}
+
+func (f *fakeGovim) call(name string) (interface{}, error) {
+	return f.funcs[name](f)
+}
+
+// testPlugin defines a function named by its prefix that calls fn
+type testPlugin struct {
+	name     string
+	fn       func() (interface{}, error)
+	shutdown int
+}
+
+func (t *testPlugin) Init(g govim.Govim, errCh chan error) error {
+	return g.DefineFunction(t.name, nil, func(govim.Govim, ...json.RawMessage) (interface{}, error) {
+		return t.fn()
+	})
+}
+
+func (t *testPlugin) Shutdown() error {
+	t.shutdown++
+	return nil
+}
+
+func TestNew(t *testing.T) {
+	p := &testPlugin{}
+	for _, prefixes := range [][]string{
+		{"foo"},
+		{""},
+		{"Foo", "Bar", "FooBar"},
+		{"Foo", "Foo"},
+	} {
+		var plugins []Plugin
+		for _, pref := range prefixes {
+			plugins = append(plugins, Plugin{Prefix: pref, Plugin: p})
+		}
+		if _, err := New(plugins...); err == nil {
+			t.Errorf("expected New to fail for prefixes %q", prefixes)
+		}
+	}
+}
+
+func TestIsolation(t *testing.T) {
+	foo := &testPlugin{name: "FooHello", fn: func() (interface{}, error) {
+		return "foo", nil
+	}}
+	bar := &testPlugin{name: "BarHello", fn: func() (interface{}, error) {
+		panic("bar is broken")
+	}}
+	bad := &testPlugin{name: "FooBad"}
+	h, err := New(
+		Plugin{Prefix: "Foo", Plugin: foo},
+		Plugin{Prefix: "Bar", Plugin: bar},
+		Plugin{Prefix: "Baz", Plugin: bad},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newFakeGovim()
+	if err := h.Init(g, make(chan error)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.funcs["FooBad"]; ok {
+		t.Errorf("plugin Baz defined a function outside its prefix")
+	}
+	if err := h.plugins[2].stoppedErr(); err == nil {
+		t.Errorf("plugin Baz not stopped after failing to initialise")
+	}
+
+	if _, err := g.call("BarHello"); err == nil || !strings.Contains(err.Error(), "bar is broken") {
+		t.Errorf("got error %v from panicking function; want the panic", err)
+	}
+	if _, err := g.call("BarHello"); err == nil || !strings.Contains(err.Error(), "plugin Bar has stopped") {
+		t.Errorf("got error %v from function of stopped plugin; want plugin Bar has stopped", err)
+	}
+	if bar.shutdown != 1 {
+		t.Errorf("stopped plugin shut down %v times; want 1", bar.shutdown)
+	}
+	if res, err := g.call("FooHello"); err != nil || res != "foo" {
+		t.Errorf("got %v, %v from FooHello; want foo, nil", res, err)
+	}
+
+	if err := h.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if foo.shutdown != 1 || bar.shutdown != 1 || bad.shutdown != 0 {
+		t.Errorf("got shutdowns %v, %v, %v; want 1, 1, 0", foo.shutdown, bar.shutdown, bad.shutdown)
+	}
+}
@@ -85,6 +85,7 @@ func expGoVersions(b build) (res []build) {
 func main() {
 	writeMaxVersionsScripts()
 	writeDockerWorkflow()
+	writeNeovimWorkflow()
 }
 
 func writeMaxVersionsScripts() {
@@ -203,6 +204,19 @@ func writeDockerWorkflow() {
 	writeFileFromTmpl(".github/workflows/docker-based_tests.yml", dockerWorkflowYaml, entries)
 }
 
+// writeNeovimWorkflow writes the workflow that runs the tests against the
+// latest Neovim. Neovim is not in the build matrix because cmd/govim does not
+// run under it, and so only the tests of the plugin API are run.
+func writeNeovimWorkflow() {
+	var vs struct {
+		MaxRealGoVersion string
+		NeovimVersion    string
+	}
+	vs.MaxRealGoVersion = semverGitHubGoVersion(testsetup.GoVersions[len(testsetup.GoVersions)-1])
+	vs.NeovimVersion = testsetup.LatestNeovim
+	writeFileFromTmpl(".github/workflows/neovim.yml", neovimYaml, vs)
+}
+
 func stringSliceToString(s []string) string {
 	var vs []string
 	for _, v := range s {
@@ -365,6 +379,41 @@ jobs:
       run: ./_scripts/testVimMain.sh
 `
 
+const neovimYaml = `# Code generated by genconfig. DO NOT EDIT.
+on:
+  push:
+    branches:
+      - main
+  pull_request:
+    branches:
+      - '**'
+  schedule:
+    - cron: '0 9 * * *'
+
+name: Neovim tests
+jobs:
+  test:
+    strategy:
+      fail-fast: false
+      matrix:
+        os: [ubuntu-20.04]
+        go-version: ["{{{.MaxRealGoVersion}}}"]
+        neovim-version: ["{{{.NeovimVersion}}}"]
+    runs-on: ${{ matrix.os }}
+    env:
+      VIM_FLAVOR: neovim
+      NEOVIM_VERSION: ${{ matrix.neovim-version }}
+      GOVIM_ERRLOGMATCH_WAIT: "25s"
+    steps:
+    - name: Checkout code
+      uses: actions/checkout@v4
+    - uses: actions/setup-go@v5
+      with:
+        go-version: ${{ matrix.go-version }}
+    - name: Run Neovim tests
+      run: ./_scripts/testNeovim.sh
+`
+
 const maxVersions = `# Code generated by genconfig. DO NOT EDIT.
 export GO_VERSIONS="{{{.GoVersions}}}"
 export VIM_VERSIONS="{{{.VimVersions}}}"
@@ -0,0 +1,274 @@
+// genvimfn generates typed Go wrappers for Vim's builtin functions from a
+// specification file, by default builtins.txt. The format of the
+// specification is documented at the top of vimfn/builtins.txt. It should be
+// run via go generate from the directory containing the specification.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	fOut = flag.String("o", "gen_vimfn.go", "the file to which to write the generated wrappers")
+
+	funcRegexp  = regexp.MustCompile(`^func ([A-Z]\w*) (\w+)\((.*)\)(?: (\S+))?$`)
+	typeRegexp  = regexp.MustCompile(`^(options|struct) ([A-Z]\w*) (\S+)$`)
+	fieldRegexp = regexp.MustCompile(`^\t([A-Z]\w*) (\w+) (\S+)$`)
+	paramRegexp = regexp.MustCompile(`^([a-z]\w*) (\.\.\.)?(\S+)$`)
+)
+
+// reservedParams are the names used for local variables in the generated
+// wrappers
+var reservedParams = map[string]bool{
+	"args": true,
+	"res":  true,
+	"r":    true,
+	"v":    true,
+	"a":    true,
+	"b":    true,
+	"err":  true,
+}
+
+type function struct {
+	goName   string
+	vimName  string
+	params   []param
+	variadic bool
+	result   string
+}
+
+type param struct {
+	name string
+	typ  string
+}
+
+type typeDecl struct {
+	options bool
+	name    string
+	help    string
+	fields  []field
+}
+
+type field struct {
+	goName string
+	key    string
+	typ    string
+}
+
+func main() {
+	if err := mainerr(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func mainerr() error {
+	flag.Parse()
+	spec := "builtins.txt"
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		spec = flag.Arg(0)
+	default:
+		return fmt.Errorf("expected at most one argument")
+	}
+	funcs, types, err := parse(spec)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	pf := func(format string, args ...interface{}) {
+		if format[len(format)-1] != '\n' {
+			format += "\n"
+		}
+		fmt.Fprintf(&buf, format, args...)
+	}
+	pf("// Code generated by genvimfn from %v. DO NOT EDIT.\n\n", spec)
+	pf("package vimfn")
+	pf("import \"encoding/json\"")
+
+	for _, f := range funcs {
+		var params, args []string
+		for i, p := range f.params {
+			typ := p.typ
+			if f.variadic && i == len(f.params)-1 {
+				typ = "..." + typ
+			} else {
+				args = append(args, p.name)
+			}
+			params = append(params, p.name+" "+typ)
+		}
+		sig := strings.Join(params, ", ")
+		argList := strings.Join(append([]string{fmt.Sprintf("%q", f.vimName)}, args...), ", ")
+		argsDecl := func() {
+			if !f.variadic {
+				return
+			}
+			pf("args := []interface{}{%v}", strings.Join(args, ", "))
+			pf("for _, a := range %v {", f.params[len(f.params)-1].name)
+			pf("args = append(args, a)")
+			pf("}")
+		}
+		if f.variadic {
+			argList = fmt.Sprintf("%q, args...", f.vimName)
+		}
+
+		pf("// %v calls %v(); see :help %[2]v().", f.goName, f.vimName)
+		if f.result == "" {
+			pf("func (v Vim) %v(%v) error {", f.goName, sig)
+			argsDecl()
+			pf("return v.call(nil, %v)", argList)
+			pf("}")
+		} else {
+			pf("func (v Vim) %v(%v) (%v, error) {", f.goName, sig, f.result)
+			argsDecl()
+			pf("var res %v", f.result)
+			pf("err := v.call(&res, %v)", argList)
+			pf("return res, err")
+			pf("}")
+		}
+
+		pf("// %v adds a call of %v() to the batch; see :help %[2]v().", f.goName, f.vimName)
+		if f.result == "" {
+			pf("func (b Batch) %v(%v) {", f.goName, sig)
+			argsDecl()
+			pf("b.b.ChannelCall(%v)", argList)
+			pf("}")
+		} else {
+			pf("// The result and error of the call are available once the batch has")
+			pf("// ended.")
+			pf("func (b Batch) %v(%v) func() (%v, error) {", f.goName, sig, f.result)
+			argsDecl()
+			pf("r := b.b.ChannelCall(%v)", argList)
+			pf("return func() (res %v, err error) {", f.result)
+			pf("err = r.Decode(&res)")
+			pf("return res, err")
+			pf("}")
+			pf("}")
+		}
+	}
+
+	for _, t := range types {
+		if t.options {
+			pf("// %v is the dict of options of %v; see :help %[2]v. Only the", t.name, t.help)
+			pf("// fields that are set are passed to Vim.")
+		} else {
+			pf("// %v is a dict returned by %v; see :help %[2]v.", t.name, t.help)
+		}
+		pf("type %v struct {", t.name)
+		for _, f := range t.fields {
+			pf("%v %v `json:\"%v\"`", f.goName, f.typ, f.key)
+		}
+		pf("}")
+		if !t.options {
+			continue
+		}
+		pf("func (o %v) MarshalJSON() ([]byte, error) {", t.name)
+		pf("m := make(map[string]interface{})")
+		for _, f := range t.fields {
+			pf("if o.%v != %v {", f.goName, zeroValue(f.typ))
+			pf("m[%q] = o.%v", f.key, f.goName)
+			pf("}")
+		}
+		pf("return json.Marshal(m)")
+		pf("}")
+	}
+
+	res, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format result: %v\n%s", err, buf.Bytes())
+	}
+	if err := os.WriteFile(*fOut, res, 0666); err != nil {
+		return fmt.Errorf("failed to write %v: %v", *fOut, err)
+	}
+	return nil
+}
+
+// parse parses the specification in the file spec
+func parse(spec string) (funcs []function, types []*typeDecl, err error) {
+	f, err := os.Open(spec)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %v: %v", spec, err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	var curr *typeDecl
+	for lineNum := 1; sc.Scan(); lineNum++ {
+		line := sc.Text()
+		errorf := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%v:%v: %v", spec, lineNum, fmt.Sprintf(format, args...))
+		}
+		if strings.HasPrefix(line, "\t") {
+			if curr == nil {
+				return nil, nil, errorf("field outside of a type declaration")
+			}
+			m := fieldRegexp.FindStringSubmatch(line)
+			if m == nil {
+				return nil, nil, errorf("invalid field %q", line)
+			}
+			curr.fields = append(curr.fields, field{goName: m[1], key: m[2], typ: m[3]})
+			continue
+		}
+		curr = nil
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if m := typeRegexp.FindStringSubmatch(line); m != nil {
+			curr = &typeDecl{options: m[1] == "options", name: m[2], help: m[3]}
+			types = append(types, curr)
+			continue
+		}
+		m := funcRegexp.FindStringSubmatch(line)
+		if m == nil {
+			return nil, nil, errorf("invalid declaration %q", line)
+		}
+		fn := function{goName: m[1], vimName: m[2], result: m[4]}
+		if m[3] != "" {
+			ps := strings.Split(m[3], ", ")
+			for i, p := range ps {
+				pm := paramRegexp.FindStringSubmatch(p)
+				if pm == nil {
+					return nil, nil, errorf("invalid parameter %q", p)
+				}
+				if reservedParams[pm[1]] {
+					return nil, nil, errorf("parameter name %q is reserved", pm[1])
+				}
+				if pm[2] != "" {
+					if i != len(ps)-1 {
+						return nil, nil, errorf("only the last parameter can be variadic")
+					}
+					fn.variadic = true
+				}
+				fn.params = append(fn.params, param{name: pm[1], typ: pm[3]})
+			}
+		}
+		funcs = append(funcs, fn)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read %v: %v", spec, err)
+	}
+	return funcs, types, nil
+}
+
+// zeroValue returns the expression with which a value of type typ is compared
+// to determine whether it is set
+func zeroValue(typ string) string {
+	switch {
+	case strings.HasPrefix(typ, "*"), strings.HasPrefix(typ, "[]"),
+		strings.HasPrefix(typ, "map["), typ == "interface{}":
+		return "nil"
+	case typ == "string":
+		return `""`
+	case typ == "bool":
+		return "false"
+	}
+	return "0"
+}
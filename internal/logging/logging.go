@@ -0,0 +1,294 @@
+// Package logging provides the levelled, structured logger used by govim.
+//
+// Every message is logged against a Subsystem at a Level. Messages below the
+// level configured for their subsystem are dropped. A Logger writes either
+// one JSON object per message, or the traditional govim text format where each
+// line of a message is prefixed with a timestamp and the instance id.
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kr/pretty"
+)
+
+// Level is the severity of a log message
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+var levelNames = [...]string{
+	Debug: "debug",
+	Info:  "info",
+	Warn:  "warn",
+	Error: "error",
+}
+
+func (l Level) String() string {
+	if l < Debug || l > Error {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses the name of a level, e.g. "warn"
+func ParseLevel(s string) (Level, error) {
+	for l, n := range levelNames {
+		if n == s {
+			return Level(l), nil
+		}
+	}
+	return Debug, fmt.Errorf("unknown log level %q; valid levels are %v", s, strings.Join(levelNames[:], ", "))
+}
+
+// Subsystem identifies the part of govim responsible for a log message
+type Subsystem string
+
+const (
+	// Govim is the subsystem for messages that do not belong to any of the
+	// more specific subsystems
+	Govim Subsystem = "govim"
+
+	// Channel is the subsystem for messages about the channel between Vim
+	// and govim
+	Channel Subsystem = "channel"
+
+	// GoplsClient is the subsystem for calls made by gopls to govim
+	GoplsClient Subsystem = "gopls-client"
+
+	// GoplsServer is the subsystem for calls made by govim to gopls
+	GoplsServer Subsystem = "gopls-server"
+
+	// Buffers is the subsystem for messages about Vim buffers
+	Buffers Subsystem = "buffers"
+
+	// Watcher is the subsystem for messages from the file watcher
+	Watcher Subsystem = "watcher"
+)
+
+// Subsystems is the list of all subsystems
+var Subsystems = []Subsystem{Govim, Channel, GoplsClient, GoplsServer, Buffers, Watcher}
+
+// Format is the format in which a Logger writes messages
+type Format int
+
+const (
+	Text Format = iota
+	JSON
+)
+
+// ParseFormat parses the name of a format, either "text" or "json"
+func ParseFormat(s string) (Format, error) {
+	switch s {
+	case "text":
+		return Text, nil
+	case "json":
+		return JSON, nil
+	}
+	return Text, fmt.Errorf("unknown log format %q; valid formats are text, json", s)
+}
+
+// Levels is the minimum level at which messages are logged. Default applies
+// to any subsystem that does not have an entry in Subsystems.
+type Levels struct {
+	Default    Level
+	Subsystems map[Subsystem]Level
+}
+
+// ParseLevels parses a comma-separated list of levels. An entry that is just
+// a level sets the default level; an entry of the form subsystem=level sets
+// the level for that subsystem. For example:
+//
+//	info,gopls-server=debug,watcher=warn
+func ParseLevels(s string) (Levels, error) {
+	res := Levels{
+		Subsystems: make(map[Subsystem]Level),
+	}
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		name, lvl, ok := strings.Cut(e, "=")
+		if !ok {
+			l, err := ParseLevel(e)
+			if err != nil {
+				return res, err
+			}
+			res.Default = l
+			continue
+		}
+		sub := Subsystem(name)
+		found := false
+		for _, s := range Subsystems {
+			found = found || s == sub
+		}
+		if !found {
+			var names []string
+			for _, s := range Subsystems {
+				names = append(names, string(s))
+			}
+			return res, fmt.Errorf("unknown log subsystem %q; valid subsystems are %v", name, strings.Join(names, ", "))
+		}
+		l, err := ParseLevel(lvl)
+		if err != nil {
+			return res, err
+		}
+		res.Subsystems[sub] = l
+	}
+	return res, nil
+}
+
+// Logger writes levelled messages tagged with their subsystem to an
+// io.Writer. A Logger is safe for concurrent use.
+type Logger struct {
+	w      io.Writer
+	format Format
+	levels Levels
+
+	// instanceID identifies the govim instance doing the logging
+	instanceID string
+
+	// lock ensures that messages are written whole
+	lock sync.Mutex
+}
+
+// New returns a Logger that writes messages in format to w, dropping those
+// below levels.
+func New(w io.Writer, format Format, levels Levels) *Logger {
+	return &Logger{
+		w:      w,
+		format: format,
+		levels: levels,
+	}
+}
+
+// SetInstanceID sets the instance id that is included with every message
+func (l *Logger) SetInstanceID(id string) {
+	l.lock.Lock()
+	l.instanceID = id
+	l.lock.Unlock()
+}
+
+// Enabled reports whether a message for sub at level lvl would be logged.
+func (l *Logger) Enabled(sub Subsystem, lvl Level) bool {
+	min, ok := l.levels.Subsystems[sub]
+	if !ok {
+		min = l.levels.Default
+	}
+	return lvl >= min
+}
+
+// Logf logs a formatted message for sub at level lvl. Arguments created by
+// Pretty are only rendered if the message is logged.
+func (l *Logger) Logf(sub Subsystem, lvl Level, format string, args ...interface{}) {
+	if !l.Enabled(sub, lvl) {
+		return
+	}
+	args = append([]interface{}(nil), args...)
+	for i, a := range args {
+		if p, ok := a.(prettyVal); ok {
+			args[i] = p.render(l.format)
+		}
+	}
+	msg := strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")
+	now := time.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if err := l.write(now, sub, lvl, msg); err != nil {
+		// The message may well have been written nonetheless, e.g. when the
+		// log could not be rotated, so try to log why
+		l.write(now, Govim, Error, fmt.Sprintf("failed to write log: %v", err))
+	}
+}
+
+// write writes a message to l.w. It must be called with l.lock held.
+func (l *Logger) write(now time.Time, sub Subsystem, lvl Level, msg string) error {
+	switch l.format {
+	case JSON:
+		entry := struct {
+			Time      string    `json:"time"`
+			Instance  string    `json:"instance,omitempty"`
+			Level     string    `json:"level"`
+			Subsystem Subsystem `json:"subsystem"`
+			Msg       string    `json:"msg"`
+		}{
+			Time:      now.Format(time.RFC3339Nano),
+			Instance:  l.instanceID,
+			Level:     lvl.String(),
+			Subsystem: sub,
+			Msg:       msg,
+		}
+		// Encoding cannot fail: all fields are strings
+		byts, _ := json.Marshal(entry)
+		_, err := l.w.Write(append(byts, '\n'))
+		return err
+	default:
+		prefix := now.Format("2006-01-02T15:04:05.000000") + "_" + l.instanceID + ": "
+		msg = strings.Replace(msg, "\n", "\n"+prefix, -1)
+		_, err := fmt.Fprint(l.w, prefix+lvl.String()+" "+string(sub)+": "+msg+"\n")
+		return err
+	}
+}
+
+// Debugf logs a formatted message for sub at level Debug
+func (l *Logger) Debugf(sub Subsystem, format string, args ...interface{}) {
+	l.Logf(sub, Debug, format, args...)
+}
+
+// Infof logs a formatted message for sub at level Info
+func (l *Logger) Infof(sub Subsystem, format string, args ...interface{}) {
+	l.Logf(sub, Info, format, args...)
+}
+
+// Warnf logs a formatted message for sub at level Warn
+func (l *Logger) Warnf(sub Subsystem, format string, args ...interface{}) {
+	l.Logf(sub, Warn, format, args...)
+}
+
+// Errorf logs a formatted message for sub at level Error
+func (l *Logger) Errorf(sub Subsystem, format string, args ...interface{}) {
+	l.Logf(sub, Error, format, args...)
+}
+
+// Write implements io.Writer, logging p as a single Govim message at level
+// Debug. It allows a Logger to be used where an io.Writer is expected.
+func (l *Logger) Write(p []byte) (int, error) {
+	l.Logf(Govim, Debug, "%s", p)
+	return len(p), nil
+}
+
+// Pretty wraps v, typically an LSP payload, for use as the argument to a
+// Logf call. v is rendered lazily: as compact JSON by a JSON Logger, and via
+// github.com/kr/pretty by a Text Logger.
+func Pretty(v interface{}) interface{} {
+	return prettyVal{v: v}
+}
+
+type prettyVal struct {
+	v interface{}
+}
+
+func (p prettyVal) render(f Format) string {
+	if f == JSON {
+		if byts, err := json.Marshal(p.v); err == nil {
+			return string(byts)
+		}
+	}
+	return pretty.Sprint(p.v)
+}
+
+func (p prettyVal) String() string {
+	return p.render(Text)
+}
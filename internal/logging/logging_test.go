@@ -0,0 +1,206 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseLevels(t *testing.T) {
+	testCases := []struct {
+		in   string
+		want Levels
+		err  string
+	}{
+		{
+			in:   "",
+			want: Levels{Default: Debug, Subsystems: map[Subsystem]Level{}},
+		},
+		{
+			in:   "warn",
+			want: Levels{Default: Warn, Subsystems: map[Subsystem]Level{}},
+		},
+		{
+			in: "info, gopls-server=debug,watcher=error",
+			want: Levels{Default: Info, Subsystems: map[Subsystem]Level{
+				GoplsServer: Debug,
+				Watcher:     Error,
+			}},
+		},
+		{
+			in:  "loud",
+			err: `unknown log level "loud"`,
+		},
+		{
+			in:  "vim=info",
+			err: `unknown log subsystem "vim"`,
+		},
+	}
+	for _, tc := range testCases {
+		got, err := ParseLevels(tc.in)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("ParseLevels(%q): got error %v; want %q", tc.in, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseLevels(%q): unexpected error: %v", tc.in, err)
+			continue
+		}
+		if got.Default != tc.want.Default || len(got.Subsystems) != len(tc.want.Subsystems) {
+			t.Errorf("ParseLevels(%q): got %v; want %v", tc.in, got, tc.want)
+			continue
+		}
+		for s, l := range tc.want.Subsystems {
+			if got.Subsystems[s] != l {
+				t.Errorf("ParseLevels(%q): got %v; want %v", tc.in, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestLoggerJSON(t *testing.T) {
+	var buf bytes.Buffer
+	levels, err := ParseLevels("warn,gopls-server=debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(&buf, JSON, levels)
+	l.SetInstanceID("#1")
+	l.Infof(Buffers, "dropped")
+	l.Warnf(Buffers, "kept %v", 1)
+	l.Debugf(GoplsServer, "params: %v", Pretty(map[string]int{"line": 5}))
+
+	type entry struct {
+		Instance  string
+		Level     string
+		Subsystem string
+		Msg       string
+	}
+	var got []entry
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var e entry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, e)
+	}
+	want := []entry{
+		{Instance: "#1", Level: "warn", Subsystem: "buffers", Msg: "kept 1"},
+		{Instance: "#1", Level: "debug", Subsystem: "gopls-server", Msg: `params: {"line":5}`},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v entries; want %v: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %v: got %+v; want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLoggerText(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, Text, Levels{})
+	l.SetInstanceID("#2")
+	l.Debugf(Channel, "first\nsecond\n")
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %v lines; want 2: %q", len(lines), buf.String())
+	}
+	if !strings.HasSuffix(lines[0], "_#2: debug channel: first") {
+		t.Errorf("unexpected first line: %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "_#2: second") {
+		t.Errorf("unexpected second line: %q", lines[1])
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "govim.log")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRotatingFile(f, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n", "dddddd\n"} {
+		if _, err := r.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Sync(); err != nil {
+		t.Fatalf("Sync after Close: %v", err)
+	}
+	want := map[string]string{
+		path:        "dddddd\n",
+		path + ".1": "cccccc\n",
+		path + ".2": "bbbbbb\n",
+	}
+	for p, w := range want {
+		byts, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(byts) != w {
+			t.Errorf("%v: got %q; want %q", p, byts, w)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected %v.3 not to exist; got %v", path, err)
+	}
+}
+
+func TestRotatingFileRotateError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "govim.log")
+	// A non-empty directory in place of the backup cannot be removed or
+	// renamed over, so rotation fails
+	if err := os.MkdirAll(filepath.Join(path+".1", "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRotatingFile(f, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	l := New(r, Text, Levels{})
+	l.Debugf(Govim, "first")
+	l.Debugf(Govim, "second")
+	l.Debugf(Govim, "third")
+	byts, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(byts), "\n"), "\n")
+	want := []string{
+		": debug govim: first",
+		": debug govim: second",
+		": error govim: failed to write log: failed to rotate " + path + ": ",
+		": debug govim: third",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %v lines; want %v: %q", len(lines), len(want), byts)
+	}
+	for i, w := range want {
+		if !strings.Contains(lines[i], w) {
+			t.Errorf("line %v: got %q; want it to contain %q", i, lines[i], w)
+		}
+	}
+}
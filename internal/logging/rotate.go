@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an io.WriteCloser that writes to a file, rotating it once it
+// exceeds a maximum size. On rotation, the file at path is renamed to
+// path.1, any existing path.1 to path.2, and so on, keeping at most a fixed
+// number of backups. Writing then continues in a new file at path, so that
+// the current log is always found at the same path.
+type RotatingFile struct {
+	path    string
+	maxSize int64
+	backups int
+
+	lock sync.Mutex
+	f    *os.File
+	size int64
+
+	// rotateErr is the error from a failed rotation, after which rotation is
+	// not attempted again
+	rotateErr error
+}
+
+// NewRotatingFile returns a RotatingFile that takes over writing to f, which
+// must have been opened for appending. f is rotated once it exceeds maxSize
+// bytes; a maxSize <= 0 disables rotation.
+func NewRotatingFile(f *os.File, maxSize int64, backups int) (*RotatingFile, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %v: %v", f.Name(), err)
+	}
+	res := &RotatingFile{
+		path:    f.Name(),
+		maxSize: maxSize,
+		backups: backups,
+		f:       f,
+		size:    fi.Size(),
+	}
+	return res, nil
+}
+
+// Name returns the path of the current log file
+func (r *RotatingFile) Name() string {
+	return r.path
+}
+
+// Write implements io.Writer. A single Write is never split across files. If
+// the file cannot be rotated, p is still written to the current file, which
+// continues to grow, and the error is returned by that one Write.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	var rotateErr error
+	if r.maxSize > 0 && r.rotateErr == nil && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			r.rotateErr = err
+			rotateErr = fmt.Errorf("%v; rotation disabled", err)
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	if err != nil {
+		return n, err
+	}
+	return n, rotateErr
+}
+
+// rotate must be called with r.lock held. If rotation fails, r.f remains the
+// file at path.
+func (r *RotatingFile) rotate() error {
+	if r.backups > 0 {
+		os.Remove(r.backupPath(r.backups))
+		for i := r.backups - 1; i > 0; i-- {
+			os.Rename(r.backupPath(i), r.backupPath(i+1))
+		}
+		if err := os.Rename(r.path, r.backupPath(1)); err != nil {
+			return fmt.Errorf("failed to rotate %v: %v", r.path, err)
+		}
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		if r.backups > 0 {
+			// r.f is still open, so move it back to path
+			os.Rename(r.backupPath(1), r.path)
+		}
+		return fmt.Errorf("failed to create %v: %v", r.path, err)
+	}
+	// Everything written to r.f has already been written to the file
+	r.f.Close()
+	r.f = f
+	r.size = 0
+	return nil
+}
+
+func (r *RotatingFile) backupPath(i int) string {
+	return fmt.Sprintf("%v.%d", r.path, i)
+}
+
+// Sync commits the contents of the current log file to stable storage
+func (r *RotatingFile) Sync() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	return r.f.Sync()
+}
+
+// Close closes the current log file
+func (r *RotatingFile) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
@@ -0,0 +1,422 @@
+// Package msgpack implements the subset of MessagePack used by Neovim's
+// msgpack-RPC API. See https://github.com/msgpack/msgpack/blob/master/spec.md
+//
+// Values are decoded to nil, bool, int64, uint64, float64, string, []byte,
+// []interface{}, map[string]interface{} or Ext, and the same types (as well
+// as the other Go integer and float types, and json.Number) can be encoded.
+// Map keys that are not strings are decoded to their fmt representation,
+// because the values exchanged with Neovim are converted to and from JSON.
+package msgpack
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Ext is a value of an extension type. Neovim uses extension types for the
+// handles of buffers, windows and tab pages.
+type Ext struct {
+	Type int8
+	Data []byte
+}
+
+// Int returns the integer encoded in the data of e, as Neovim encodes the
+// handles of buffers, windows and tab pages
+func (e Ext) Int() (int64, error) {
+	v, err := NewDecoder(bytes.NewReader(e.Data)).Decode()
+	if err != nil {
+		return 0, err
+	}
+	switch v := v.(type) {
+	case int64:
+		return v, nil
+	case uint64:
+		return int64(v), nil
+	}
+	return 0, fmt.Errorf("ext data is a %T, not an integer", v)
+}
+
+// Decoder reads values from an input stream
+type Decoder struct {
+	r *bufio.Reader
+}
+
+// NewDecoder returns a Decoder that reads from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next value from the input stream. io.EOF is returned if
+// the stream ends before a value starts.
+func (d *Decoder) Decode() (interface{}, error) {
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	v, err := d.decode(b)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+func (d *Decoder) decode(b byte) (interface{}, error) {
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xe0 == 0xa0:
+		return d.str(int(b & 0x1f))
+	case b&0xf0 == 0x90:
+		return d.array(int(b & 0x0f))
+	case b&0xf0 == 0x80:
+		return d.map_(int(b & 0x0f))
+	}
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (b - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return d.bytes(int(n))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.uint(1 << (b - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.ext(int(n))
+	case 0xca:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.uint(1 << (b - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if n <= math.MaxInt64 {
+			return int64(n), nil
+		}
+		return n, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		n, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		// sign extend
+		shift := 64 - 8*uint(size)
+		return int64(n<<shift) >> shift, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.ext(1 << (b - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (b - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (b - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (b - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.map_(int(n))
+	}
+	return nil, fmt.Errorf("invalid msgpack type byte 0x%x", b)
+}
+
+// uint reads a big-endian unsigned integer of size bytes
+func (d *Decoder) uint(size int) (uint64, error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(d.r, buf[8-size:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+func (d *Decoder) bytes(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func (d *Decoder) str(n int) (interface{}, error) {
+	buf, err := d.bytes(n)
+	return string(buf), err
+}
+
+func (d *Decoder) ext(n int) (interface{}, error) {
+	typ, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.bytes(n)
+	return Ext{Type: int8(typ), Data: data}, err
+}
+
+func (d *Decoder) array(n int) (interface{}, error) {
+	res := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+func (d *Decoder) map_(n int) (interface{}, error) {
+	res := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.Decode()
+		if err != nil {
+			return nil, err
+		}
+		switch k := k.(type) {
+		case string:
+			res[k] = v
+		case []byte:
+			res[string(k)] = v
+		default:
+			res[fmt.Sprint(k)] = v
+		}
+	}
+	return res, nil
+}
+
+// Encoder writes values to an output stream
+type Encoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewEncoder returns an Encoder that writes to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes v to the output stream in a single call of Write
+func (e *Encoder) Encode(v interface{}) error {
+	e.buf = e.buf[:0]
+	if err := e.encode(v); err != nil {
+		return err
+	}
+	_, err := e.w.Write(e.buf)
+	return err
+}
+
+func (e *Encoder) encode(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.buf = append(e.buf, 0xc0)
+	case bool:
+		if v {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case int:
+		e.int(int64(v))
+	case int8:
+		e.int(int64(v))
+	case int16:
+		e.int(int64(v))
+	case int32:
+		e.int(int64(v))
+	case int64:
+		e.int(v)
+	case uint:
+		e.uint(uint64(v))
+	case uint8:
+		e.uint(uint64(v))
+	case uint16:
+		e.uint(uint64(v))
+	case uint32:
+		e.uint(uint64(v))
+	case uint64:
+		e.uint(v)
+	case float32:
+		e.float(float64(v))
+	case float64:
+		e.float(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			e.int(i)
+		} else if f, err := strconv.ParseFloat(string(v), 64); err == nil {
+			e.float(f)
+		} else {
+			return fmt.Errorf("invalid number %q", v)
+		}
+	case string:
+		e.strHeader(len(v))
+		e.buf = append(e.buf, v...)
+	case []byte:
+		e.header(len(v), 0xc4, 0xc5, 0xc6)
+		e.buf = append(e.buf, v...)
+	case Ext:
+		switch len(v.Data) {
+		case 1, 2, 4, 8, 16:
+			e.buf = append(e.buf, 0xd4+byte(bitLen(len(v.Data))))
+		default:
+			e.header(len(v.Data), 0xc7, 0xc8, 0xc9)
+		}
+		e.buf = append(e.buf, byte(v.Type))
+		e.buf = append(e.buf, v.Data...)
+	case []interface{}:
+		if len(v) < 16 {
+			e.buf = append(e.buf, 0x90|byte(len(v)))
+		} else {
+			e.collHeader(len(v), 0xdc, 0xdd)
+		}
+		for _, vv := range v {
+			if err := e.encode(vv); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		if len(v) < 16 {
+			e.buf = append(e.buf, 0x80|byte(len(v)))
+		} else {
+			e.collHeader(len(v), 0xde, 0xdf)
+		}
+		for k, vv := range v {
+			if err := e.encode(k); err != nil {
+				return err
+			}
+			if err := e.encode(vv); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot encode value of type %T", v)
+	}
+	return nil
+}
+
+func (e *Encoder) int(v int64) {
+	switch {
+	case v >= 0:
+		e.uint(uint64(v))
+	case v >= -32:
+		e.buf = append(e.buf, byte(v))
+	case v >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(v))
+	case v >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1)
+		e.buf = appendUint16(e.buf, uint16(v))
+	case v >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2)
+		e.buf = appendUint32(e.buf, uint32(v))
+	default:
+		e.buf = append(e.buf, 0xd3)
+		e.buf = appendUint64(e.buf, uint64(v))
+	}
+}
+
+func (e *Encoder) uint(v uint64) {
+	switch {
+	case v <= 0x7f:
+		e.buf = append(e.buf, byte(v))
+	case v <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(v))
+	case v <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd)
+		e.buf = appendUint16(e.buf, uint16(v))
+	case v <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce)
+		e.buf = appendUint32(e.buf, uint32(v))
+	default:
+		e.buf = append(e.buf, 0xcf)
+		e.buf = appendUint64(e.buf, v)
+	}
+}
+
+func (e *Encoder) float(v float64) {
+	e.buf = append(e.buf, 0xcb)
+	e.buf = appendUint64(e.buf, math.Float64bits(v))
+}
+
+func (e *Encoder) strHeader(n int) {
+	if n < 32 {
+		e.buf = append(e.buf, 0xa0|byte(n))
+		return
+	}
+	e.header(n, 0xd9, 0xda, 0xdb)
+}
+
+// header appends the type byte and length of a value of length n with 8, 16
+// or 32 bit lengths, the type bytes of which are b8, b16 and b32
+func (e *Encoder) header(n int, b8, b16, b32 byte) {
+	switch {
+	case n <= math.MaxUint8:
+		e.buf = append(e.buf, b8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, b16)
+		e.buf = appendUint16(e.buf, uint16(n))
+	default:
+		e.buf = append(e.buf, b32)
+		e.buf = appendUint32(e.buf, uint32(n))
+	}
+}
+
+// collHeader appends the type byte and length of an array or map of length
+// n with 16 or 32 bit lengths, the type bytes of which are b16 and b32
+func (e *Encoder) collHeader(n int, b16, b32 byte) {
+	if n <= math.MaxUint16 {
+		e.buf = append(e.buf, b16)
+		e.buf = appendUint16(e.buf, uint16(n))
+		return
+	}
+	e.buf = append(e.buf, b32)
+	e.buf = appendUint32(e.buf, uint32(n))
+}
+
+// bitLen returns log2(n) for n a power of 2
+func bitLen(n int) int {
+	res := 0
+	for n > 1 {
+		n >>= 1
+		res++
+	}
+	return res
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
@@ -0,0 +1,118 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	many := make([]interface{}, 20)
+	for i := range many {
+		many[i] = int64(i * 1000)
+	}
+	vals := []interface{}{
+		nil,
+		true,
+		false,
+		int64(0),
+		int64(127),
+		int64(128),
+		int64(-1),
+		int64(-33),
+		int64(-200),
+		int64(70000),
+		int64(math.MinInt64),
+		uint64(math.MaxUint64),
+		1.5,
+		"",
+		"hello",
+		long,
+		[]byte{1, 2, 3},
+		[]interface{}{int64(1), "two", []interface{}{}},
+		many,
+		map[string]interface{}{"a": int64(1), "b": []interface{}{nil}},
+		Ext{Type: 1, Data: []byte{5}},
+		Ext{Type: 2, Data: []byte{1, 2, 3}},
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, v := range vals {
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("failed to encode %v: %v", v, err)
+		}
+	}
+	dec := NewDecoder(&buf)
+	for _, want := range vals {
+		got, err := dec.Decode()
+		if err != nil {
+			t.Fatalf("failed to decode %v: %v", want, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %#v; want %#v", got, want)
+		}
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		t.Errorf("got error %v at end of stream; want io.EOF", err)
+	}
+}
+
+func TestEncodeTypes(t *testing.T) {
+	testCases := []struct {
+		v    interface{}
+		want string
+	}{
+		{5, "\x05"},
+		{uint8(200), "\xcc\xc8"},
+		{int16(-2), "\xfe"},
+		{json.Number("300"), "\xcd\x01\x2c"},
+		{json.Number("0.5"), "\xcb\x3f\xe0\x00\x00\x00\x00\x00\x00"},
+		{[]interface{}{"a", nil}, "\x92\xa1a\xc0"},
+	}
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(tc.v); err != nil {
+			t.Errorf("failed to encode %#v: %v", tc.v, err)
+			continue
+		}
+		if got := buf.String(); got != tc.want {
+			t.Errorf("encoding %#v: got %q; want %q", tc.v, got, tc.want)
+		}
+	}
+	if err := NewEncoder(io.Discard).Encode(struct{}{}); err == nil {
+		t.Errorf("expected an error encoding a struct")
+	}
+}
+
+func TestDecodeNeovim(t *testing.T) {
+	// A response as sent by Neovim: [1, msgid, nil, result], in which the
+	// result is a buffer handle (ext type 0) and a map with an integer key,
+	// encoded with a float32 and a fixed-width str8
+	in := "\x94\x01\x07\xc0\x92\xd4\x00\x03\x81\x01\xca\x3f\xc0\x00\x00"
+	got, err := NewDecoder(strings.NewReader(in)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		int64(1), int64(7), nil,
+		[]interface{}{
+			Ext{Type: 0, Data: []byte{3}},
+			map[string]interface{}{"1": 1.5},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v; want %#v", got, want)
+	}
+	h, err := want[3].([]interface{})[0].(Ext).Int()
+	if err != nil || h != 3 {
+		t.Errorf("got handle %v, %v; want 3, nil", h, err)
+	}
+	if _, err := NewDecoder(strings.NewReader("\x92\x01")).Decode(); err != io.ErrUnexpectedEOF {
+		t.Errorf("got error %v for truncated input; want io.ErrUnexpectedEOF", err)
+	}
+}
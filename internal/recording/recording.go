@@ -0,0 +1,210 @@
+// Package recording defines the format of a recording of a govim session.
+//
+// A recording captures the messages exchanged between Vim and govim over the
+// channel, and between govim and gopls over JSON-RPC, in the order in which
+// govim sent or received them. A recording of a test also captures the
+// commands of the test driver. It is written as a header line followed by one
+// JSON-encoded Entry per line. Recordings are created by setting
+// GOVIM_RECORD, and can be replayed against a fresh govim with the replay
+// command of the testdriver package.
+package recording
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Version is the version of the recording format
+const Version = 1
+
+// Stream identifies the connection on which a message was exchanged
+type Stream string
+
+const (
+	// Vim is the channel between Vim and govim
+	Vim Stream = "vim"
+
+	// Gopls is the JSON-RPC connection between govim and gopls
+	Gopls Stream = "gopls"
+
+	// Driver is the connection between a test driver and govim, by which a
+	// script has govim issue commands to Vim. Its messages are the commands
+	// received from the driver.
+	Driver Stream = "driver"
+)
+
+// Direction is the direction of a message relative to govim
+type Direction string
+
+const (
+	Send Direction = "send"
+	Recv Direction = "recv"
+)
+
+// Header is the first line of a recording
+type Header struct {
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+
+	// WorkingDirectory is the working directory of govim during the
+	// recording. Paths within it are rewritten to be relative to the
+	// directory of the replay.
+	WorkingDirectory string `json:"wd"`
+}
+
+// Entry is a single recorded message
+type Entry struct {
+	Time   time.Time       `json:"time"`
+	Stream Stream          `json:"stream"`
+	Dir    Direction       `json:"dir"`
+	Msg    json.RawMessage `json:"msg"`
+}
+
+// Writer writes a recording. A Writer is safe for concurrent use.
+type Writer struct {
+	lock sync.Mutex
+	f    *os.File
+	enc  *json.Encoder
+	err  error
+}
+
+// Create creates the recording file path, writing h as its header
+func Create(path string, h Header) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording %v: %v", path, err)
+	}
+	h.Version = Version
+	w := &Writer{
+		f:   f,
+		enc: json.NewEncoder(f),
+	}
+	if err := w.enc.Encode(h); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write recording header to %v: %v", path, err)
+	}
+	return w, nil
+}
+
+// Record records msg, which must be a single JSON value, as sent or received
+// on stream s. The first error encountered is returned by Close; subsequent
+// messages are dropped.
+func (w *Writer) Record(s Stream, d Direction, msg []byte) {
+	e := Entry{
+		Time:   time.Now(),
+		Stream: s,
+		Dir:    d,
+		Msg:    append(json.RawMessage(nil), msg...),
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.err != nil {
+		return
+	}
+	w.err = w.enc.Encode(e)
+}
+
+// TapReader returns a reader that reads from r, recording each JSON value
+// read as a message received on stream s. A message is recorded by the read
+// that completes it, and so before any message sent in response to it.
+func (w *Writer) TapReader(s Stream, r io.Reader) io.Reader {
+	return &tapReader{w: w, s: s, r: r}
+}
+
+type tapReader struct {
+	w *Writer
+	s Stream
+	r io.Reader
+
+	// buf holds the bytes read of a message that is not yet complete
+	buf []byte
+}
+
+func (t *tapReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.buf = append(t.buf, p[:n]...)
+		t.record()
+	}
+	return n, err
+}
+
+// record records the complete messages held in t.buf
+func (t *tapReader) record() {
+	for len(t.buf) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(t.buf))
+		var msg json.RawMessage
+		if err := dec.Decode(&msg); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				// Not JSON, so there is nothing more that we can record
+				t.buf = nil
+			}
+			return
+		}
+		t.w.Record(t.s, Recv, msg)
+		t.buf = t.buf[dec.InputOffset():]
+	}
+	t.buf = nil
+}
+
+// TapWriter returns a writer that writes to wr, recording each write as a
+// message sent on stream s. Each write must therefore be a single JSON
+// value, as is the case for a json.Encoder.
+func (w *Writer) TapWriter(s Stream, wr io.Writer) io.Writer {
+	return &tapWriter{w: w, s: s, wr: wr}
+}
+
+type tapWriter struct {
+	w  *Writer
+	s  Stream
+	wr io.Writer
+}
+
+func (t *tapWriter) Write(p []byte) (int, error) {
+	t.w.Record(t.s, Send, p)
+	return t.wr.Write(p)
+}
+
+// Close closes the recording, returning the first error encountered while
+// writing it
+func (w *Writer) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if err := w.f.Close(); err != nil && w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+// Read reads the recording at path
+func Read(path string) (Header, []Entry, error) {
+	var h Header
+	f, err := os.Open(path)
+	if err != nil {
+		return h, nil, fmt.Errorf("failed to open recording: %v", err)
+	}
+	defer f.Close()
+	dec := json.NewDecoder(bufio.NewReader(f))
+	if err := dec.Decode(&h); err != nil {
+		return h, nil, fmt.Errorf("failed to read header of recording %v: %v", path, err)
+	}
+	if h.Version != Version {
+		return h, nil, fmt.Errorf("recording %v has version %v; only version %v is supported", path, h.Version, Version)
+	}
+	var entries []Entry
+	for dec.More() {
+		var e Entry
+		if err := dec.Decode(&e); err != nil {
+			return h, nil, fmt.Errorf("failed to read entry %v of recording %v: %v", len(entries)+1, path, err)
+		}
+		entries = append(entries, e)
+	}
+	return h, entries, nil
+}
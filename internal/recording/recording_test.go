@@ -0,0 +1,85 @@
+package recording
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.rec")
+	w, err := Create(path, Header{WorkingDirectory: "/work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := w.TapReader(Vim, strings.NewReader(`[1,["loaded"]]`+"\n"+`[2, ["function", "GOVIMHover", []]]`))
+	if _, err := io.ReadAll(in); err != nil {
+		t.Fatal(err)
+	}
+	out := w.TapWriter(Vim, io.Discard)
+	enc := json.NewEncoder(out)
+	if err := enc.Encode([]interface{}{2, "hover"}); err != nil {
+		t.Fatal(err)
+	}
+	w.Record(Gopls, Send, []byte(`{"jsonrpc":"2.0","method":"exit"}`))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	h, entries, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Version != Version || h.WorkingDirectory != "/work" {
+		t.Errorf("unexpected header: %+v", h)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, string(e.Stream)+" "+string(e.Dir)+" "+string(e.Msg))
+	}
+	want := []string{
+		`vim recv [1,["loaded"]]`,
+		`vim recv [2,["function","GOVIMHover",[]]]`,
+		`vim send [2,"hover"]`,
+		`gopls send {"jsonrpc":"2.0","method":"exit"}`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got entries %q; want %q", got, want)
+	}
+}
+
+// TestTapReaderPartial verifies that a message split across reads is recorded
+// by the read that completes it
+func TestTapReaderPartial(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.rec")
+	w, err := Create(path, Header{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, pw := io.Pipe()
+	in := w.TapReader(Vim, pr)
+	buf := make([]byte, 100)
+	for _, part := range []string{`[1,["fun`, `ction"]]` + "\n" + `[2,`, `"x"]`} {
+		go pw.Write([]byte(part))
+		if _, err := in.Read(buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	_, entries, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, string(e.Msg))
+	}
+	if want := []string{`[1,["function"]]`, `[2,"x"]`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got messages %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,328 @@
+// Package traffic records the messages that govim exchanges with Vim and with
+// gopls, for inspection from within Vim.
+//
+// Messages are only recorded whilst recording is started, i.e. whilst
+// something is inspecting them. They are recorded as Entry values in a
+// fixed-size ring, so that only the most recent messages are kept. Each
+// response is matched with its request so that the latency of the round trip
+// can be reported.
+package traffic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/govim/govim/internal/logging"
+)
+
+// Direction is the direction of a message relative to govim
+type Direction int
+
+const (
+	// Send is a message sent by govim
+	Send Direction = iota
+
+	// Recv is a message received by govim
+	Recv
+)
+
+func (d Direction) String() string {
+	if d == Send {
+		return "->"
+	}
+	return "<-"
+}
+
+func (d Direction) opposite() Direction {
+	if d == Send {
+		return Recv
+	}
+	return Send
+}
+
+// Kind is the kind of a message
+type Kind int
+
+const (
+	Request Kind = iota
+	Response
+	Notification
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Request:
+		return "request"
+	case Response:
+		return "response"
+	case Notification:
+		return "notification"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// maxSummary is the maximum length in bytes of an Entry.Summary
+const maxSummary = 512
+
+// maxPending is the maximum number of requests awaiting a response that are
+// tracked. A request that never gets a response, e.g. because it was
+// cancelled, would otherwise be tracked forever.
+const maxPending = 256
+
+// Entry is a single recorded message
+type Entry struct {
+	// Seq is the sequence number of the entry; entries are numbered from 1
+	Seq uint64
+
+	Time      time.Time
+	Subsystem logging.Subsystem
+	Direction Direction
+	Kind      Kind
+
+	// ID identifies a request and its response within Subsystem. It is
+	// empty for notifications.
+	ID string
+
+	Method string
+
+	// Latency is the time between a request and its response, and is only
+	// set for responses
+	Latency time.Duration
+
+	// Err is the error returned in a response, if any
+	Err string
+
+	// Summary is the JSON encoding of the params of a request or
+	// notification, or the result of a response, truncated to a few hundred
+	// bytes
+	Summary string
+}
+
+type pendingKey struct {
+	sub logging.Subsystem
+	dir Direction
+	id  string
+}
+
+type pending struct {
+	method string
+	start  time.Time
+}
+
+// Recorder records Entry values in a ring. A Recorder is safe for concurrent
+// use.
+type Recorder struct {
+	lock sync.Mutex
+
+	// recorders is the number of calls to Start not yet matched by a call to
+	// Stop. Messages are only recorded whilst it is non-zero.
+	recorders int
+
+	entries []Entry
+	next    int
+	seq     uint64
+	nextID  uint64
+
+	// pending are the recorded requests awaiting a response
+	pending map[pendingKey]pending
+
+	// excluded are the methods that are not recorded
+	excluded map[string]bool
+}
+
+// NewRecorder returns a Recorder that keeps the most recent size entries. A
+// Recorder with a size of 0 records nothing.
+func NewRecorder(size int) *Recorder {
+	return &Recorder{
+		entries:  make([]Entry, 0, size),
+		pending:  make(map[pendingKey]pending),
+		excluded: make(map[string]bool),
+	}
+}
+
+// Exclude stops the recording of requests and notifications for method, and
+// of the responses to those requests. This is typically used to exclude the
+// traffic that results from inspecting the recorded traffic.
+func (r *Recorder) Exclude(method string) {
+	r.lock.Lock()
+	r.excluded[method] = true
+	r.lock.Unlock()
+}
+
+// Start starts the recording of messages. Recording continues until each call
+// to Start has been matched by a call to Stop.
+func (r *Recorder) Start() {
+	r.lock.Lock()
+	r.recorders++
+	r.lock.Unlock()
+}
+
+// Stop undoes a previous call to Start. The entries already recorded are
+// kept, but the responses to the requests recorded are not recorded once
+// recording has stopped.
+func (r *Recorder) Stop() {
+	r.lock.Lock()
+	if r.recorders > 0 {
+		r.recorders--
+	}
+	if r.recorders == 0 {
+		r.pending = make(map[pendingKey]pending)
+	}
+	r.lock.Unlock()
+}
+
+// Recording reports whether messages are being recorded. Callers can use it to
+// avoid the work of describing a message that will not be recorded.
+func (r *Recorder) Recording() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.recorders > 0
+}
+
+// Request records a request for method sent or received by govim. id
+// identifies the request within sub and dir; if it is empty a unique id is
+// allocated. Request returns the id for use in the corresponding call to
+// Response. The response to a request is only recorded if the request was.
+func (r *Recorder) Request(sub logging.Subsystem, dir Direction, id, method string, params interface{}) string {
+	now := time.Now()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if id == "" {
+		r.nextID++
+		id = strconv.FormatUint(r.nextID, 10)
+	}
+	if r.recorders == 0 || r.excluded[method] {
+		return id
+	}
+	if len(r.pending) >= maxPending {
+		r.dropOldestPending()
+	}
+	r.pending[pendingKey{sub: sub, dir: dir, id: id}] = pending{method: method, start: now}
+	r.add(Entry{
+		Time:      now,
+		Subsystem: sub,
+		Direction: dir,
+		Kind:      Request,
+		ID:        id,
+		Method:    method,
+		Summary:   summarise(params),
+	})
+	return id
+}
+
+// Response records the response to the request identified by id, if that
+// request was recorded. dir is the direction of the response, which is
+// opposite to that of the request.
+func (r *Recorder) Response(sub logging.Subsystem, dir Direction, id string, err error, result interface{}) {
+	now := time.Now()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	key := pendingKey{sub: sub, dir: dir.opposite(), id: id}
+	p, ok := r.pending[key]
+	if !ok {
+		return
+	}
+	delete(r.pending, key)
+	e := Entry{
+		Time:      now,
+		Subsystem: sub,
+		Direction: dir,
+		Kind:      Response,
+		ID:        id,
+		Method:    p.method,
+		Latency:   now.Sub(p.start),
+	}
+	if err != nil {
+		e.Err = err.Error()
+	} else {
+		e.Summary = summarise(result)
+	}
+	r.add(e)
+}
+
+// Notification records a notification for method sent or received by govim
+func (r *Recorder) Notification(sub logging.Subsystem, dir Direction, method string, params interface{}) {
+	now := time.Now()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.recorders == 0 || r.excluded[method] {
+		return
+	}
+	r.add(Entry{
+		Time:      now,
+		Subsystem: sub,
+		Direction: dir,
+		Kind:      Notification,
+		Method:    method,
+		Summary:   summarise(params),
+	})
+}
+
+// dropOldestPending stops tracking the oldest pending request. It must be
+// called with r.lock held.
+func (r *Recorder) dropOldestPending() {
+	var oldest pendingKey
+	var start time.Time
+	for k, p := range r.pending {
+		if start.IsZero() || p.start.Before(start) {
+			oldest, start = k, p.start
+		}
+	}
+	delete(r.pending, oldest)
+}
+
+// add must be called with r.lock held
+func (r *Recorder) add(e Entry) {
+	if cap(r.entries) == 0 {
+		return
+	}
+	r.seq++
+	e.Seq = r.seq
+	if len(r.entries) < cap(r.entries) {
+		r.entries = append(r.entries, e)
+		return
+	}
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+}
+
+// Entries returns the entries still held by r with a sequence number greater
+// than since, oldest first.
+func (r *Recorder) Entries(since uint64) []Entry {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var res []Entry
+	for i := range r.entries {
+		e := r.entries[(r.next+i)%len(r.entries)]
+		if e.Seq > since {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+func summarise(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	var byts []byte
+	switch v := v.(type) {
+	case json.RawMessage:
+		byts = v
+	case []byte:
+		byts = v
+	default:
+		var err error
+		byts, err = json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+	}
+	if len(byts) > maxSummary {
+		return string(byts[:maxSummary]) + "..."
+	}
+	return string(byts)
+}
@@ -0,0 +1,120 @@
+package traffic
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/govim/govim/internal/logging"
+)
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder(3)
+	r.Start()
+	id := r.Request(logging.GoplsServer, Send, "", "textDocument/hover", map[string]int{"line": 1})
+	r.Notification(logging.Channel, Recv, "log", []string{"hello"})
+	r.Request(logging.Channel, Send, "7", "call", []string{"bufnr"})
+	r.Response(logging.Channel, Recv, "7", nil, 1)
+	r.Response(logging.GoplsServer, Recv, id, errors.New("boom"), nil)
+
+	got := r.Entries(0)
+	if len(got) != 3 {
+		t.Fatalf("got %v entries; want 3: %v", len(got), got)
+	}
+	if got[0].Seq != 3 || got[2].Seq != 5 {
+		t.Errorf("got entries %v to %v; want 3 to 5", got[0].Seq, got[2].Seq)
+	}
+	resp := got[1]
+	if resp.Kind != Response || resp.Method != "call" || resp.Summary != "1" {
+		t.Errorf("unexpected channel response: %+v", resp)
+	}
+	resp = got[2]
+	if resp.Kind != Response || resp.Method != "textDocument/hover" || resp.Err != "boom" {
+		t.Errorf("unexpected gopls response: %+v", resp)
+	}
+	if got := r.Entries(4); len(got) != 1 || got[0].Seq != 5 {
+		t.Errorf("Entries(4): got %v; want entry 5 only", got)
+	}
+}
+
+func TestRecorderUnmatchedResponse(t *testing.T) {
+	r := NewRecorder(10)
+	r.Start()
+	r.Request(logging.Channel, Recv, "1", "function:GOVIMHover", nil)
+	// A response in the same direction as the request does not match it, and
+	// is not recorded
+	r.Response(logging.Channel, Recv, "1", nil, nil)
+	// Nor is a response to a request that was not recorded
+	r.Response(logging.Channel, Send, "2", nil, nil)
+	got := r.Entries(0)
+	if len(got) != 1 || got[0].Kind != Request {
+		t.Errorf("unexpected entries: %+v", got)
+	}
+}
+
+func TestRecorderPending(t *testing.T) {
+	r := NewRecorder(10)
+	// Requests are only tracked whilst recording
+	r.Request(logging.GoplsServer, Send, "", "textDocument/hover", nil)
+	if len(r.pending) != 0 {
+		t.Errorf("tracked %v requests whilst not recording", len(r.pending))
+	}
+	r.Start()
+	for i := 0; i < maxPending+10; i++ {
+		r.Request(logging.GoplsServer, Send, "", "textDocument/hover", nil)
+	}
+	if len(r.pending) != maxPending {
+		t.Errorf("tracked %v requests; want %v", len(r.pending), maxPending)
+	}
+	r.Stop()
+	if len(r.pending) != 0 {
+		t.Errorf("tracked %v requests after recording stopped", len(r.pending))
+	}
+}
+
+func TestRecorderExclude(t *testing.T) {
+	r := NewRecorder(10)
+	r.Start()
+	r.Exclude("function:GOVIM_internal_InspectorEntries")
+	r.Request(logging.Channel, Recv, "1", "function:GOVIM_internal_InspectorEntries", nil)
+	r.Request(logging.Channel, Recv, "2", "function:GOVIMHover", nil)
+	r.Response(logging.Channel, Send, "1", nil, []string{"row"})
+	r.Response(logging.Channel, Send, "2", nil, "hover")
+	got := r.Entries(0)
+	if len(got) != 2 || got[0].Method != "function:GOVIMHover" || got[1].Method != "function:GOVIMHover" {
+		t.Errorf("unexpected entries: %+v", got)
+	}
+}
+
+func TestRecorderStartStop(t *testing.T) {
+	r := NewRecorder(10)
+	r.Notification(logging.Channel, Recv, "log", []string{"before"})
+	r.Request(logging.Channel, Send, "1", "call", []string{"bufnr"})
+	r.Start()
+	r.Start()
+	// The response to a request made before recording started is not
+	// recorded
+	r.Response(logging.Channel, Recv, "1", nil, 1)
+	r.Notification(logging.Channel, Recv, "log", []string{"during"})
+	r.Stop()
+	r.Notification(logging.Channel, Recv, "log", []string{"still"})
+	r.Stop()
+	r.Notification(logging.Channel, Recv, "log", []string{"after"})
+	if r.Recording() {
+		t.Errorf("still recording after matching calls to Stop")
+	}
+	got := r.Entries(0)
+	if len(got) != 2 || got[0].Summary != `["during"]` || got[1].Summary != `["still"]` {
+		t.Errorf("unexpected entries: %+v", got)
+	}
+}
+
+func TestRecorderZeroSize(t *testing.T) {
+	r := NewRecorder(0)
+	r.Start()
+	id := r.Request(logging.GoplsServer, Send, "", "textDocument/hover", nil)
+	r.Response(logging.GoplsServer, Recv, id, nil, nil)
+	r.Notification(logging.Channel, Recv, "log", nil)
+	if got := r.Entries(0); len(got) != 0 {
+		t.Errorf("unexpected entries: %+v", got)
+	}
+}
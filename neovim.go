@@ -0,0 +1,179 @@
+package govim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/govim/govim/internal/msgpack"
+	"gopkg.in/tomb.v2"
+)
+
+// NewNeovim creates a new govim instance for plug that communicates with
+// Neovim via msgpack-RPC over in and out. The Neovim side of the connection
+// is the glue in autoload/govim/nvim.vim, which takes the place of
+// plugin/govim.vim; the resulting Govim instance is otherwise the same as
+// that returned by NewGovim.
+//
+// Note that features of Vim that Neovim does not have, e.g. popups, text
+// properties and listeners, are not available to plugins via Neovim.
+func NewNeovim(plug Plugin, in io.Reader, out io.Writer, log io.Writer, logFile *os.File, t *tomb.Tomb) (Govim, error) {
+	g := newGovim(plug, newMsgpackTransport(in, out), log, logFile, t)
+	g.flavor = FlavorNeovim
+	return g, nil
+}
+
+// msgpack-RPC message types. See
+// https://github.com/msgpack-rpc/msgpack-rpc/blob/master/spec.md
+const (
+	msgpackRequest      = 0
+	msgpackResponse     = 1
+	msgpackNotification = 2
+)
+
+// msgpackRPCMethod is the method with which the Neovim glue makes requests
+// of govim, and nvimHandler the function via which govim makes requests of
+// Neovim
+const (
+	msgpackRPCMethod = "govim"
+	nvimHandler      = "govim#nvim#Handle"
+)
+
+// msgpackTransport maps the messages of a Vim channel onto Neovim's
+// msgpack-RPC API.
+//
+// A request from Neovim, made via rpcrequest(), carries the message as its
+// only parameter; it is read with an id one more than its msgpack-RPC msgid,
+// so that the id is positive. A message from govim with an id of 0 is a call
+// of the form [id, type, ...], which is sent as a request to call
+// nvimHandler with the message; Neovim's response to that request is read as
+// the ["callback", id, [err, val]] message that Vim would have sent.
+type msgpackTransport struct {
+	in  *msgpack.Decoder
+	out *msgpack.Encoder
+}
+
+func newMsgpackTransport(in io.Reader, out io.Writer) *msgpackTransport {
+	return &msgpackTransport{
+		in:  msgpack.NewDecoder(in),
+		out: msgpack.NewEncoder(out),
+	}
+}
+
+func (t *msgpackTransport) read() (int, json.RawMessage, error) {
+	v, err := t.in.Decode()
+	if err != nil {
+		return 0, nil, err
+	}
+	msg, ok := v.([]interface{})
+	if !ok || len(msg) == 0 {
+		return 0, nil, fmt.Errorf("invalid msgpack-RPC message %v", v)
+	}
+	var id int
+	var payload interface{}
+	switch typ, _ := msg[0].(int64); {
+	case typ == msgpackRequest && len(msg) == 4:
+		msgid, ok := msg[1].(int64)
+		if !ok {
+			return 0, nil, fmt.Errorf("invalid msgid in msgpack-RPC request %v", msg)
+		}
+		p, err := rpcParam(msg[2], msg[3])
+		if err != nil {
+			return 0, nil, err
+		}
+		id, payload = int(msgid)+1, p
+	case typ == msgpackNotification && len(msg) == 3:
+		p, err := rpcParam(msg[1], msg[2])
+		if err != nil {
+			return 0, nil, err
+		}
+		payload = p
+	case typ == msgpackResponse && len(msg) == 4:
+		resp := msg[3]
+		if msg[2] != nil {
+			resp = []interface{}{nvimErrString(msg[2])}
+		}
+		payload = []interface{}{"callback", msg[1], resp}
+	default:
+		return 0, nil, fmt.Errorf("invalid msgpack-RPC message %v", msg)
+	}
+	byts, err := json.Marshal(toJSONValue(payload))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to convert msgpack-RPC message %v to JSON: %v", msg, err)
+	}
+	return id, byts, nil
+}
+
+func (t *msgpackTransport) write(id int, payload interface{}) error {
+	// Round-trip the payload via JSON so that it is encoded exactly as it
+	// would be for Vim, e.g. respecting json struct tags
+	byts, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(byts))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	if id != 0 {
+		return t.out.Encode([]interface{}{msgpackResponse, id - 1, nil, v})
+	}
+	call, ok := v.([]interface{})
+	if !ok || len(call) == 0 {
+		return fmt.Errorf("invalid call to Neovim %s", byts)
+	}
+	return t.out.Encode([]interface{}{msgpackRequest, call[0], "nvim_call_function", []interface{}{nvimHandler, []interface{}{v}}})
+}
+
+// rpcParam returns the single parameter of a request or notification of
+// msgpackRPCMethod
+func rpcParam(method, params interface{}) (interface{}, error) {
+	if method != msgpackRPCMethod {
+		return nil, fmt.Errorf("unknown msgpack-RPC method %v", method)
+	}
+	ps, ok := params.([]interface{})
+	if !ok || len(ps) != 1 {
+		return nil, fmt.Errorf("invalid params for msgpack-RPC method %v: %v", method, params)
+	}
+	return ps[0], nil
+}
+
+// nvimErrString returns the message of an error in a msgpack-RPC response
+// from Neovim, which takes the form [type, message]
+func nvimErrString(err interface{}) string {
+	if e, ok := err.([]interface{}); ok && len(e) == 2 {
+		if s, ok := e[1].(string); ok {
+			return s
+		}
+	}
+	return fmt.Sprint(err)
+}
+
+// toJSONValue converts a value decoded from msgpack to a value that can be
+// encoded as JSON. Binary strings become strings and, as for the buffer,
+// window and tab page handles that Neovim encodes as extension types,
+// extension values become their integer value.
+func toJSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case msgpack.Ext:
+		if i, err := v.Int(); err == nil {
+			return i
+		}
+		return nil
+	case []interface{}:
+		for i, vv := range v {
+			v[i] = toJSONValue(vv)
+		}
+	case map[string]interface{}:
+		for k, vv := range v {
+			v[k] = toJSONValue(vv)
+		}
+	}
+	return v
+}
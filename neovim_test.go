@@ -0,0 +1,85 @@
+package govim
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/govim/govim/internal/msgpack"
+)
+
+func TestMsgpackTransportRead(t *testing.T) {
+	var in bytes.Buffer
+	enc := msgpack.NewEncoder(&in)
+	msgs := []interface{}{
+		// a call of a function defined by govim
+		[]interface{}{0, 4, "govim", []interface{}{[]interface{}{"function", "function:Hello", []interface{}{msgpack.Ext{Type: 0, Data: []byte{1}}}}}},
+		// a response to a call from govim
+		[]interface{}{1, 7, nil, []interface{}{"", []byte("val")}},
+		// a failed call from govim
+		[]interface{}{1, 8, []interface{}{0, "boom"}, nil},
+		// a notification
+		[]interface{}{2, "govim", []interface{}{[]interface{}{"shutdown"}}},
+	}
+	for _, m := range msgs {
+		if err := enc.Encode(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tr := newMsgpackTransport(&in, nil)
+	want := []struct {
+		id      int
+		payload string
+	}{
+		{5, `["function","function:Hello",[1]]`},
+		{0, `["callback",7,["","val"]]`},
+		{0, `["callback",8,["boom"]]`},
+		{0, `["shutdown"]`},
+	}
+	for _, w := range want {
+		id, payload, err := tr.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != w.id || string(payload) != w.payload {
+			t.Errorf("got [%v, %s]; want [%v, %s]", id, payload, w.id, w.payload)
+		}
+	}
+
+	in.Reset()
+	enc.Encode([]interface{}{0, 1, "nvim_buf_attach", []interface{}{}})
+	if _, _, err := tr.read(); err == nil {
+		t.Errorf("expected an error reading a request for an unknown method")
+	}
+}
+
+func TestMsgpackTransportWrite(t *testing.T) {
+	var out bytes.Buffer
+	tr := newMsgpackTransport(nil, &out)
+	if err := tr.write(0, []interface{}{3, "call", "bufnr", struct {
+		Name string `json:"name"`
+	}{"x"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.write(5, [2]interface{}{"", 1.5}); err != nil {
+		t.Fatal(err)
+	}
+	dec := msgpack.NewDecoder(&out)
+	want := []interface{}{
+		[]interface{}{int64(0), int64(3), "nvim_call_function", []interface{}{
+			"govim#nvim#Handle", []interface{}{
+				[]interface{}{int64(3), "call", "bufnr", map[string]interface{}{"name": "x"}},
+			},
+		}},
+		[]interface{}{int64(1), int64(4), nil, []interface{}{"", 1.5}},
+	}
+	for _, w := range want {
+		got, err := dec.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("got %#v; want %#v", got, w)
+		}
+	}
+}
@@ -4,6 +4,19 @@ if exists("g:govimpluginloaded")
 endif
 let g:govimpluginloaded=1
 
+" Neovim does not have Vim's channels; the glue in autoload/govim/nvim.vim
+" talks to govim via msgpack-RPC instead. Neovim lacks features of Vim that
+" govim relies on, so govim itself does not yet run in Neovim: the glue is
+" only used to test the plugin API against Neovim, where the testdriver sets
+"
+"   let g:govim_neovim = 1
+if has("nvim")
+  if get(g:, "govim_neovim", 0)
+    call govim#nvim#Start(expand("<sfile>:p:h:h"))
+  endif
+  finish
+endif
+
 augroup govim
 augroup END
 
@@ -48,12 +61,15 @@ if index(split(system("echo -n ${GOVIM_LOG-on}"), ","), "on") >= 0
   call ch_logfile(s:ch_logfile, "a")
 endif
 let s:channel = ""
+let s:job = v:null
 let s:timer = ""
 let s:plugindir = expand(expand("<sfile>:p:h:h"))
 
 let s:govim_status = "loading"
 let s:loadStatusCallbacks = []
 
+let s:statusline = {"workspace": {}, "buffers": {}, "busy": v:false, "view": "", "lastProgressTitle": ""}
+
 let s:userBusy = 0
 
 set ballooneval
@@ -64,12 +80,16 @@ let s:scheduleBacklog = []
 let s:activeGovimCalls = 0
 augroup govimScheduler
 
+" s:listenerTargets are the govim functions to which changes in buffers are
+" sent, via s:enrichDelta; see s:listenerAdd
+let s:listenerTargets = {}
+
 function s:ch_evalexpr(args)
-  " For all callbacks to govim (other than the handler ultimately responsible
-  " for a listener_add callback) we need to flush any pending delta
+  " For all callbacks to govim (other than the handlers ultimately responsible
+  " for listener_add callbacks) we need to flush any pending delta
   " notifications so that govim isn't ever working with stale buffer
   " contents
-  if a:args[0] != "function" || a:args[1] != "function:GOVIM_internal_BufChanged"
+  if a:args[0] != "function" || !has_key(s:listenerTargets, a:args[1])
     call listener_flush()
   endif
   if s:minVimSafeState
@@ -128,6 +148,7 @@ function s:drainScheduleBacklog(drop)
   else
     call ch_log("old safe state: will drain schedule backlog; no pending calls")
   endif
+  let l:ran = 0
   while len(s:scheduleBacklog) > 0
     if s:minVimSafeState
       call ch_log("drainScheduleBacklog: running work from the queue with state: ".string(state()))
@@ -135,8 +156,14 @@ function s:drainScheduleBacklog(drop)
     let l:args = ["schedule", s:scheduleBacklog[0]]
     let s:scheduleBacklog = s:scheduleBacklog[1:]
     let l:resp = s:ch_evalexpr(l:args)
+    let l:ran = 1
   endwhile
   let s:waitingToDrain = 0
+  " Scheduled work can change the viewport without triggering the autocommands
+  " that push changes in it, e.g. by calling cursor()
+  if l:ran && s:viewportSubscribed
+    call s:viewportChanged()
+  endif
 endfunction
 
 function s:callbackFunction(name, args)
@@ -155,6 +182,48 @@ function s:callbackCommand(name, flags, ...)
   return s:ch_evalexpr(l:args)
 endfunction
 
+" s:vim9 is set by s:enableVim9, which govim calls when the Vim to which it
+" is connected is recent enough to use Vim9 script: functions are then defined
+" as Vim9 def functions, and changes in buffers are sent to govim as
+" notifications
+let s:vim9 = 0
+
+function s:enableVim9()
+  let s:vim9 = 1
+endfunction
+
+" The Vim9 script of the 8.2 patches that have it is not that of Vim 9, hence
+" the check of v:version rather than has("vim9script"). govim checks the same
+" version before calling s:enableVim9
+if v:version >= 900
+  " s:callbackFunction9 is the Vim9 counterpart of s:callbackFunction, via
+  " which functions defined by s:defineFunction9 call govim. The Vim
+  " versions that have Vim9 script all have SafeState, so there is no need
+  " to track active calls
+  def s:callbackFunction9(name: string, args: list<any>): any
+    if !has_key(s:listenerTargets, "function:" .. name)
+      listener_flush()
+    endif
+    var resp = ch_evalexpr(s:channel, ["function", "function:" .. name, args])
+    if resp[0] != ""
+      throw resp[0]
+    endif
+    return resp[1]
+  enddef
+
+  " s:notify calls the govim function name with args without waiting for
+  " the result; an error is reported by s:notifyDone
+  def s:notify(name: string, args: list<any>)
+    ch_sendexpr(s:channel, ["function", "function:" .. name, args], {callback: s:notifyDone})
+  enddef
+
+  def s:notifyDone(ch: channel, resp: list<any>)
+    if resp[0] != ""
+      echoerr resp[0]
+    endif
+  enddef
+endif
+
 function s:callbackAutoCommand(name, def, exprs)
   " When govim is the process of loading, i.e. its Init(Govim) method is
   " called, we make a number of calls to Vim to register functions, commands
@@ -176,14 +245,40 @@ function s:callbackAutoCommand(name, def, exprs)
   return s:ch_evalexpr(l:args)
 endfunction
 
+" s:shutdownTimeout is the time in milliseconds that Vim waits for govim to
+" shut down: for its shutdown request to complete, and then for the govim
+" process to exit. govim stops gopls and the other language servers
+" concurrently, giving each at most twice goplsStopTimeout (2s) to respond and
+" then exit, so this must comfortably exceed 4s.
+let s:shutdownTimeout = 10000
+
 function s:doShutdown()
   if s:govim_status != "loaded" && s:govim_status != "initcomplete"
     " TODO: anything to do here other than return?
     return
   endif
-  let l:shutdownRes = s:ch_evalexpr(["shutdown"])
-  call ch_log("shutdown complete: ".string(l:shutdownRes))
+  " Flush pending changes in all buffers, not just the current one, so that
+  " govim does not miss changes during shutdown
+  for l:b in getbufinfo({'bufloaded': 1})
+    call listener_flush(l:b.bufnr)
+  endfor
+  let l:start = reltime()
+  let l:shutdownRes = ch_evalexpr(s:channel, ["shutdown"], {"timeout": s:shutdownTimeout})
+  if type(l:shutdownRes) != v:t_list
+    call ch_log("shutdown timed out after ".s:shutdownTimeout."ms")
+  elseif l:shutdownRes[0] != ""
+    call ch_log("shutdown failed: ".l:shutdownRes[0])
+  else
+    call ch_log("shutdown complete")
+  endif
   call ch_close(s:channel)
+  if s:job is v:null
+    return
+  endif
+  " govim exits once the channel is closed; wait for it to do so
+  while job_status(s:job) == "run" && reltimefloat(reltime(l:start))*1000 < s:shutdownTimeout
+    sleep 10m
+  endwhile
 endfunction
 
 function s:buildCurrentViewport()
@@ -201,10 +296,106 @@ function s:buildCurrentViewport()
   let l:viewport = {
         \ 'Current': l:currWin,
         \ 'Windows': l:windows,
+        \ 'Cursor': {'bufnr': bufnr(""), 'line': line("."), 'col': col(".")},
         \ }
   return l:viewport
 endfunction
 
+" s:viewportDebounce is the time in milliseconds for which the viewport must
+" be still before a change to it is pushed to govim
+let s:viewportDebounce = 50
+let s:viewportTimer = -1
+let s:viewport = {}
+let s:viewportSubscribed = 0
+
+" s:subscribeViewport starts pushing changes in the viewport to govim, and
+" returns the viewport to which those changes apply
+function s:subscribeViewport()
+  let l:vp = s:buildCurrentViewport()
+  let s:viewport = s:viewportState(l:vp)
+  let s:viewportSubscribed = 1
+  augroup govimViewport
+    au!
+    au BufEnter,CursorMoved,WinEnter * call s:viewportChanged()
+    if exists("##WinScrolled")
+      au WinScrolled * call s:viewportChanged()
+    endif
+  augroup END
+  return l:vp
+endfunction
+
+" s:unsubscribeViewport stops pushing changes in the viewport to govim
+function s:unsubscribeViewport()
+  let s:viewportSubscribed = 0
+  if s:viewportTimer != -1
+    call timer_stop(s:viewportTimer)
+    let s:viewportTimer = -1
+  endif
+  augroup govimViewport
+    au!
+  augroup END
+  augroup! govimViewport
+  let s:viewport = {}
+endfunction
+
+" s:viewportState returns the viewport vp keyed by window id, the form in
+" which it is compared to find what has changed
+function s:viewportState(vp)
+  let l:windows = {}
+  for l:w in a:vp.Windows
+    let l:windows[l:w.winid] = l:w
+  endfor
+  return {"current": get(a:vp.Current, "winid", 0), "windows": l:windows, "cursor": a:vp.Cursor}
+endfunction
+
+" s:viewportChanged (re)starts the timer after which the change is pushed
+function s:viewportChanged()
+  if s:viewportTimer != -1
+    call timer_stop(s:viewportTimer)
+  endif
+  let s:viewportTimer = timer_start(s:viewportDebounce, function("s:pushViewport"))
+endfunction
+
+" s:pushViewport pushes to govim what has changed in the viewport since it
+" was last pushed. The push is a notification, to which govim does not
+" respond.
+function s:pushViewport(timer)
+  let s:viewportTimer = -1
+  if !s:viewportSubscribed || (s:govim_status != "loaded" && s:govim_status != "initcomplete")
+    return
+  endif
+  let l:new = s:viewportState(s:buildCurrentViewport())
+  let l:delta = {}
+  if l:new.current != s:viewport.current
+    let l:delta.Current = l:new.current
+  endif
+  let l:changed = []
+  for [l:id, l:w] in items(l:new.windows)
+    if get(s:viewport.windows, l:id, {}) != l:w
+      call add(l:changed, l:w)
+    endif
+  endfor
+  if len(l:changed) > 0
+    let l:delta.Windows = l:changed
+  endif
+  let l:closed = []
+  for l:id in keys(s:viewport.windows)
+    if !has_key(l:new.windows, l:id)
+      call add(l:closed, str2nr(l:id))
+    endif
+  endfor
+  if len(l:closed) > 0
+    let l:delta.Closed = l:closed
+  endif
+  if l:new.cursor != s:viewport.cursor
+    let l:delta.Cursor = l:new.cursor
+  endif
+  let s:viewport = l:new
+  if l:delta != {}
+    call ch_sendexpr(s:channel, ["viewport", l:delta])
+  endif
+endfunction
+
 function GOVIMPluginStatus(...)
   if s:govim_status != "loaded" && s:govim_status != "failed" && len(a:000) != 0
     call extend(s:loadStatusCallbacks, a:000)
@@ -212,6 +403,46 @@ function GOVIMPluginStatus(...)
   return s:govim_status
 endfunction
 
+" GOVIMStatusline returns a dict describing the state of govim and gopls,
+" intended for use in statuslines. It does not call govim, so it is cheap to
+" call on every redraw. The dict has the following keys:
+"
+"   buffer            - the number of diagnostics per severity ("error",
+"                       "warning", "info" and "hint") in the buffer
+"   workspace         - the number of diagnostics per severity across the
+"                       workspace
+"   busy              - whether gopls is reporting progress on a task
+"   view              - the root directory of the gopls workspace folder
+"   lastProgressTitle - the title of the most recent gopls progress
+"
+" The buffer is given by the optional argument, defaulting to the buffer of
+" the window whose statusline is being drawn, or the current buffer. The User
+" autocommand GOVIMStatusChanged fires whenever the state changes.
+function GOVIMStatusline(...)
+  if len(a:000) > 0
+    let l:bufnr = a:1
+  elseif exists("g:statusline_winid")
+    let l:bufnr = winbufnr(g:statusline_winid)
+  else
+    let l:bufnr = bufnr("")
+  endif
+  let l:counts = {"error": 0, "warning": 0, "info": 0, "hint": 0}
+  return {
+        \ "buffer": copy(get(s:statusline.buffers, string(l:bufnr), l:counts)),
+        \ "workspace": extend(copy(l:counts), s:statusline.workspace),
+        \ "busy": s:statusline.busy,
+        \ "view": s:statusline.view,
+        \ "lastProgressTitle": s:statusline.lastProgressTitle,
+        \ }
+endfunction
+
+function GOVIM_internal_SetStatusline(status)
+  let s:statusline = a:status
+  if exists("#User#GOVIMStatusChanged")
+    doautocmd <nomodeline> User GOVIMStatusChanged
+  endif
+endfunction
+
 function s:userBusy(busy)
   if s:userBusy != a:busy
     let s:userBusy = a:busy
@@ -337,6 +568,11 @@ func s:defineCommand(name, attrs)
 endfunction
 
 func s:defineFunction(name, argsStr, range)
+  " Vim9 def functions cannot take a range
+  if s:vim9 && !a:range
+    call s:defineFunction9(a:name, a:argsStr)
+    return
+  endif
   let l:params = join(a:argsStr, ", ")
   let l:args = "let l:args = []\n"
   if len(a:argsStr) == 1 && a:argsStr[0] == "..."
@@ -367,6 +603,32 @@ func s:defineFunction(name, argsStr, range)
         \ "endfunction\n"
 endfunction
 
+" s:defineFunction9 is the Vim9 counterpart of s:defineFunction. The
+" parameters are named by position rather than by argsStr, because a name
+" that is valid in legacy script need not be so in Vim9 script
+func s:defineFunction9(name, argsStr)
+  let l:params = []
+  let l:args = []
+  for i in a:argsStr
+    if i == "..."
+      call add(l:params, "...rest: list<any>")
+      call add(l:args, "rest")
+    else
+      let l:param = "p".(len(l:params)+1)
+      call add(l:params, l:param.": any")
+      call add(l:args, l:param)
+    endif
+  endfor
+  if a:argsStr == ["..."]
+    let l:args = "rest"
+  else
+    let l:args = "[".join(l:args, ", ")."]"
+  endif
+  execute "def! " . a:name . "(" . join(l:params, ", ") . "): any\n" .
+        \ "return s:callbackFunction9(\"" . a:name . "\", " . l:args . ")\n" .
+        \ "enddef\n"
+endfunction
+
 function s:govimExit(job, exitstatus)
   if a:exitstatus != 0
     let s:govim_status = "failed"
@@ -394,49 +656,158 @@ func s:logFilePaths()
   endif
 endfunction
 
+" GOVIMLogs {govim|gopls|channel} [pattern] opens the named log in a new
+" window that follows the log as it grows. If a pattern is given, only the
+" lines that match it are shown. The govim log is written as one JSON object
+" per line by default, so for example:
+"
+"   :GOVIMLogs govim "subsystem":"gopls-client"
+"
+" shows only the messages of the gopls client.
+command -bar -nargs=+ -complete=customlist,s:logsComplete GOVIMLogs call s:logs(<f-args>)
 
-function s:install(force)
-  let oldpath = getcwd()
-  execute "cd ".s:plugindir
-
-  " Note that we use "env -i" to use git with an empty environment.
-  " Otherwise, using vim with govim as the editor in "git commit" will break,
-  " as "git commit" will set GIT_DIR, which "git rev-parse" will follow.
-  " We'd then end up with the user's in-progress commit, not govim's HEAD.
-  " TODO: make work on Windows
-  let commit = trim(system("env -i git rev-parse HEAD 2>&1"))
-  if v:shell_error
-    throw commit
-  endif
-
-  let goversion = trim(system("go version"))
-  if v:shell_error
-    throw goversion
-  endif
-
-  let targetdir = s:plugindir."/cmd/govim/.bin/".sha256(commit.goversion)."/"
-  if $GOVIM_DEBUG_INSTALL == "true"
-	  " The first line may directly follow a "hint" message from Vim.
-	  echo "\n"
-	  echom "oldpath: ".oldpath
-	  echom "plugindir: ".s:plugindir
-	  echom "commit: ".commit
-	  echom "force: ".a:force
-	  echom "always install: ".$GOVIM_ALWAYS_INSTALL
-	  echom "govim readable: ".filereadable(targetdir."govim")
-	  echom "gopls readable: ".filereadable(targetdir."gopls")
-  endif
-  if a:force || $GOVIM_ALWAYS_INSTALL == "true" || !filereadable(targetdir."govim") || !filereadable(targetdir."gopls")
-    echom "Installing govim and gopls"
-    call feedkeys(" ") " to prevent press ENTER to continue
-    " TODO: make work on Windows
-    let install = system("env GO111MODULE=on GOBIN=".shellescape(targetdir)." GOWORK=off go install github.com/govim/govim/cmd/govim golang.org/x/tools/gopls 2>&1")
-    if v:shell_error
-      throw install
-    endif
+let s:logsInterval = 1000
+
+func s:logsComplete(argLead, cmdLine, cursorPos)
+  if cmdLine[:cursorPos-1] =~ '^\S\+\s\+\S*$'
+    return filter(["govim", "gopls", "channel"], 'v:val =~ "^".a:argLead')
   endif
-  execute "cd ".oldpath
-  return targetdir
+  return []
+endfunction
+
+func s:logs(name, ...)
+  if !exists("s:ch_logfile")
+    echoerr "govim logging turned off; enable by setting GOVIM_LOG=on"
+    return
+  endif
+  let l:files = {"govim": s:govim_logfile, "gopls": s:gopls_logfile, "channel": s:ch_logfile}
+  if !has_key(l:files, a:name)
+    echoerr "unknown log ".string(a:name)."; valid logs are govim, gopls and channel"
+    return
+  endif
+  let l:file = l:files[a:name]
+  if !filereadable(l:file)
+    echoerr "the ".a:name." log is not available"
+    return
+  endif
+  new
+  setlocal buftype=nofile bufhidden=wipe noswapfile nobuflisted nomodifiable
+  let b:govim_log = {"file": l:file, "pattern": join(a:000, " "), "offset": 0, "tail": tempname()}
+  execute "silent file ".fnameescape("[govim ".a:name." log".(b:govim_log.pattern == "" ? "" : " /".b:govim_log.pattern."/")."]")
+  let l:bufnr = bufnr("")
+  call s:logsRefresh(l:bufnr, 0)
+  let b:govim_log.timer = timer_start(s:logsInterval, function("s:logsRefresh", [l:bufnr]), {"repeat": -1})
+  autocmd BufWipeout <buffer> call s:logsClose(str2nr(expand("<abuf>")))
+endfunction
+
+func s:logsClose(bufnr)
+  let l:log = getbufvar(a:bufnr, "govim_log")
+  call timer_stop(l:log.timer)
+  call delete(l:log.tail)
+endfunction
+
+" s:logsRefresh appends to the log window of buffer bufnr the lines that have
+" been added to its log since the last refresh. govim reads only the end of
+" the log that has been added since, tracked via the byte offset of the first
+" line that has not been shown, and writes its complete lines to the file
+" log.tail. A line that has not been completely written yet is shown on a
+" later refresh. When the log is rotated, the window starts again from the
+" beginning of the new log. A window that is not visible is refreshed once it
+" is visible again.
+func s:logsRefresh(bufnr, timer)
+  let l:log = getbufvar(a:bufnr, "govim_log", {})
+  let l:winid = bufwinid(a:bufnr)
+  if l:log == {} || l:winid == -1 || getfsize(l:log.file) == l:log.offset || !exists("*GOVIM_internal_LogTail")
+    return
+  endif
+  let l:res = GOVIM_internal_LogTail(l:log.file, l:log.offset, l:log.tail)
+  let l:log.offset = l:res.offset
+  if l:res.reset
+    call setbufvar(a:bufnr, "&modifiable", 1)
+    call deletebufline(a:bufnr, 1, "$")
+    call setbufvar(a:bufnr, "&modifiable", 0)
+  endif
+  let l:new = readfile(l:log.tail)
+  if l:log.pattern != ""
+    call filter(l:new, 'v:val =~ l:log.pattern')
+  endif
+  if len(l:new) == 0
+    return
+  endif
+  let l:follow = line(".", l:winid) == line("$", l:winid)
+  call setbufvar(a:bufnr, "&modifiable", 1)
+  if getbufline(a:bufnr, 1, "$") == [""]
+    call setbufline(a:bufnr, 1, l:new)
+  else
+    call appendbufline(a:bufnr, "$", l:new)
+  endif
+  call setbufvar(a:bufnr, "&modifiable", 0)
+  if l:follow
+    call win_execute(l:winid, "normal! G")
+  endif
+endfunction
+
+" GOVIMInspector [pattern] opens a new window with a live table of the
+" messages exchanged between Vim and govim, and between govim and gopls. Each
+" response shows the latency of its round trip; responses that took 100ms or
+" more are marked with a "!" in the first column. If a pattern (a Go regular
+" expression) is given, only the rows that match it are shown, e.g.
+"
+"   :GOVIMInspector gopls-server.*textDocument/hover
+command -bar -nargs=? GOVIMInspector call s:inspector(<q-args>)
+
+func s:inspector(pattern)
+  if !exists("*GOVIM_internal_InspectorEntries")
+    echoerr "govim has not yet initialized"
+    return
+  endif
+  " Traffic is only recorded whilst an inspector is open. Starting the
+  " recording validates the pattern, before a window is opened.
+  let l:header = GOVIM_internal_InspectorStart(a:pattern)
+  new
+  setlocal buftype=nofile bufhidden=wipe noswapfile nobuflisted nowrap
+  call setline(1, l:header)
+  setlocal nomodifiable
+  let b:govim_inspector = {"pattern": a:pattern, "seq": 0}
+  execute "silent file ".fnameescape("[govim inspector".(a:pattern == "" ? "" : " /".a:pattern."/")."]")
+  let l:bufnr = bufnr("")
+  call s:inspectorRefresh(l:bufnr, 0)
+  let b:govim_inspector.timer = timer_start(s:logsInterval, function("s:inspectorRefresh", [l:bufnr]), {"repeat": -1})
+  autocmd BufWipeout <buffer> call s:inspectorClose(str2nr(expand("<abuf>")))
+endfunction
+
+func s:inspectorClose(bufnr)
+  call timer_stop(getbufvar(a:bufnr, "govim_inspector").timer)
+  if s:govim_status == "initcomplete"
+    call GOVIM_internal_InspectorStop()
+  endif
+endfunction
+
+" s:inspectorRefresh appends to the inspector window of buffer bufnr the rows
+" for the messages recorded since the last refresh
+func s:inspectorRefresh(bufnr, timer)
+  let l:insp = getbufvar(a:bufnr, "govim_inspector", {})
+  if l:insp == {} || !exists("*GOVIM_internal_InspectorEntries")
+    return
+  endif
+  let l:res = GOVIM_internal_InspectorEntries(l:insp.seq, l:insp.pattern)
+  let l:insp.seq = l:res.seq
+  if len(l:res.lines) == 0
+    return
+  endif
+  let l:winid = bufwinid(a:bufnr)
+  let l:follow = l:winid == -1 || line(".", l:winid) == line("$", l:winid)
+  call setbufvar(a:bufnr, "&modifiable", 1)
+  call appendbufline(a:bufnr, "$", l:res.lines)
+  call setbufvar(a:bufnr, "&modifiable", 0)
+  if l:follow && l:winid != -1
+    call win_execute(l:winid, "normal! G")
+  endif
+endfunction
+
+
+function s:install(force)
+  return govim#install#Install(s:plugindir, a:force)
 endfunction
 
 " TODO: would be nice to be able to specify -1 as a timeout
@@ -450,19 +821,43 @@ else
     let start = targetdir."govim ".targetdir."gopls"
   endif
   let opts.exit_cb = function("s:govimExit")
-  let job = job_start(start, opts)
-  let s:channel = job_getchannel(job)
+  let s:job = job_start(start, opts)
+  let s:channel = job_getchannel(s:job)
 endif
 
-au VimLeave * call s:doShutdown()
+au VimLeavePre * call s:doShutdown()
 
-function GOVIM_internal_EnrichDelta(bufnr, start, end, added, changes)
-  for l:change in a:changes
-    let l:change.lines = getbufline(a:bufnr, l:change.lnum, l:change.end-1+l:change.added)
-  endfor
-  call GOVIM_internal_BufChanged(a:bufnr, a:start, a:end, a:added, a:changes)
+" s:listenerAdd adds a listener for changes in buffer bufnr that sends the
+" changes, with the changed lines, to the govim function target, which has
+" the parameters bufnr, start, end, added and changes, as per listener_add()
+function s:listenerAdd(target, bufnr)
+  let s:listenerTargets["function:".a:target] = 1
+  return listener_add(function("s:enrichDelta", [a:target]), a:bufnr)
 endfunction
 
+if v:version >= 900
+  " s:enrichDelta is called on every change in a buffer, so is defined as a
+  " Vim9 def function where possible; no result is needed, so once govim has
+  " enabled Vim9 the change is sent as a notification
+  def s:enrichDelta(target: string, bufnr: number, start: number, end: number, added: number, changes: list<dict<any>>)
+    for change in changes
+      change.lines = getbufline(bufnr, change.lnum, change.end - 1 + change.added)
+    endfor
+    if s:vim9
+      s:notify(target, [bufnr, start, end, added, changes])
+    else
+      s:callbackFunction9(target, [bufnr, start, end, added, changes])
+    endif
+  enddef
+else
+  function s:enrichDelta(target, bufnr, start, end, added, changes)
+    for l:change in a:changes
+      let l:change.lines = getbufline(a:bufnr, l:change.lnum, l:change.end-1+l:change.added)
+    endfor
+    call call(a:target, [a:bufnr, a:start, a:end, a:added, a:changes])
+  endfunction
+endif
+
 " s:cursorPos returns a structure that represents the current cursor position.
 " This is interpretted within (*vimstate).parseCursorPos
 function s:cursorPos()
@@ -494,6 +889,9 @@ function s:batchCall(calls)
   " For an expr:
   " c[2] is the expression to evaluate
   "
+  " The result is a dict with the results of the calls made, in order. If a
+  " call fails its assertion the calls that follow it are not made, failed is
+  " the index of the call and error the reason; otherwise failed is -1.
   let l:results = []
   for l:call in a:calls
     let l:type = l:call[0]
@@ -516,7 +914,7 @@ function s:batchCall(calls)
       endtry
       let l:check = Must(l:res, l:err)
       if !l:check[0]
-        throw "failed to call ".l:fn."(".string(l:args)."): ".l:check[1]
+        return {'results': l:results, 'failed': len(l:results), 'error': "failed to call ".l:fn."(".string(l:args)."): ".l:check[1]}
       endif
     elseif l:type == "expr"
       let l:expr = l:call[2]
@@ -529,14 +927,14 @@ function s:batchCall(calls)
       endtry
       let l:check = Must(l:res, l:err)
       if !l:check[0]
-        throw "failed to eval ".l:expr.": ".l:check[1]
+        return {'results': l:results, 'failed': len(l:results), 'error': "failed to eval ".l:expr.": ".l:check[1]}
       endif
     else
       throw "Unknown batch type: ".l:type
     endif
     call add(l:results, l:res)
   endfor
-  return l:results
+  return {'results': l:results, 'failed': -1, 'error': ''}
 endfunction
 
 function s:mustNoError()
@@ -581,6 +979,35 @@ function s:mustBeErrorOrNil(...)
   return l:args.f
 endfunction
 
+" s:popupCreate creates a popup for the popup package, passing the keys typed
+" in the popup to the govim function filter, if it is not empty
+function s:popupCreate(what, options, filter)
+  let l:options = copy(a:options)
+  if a:filter != ""
+    let l:options.filter = function("s:popupFilter", [a:filter])
+  endif
+  return popup_create(a:what, l:options)
+endfunction
+
+" s:popupKeys gives the <> notation of the keys that the widgets of the popup
+" package handle, for the versions of Vim that do not have keytrans()
+let s:popupKeys = {" ": "<Space>", "<": "<lt>", "\\": "<Bslash>", "|": "<Bar>"}
+for s:key in ["CR", "Esc", "BS", "Tab", "S-Tab", "Up", "Down", "Left", "Right",
+      \ "PageUp", "PageDown", "Home", "End", "C-B", "C-C", "C-D", "C-E", "C-F",
+      \ "C-H", "C-N", "C-P", "C-U", "C-Y"]
+  let s:popupKeys[eval('"\<'.s:key.'>"')] = "<".s:key.">"
+endfor
+unlet s:key
+
+function s:popupFilter(filter, id, key)
+  if exists("*keytrans")
+    let l:key = keytrans(a:key)
+  else
+    let l:key = get(s:popupKeys, a:key, a:key)
+  endif
+  return call(a:filter, [a:id, l:key])
+endfunction
+
 function GOVIM_internal_SuggestedFixesFilter(id, key)
     if a:key == "\<c-n>"
         GOVIMSuggestedFixes next
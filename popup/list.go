@@ -0,0 +1,120 @@
+package popup
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/govim/govim"
+	"github.com/govim/govim/vimfn"
+)
+
+// list is a list of lines in a popup, one of which is selected. It is the
+// basis of Menu, Picker and Tree, which render into a list the items from
+// which the user selects.
+type list struct {
+	base
+	m *Manager
+
+	// header is the number of lines before the items, for example the
+	// prompt of a Picker
+	header []string
+	items  []string
+
+	// selected is the index in items of the selected item, or -1 if there
+	// are no items
+	selected int
+
+	// height is the number of lines shown by the popup, from its
+	// maxheight option, or 0 if that is not limited. Rather than scroll the
+	// popup, which would scroll the header out of view, the items before
+	// first are not rendered.
+	height int
+	first  int
+}
+
+func newList(m *Manager, opts vimfn.PopupOptions) *list {
+	return &list{
+		m:      m,
+		height: opts.MaxHeight,
+	}
+}
+
+// setItems replaces the items of l, selecting the item selected, and
+// scrolling so that the selected item is shown
+func (l *list) setItems(header, items []string, selected int) {
+	l.header, l.items = header, items
+	switch {
+	case len(items) == 0:
+		selected = -1
+	case selected < 0:
+		selected = 0
+	case selected >= len(items):
+		selected = len(items) - 1
+	}
+	l.selected = selected
+	l.scroll()
+}
+
+// move moves the selection by delta items, wrapping around at either end of
+// the list, as per popup_filter_menu()
+func (l *list) move(delta int) {
+	if len(l.items) == 0 {
+		return
+	}
+	l.selected = ((l.selected+delta)%len(l.items) + len(l.items)) % len(l.items)
+	l.scroll()
+}
+
+// scroll scrolls l so that the selected item is shown
+func (l *list) scroll() {
+	items := l.height - len(l.header)
+	if l.height == 0 || items <= 0 || l.selected < 0 {
+		l.first = 0
+		return
+	}
+	if l.selected < l.first {
+		l.first = l.selected
+	}
+	if l.selected >= l.first+items {
+		l.first = l.selected - items + 1
+	}
+}
+
+// lines returns the lines of the popup of l: the header, followed by the
+// items from the first shown. The items are padded to the width of the
+// widest, so that the highlight of the selected item spans the popup.
+func (l *list) lines() []Line {
+	width := 0
+	for _, s := range append(append([]string{}, l.header...), l.items...) {
+		if w := utf8.RuneCountInString(s); w > width {
+			width = w
+		}
+	}
+	res := []Line{}
+	for _, s := range l.header {
+		res = append(res, Line{Text: s, Props: []Prop{}})
+	}
+	for i := l.first; i < len(l.items); i++ {
+		line := Line{Text: l.items[i], Props: []Prop{}}
+		if i == l.selected {
+			line.Text += strings.Repeat(" ", width-utf8.RuneCountInString(line.Text))
+			if line.Text != "" {
+				line.Props = append(line.Props, Prop{Type: l.m.selectedProp(), Col: 1, Len: len(line.Text)})
+			}
+		}
+		res = append(res, line)
+	}
+	return res
+}
+
+// create creates the popup of l for w
+func (l *list) create(g govim.Govim, w widget, opts vimfn.PopupOptions) error {
+	id, err := l.m.create(g, w, l.lines(), opts, true)
+	l.id = id
+	return err
+}
+
+// update updates the popup of l with its lines
+func (l *list) update(g govim.Govim) error {
+	return vimfn.New(g).PopupSetText(l.id, l.lines())
+}
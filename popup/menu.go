@@ -0,0 +1,104 @@
+package popup
+
+import (
+	"github.com/govim/govim"
+	"github.com/govim/govim/vimfn"
+)
+
+// MenuKeys bind keys, in the <> notation of keytrans(), for example "<C-N>",
+// to functions that handle them in a Menu in place of its default handling
+type MenuKeys map[string]func(g govim.Govim, m *Menu) error
+
+// MenuConfig is the configuration of a Menu
+type MenuConfig struct {
+	// Items are the items from which the user selects
+	Items []string
+
+	// Keys are handled before the default keys of the menu
+	Keys MenuKeys
+
+	// OnSelect, if set, is called with the index in Items of the selected
+	// item once the menu is closed by a selection
+	OnSelect func(g govim.Govim, index int) error
+
+	// OnCancel, if set, is called once the menu is closed other than by a
+	// selection
+	OnCancel func(g govim.Govim) error
+
+	// Options are the options of the popup of the menu. Options that are not
+	// set default as per popup_menu(); the callback, filter and mapping
+	// options are set by the menu.
+	Options vimfn.PopupOptions
+}
+
+// Menu is a popup from which the user selects an item, as per popup_menu().
+// The selection is moved with j, k, <Down>, <Up>, <C-N>, <C-P>, <Tab> and
+// <S-Tab>; <CR> or <Space> select the item, and <Esc>, <C-C> or x cancel the
+// menu.
+type Menu struct {
+	*list
+	config MenuConfig
+}
+
+// NewMenu creates a menu with config, with the first item selected
+func (m *Manager) NewMenu(g govim.Govim, config MenuConfig) (*Menu, error) {
+	opts := withDefaults(config.Options)
+	w := &Menu{
+		list:   newList(m, opts),
+		config: config,
+	}
+	w.setItems(nil, config.Items, 0)
+	if err := w.create(g, w, opts); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Selected returns the index in the items of the menu of the selected item
+func (w *Menu) Selected() int {
+	return w.selected
+}
+
+// Select selects the item index
+func (w *Menu) Select(g govim.Govim, index int) error {
+	w.setItems(nil, w.items, index)
+	return w.update(g)
+}
+
+func (w *Menu) key(g govim.Govim, key string) (bool, error) {
+	if f, ok := w.config.Keys[key]; ok {
+		return true, f(g, w)
+	}
+	return listKey(g, w.list, key)
+}
+
+func (w *Menu) closed(g govim.Govim, result int) error {
+	if result == resultSelect && w.selected >= 0 {
+		if w.config.OnSelect != nil {
+			return w.config.OnSelect(g, w.selected)
+		}
+		return nil
+	}
+	if w.config.OnCancel != nil {
+		return w.config.OnCancel(g)
+	}
+	return nil
+}
+
+// listKey handles key in l as per Menu, returning whether the key was
+// consumed
+func listKey(g govim.Govim, l *list, key string) (bool, error) {
+	switch key {
+	case "j", "<Down>", "<C-N>", "<Tab>":
+		l.move(1)
+		return true, l.update(g)
+	case "k", "<Up>", "<C-P>", "<S-Tab>":
+		l.move(-1)
+		return true, l.update(g)
+	case "<CR>", "<Space>":
+		return true, vimfn.New(g).PopupClose(l.id, resultSelect)
+	case "<Esc>", "<C-C>", "x":
+		return true, vimfn.New(g).PopupClose(l.id, resultCancel)
+	}
+	return !isMouse(key), nil
+}
@@ -0,0 +1,168 @@
+package popup
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/govim/govim"
+	"github.com/govim/govim/vimfn"
+)
+
+// NotificationsConfig is the configuration of a stack of Notifications
+type NotificationsConfig struct {
+	// Options are the options of the popup of each notification. Options
+	// that are not set default as per popup_notification(), other than time,
+	// which is not set: a notification is shown until it is closed, by a
+	// click or by the plugin, unless Options.Time is set. The position of a
+	// notification is set by the stack.
+	Options vimfn.PopupOptions
+}
+
+// Notifications is a stack of notification popups in the top right corner of
+// the screen, as per popup_notification(). A notification is added to the
+// bottom of the stack, and those below a notification that is closed move up
+// to fill its place.
+type Notifications struct {
+	m      *Manager
+	config NotificationsConfig
+
+	stackLock sync.Mutex
+	stack     []*Notification
+}
+
+// Notification is a popup in a stack of Notifications
+type Notification struct {
+	base
+	n      *Notifications
+	height int
+}
+
+// NewNotifications returns an empty stack of notifications with config
+func (m *Manager) NewNotifications(config NotificationsConfig) *Notifications {
+	opts := &config.Options
+	if opts.MinWidth == 0 {
+		opts.MinWidth = 20
+	}
+	if opts.ZIndex == 0 {
+		opts.ZIndex = 300
+	}
+	if opts.Highlight == "" {
+		opts.Highlight = "WarningMsg"
+	}
+	if opts.Border == nil {
+		opts.Border = []int{}
+	}
+	if opts.Padding == nil {
+		opts.Padding = []int{0, 1, 0, 1}
+	}
+	if opts.Close == "" {
+		opts.Close = "click"
+	}
+	if opts.Wrap == nil {
+		no := false
+		opts.Wrap = &no
+	}
+	opts.Pos = "topright"
+	return &Notifications{m: m, config: config}
+}
+
+// Notify adds a notification of lines to the bottom of the stack
+func (n *Notifications) Notify(g govim.Govim, lines []string) (*Notification, error) {
+	w, err := vimfn.New(g).WinWidth(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get window width: %v", err)
+	}
+	opts := n.config.Options
+	opts.Col = w
+	opts.Line = n.line(len(n.notifications()))
+	res := &Notification{n: n}
+	res.setHeight(lines)
+	res.id, err = n.m.create(g, res, lines, opts, false)
+	if err != nil {
+		return nil, err
+	}
+	n.stackLock.Lock()
+	n.stack = append(n.stack, res)
+	n.stackLock.Unlock()
+	return res, nil
+}
+
+// SetText replaces the lines of the notification, moving those below it
+// should its height change
+func (w *Notification) SetText(g govim.Govim, lines []string) error {
+	if err := vimfn.New(g).PopupSetText(w.id, lines); err != nil {
+		return err
+	}
+	if w.setHeight(lines) {
+		return w.n.restack(g)
+	}
+	return nil
+}
+
+// setHeight sets the height of w, in screen lines, for lines, returning
+// whether the height changed
+func (w *Notification) setHeight(lines []string) bool {
+	opts := w.n.config.Options
+	height := len(lines) + edges(opts.Border) + edges(opts.Padding)
+	changed := height != w.height
+	w.height = height
+	return changed
+}
+
+func (w *Notification) key(g govim.Govim, key string) (bool, error) {
+	return false, nil
+}
+
+func (w *Notification) closed(g govim.Govim, result int) error {
+	n := w.n
+	n.stackLock.Lock()
+	for i, o := range n.stack {
+		if o == w {
+			n.stack = append(n.stack[:i], n.stack[i+1:]...)
+			break
+		}
+	}
+	n.stackLock.Unlock()
+	return n.restack(g)
+}
+
+func (n *Notifications) notifications() []*Notification {
+	n.stackLock.Lock()
+	defer n.stackLock.Unlock()
+	return append([]*Notification{}, n.stack...)
+}
+
+// line returns the screen line of the notification at index i of the stack
+func (n *Notifications) line(i int) int {
+	line := 1
+	for _, w := range n.notifications()[:i] {
+		line += w.height
+	}
+	return line
+}
+
+// restack moves each notification of the stack to its line. The stack is not
+// locked while Vim is called, because a notification may be closed meanwhile.
+func (n *Notifications) restack(g govim.Govim) error {
+	line := 1
+	for _, w := range n.notifications() {
+		if err := vimfn.New(g).PopupSetOptions(w.id, vimfn.PopupOptions{Line: line}); err != nil {
+			return fmt.Errorf("failed to move notification %v: %v", w.id, err)
+		}
+		line += w.height
+	}
+	return nil
+}
+
+// edges returns the sum of the top and bottom of a border or padding option
+func edges(v []int) int {
+	switch {
+	case v == nil:
+		return 0
+	case len(v) == 0:
+		return 2
+	case len(v) < 3:
+		return 2 * v[0]
+	}
+	return v[0] + v[2]
+}
@@ -0,0 +1,163 @@
+package popup
+
+import (
+	"github.com/govim/govim"
+	"github.com/govim/govim/vimfn"
+)
+
+// pagerMaxHeight is the default height of a Pager
+const pagerMaxHeight = 15
+
+// PagerKeys bind keys, in the <> notation of keytrans(), to functions that
+// handle them in a Pager in place of its default handling
+type PagerKeys map[string]func(g govim.Govim, p *Pager) error
+
+// PagerConfig is the configuration of a Pager
+type PagerConfig struct {
+	// Lines are the lines shown by the pager
+	Lines []string
+
+	// Follow, if set, keeps the end of the lines shown as lines are added
+	// with SetLines, as long as the end was shown before, as per tail -f
+	Follow bool
+
+	// Keys are handled before the default keys of the pager
+	Keys PagerKeys
+
+	// OnClose, if set, is called once the pager is closed
+	OnClose func(g govim.Govim) error
+
+	// Options are the options of the popup of the pager, which default as per
+	// MenuConfig.Options, other than MaxHeight, which defaults to 15 lines
+	Options vimfn.PopupOptions
+}
+
+// Pager is a popup that shows lines of text, which the user scrolls with the
+// keys that scroll a window: j, k, <Down>, <Up>, <C-E>, <C-Y>, <C-D>, <C-U>,
+// <C-F>, <C-B>, <PageDown>, <PageUp>, <Space>, g, G, <Home> and <End>. q or
+// <Esc> close the pager; other keys are not consumed.
+type Pager struct {
+	base
+	m      *Manager
+	config PagerConfig
+	lines  []string
+
+	// first is the 1-indexed first line shown, as per the firstline option
+	// of the popup
+	first  int
+	height int
+}
+
+// NewPager creates a pager with config, showing the first of its lines, or
+// the last if config.Follow is set
+func (m *Manager) NewPager(g govim.Govim, config PagerConfig) (*Pager, error) {
+	if config.Options.MaxHeight == 0 {
+		config.Options.MaxHeight = pagerMaxHeight
+	}
+	opts := withDefaults(config.Options)
+	w := &Pager{
+		m:      m,
+		config: config,
+		lines:  config.Lines,
+		first:  1,
+		height: opts.MaxHeight,
+	}
+	if config.Follow {
+		w.first = w.last()
+	}
+	opts.FirstLine = &w.first
+	id, err := m.create(g, w, w.text(), opts, true)
+	if err != nil {
+		return nil, err
+	}
+	w.id = id
+	return w, nil
+}
+
+// FirstLine returns the 1-indexed first line shown by the pager
+func (w *Pager) FirstLine() int {
+	return w.first
+}
+
+// SetLines replaces the lines shown by the pager
+func (w *Pager) SetLines(g govim.Govim, lines []string) error {
+	follow := w.config.Follow && w.first == w.last()
+	w.lines = lines
+	first := w.first
+	if follow {
+		first = w.last()
+	}
+	if err := vimfn.New(g).PopupSetText(w.id, w.text()); err != nil {
+		return err
+	}
+	return w.ScrollTo(g, first)
+}
+
+// last returns the first line shown when the pager is scrolled to the end of
+// its lines
+func (w *Pager) last() int {
+	if l := len(w.lines) - w.height + 1; l > 1 {
+		return l
+	}
+	return 1
+}
+
+// text returns the text of the popup of the pager, which must not be empty
+// for the popup to be created
+func (w *Pager) text() []string {
+	if len(w.lines) == 0 {
+		return []string{""}
+	}
+	return w.lines
+}
+
+// ScrollTo scrolls the pager so that line, 1-indexed, is the first shown, as
+// far as the lines of the pager allow
+func (w *Pager) ScrollTo(g govim.Govim, line int) error {
+	if line > w.last() {
+		line = w.last()
+	}
+	if line < 1 {
+		line = 1
+	}
+	w.first = line
+	return vimfn.New(g).PopupSetOptions(w.id, vimfn.PopupOptions{FirstLine: &line})
+}
+
+func (w *Pager) key(g govim.Govim, key string) (bool, error) {
+	if f, ok := w.config.Keys[key]; ok {
+		return true, f(g, w)
+	}
+	half := w.height / 2
+	if half == 0 {
+		half = 1
+	}
+	switch key {
+	case "j", "<Down>", "<C-E>":
+		return true, w.ScrollTo(g, w.first+1)
+	case "k", "<Up>", "<C-Y>":
+		return true, w.ScrollTo(g, w.first-1)
+	case "<C-D>":
+		return true, w.ScrollTo(g, w.first+half)
+	case "<C-U>":
+		return true, w.ScrollTo(g, w.first-half)
+	case "<C-F>", "<PageDown>", "<Space>":
+		return true, w.ScrollTo(g, w.first+w.height)
+	case "<C-B>", "<PageUp>":
+		return true, w.ScrollTo(g, w.first-w.height)
+	case "g", "<Home>":
+		return true, w.ScrollTo(g, 1)
+	case "G", "<End>":
+		return true, w.ScrollTo(g, w.last())
+	case "q", "<Esc>":
+		return true, vimfn.New(g).PopupClose(w.id, resultCancel)
+	}
+	return false, nil
+}
+
+func (w *Pager) closed(g govim.Govim, result int) error {
+	if w.config.OnClose != nil {
+		return w.config.OnClose(g)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package popup
+
+import (
+	"strings"
+
+	"github.com/govim/govim"
+	"github.com/govim/govim/vimfn"
+)
+
+// PickerKeys bind keys, in the <> notation of keytrans(), to functions that
+// handle them in a Picker in place of its default handling
+type PickerKeys map[string]func(g govim.Govim, p *Picker) error
+
+// PickerConfig is the configuration of a Picker
+type PickerConfig struct {
+	// Items are the items from which the user picks
+	Items []string
+
+	// Prompt precedes the query in the first line of the picker. It defaults
+	// to "> ".
+	Prompt string
+
+	// Match reports whether item matches query. It defaults to a case
+	// insensitive match of the words of query, in any order, within item.
+	Match func(item, query string) bool
+
+	// Keys are handled before the default keys of the picker
+	Keys PickerKeys
+
+	// OnSelect, if set, is called with the index in Items of the selected
+	// item once the picker is closed by a selection
+	OnSelect func(g govim.Govim, index int) error
+
+	// OnCancel, if set, is called once the picker is closed other than by a
+	// selection
+	OnCancel func(g govim.Govim) error
+
+	// Options are the options of the popup of the picker, which default as
+	// per MenuConfig.Options
+	Options vimfn.PopupOptions
+}
+
+// Picker is a popup from which the user picks an item, having filtered the
+// items by typing a query. The query is edited with <BS>, <C-H> and <C-U>,
+// and the selection is moved with <Down>, <Up>, <C-N>, <C-P>, <Tab> and
+// <S-Tab>; <CR> selects the item, and <Esc> or <C-C> cancel the picker.
+type Picker struct {
+	*list
+	config PickerConfig
+	query  string
+
+	// matches are the indices in config.Items of the items that match the
+	// query, which are the items of the list
+	matches []int
+}
+
+// NewPicker creates a picker with config, with an empty query
+func (m *Manager) NewPicker(g govim.Govim, config PickerConfig) (*Picker, error) {
+	if config.Prompt == "" {
+		config.Prompt = "> "
+	}
+	if config.Match == nil {
+		config.Match = matchWords
+	}
+	opts := withDefaults(config.Options)
+	w := &Picker{
+		list:   newList(m, opts),
+		config: config,
+	}
+	w.filter()
+	if err := w.create(g, w, opts); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Query returns the query typed in the picker
+func (w *Picker) Query() string {
+	return w.query
+}
+
+// SetQuery replaces the query typed in the picker
+func (w *Picker) SetQuery(g govim.Govim, query string) error {
+	w.query = query
+	w.filter()
+	return w.update(g)
+}
+
+// Selected returns the index in the items of the picker of the selected
+// item, or -1 if no item matches the query
+func (w *Picker) Selected() int {
+	if w.selected < 0 {
+		return -1
+	}
+	return w.matches[w.selected]
+}
+
+// filter filters the items of the picker by its query, selecting the first
+// that matches
+func (w *Picker) filter() {
+	w.matches = nil
+	var items []string
+	for i, item := range w.config.Items {
+		if w.config.Match(item, w.query) {
+			w.matches = append(w.matches, i)
+			items = append(items, item)
+		}
+	}
+	w.setItems([]string{w.config.Prompt + w.query}, items, 0)
+}
+
+func (w *Picker) key(g govim.Govim, key string) (bool, error) {
+	if f, ok := w.config.Keys[key]; ok {
+		return true, f(g, w)
+	}
+	switch key {
+	case "<Down>", "<C-N>", "<Tab>":
+		w.move(1)
+		return true, w.update(g)
+	case "<Up>", "<C-P>", "<S-Tab>":
+		w.move(-1)
+		return true, w.update(g)
+	case "<BS>", "<C-H>":
+		if w.query == "" {
+			return true, nil
+		}
+		r := []rune(w.query)
+		return true, w.SetQuery(g, string(r[:len(r)-1]))
+	case "<C-U>":
+		return true, w.SetQuery(g, "")
+	case "<CR>":
+		return true, vimfn.New(g).PopupClose(w.id, resultSelect)
+	case "<Esc>", "<C-C>":
+		return true, vimfn.New(g).PopupClose(w.id, resultCancel)
+	}
+	if t, ok := text(key); ok {
+		return true, w.SetQuery(g, w.query+t)
+	}
+	return !isMouse(key), nil
+}
+
+func (w *Picker) closed(g govim.Govim, result int) error {
+	if result == resultSelect && w.selected >= 0 {
+		if w.config.OnSelect != nil {
+			return w.config.OnSelect(g, w.Selected())
+		}
+		return nil
+	}
+	if w.config.OnCancel != nil {
+		return w.config.OnCancel(g)
+	}
+	return nil
+}
+
+// matchWords is the default match of a Picker
+func matchWords(item, query string) bool {
+	item = strings.ToLower(item)
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(item, word) {
+			return false
+		}
+	}
+	return true
+}
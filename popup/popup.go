@@ -0,0 +1,234 @@
+// Package popup provides widgets built from Vim's popup windows: a menu, a
+// filterable picker, a tree view, a scrollable pager and a stack of
+// notifications.
+//
+// The keys typed in a widget are handled in Go: plugin/govim.vim passes each
+// key, in the <> notation of keytrans(), to a function that the Manager of the
+// widget defines, so a plugin need not write a Vim script filter for each of
+// its popups. Likewise the closing of a widget is handled in Go, whether the
+// widget is closed by a key, by the plugin or by Vim.
+//
+// Popups are specific to Vim: the widgets are not available under Neovim.
+package popup
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/govim/govim"
+	"github.com/govim/govim/vimfn"
+)
+
+// Manager creates widgets and routes the keys typed in them, and their
+// closing, to Go. A plugin creates one Manager, in its Init, for all its
+// widgets.
+type Manager struct {
+	name string
+
+	widgetsLock sync.Mutex
+	widgets     map[int]widget
+}
+
+// widget is implemented by each of the widgets of the package
+type widget interface {
+	// key handles key, typed in the widget, returning whether the key was
+	// consumed
+	key(g govim.Govim, key string) (bool, error)
+
+	// closed is called once the widget has been closed with result
+	closed(g govim.Govim, result int) error
+}
+
+// NewManager returns a Manager that handles keys and closing via the Vim
+// functions name+"Key" and name+"Closed", which it defines on g, and that
+// highlights the selection in widgets with the text property type
+// name+"Selected". name must therefore begin with a capital letter.
+func NewManager(g govim.Govim, name string) (*Manager, error) {
+	m := &Manager{
+		name:    name,
+		widgets: make(map[int]widget),
+	}
+	if err := g.DefineFunction(m.keyFunc(), []string{"id", "key"}, m.handleKey); err != nil {
+		return nil, err
+	}
+	if err := g.DefineFunction(m.closedFunc(), []string{"id", "result"}, m.handleClosed); err != nil {
+		return nil, err
+	}
+	if err := vimfn.New(g).PropTypeAdd(m.selectedProp(), vimfn.PropTypeOptions{Highlight: "PmenuSel"}); err != nil {
+		return nil, fmt.Errorf("failed to define text property type %v: %v", m.selectedProp(), err)
+	}
+	return m, nil
+}
+
+func (m *Manager) keyFunc() string      { return m.name + "Key" }
+func (m *Manager) closedFunc() string   { return m.name + "Closed" }
+func (m *Manager) selectedProp() string { return m.name + "Selected" }
+
+// create creates a popup for w with what, as per popup_create(), and opts,
+// passing the keys typed in it to w if filter is set
+func (m *Manager) create(g govim.Govim, w widget, what interface{}, opts vimfn.PopupOptions, filter bool) (int, error) {
+	opts.Callback = m.closedFunc()
+	var filterFunc string
+	if filter {
+		filterFunc = m.keyFunc()
+		no := false
+		opts.Mapping = &no
+	}
+	var id int
+	res, err := g.ChannelCall("s:popupCreate", what, opts, filterFunc)
+	if err == nil {
+		err = json.Unmarshal(res, &id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to create popup: %v", err)
+	}
+	m.widgetsLock.Lock()
+	m.widgets[id] = w
+	m.widgetsLock.Unlock()
+	return id, nil
+}
+
+func (m *Manager) widget(id int) widget {
+	m.widgetsLock.Lock()
+	defer m.widgetsLock.Unlock()
+	return m.widgets[id]
+}
+
+func (m *Manager) handleKey(g govim.Govim, args ...json.RawMessage) (interface{}, error) {
+	var id int
+	var key string
+	if err := json.Unmarshal(args[0], &id); err != nil {
+		return nil, fmt.Errorf("failed to decode popup id: %v", err)
+	}
+	if err := json.Unmarshal(args[1], &key); err != nil {
+		return nil, fmt.Errorf("failed to decode key: %v", err)
+	}
+	w := m.widget(id)
+	if w == nil {
+		return false, nil
+	}
+	return w.key(g, key)
+}
+
+func (m *Manager) handleClosed(g govim.Govim, args ...json.RawMessage) (interface{}, error) {
+	var id, result int
+	if err := json.Unmarshal(args[0], &id); err != nil {
+		return nil, fmt.Errorf("failed to decode popup id: %v", err)
+	}
+	// A popup closed by Vim, rather than by a widget, may have a result
+	// other than a number, which is taken to be 0
+	json.Unmarshal(args[1], &result)
+	m.widgetsLock.Lock()
+	w := m.widgets[id]
+	delete(m.widgets, id)
+	m.widgetsLock.Unlock()
+	if w == nil {
+		return nil, nil
+	}
+	return nil, w.closed(g, result)
+}
+
+// base is embedded in each widget, and provides the methods that are common
+// to all of them
+type base struct {
+	id int
+}
+
+// ID returns the id of the popup window of the widget
+func (b *base) ID() int {
+	return b.id
+}
+
+// Close closes the widget, as if cancelled by the user
+func (b *base) Close(g govim.Govim) error {
+	return vimfn.New(g).PopupClose(b.id, resultCancel)
+}
+
+// Hide hides the widget, which no longer receives keys until it is shown
+func (b *base) Hide(g govim.Govim) error {
+	return vimfn.New(g).PopupHide(b.id)
+}
+
+// Show shows the widget after it has been hidden
+func (b *base) Show(g govim.Govim) error {
+	return vimfn.New(g).PopupShow(b.id)
+}
+
+const (
+	// resultCancel is the result with which a widget is closed when it is
+	// cancelled, as per popup_filter_menu()
+	resultCancel = -1
+
+	// resultSelect is the result with which a widget is closed when an item
+	// is selected
+	resultSelect = 1
+)
+
+// Line is a line of text in a popup, with text properties
+type Line struct {
+	Text  string `json:"text"`
+	Props []Prop `json:"props"`
+}
+
+// Prop is a text property in a Line. Col is 1-indexed, and Type must be a
+// text property type defined in Vim.
+type Prop struct {
+	Type string `json:"type"`
+	Col  int    `json:"col"`
+	Len  int    `json:"length"`
+}
+
+// text returns the text typed by key, and whether key types text at all
+func text(key string) (string, bool) {
+	switch key {
+	case "<Space>":
+		return " ", true
+	case "<lt>":
+		return "<", true
+	case "<Bslash>":
+		return "\\", true
+	case "<Bar>":
+		return "|", true
+	}
+	if r, n := utf8.DecodeRuneInString(key); n == len(key) && r != utf8.RuneError && r >= ' ' {
+		return key, true
+	}
+	return "", false
+}
+
+// isMouse reports whether key is a mouse event. Widgets that otherwise
+// consume every key let mouse events through, so that a popup can still be
+// dragged or closed by a click.
+func isMouse(key string) bool {
+	for _, s := range []string{"Mouse", "Drag", "Release", "ScrollWheel"} {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// withDefaults returns opts, with the options that are not set in opts set as
+// per popup_menu()
+func withDefaults(opts vimfn.PopupOptions) vimfn.PopupOptions {
+	if opts.Line == nil && opts.Col == nil && opts.Pos == "" {
+		opts.Pos = "center"
+	}
+	if opts.Wrap == nil {
+		no := false
+		opts.Wrap = &no
+	}
+	if opts.Border == nil {
+		opts.Border = []int{}
+	}
+	if opts.Padding == nil {
+		opts.Padding = []int{0, 1, 0, 1}
+	}
+	if opts.ZIndex == 0 {
+		opts.ZIndex = 200
+	}
+	return opts
+}
@@ -0,0 +1,258 @@
+package popup_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/govim/govim"
+	"github.com/govim/govim/govimtest"
+	"github.com/govim/govim/popup"
+	"github.com/govim/govim/vimfn"
+)
+
+// plugin is a plugin whose only state is the Manager of its widgets
+type plugin struct {
+	m *popup.Manager
+}
+
+func (p *plugin) Init(g govim.Govim, errCh chan error) (err error) {
+	p.m, err = popup.NewManager(g, "Widgets")
+	return err
+}
+
+func (p *plugin) Shutdown() error {
+	return nil
+}
+
+func newVim(t *testing.T) (*govimtest.Vim, *popup.Manager) {
+	t.Helper()
+	p := new(plugin)
+	v := govimtest.NewT(t, p, govimtest.Config{})
+	return v, p.m
+}
+
+// results records the results passed to the callbacks of a widget
+type results struct {
+	mu  sync.Mutex
+	got []string
+}
+
+func (r *results) add(format string, args ...interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.got = append(r.got, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (r *results) check(t *testing.T, want ...string) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !reflect.DeepEqual(r.got, want) {
+		t.Errorf("got results %q; want %q", r.got, want)
+	}
+}
+
+// keys types keys in the popup with id, each of which must be consumed
+func keys(t *testing.T, v *govimtest.Vim, id int, keys ...string) {
+	t.Helper()
+	for _, k := range keys {
+		consumed, err := v.PopupKey(id, k)
+		if err != nil {
+			t.Fatalf("failed to type %v: %v", k, err)
+		}
+		if !consumed {
+			t.Fatalf("%v was not consumed", k)
+		}
+	}
+}
+
+// check evaluates expr, and compares its result with want
+func check(t *testing.T, v *govimtest.Vim, expr string, want interface{}) {
+	t.Helper()
+	res, err := v.Expr(expr)
+	if err != nil {
+		t.Fatalf("failed to evaluate %v: %v", expr, err)
+	}
+	got := reflect.New(reflect.TypeOf(want))
+	if err := json.Unmarshal(res, got.Interface()); err != nil {
+		t.Fatalf("%v: failed to decode %s: %v", expr, res, err)
+	}
+	if !reflect.DeepEqual(got.Elem().Interface(), want) {
+		t.Errorf("%v: got %#v; want %#v", expr, got.Elem().Interface(), want)
+	}
+}
+
+func text(id int) string {
+	return fmt.Sprintf("getbufline(winbufnr(%v), 1, '$')", id)
+}
+
+func TestMenu(t *testing.T) {
+	v, m := newVim(t)
+	g := v.Govim()
+	var r results
+	menu, err := m.NewMenu(g, popup.MenuConfig{
+		Items: []string{"one", "two", "three"},
+		Keys: popup.MenuKeys{
+			"d": func(g govim.Govim, m *popup.Menu) error {
+				return r.add("d on %v", m.Selected())
+			},
+		},
+		OnSelect: func(g govim.Govim, index int) error { return r.add("selected %v", index) },
+		OnCancel: func(g govim.Govim) error { return r.add("cancelled") },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(t, v, text(menu.ID()), []string{"one  ", "two", "three"})
+	keys(t, v, menu.ID(), "j", "<Down>", "<Down>", "k", "d")
+	check(t, v, text(menu.ID()), []string{"one", "two", "three"})
+	keys(t, v, menu.ID(), "<CR>")
+	check(t, v, "popup_list()", []int{})
+	r.check(t, "d on 2", "selected 2")
+
+	menu, err = m.NewMenu(g, popup.MenuConfig{
+		Items:    []string{"one"},
+		OnCancel: func(g govim.Govim) error { return r.add("cancelled") },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if consumed, err := v.PopupKey(menu.ID(), "<LeftMouse>"); err != nil || consumed {
+		t.Errorf("got %v, %v typing <LeftMouse>; want false, nil", consumed, err)
+	}
+	if err := menu.Close(g); err != nil {
+		t.Fatal(err)
+	}
+	r.check(t, "d on 2", "selected 2", "cancelled")
+}
+
+func TestPicker(t *testing.T) {
+	v, m := newVim(t)
+	g := v.Govim()
+	var r results
+	picker, err := m.NewPicker(g, popup.PickerConfig{
+		Items:    []string{"main.go", "main_test.go", "README.md"},
+		OnSelect: func(g govim.Govim, index int) error { return r.add("selected %v", index) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(t, v, text(picker.ID()), []string{"> ", "main.go     ", "main_test.go", "README.md"})
+	keys(t, v, picker.ID(), "t", "e", "<Space>", "M", "<Down>")
+	check(t, v, text(picker.ID()), []string{"> te M", "main_test.go"})
+	keys(t, v, picker.ID(), "<BS>", "<BS>", "<C-U>", "r", "<Down>")
+	if got := picker.Query(); got != "r" {
+		t.Errorf("got query %q; want %q", got, "r")
+	}
+	check(t, v, text(picker.ID()), []string{"> r", "README.md"})
+	keys(t, v, picker.ID(), "<CR>")
+	r.check(t, "selected 2")
+}
+
+func TestTree(t *testing.T) {
+	v, m := newVim(t)
+	g := v.Govim()
+	var r results
+	roots := []*popup.Node{
+		{Text: "cmd", Children: []*popup.Node{
+			{Text: "govim"},
+		}},
+		{Text: "README.md"},
+	}
+	tree, err := m.NewTree(g, popup.TreeConfig{
+		Roots:    roots,
+		OnSelect: func(g govim.Govim, n *popup.Node) error { return r.add("selected %v", n.Text) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(t, v, text(tree.ID()), []string{"▸ cmd      ", "  README.md"})
+	keys(t, v, tree.ID(), "l", "j")
+	check(t, v, text(tree.ID()), []string{"▾ cmd", "    govim  ", "  README.md"})
+	keys(t, v, tree.ID(), "h")
+	if got := tree.Selected(); got != roots[0] {
+		t.Errorf("got %v selected after h; want cmd", got.Text)
+	}
+	keys(t, v, tree.ID(), "<CR>")
+	check(t, v, text(tree.ID()), []string{"▸ cmd      ", "  README.md"})
+	keys(t, v, tree.ID(), "<CR>", "j", "<CR>")
+	check(t, v, "popup_list()", []int{})
+	r.check(t, "selected govim")
+}
+
+func TestPager(t *testing.T) {
+	v, m := newVim(t)
+	g := v.Govim()
+	var lines []string
+	for i := 1; i <= 10; i++ {
+		lines = append(lines, fmt.Sprint(i))
+	}
+	pager, err := m.NewPager(g, popup.PagerConfig{
+		Lines:   lines,
+		Follow:  true,
+		Options: vimfn.PopupOptions{MaxHeight: 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	firstline := fmt.Sprintf("popup_getoptions(%v).firstline", pager.ID())
+	check(t, v, firstline, 7)
+	keys(t, v, pager.ID(), "g", "j", "<C-D>")
+	check(t, v, firstline, 4)
+	keys(t, v, pager.ID(), "<PageDown>", "<PageDown>")
+	check(t, v, firstline, 7)
+	if err := pager.SetLines(g, append(lines, "11")); err != nil {
+		t.Fatal(err)
+	}
+	check(t, v, firstline, 8)
+	keys(t, v, pager.ID(), "k", "<C-Y>")
+	if err := pager.SetLines(g, append(lines, "11", "12")); err != nil {
+		t.Fatal(err)
+	}
+	check(t, v, firstline, 6)
+	if consumed, err := v.PopupKey(pager.ID(), "x"); err != nil || consumed {
+		t.Errorf("got %v, %v typing x; want false, nil", consumed, err)
+	}
+	keys(t, v, pager.ID(), "q")
+	check(t, v, "popup_list()", []int{})
+}
+
+func TestNotifications(t *testing.T) {
+	v, m := newVim(t)
+	g := v.Govim()
+	n := m.NewNotifications(popup.NotificationsConfig{})
+	first, err := n.Notify(g, []string{"one"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := n.Notify(g, []string{"two", "lines"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := n.Notify(g, []string{"three"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := func(w *popup.Notification) string {
+		return fmt.Sprintf("popup_getoptions(%v).line", w.ID())
+	}
+	check(t, v, line(first), 1)
+	check(t, v, line(second), 4)
+	check(t, v, line(third), 8)
+	if err := first.SetText(g, []string{"one", "more"}); err != nil {
+		t.Fatal(err)
+	}
+	check(t, v, line(second), 5)
+	check(t, v, line(third), 9)
+	if err := second.Close(g); err != nil {
+		t.Fatal(err)
+	}
+	check(t, v, line(third), 5)
+	if _, err := v.PopupKey(third.ID(), "x"); err == nil {
+		t.Errorf("typed a key in a notification, which has no filter")
+	}
+}
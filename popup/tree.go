@@ -0,0 +1,170 @@
+package popup
+
+import (
+	"strings"
+
+	"github.com/govim/govim"
+	"github.com/govim/govim/vimfn"
+)
+
+// Node is a node in a Tree. A node with children is a branch, which is shown
+// expanded or collapsed; a node without is a leaf.
+type Node struct {
+	Text     string
+	Children []*Node
+	Expanded bool
+}
+
+// TreeKeys bind keys, in the <> notation of keytrans(), to functions that
+// handle them in a Tree in place of its default handling
+type TreeKeys map[string]func(g govim.Govim, t *Tree) error
+
+// TreeConfig is the configuration of a Tree
+type TreeConfig struct {
+	// Roots are the nodes at the top of the tree
+	Roots []*Node
+
+	// Keys are handled before the default keys of the tree
+	Keys TreeKeys
+
+	// OnSelect, if set, is called with the selected leaf once the tree is
+	// closed by a selection
+	OnSelect func(g govim.Govim, n *Node) error
+
+	// OnCancel, if set, is called once the tree is closed other than by a
+	// selection
+	OnCancel func(g govim.Govim) error
+
+	// Options are the options of the popup of the tree, which default as per
+	// MenuConfig.Options
+	Options vimfn.PopupOptions
+}
+
+// Tree is a popup that shows a tree of nodes, from which the user selects a
+// leaf. The selection is moved as per Menu; l or <Right> expand a branch, h or
+// <Left> collapse a branch or move to the parent of a node, and <CR> or
+// <Space> toggle a branch or select a leaf. <Esc>, <C-C> or x cancel the tree.
+type Tree struct {
+	*list
+	config TreeConfig
+
+	// shown are the nodes that are shown, in the order of the items of the
+	// list, with their parents and depths
+	shown []treeNode
+}
+
+type treeNode struct {
+	n      *Node
+	parent *Node
+	depth  int
+}
+
+// NewTree creates a tree with config, with the first root selected
+func (m *Manager) NewTree(g govim.Govim, config TreeConfig) (*Tree, error) {
+	opts := withDefaults(config.Options)
+	w := &Tree{
+		list:   newList(m, opts),
+		config: config,
+	}
+	w.render(nil)
+	if err := w.create(g, w, opts); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Selected returns the selected node, or nil if the tree has no nodes
+func (w *Tree) Selected() *Node {
+	if w.selected < 0 {
+		return nil
+	}
+	return w.shown[w.selected].n
+}
+
+// Refresh renders the tree again, after its nodes have been changed,
+// keeping the selected node selected if it is still shown
+func (w *Tree) Refresh(g govim.Govim) error {
+	w.render(w.Selected())
+	return w.update(g)
+}
+
+// render renders the nodes that are shown into the items of the list,
+// selecting sel, or the first node if sel is not shown
+func (w *Tree) render(sel *Node) {
+	w.shown = nil
+	var walk func(nodes []*Node, parent *Node, depth int)
+	walk = func(nodes []*Node, parent *Node, depth int) {
+		for _, n := range nodes {
+			w.shown = append(w.shown, treeNode{n: n, parent: parent, depth: depth})
+			if n.Expanded {
+				walk(n.Children, n, depth+1)
+			}
+		}
+	}
+	walk(w.config.Roots, nil, 0)
+	var items []string
+	selected := 0
+	for i, tn := range w.shown {
+		marker := "  "
+		if len(tn.n.Children) > 0 {
+			marker = "▸ "
+			if tn.n.Expanded {
+				marker = "▾ "
+			}
+		}
+		items = append(items, strings.Repeat("  ", tn.depth)+marker+tn.n.Text)
+		if tn.n == sel {
+			selected = i
+		}
+	}
+	w.setItems(nil, items, selected)
+}
+
+// expand expands or collapses the selected node, if it is a branch
+func (w *Tree) expand(g govim.Govim, expand bool) error {
+	n := w.Selected()
+	if n == nil || len(n.Children) == 0 || n.Expanded == expand {
+		return nil
+	}
+	n.Expanded = expand
+	return w.Refresh(g)
+}
+
+func (w *Tree) key(g govim.Govim, key string) (bool, error) {
+	if f, ok := w.config.Keys[key]; ok {
+		return true, f(g, w)
+	}
+	switch key {
+	case "l", "<Right>":
+		return true, w.expand(g, true)
+	case "h", "<Left>":
+		n := w.Selected()
+		if n != nil && len(n.Children) > 0 && n.Expanded {
+			return true, w.expand(g, false)
+		}
+		if n != nil && w.shown[w.selected].parent != nil {
+			w.render(w.shown[w.selected].parent)
+			return true, w.update(g)
+		}
+		return true, nil
+	case "<CR>", "<Space>":
+		n := w.Selected()
+		if n != nil && len(n.Children) > 0 {
+			return true, w.expand(g, !n.Expanded)
+		}
+	}
+	return listKey(g, w.list, key)
+}
+
+func (w *Tree) closed(g govim.Govim, result int) error {
+	if result == resultSelect && w.selected >= 0 {
+		if w.config.OnSelect != nil {
+			return w.config.OnSelect(g, w.Selected())
+		}
+		return nil
+	}
+	if w.config.OnCancel != nil {
+		return w.config.OnCancel(g)
+	}
+	return nil
+}
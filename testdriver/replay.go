@@ -0,0 +1,782 @@
+package testdriver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/govim/govim"
+	"github.com/govim/govim/internal/recording"
+	"github.com/rogpeppe/go-internal/testscript"
+	"gopkg.in/tomb.v2"
+)
+
+// KeyReplayPlugin is the testscript value key of the ReplayPlugin used by
+// Replay
+const KeyReplayPlugin = "replayPlugin"
+
+// ReplayPlugin creates the plugin for a replay. goplsPath is the path of the
+// gopls binary the plugin must use, or empty for the gopls normally used by
+// tests. env is added to the environment of the plugin and of gopls.
+type ReplayPlugin func(goplsPath string, env []string) (govim.Plugin, error)
+
+// The environment variables by which Replay configures StubGopls
+const (
+	envReplayRecording = "GOVIM_REPLAY_RECORDING"
+	envReplayWorkdir   = "GOVIM_REPLAY_WORKDIR"
+	envReplayMasks     = "GOVIM_REPLAY_MASKS"
+	envReplayTimeout   = "GOVIM_REPLAY_TIMEOUT"
+	envReplayReport    = "GOVIM_REPLAY_REPORT"
+)
+
+// The prefixes of the lines of the report written by StubGopls
+const (
+	reportPid        = "pid "
+	reportDivergence = "divergence: "
+	reportDone       = "done"
+)
+
+// defaultReplayMasks match the parts of messages that always differ between a
+// recording and its replay
+var defaultReplayMasks = []string{
+	`let s:(govim|gopls)_logfile=\\"[^\\]*\\"`,
+}
+
+// Replay is a testscript command that replays a recording, created by running
+// govim with GOVIM_RECORD set, against a fresh instance of govim:
+//
+//	replay [-gopls stub|real] [-mask regexp]... [-timeout duration] recording
+//
+// The messages that Vim sent in the recording are sent to govim, each once
+// govim has sent the messages to Vim that preceded it in the recording, except
+// that a reply to a call from govim is sent as soon as govim makes the call,
+// and a request to run a scheduled function need only wait for the call that
+// scheduled it. The commands of the test driver in a recording of a test are
+// likewise issued by govim in turn. The messages that govim sends to Vim are
+// compared with those in the recording, without regard to order between two
+// messages from Vim; a message that govim sends earlier than in the recording
+// is matched with a later entry. The ids that govim allocates, for example of
+// its calls to Vim, are not compared, and the ids in the messages sent to
+// govim are mapped to those that govim allocated in the replay, since they
+// depend on the order in which govim handles concurrent events. With -gopls
+// stub (the default) gopls is replaced by StubGopls, which plays back the
+// recorded messages from gopls in the same way and compares the messages that
+// govim sends to gopls. With -gopls real the gopls normally used by tests is
+// used, and only the messages between Vim and govim are compared.
+//
+// Paths within the working directory of the recording are rewritten to be
+// within $WORK, and the parts of messages that match a -mask regular
+// expression are ignored when comparing. Each divergence from the recording
+// is logged, and replay fails if there are any; ! replay fails if there are
+// none.
+//
+// The script must be run with the ReplayPlugin value KeyReplayPlugin.
+func Replay(ts *testscript.TestScript, neg bool, args []string) {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fGopls := fs.String("gopls", "stub", "stub or real")
+	fTimeout := fs.Duration("timeout", 10*time.Second, "how long to wait for each message from govim")
+	var masks []string
+	fs.Func("mask", "regular expression matching parts of messages to ignore", func(s string) error {
+		masks = append(masks, s)
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		ts.Fatalf("replay: %v", err)
+	}
+	if fs.NArg() != 1 {
+		ts.Fatalf("usage: replay [-gopls stub|real] [-mask regexp]... [-timeout duration] recording")
+	}
+	mkPlugin, ok := ts.Value(KeyReplayPlugin).(ReplayPlugin)
+	if !ok {
+		ts.Fatalf("replay failed to find a ReplayPlugin value for %v", KeyReplayPlugin)
+	}
+	path := ts.MkAbs(fs.Arg(0))
+	header, entries, err := recording.Read(path)
+	ts.Check(err)
+	workdir := ts.Getenv("WORK")
+	masks = append(masks, defaultReplayMasks...)
+	norm, err := newNormaliser(header.WorkingDirectory, workdir, masks)
+	ts.Check(err)
+
+	var goplsPath string
+	var env []string
+	report := filepath.Join(ts.Getenv("TMPDIR"), fmt.Sprintf("replay_%v.report", time.Now().UnixNano()))
+	switch *fGopls {
+	case "stub":
+		goplsPath, err = lookPath(ts.Getenv("PATH"), "stubgopls")
+		ts.Check(err)
+		env = []string{
+			envReplayRecording + "=" + path,
+			envReplayWorkdir + "=" + workdir,
+			envReplayMasks + "=" + strings.Join(masks, "\n"),
+			envReplayTimeout + "=" + fTimeout.String(),
+			envReplayReport + "=" + report,
+		}
+	case "real":
+	default:
+		ts.Fatalf("replay: -gopls must be stub or real; got %q", *fGopls)
+	}
+	plug, err := mkPlugin(goplsPath, env)
+	ts.Check(err)
+
+	logFile, err := os.CreateTemp(ts.Getenv("TMPDIR"), "govim_replay.log*")
+	ts.Check(err)
+	defer logFile.Close()
+
+	var t tomb.Tomb
+	vimIn, toGovim := io.Pipe()
+	fromGovim, vimOut := io.Pipe()
+	// govim only tells Vim the name of its log file if it has one, so only
+	// give it one if it did in the recording
+	var govimLogFile *os.File
+	if recordsLogFile(entries) {
+		govimLogFile = logFile
+	}
+	g, err := govim.NewGovim(plug, vimIn, vimOut, logFile, govimLogFile, &t)
+	ts.Check(err)
+	t.Go(g.Run)
+	go func() {
+		// Should govim stop early, fail the sending of further messages
+		// rather than block forever
+		<-t.Dying()
+		vimIn.CloseWithError(fmt.Errorf("govim stopped: %v", t.Err()))
+	}()
+
+	recv := make(chan json.RawMessage)
+	go decodeMessages(json.NewDecoder(fromGovim), recv)
+
+	var divergences []string
+	r := &replayer{
+		stream:  recording.Vim,
+		entries: entries,
+		norm:    norm,
+		timeout: *fTimeout,
+		recv:    recv,
+		send: func(msg json.RawMessage) error {
+			_, err := toGovim.Write(append(msg, '\n'))
+			return err
+		},
+		issue: func(cmd json.RawMessage) error {
+			var args []interface{}
+			if err := json.Unmarshal(cmd, &args); err != nil {
+				return err
+			}
+			f, err := driverCommand(args, func(error, ...interface{}) {})
+			if err != nil {
+				return err
+			}
+			// Scheduling the command, and so the command, only completes
+			// once the replay has sent Vim's replies, so do not wait for
+			// it. A failure shows as a divergence.
+			go func() {
+				defer func() {
+					if r := recover(); r != nil && r != govim.ErrShuttingDown {
+						panic(r)
+					}
+				}()
+				g.Schedule(f)
+			}()
+			return nil
+		},
+		report: func(format string, args ...interface{}) {
+			divergences = append(divergences, "vim: "+fmt.Sprintf(format, args...))
+		},
+	}
+	r.run()
+
+	toGovim.Close()
+	t.Kill(nil)
+	select {
+	case <-t.Dead():
+	case <-time.After(*fTimeout):
+		ts.Logf("timed out waiting for govim to stop")
+	}
+	vimOut.Close()
+
+	if *fGopls == "stub" {
+		divergences = append(divergences, readStubReport(ts, report, *fTimeout)...)
+	}
+	for _, d := range divergences {
+		ts.Logf("%s", d)
+	}
+	ts.Logf("govim log: %v", logFile.Name())
+	switch {
+	case len(divergences) > 0 && !neg:
+		ts.Fatalf("replay of %v diverged from the recording in %v places", fs.Arg(0), len(divergences))
+	case len(divergences) == 0 && neg:
+		ts.Fatalf("replay of %v unexpectedly matched the recording", fs.Arg(0))
+	}
+}
+
+// readStubReport interrupts StubGopls and returns the divergences it reported
+func readStubReport(ts *testscript.TestScript, path string, timeout time.Duration) []string {
+	var divergences []string
+	deadline := time.Now().Add(timeout)
+	interrupted := false
+	for {
+		byts, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			ts.Fatalf("failed to read stub gopls report: %v", err)
+		}
+		divergences = divergences[:0]
+		done := false
+		for _, l := range strings.Split(string(byts), "\n") {
+			switch {
+			case strings.HasPrefix(l, reportPid) && !interrupted:
+				// The stub gopls plays back its stream before waiting for
+				// further messages from govim, which we know have all been
+				// sent
+				if pid, err := strconv.Atoi(strings.TrimPrefix(l, reportPid)); err == nil {
+					if p, err := os.FindProcess(pid); err == nil {
+						p.Signal(os.Interrupt)
+					}
+				}
+				interrupted = true
+			case strings.HasPrefix(l, reportDivergence):
+				divergences = append(divergences, "gopls: "+strings.TrimPrefix(l, reportDivergence))
+			case l == reportDone:
+				done = true
+			}
+		}
+		if done {
+			return divergences
+		}
+		if time.Now().After(deadline) {
+			return append(divergences, "gopls: timed out waiting for the stub gopls to finish")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// StubGopls is a stand-in for gopls that plays back the gopls stream of a
+// recording, as configured by Replay. It should be registered as the
+// "stubgopls" command in testscript.RunMain.
+//
+// StubGopls writes the divergences between the messages it receives from
+// govim and those in the recording to a report file. Once it has played back
+// the recording it reports any further messages from govim as divergences
+// until it is interrupted, its standard input is closed, or it receives no
+// messages for the timeout of the replay.
+func StubGopls() (exitCode int) {
+	defer cleanUp(&exitCode)
+	rf, err := os.OpenFile(os.Getenv(envReplayReport), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		ef("failed to open report: %v", err)
+	}
+	defer rf.Close()
+	fmt.Fprintf(rf, "%v%v\n", reportPid, os.Getpid())
+	defer fmt.Fprintln(rf, reportDone)
+
+	header, entries, err := recording.Read(os.Getenv(envReplayRecording))
+	if err != nil {
+		ef("%v", err)
+	}
+	timeout, err := time.ParseDuration(os.Getenv(envReplayTimeout))
+	if err != nil {
+		ef("failed to parse %v: %v", envReplayTimeout, err)
+	}
+	norm, err := newNormaliser(header.WorkingDirectory, os.Getenv(envReplayWorkdir), strings.Split(os.Getenv(envReplayMasks), "\n"))
+	if err != nil {
+		ef("%v", err)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	recv := make(chan json.RawMessage)
+	go func() {
+		defer close(recv)
+		br := bufio.NewReader(os.Stdin)
+		for {
+			msg, err := readFramed(br)
+			if err != nil {
+				return
+			}
+			recv <- msg
+		}
+	}()
+	r := &replayer{
+		stream:  recording.Gopls,
+		entries: entries,
+		norm:    norm,
+		timeout: timeout,
+		recv:    recv,
+		send: func(msg json.RawMessage) error {
+			return writeFramed(os.Stdout, msg)
+		},
+		report: func(format string, args ...interface{}) {
+			fmt.Fprintf(rf, "%v%v\n", reportDivergence, strings.Replace(fmt.Sprintf(format, args...), "\n", " ", -1))
+		},
+	}
+	r.run()
+	for {
+		select {
+		case msg, ok := <-recv:
+			if !ok {
+				return 0
+			}
+			r.report("unexpected message: %s", msg)
+		case <-interrupt:
+			return 0
+		case <-time.After(timeout):
+			return 0
+		}
+	}
+}
+
+// replayer replays one stream of a recording against govim. It sends the
+// messages that govim received in the recording, and checks that govim sends
+// the messages that it sent in the recording.
+type replayer struct {
+	stream  recording.Stream
+	entries []recording.Entry
+	norm    *normaliser
+	timeout time.Duration
+
+	// recv is the channel of messages sent by govim; it is closed when
+	// govim stops sending messages
+	recv <-chan json.RawMessage
+
+	// send sends a message to govim
+	send func(json.RawMessage) error
+
+	// issue, if set, has govim issue a command of the test driver
+	issue func(json.RawMessage) error
+
+	// report reports a divergence from the recording
+	report func(format string, args ...interface{})
+
+	// unexpected are the messages that govim has sent that match no entry
+	unexpected []json.RawMessage
+
+	// ids maps the ids allocated by govim in the recording to those that it
+	// allocated in the replay, keyed by idRef.kind and idRef.id
+	ids map[string]json.RawMessage
+
+	// replies maps the calls made by govim in the recording, keyed like
+	// ids, to the index of the entry that replies to them
+	replies map[string]int
+
+	// sent records the indexes of the replies already sent to govim
+	sent map[int]bool
+
+	// failed is set once a message could not be sent to govim
+	failed bool
+}
+
+// expectation is a message that govim is expected to send
+type expectation struct {
+	// entry is the number of the entry in the recording, from 1
+	entry int
+	msg   []byte
+	key   string
+	refs  []idRef
+}
+
+// idRef is an id allocated by govim in a message that it sent
+type idRef struct {
+	kind string
+	id   json.RawMessage
+}
+
+func (r idRef) key() string {
+	return r.kind + " " + string(r.id)
+}
+
+func (r *replayer) run() {
+	r.ids = make(map[string]json.RawMessage)
+	r.replies = make(map[string]int)
+	r.sent = make(map[int]bool)
+	var expected []expectation
+	for i, e := range r.entries {
+		if e.Stream != r.stream {
+			continue
+		}
+		msg := r.norm.rewrite(e.Msg)
+		if e.Dir == recording.Send {
+			masked, refs := govimIDs(r.stream, msg)
+			expected = append(expected, expectation{entry: i + 1, msg: msg, key: r.norm.key(masked), refs: refs})
+		} else if ref, set := replyRef(r.stream, msg); set != nil && ref.kind != "schedule" {
+			r.replies[ref.key()] = i
+		}
+	}
+	for i, e := range r.entries {
+		if r.failed {
+			return
+		}
+		// Entries before i have numbers up to i
+		preceding := func(e expectation) bool { return e.entry <= i }
+		if e.Stream == recording.Driver && r.issue != nil {
+			// The driver waited for each of its commands to be issued
+			// before sending the next
+			expected = r.await(expected, preceding)
+			expected = r.reportMissing(expected, preceding)
+			if err := r.issue(r.norm.rewrite(e.Msg)); err != nil {
+				r.report("entry %v: failed to issue driver command %s: %v", i+1, e.Msg, err)
+			}
+			continue
+		}
+		if e.Stream != r.stream || e.Dir != recording.Recv || r.sent[i] {
+			continue
+		}
+		msg := r.norm.rewrite(e.Msg)
+		if ref, set := replyRef(r.stream, msg); set != nil {
+			// A reply to govim need only wait for the message to which it
+			// replies. Waiting for others that preceded it in the recording
+			// can deadlock, because they might only be sent by govim once it
+			// has the reply.
+			refers := func(e expectation) bool { return e.refers(ref) }
+			expected = r.await(expected, refers)
+			if r.sent[i] {
+				continue
+			}
+			id := r.ids[ref.key()]
+			if id == nil {
+				// There is nothing to reply to
+				expected = r.reportMissing(expected, refers)
+				continue
+			}
+			msg = set(id)
+		} else {
+			expected = r.await(expected, preceding)
+			expected = r.reportMissing(expected, preceding)
+		}
+		r.sendEntry(i, msg)
+	}
+	if r.failed {
+		return
+	}
+	expected = r.await(expected, nil)
+	r.reportMissing(expected, nil)
+
+	// Report any messages sent by govim beyond those recorded
+	for _, m := range r.unexpected {
+		r.report("unexpected message: %s", m)
+	}
+	for {
+		select {
+		case msg, ok := <-r.recv:
+			if !ok {
+				return
+			}
+			r.report("unexpected message: %s", msg)
+		default:
+			return
+		}
+	}
+}
+
+// await waits for govim to send the messages expected that are selected by
+// wanted, or all of them if wanted is nil, in any order, until govim stops or
+// no message has been received for r.timeout. The messages still expected
+// are returned.
+func (r *replayer) await(expected []expectation, wanted func(expectation) bool) []expectation {
+	waiting := func() bool {
+		for _, e := range expected {
+			if wanted == nil || wanted(e) {
+				return true
+			}
+		}
+		return false
+	}
+	for waiting() {
+		select {
+		case msg, ok := <-r.recv:
+			if !ok {
+				return expected
+			}
+			expected = r.match(expected, msg)
+		case <-time.After(r.timeout):
+			return expected
+		}
+	}
+	return expected
+}
+
+// match removes the first expectation met by msg from expected, noting the
+// ids that govim allocated in msg. If msg is not expected it is kept in
+// r.unexpected.
+func (r *replayer) match(expected []expectation, msg json.RawMessage) []expectation {
+	masked, refs := govimIDs(r.stream, msg)
+	key := r.norm.key(masked)
+	for i, e := range expected {
+		if e.key != key {
+			continue
+		}
+		expected = append(expected[:i], expected[i+1:]...)
+		for j, ref := range e.refs {
+			r.ids[ref.key()] = refs[j].id
+			// govim might wait for the reply to its call before sending
+			// the messages that precede the reply in the recording, so
+			// reply straight away
+			if k, ok := r.replies[ref.key()]; ok && !r.sent[k] && !r.failed {
+				_, set := replyRef(r.stream, r.norm.rewrite(r.entries[k].Msg))
+				r.sendEntry(k, set(refs[j].id))
+			}
+		}
+		return expected
+	}
+	r.unexpected = append(r.unexpected, msg)
+	return expected
+}
+
+// sendEntry sends msg, the message of entry i rewritten for the replay, to
+// govim
+func (r *replayer) sendEntry(i int, msg []byte) {
+	r.sent[i] = true
+	if err := r.send(msg); err != nil {
+		r.report("entry %v: failed to send %s: %v", i+1, msg, err)
+		r.failed = true
+	}
+}
+
+// reportMissing reports the expectations selected by missing, or all of them
+// if missing is nil, as messages not received. The rest are returned.
+func (r *replayer) reportMissing(expected []expectation, missing func(expectation) bool) []expectation {
+	var rest []expectation
+	for _, e := range expected {
+		if missing == nil || missing(e) {
+			r.report("entry %v: message not received: %s", e.entry, e.msg)
+		} else {
+			rest = append(rest, e)
+		}
+	}
+	return rest
+}
+
+// refers reports whether e allocates the id ref
+func (e expectation) refers(ref idRef) bool {
+	for _, r := range e.refs {
+		if r.key() == ref.key() {
+			return true
+		}
+	}
+	return false
+}
+
+// govimIDs returns msg, a message sent by govim on stream s, with the ids that
+// govim allocated in it replaced by placeholders, along with those ids. govim
+// allocates ids in the order in which it handles events, which can differ
+// between a recording and its replay, so messages are compared without them.
+// The ids in the messages sent to govim in reply are mapped by mapIDs.
+func govimIDs(s recording.Stream, msg []byte) ([]byte, []idRef) {
+	var refs []idRef
+	placeholder := func(kind string, v *json.RawMessage) {
+		refs = append(refs, idRef{kind: kind, id: *v})
+		*v = json.RawMessage(strconv.Quote("<" + kind + ">"))
+	}
+	switch s {
+	case recording.Vim:
+		// A call from govim is [0, [id, type, args...]]; a call to
+		// s:schedule has the id of the scheduled function as its argument
+		var m []json.RawMessage
+		var call []json.RawMessage
+		if json.Unmarshal(msg, &m) != nil || len(m) != 2 || string(m[0]) != "0" || json.Unmarshal(m[1], &call) != nil || len(call) < 2 {
+			return msg, nil
+		}
+		placeholder("callback", &call[0])
+		if len(call) == 4 && string(call[1]) == `"call"` && string(call[2]) == `"s:schedule"` {
+			placeholder("schedule", &call[3])
+		}
+		m[1], _ = json.Marshal(call)
+		res, _ := json.Marshal(m)
+		return res, refs
+	case recording.Gopls:
+		// A request from govim has both a method and an id
+		var m map[string]json.RawMessage
+		if json.Unmarshal(msg, &m) != nil || m["method"] == nil || m["id"] == nil {
+			return msg, nil
+		}
+		id := m["id"]
+		placeholder("request", &id)
+		m["id"] = id
+		res, _ := json.Marshal(m)
+		return res, refs
+	}
+	return msg, nil
+}
+
+// replyRef returns the id allocated by govim to which msg, a message to send
+// to govim, replies. set returns msg with that id replaced; it is nil if msg
+// is not a reply.
+func replyRef(s recording.Stream, msg []byte) (ref idRef, set func(id json.RawMessage) []byte) {
+	switch s {
+	case recording.Vim:
+		// A reply to govim is [n, ["callback", id, ...]] or a request to
+		// run a scheduled function [n, ["schedule", id]]
+		var m []json.RawMessage
+		var call []json.RawMessage
+		if json.Unmarshal(msg, &m) != nil || len(m) != 2 || json.Unmarshal(m[1], &call) != nil || len(call) < 2 {
+			return ref, nil
+		}
+		switch string(call[0]) {
+		case `"callback"`:
+			ref.kind = "callback"
+		case `"schedule"`:
+			ref.kind = "schedule"
+		default:
+			return ref, nil
+		}
+		ref.id = call[1]
+		return ref, func(id json.RawMessage) []byte {
+			call[1] = id
+			m[1], _ = json.Marshal(call)
+			res, _ := json.Marshal(m)
+			return res
+		}
+	case recording.Gopls:
+		// A response to govim has an id but no method
+		var m map[string]json.RawMessage
+		if json.Unmarshal(msg, &m) != nil || m["method"] != nil || m["id"] == nil {
+			return ref, nil
+		}
+		ref = idRef{kind: "request", id: m["id"]}
+		return ref, func(id json.RawMessage) []byte {
+			m["id"] = id
+			res, _ := json.Marshal(m)
+			return res
+		}
+	}
+	return ref, nil
+}
+
+// normaliser rewrites recorded messages for a replay, and normalises messages
+// for comparison
+type normaliser struct {
+	from, to []byte
+	masks    []*regexp.Regexp
+}
+
+// newNormaliser returns a normaliser that rewrites paths in the recording's
+// working directory from to be within to, and that ignores the parts of
+// messages that match masks
+func newNormaliser(from, to string, masks []string) (*normaliser, error) {
+	n := new(normaliser)
+	if from != "" {
+		n.from = jsonString(from)
+		n.to = jsonString(to)
+	}
+	for _, m := range masks {
+		if m == "" {
+			continue
+		}
+		re, err := regexp.Compile(m)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mask %q: %v", m, err)
+		}
+		n.masks = append(n.masks, re)
+	}
+	return n, nil
+}
+
+// jsonString returns s encoded as the contents of a JSON string
+func jsonString(s string) []byte {
+	byts, _ := json.Marshal(s)
+	return byts[1 : len(byts)-1]
+}
+
+func (n *normaliser) rewrite(msg []byte) []byte {
+	if n.from == nil {
+		return append([]byte(nil), msg...)
+	}
+	return bytes.ReplaceAll(msg, n.from, n.to)
+}
+
+// key returns the form of msg used for comparison: its canonical JSON
+// encoding with the parts that match a mask removed
+func (n *normaliser) key(msg []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(msg, &v); err == nil {
+		if byts, err := json.Marshal(v); err == nil {
+			msg = byts
+		}
+	}
+	for _, re := range n.masks {
+		msg = re.ReplaceAll(msg, []byte("<masked>"))
+	}
+	return string(msg)
+}
+
+// recordsLogFile reports whether govim told Vim the name of its log file in
+// entries
+func recordsLogFile(entries []recording.Entry) bool {
+	for _, e := range entries {
+		if e.Stream == recording.Vim && e.Dir == recording.Send && bytes.Contains(e.Msg, []byte(`let s:govim_logfile=`)) {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeMessages(dec *json.Decoder, ch chan<- json.RawMessage) {
+	defer close(ch)
+	for {
+		var msg json.RawMessage
+		if err := dec.Decode(&msg); err != nil {
+			return
+		}
+		ch <- msg
+	}
+}
+
+// readFramed reads a JSON-RPC message framed by a Content-Length header, as
+// used by LSP
+func readFramed(r *bufio.Reader) (json.RawMessage, error) {
+	var length int64
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid header line %q", line)
+		}
+		if strings.TrimSpace(name) == "Content-Length" {
+			if length, err = strconv.ParseInt(strings.TrimSpace(value), 10, 32); err != nil {
+				return nil, fmt.Errorf("failed to parse Content-Length %q: %v", value, err)
+			}
+		}
+	}
+	if length <= 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+	msg := make([]byte, length)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// writeFramed writes msg framed by a Content-Length header
+func writeFramed(w io.Writer, msg json.RawMessage) error {
+	_, err := fmt.Fprintf(w, "Content-Length: %v\r\n\r\n%s", len(msg), msg)
+	return err
+}
+
+// lookPath finds the executable file in the directories of path, a PATH-style
+// list
+func lookPath(path, file string) (string, error) {
+	for _, dir := range filepath.SplitList(path) {
+		for _, name := range []string{file, file + ".exe"} {
+			p := filepath.Join(dir, name)
+			if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+				return p, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("failed to find %v in PATH", file)
+}
@@ -0,0 +1,212 @@
+package testdriver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/govim/govim/internal/recording"
+)
+
+func TestReplayer(t *testing.T) {
+	entry := func(dir recording.Direction, msg string) recording.Entry {
+		return recording.Entry{Stream: recording.Vim, Dir: dir, Msg: json.RawMessage(msg)}
+	}
+	entries := []recording.Entry{
+		entry(recording.Send, `[0,["ex","let s:govim_logfile=\"/rec/govim.log\""]]`),
+		entry(recording.Send, `[0,["call","bufname","/rec/main.go"]]`),
+		{Stream: recording.Gopls, Dir: recording.Recv, Msg: json.RawMessage(`{}`)},
+		entry(recording.Recv, `[1,["loaded"]]`),
+		entry(recording.Send, `[1,"ok"]`),
+		entry(recording.Send, `[0,["redraw",""]]`),
+	}
+	norm, err := newNormaliser("/rec", "/work", defaultReplayMasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := make(chan json.RawMessage, 10)
+	for _, m := range []string{
+		// In a different order, with different whitespace and log file
+		`[0, ["call", "bufname", "/work/main.go"]]`,
+		`[0,["ex","let s:govim_logfile=\"/tmp/replay.log\""]]`,
+		`[1,"ok"]`,
+		`[0,["echo","unexpected"]]`,
+	} {
+		recv <- json.RawMessage(m)
+	}
+	close(recv)
+
+	var sent []string
+	var divergences []string
+	r := &replayer{
+		stream:  recording.Vim,
+		entries: entries,
+		norm:    norm,
+		timeout: time.Second,
+		recv:    recv,
+		send: func(msg json.RawMessage) error {
+			sent = append(sent, string(msg))
+			return nil
+		},
+		report: func(format string, args ...interface{}) {
+			divergences = append(divergences, fmt.Sprintf(format, args...))
+		},
+	}
+	r.run()
+
+	if want := []string{`[1,["loaded"]]`}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent %q; want %q", sent, want)
+	}
+	want := []string{
+		`entry 6: message not received: [0,["redraw",""]]`,
+		`unexpected message: [0,["echo","unexpected"]]`,
+	}
+	if !reflect.DeepEqual(divergences, want) {
+		t.Errorf("got divergences %q; want %q", divergences, want)
+	}
+}
+
+func TestReplayerIDs(t *testing.T) {
+	entry := func(dir recording.Direction, msg string) recording.Entry {
+		return recording.Entry{Stream: recording.Vim, Dir: dir, Msg: json.RawMessage(msg)}
+	}
+	entries := []recording.Entry{
+		entry(recording.Send, `[0,[1,"call","GOVIM_internal_SetStatusline",{}]]`),
+		entry(recording.Send, `[0,[2,"call","s:schedule",1]]`),
+		entry(recording.Recv, `[5,["callback",2,["",0]]]`),
+		entry(recording.Recv, `[6,["schedule",1]]`),
+		entry(recording.Send, `[0,[3,"expr","bufnr()"]]`),
+		entry(recording.Recv, `[7,["callback",3,["",1]]]`),
+	}
+	norm, err := newNormaliser("", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := make(chan json.RawMessage, 10)
+	for _, m := range []string{
+		// govim allocated the ids in a different order, and sent the last
+		// message earlier than in the recording
+		`[0,[1,"call","s:schedule",7]]`,
+		`[0,[2,"expr","bufnr()"]]`,
+		`[0,[3,"call","GOVIM_internal_SetStatusline",{}]]`,
+	} {
+		recv <- json.RawMessage(m)
+	}
+	close(recv)
+
+	var sent []string
+	var divergences []string
+	r := &replayer{
+		stream:  recording.Vim,
+		entries: entries,
+		norm:    norm,
+		timeout: time.Second,
+		recv:    recv,
+		send: func(msg json.RawMessage) error {
+			sent = append(sent, string(msg))
+			return nil
+		},
+		report: func(format string, args ...interface{}) {
+			divergences = append(divergences, fmt.Sprintf(format, args...))
+		},
+	}
+	r.run()
+
+	want := []string{
+		`[5,["callback",1,["",0]]]`,
+		`[6,["schedule",7]]`,
+		`[7,["callback",2,["",1]]]`,
+	}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent %q; want %q", sent, want)
+	}
+	if len(divergences) > 0 {
+		t.Errorf("unexpected divergences %q", divergences)
+	}
+}
+
+func TestReplayerEarlyCall(t *testing.T) {
+	entry := func(dir recording.Direction, msg string) recording.Entry {
+		return recording.Entry{Stream: recording.Vim, Dir: dir, Msg: json.RawMessage(msg)}
+	}
+	entries := []recording.Entry{
+		{Stream: recording.Driver, Dir: recording.Recv, Msg: json.RawMessage(`["ex","e main.go"]`)},
+		entry(recording.Send, `[0,[1,"call","s:schedule",1]]`),
+		entry(recording.Recv, `[5,["callback",1,["",0]]]`),
+		entry(recording.Send, `[0,[2,"call","GOVIM_internal_SetStatusline",{}]]`),
+		entry(recording.Recv, `[6,["callback",2,["",0]]]`),
+	}
+	norm, err := newNormaliser("", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := make(chan json.RawMessage, 10)
+
+	var issued []string
+	var sent []string
+	var divergences []string
+	r := &replayer{
+		stream:  recording.Vim,
+		entries: entries,
+		norm:    norm,
+		timeout: time.Second,
+		recv:    recv,
+		issue: func(cmd json.RawMessage) error {
+			issued = append(issued, string(cmd))
+			// govim makes a call that came later in the recording, and
+			// only schedules the command once it has the reply
+			recv <- json.RawMessage(`[0,[1,"call","GOVIM_internal_SetStatusline",{}]]`)
+			return nil
+		},
+		send: func(msg json.RawMessage) error {
+			sent = append(sent, string(msg))
+			if len(sent) == 1 {
+				recv <- json.RawMessage(`[0,[2,"call","s:schedule",3]]`)
+				close(recv)
+			}
+			return nil
+		},
+		report: func(format string, args ...interface{}) {
+			divergences = append(divergences, fmt.Sprintf(format, args...))
+		},
+	}
+	r.run()
+
+	if want := []string{`["ex","e main.go"]`}; !reflect.DeepEqual(issued, want) {
+		t.Errorf("issued %q; want %q", issued, want)
+	}
+	want := []string{
+		`[6,["callback",1,["",0]]]`,
+		`[5,["callback",2,["",0]]]`,
+	}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent %q; want %q", sent, want)
+	}
+	if len(divergences) > 0 {
+		t.Errorf("unexpected divergences %q", divergences)
+	}
+}
+
+func TestFraming(t *testing.T) {
+	var buf bytes.Buffer
+	msgs := []string{`{"id":1}`, `{"method":"exit"}`}
+	for _, m := range msgs {
+		if err := writeFramed(&buf, json.RawMessage(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	br := bufio.NewReader(&buf)
+	for _, want := range msgs {
+		got, err := readFramed(br)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("got %s; want %s", got, want)
+		}
+	}
+}
@@ -73,12 +73,15 @@ type TestDriver struct {
 	log     io.Writer
 	debug   Debug
 
+	flavor  govim.Flavor
 	ptySize *pty.Winsize
 	cmd     *exec.Cmd
 
 	name string
 
 	plugin govim.Plugin
+	tap    func(in io.Reader, out io.Writer) (io.Reader, io.Writer, error)
+	record func(cmd json.RawMessage)
 
 	quitVim    chan bool
 	quitGovim  chan bool
@@ -105,6 +108,15 @@ type Config struct {
 	Log     io.Writer
 	*testscript.Env
 	Plugin govim.Plugin
+
+	// Tap, if set, is called with the connection from Vim before govim is
+	// created, and returns the reader and writer that govim should use in its
+	// place, e.g. to record the messages exchanged with Vim
+	Tap func(in io.Reader, out io.Writer) (io.Reader, io.Writer, error)
+
+	// RecordCommand, if set, is called with each command that a script
+	// sends to the driver, before govim issues it to Vim
+	RecordCommand func(cmd json.RawMessage)
 }
 
 type VimConfig struct {
@@ -133,6 +145,8 @@ func NewTestDriver(c *Config) (*TestDriver, error) {
 		name: c.Name,
 
 		plugin: c.Plugin,
+		tap:    c.Tap,
+		record: c.RecordCommand,
 	}
 	if c.Log != nil {
 		res.readLog = c.ReadLog
@@ -166,6 +180,12 @@ func NewTestDriver(c *Config) (*TestDriver, error) {
 	case govim.FlavorGvim:
 		srcVimrc = filepath.Join(c.GovimPath, "cmd", "govim", "config", "minimal.gvimrc")
 		dstVimrc = filepath.Join(c.TestHomePath, ".gvimrc")
+	case govim.FlavorNeovim:
+		srcVimrc = filepath.Join(c.GovimPath, "cmd", "govim", "config", "minimal.init.vim")
+		dstVimrc = filepath.Join(c.TestHomePath, ".config", "nvim", "init.vim")
+		if err := os.MkdirAll(filepath.Dir(dstVimrc), 0777); err != nil {
+			return nil, fmt.Errorf("failed to create Neovim config directory: %v", err)
+		}
 	default:
 		return nil, fmt.Errorf("need to add vimrc behaviour for flavour %v", flav)
 	}
@@ -179,6 +199,13 @@ func NewTestDriver(c *Config) (*TestDriver, error) {
 	}
 	// add a blank line for good measure
 	dstVimrcBuf.WriteString("\n\" ======== TEST-ONLY ADDITIONS =======\n\n")
+	if flav == govim.FlavorNeovim {
+		// The plugin is installed as a Vim package, i.e. at
+		// PACKPATH/pack/*/start/govim, which Neovim does not look in by
+		// default
+		packpath := filepath.Dir(filepath.Dir(filepath.Dir(filepath.Dir(c.TestPluginPath))))
+		fmt.Fprintf(&dstVimrcBuf, "set packpath^=%v\n", packpath)
+	}
 	// add test-only VimScript
 	testFns := filepath.Join(c.GovimPath, "testdriver", "test_functions.vim")
 	if contents, err := os.ReadFile(testFns); err != nil {
@@ -190,6 +217,7 @@ func NewTestDriver(c *Config) (*TestDriver, error) {
 		return nil, fmt.Errorf("failed to write %v: %v", dstVimrc, err)
 	}
 
+	res.flavor = flav
 	res.govimListener = gl
 	res.driverListener = dl
 
@@ -351,6 +379,9 @@ func (d *TestDriver) Wait() error {
 }
 
 func (d *TestDriver) runVim() error {
+	if d.flavor == govim.FlavorNeovim {
+		return d.runNeovim()
+	}
 	thepty, err := pty.StartWithSize(d.cmd, d.ptySize)
 	if err != nil {
 		close(d.doneQuitVim)
@@ -382,6 +413,46 @@ func (d *TestDriver) runVim() error {
 	return nil
 }
 
+// runNeovim runs Neovim embedded, i.e. with its msgpack-RPC UI channel on
+// stdin and stdout, rather than on a pty. We never attach a UI, so stdout is
+// simply drained. Neovim exits when its stdin is closed, so we connect stdin
+// to a pipe that stays open until Neovim exits.
+func (d *TestDriver) runNeovim() error {
+	_, err := d.cmd.StdinPipe()
+	var stdout io.ReadCloser
+	if err == nil {
+		stdout, err = d.cmd.StdoutPipe()
+	}
+	if err == nil {
+		err = d.cmd.Start()
+	}
+	if err != nil {
+		close(d.doneQuitVim)
+		err := fmt.Errorf("failed to start %v: %v", strings.Join(d.cmd.Args, " "), err)
+		d.Logf("error: %+v", err)
+		return err
+	}
+	d.tombgo(func() error {
+		defer close(d.doneQuitVim)
+		if err := d.cmd.Wait(); err != nil {
+			select {
+			case <-d.quitVim:
+			default:
+				return fmt.Errorf("neovim exited: %v", err)
+			}
+		}
+		return nil
+	})
+
+	if d.debug.Enabled {
+		d.LogStripANSI(stdout)
+	} else {
+		io.Copy(io.Discard, stdout)
+	}
+
+	return nil
+}
+
 func (d *TestDriver) Close() {
 	d.closeLock.Lock()
 	if d.closed {
@@ -478,7 +549,19 @@ func (d *TestDriver) listenGovim() error {
 	if d.log != nil {
 		log = d.log
 	}
-	g, err := govim.NewGovim(d.plugin, conn, conn, log, nil, &d.tomb)
+	newGovim := govim.NewGovim
+	if d.flavor == govim.FlavorNeovim {
+		newGovim = govim.NewNeovim
+	}
+	var in io.Reader = conn
+	var out io.Writer = conn
+	if d.tap != nil {
+		in, out, err = d.tap(conn, conn)
+		if err != nil {
+			return fmt.Errorf("failed to tap the connection from Vim: %v", err)
+		}
+	}
+	g, err := newGovim(d.plugin, in, out, log, nil, &d.tomb)
 	if err != nil {
 		return fmt.Errorf("failed to create govim: %v", err)
 	}
@@ -517,11 +600,17 @@ func (d *TestDriver) listenDriver() error {
 				}
 			}
 			dec := json.NewDecoder(conn)
-			var args []interface{}
-			if err := dec.Decode(&args); err != nil {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
 				panic(fmt.Errorf("failed to read command for driver: %v", err))
 			}
-			cmd := args[0]
+			var args []interface{}
+			if err := json.Unmarshal(raw, &args); err != nil {
+				panic(fmt.Errorf("failed to decode command for driver: %v", err))
+			}
+			if d.record != nil {
+				d.record(raw)
+			}
 			res := []interface{}{""}
 			add := func(err error, is ...interface{}) {
 				toAdd := []interface{}{""}
@@ -552,45 +641,11 @@ func (d *TestDriver) listenDriver() error {
 				}
 				return ch
 			}
-			switch cmd {
-			case "redraw":
-				var force string
-				if len(args) == 2 {
-					force = args[1].(string)
-				}
-				<-schedule(func(g govim.Govim) error {
-					add(g.ChannelRedraw(force == "force"))
-					return nil
-				})
-			case "ex":
-				expr := args[1].(string)
-				<-schedule(func(g govim.Govim) error {
-					add(g.ChannelEx(expr))
-					return nil
-				})
-			case "normal":
-				expr := args[1].(string)
-				<-schedule(func(g govim.Govim) error {
-					add(g.ChannelNormal(expr))
-					return nil
-				})
-			case "expr":
-				expr := args[1].(string)
-				<-schedule(func(g govim.Govim) error {
-					resp, err := g.ChannelExpr(expr)
-					add(err, resp)
-					return nil
-				})
-			case "call":
-				fn := args[1].(string)
-				<-schedule(func(g govim.Govim) error {
-					resp, err := g.ChannelCall(fn, args[2:]...)
-					add(err, resp)
-					return nil
-				})
-			default:
-				panic(fmt.Errorf("don't yet know how to handle %v", cmd))
+			f, err := driverCommand(args, add)
+			if err != nil {
+				panic(err)
 			}
+			<-schedule(f)
 			enc := json.NewEncoder(conn)
 			if err := enc.Encode(res); err != nil {
 				panic(fmt.Errorf("failed to encode response %v: %v", res, err))
@@ -605,6 +660,50 @@ func (d *TestDriver) listenDriver() error {
 	return nil
 }
 
+// driverCommand returns the function that issues args, a command sent to the
+// driver, to Vim. add is called with the result of each call to Vim.
+func driverCommand(args []interface{}, add func(err error, is ...interface{})) (func(govim.Govim) error, error) {
+	switch cmd := args[0]; cmd {
+	case "redraw":
+		var force string
+		if len(args) == 2 {
+			force = args[1].(string)
+		}
+		return func(g govim.Govim) error {
+			add(g.ChannelRedraw(force == "force"))
+			return nil
+		}, nil
+	case "ex":
+		expr := args[1].(string)
+		return func(g govim.Govim) error {
+			add(g.ChannelEx(expr))
+			return nil
+		}, nil
+	case "normal":
+		expr := args[1].(string)
+		return func(g govim.Govim) error {
+			add(g.ChannelNormal(expr))
+			return nil
+		}, nil
+	case "expr":
+		expr := args[1].(string)
+		return func(g govim.Govim) error {
+			resp, err := g.ChannelExpr(expr)
+			add(err, resp)
+			return nil
+		}, nil
+	case "call":
+		fn := args[1].(string)
+		return func(g govim.Govim) error {
+			resp, err := g.ChannelCall(fn, args[2:]...)
+			add(err, resp)
+			return nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("don't yet know how to handle %v", cmd)
+	}
+}
+
 // Vim is a sidecar that effectively drives Vim via a simple JSON-based
 // API
 func Vim() (exitCode int) {
@@ -888,6 +987,9 @@ func Sleep(ts *testscript.TestScript, neg bool, args []string) {
 // [gvim]
 // Satisfied if we are testing with gvim
 //
+// [neovim]
+// Satisfied if we are testing with neovim
+//
 // [v1.2.3]
 // Satisfied if we are running vim/gvim/whatever version >=v1.2.3
 func Condition(cond string) (bool, error) {
@@ -920,7 +1022,7 @@ func Condition(cond string) (bool, error) {
 	// Is the condition a semver version? If so, check
 	// against the version of vim running
 	if semver.IsValid(cond) {
-		version, err := getVimFlavourVersion(cmd)
+		version, err := getVimFlavourVersion(envf, cmd)
 		if err != nil {
 			return false, err
 		}
@@ -935,13 +1037,18 @@ func Condition(cond string) (bool, error) {
 		f = govim.FlavorVim
 	case govim.FlavorGvim.String():
 		f = govim.FlavorGvim
+	case govim.FlavorNeovim.String():
+		f = govim.FlavorNeovim
 	default:
 		return false, fmt.Errorf("unknown condition %v", cond)
 	}
 	return envf == f, nil
 }
 
-func getVimFlavourVersion(c testsetup.Command) (string, error) {
+func getVimFlavourVersion(f govim.Flavor, c testsetup.Command) (string, error) {
+	if f == govim.FlavorNeovim {
+		return getNeovimVersion(c)
+	}
 	allArgs := make([]string, len(c))
 	// testscript prepends PATH with the directory that contain
 	// subcommands. Since we are going to execute vim here, and also
@@ -973,6 +1080,22 @@ func getVimFlavourVersion(c testsetup.Command) (string, error) {
 	return version, nil
 }
 
+// getNeovimVersion returns the version of the Neovim run by c, from the first
+// line of the output of nvim --version, e.g. "NVIM v0.9.5"
+func getNeovimVersion(c testsetup.Command) (string, error) {
+	cmd := exec.Command(c[0], "--version")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to get version from Neovim via %v: %v\n%s", strings.Join(cmd.Args, " "), err, out)
+	}
+	line, _, _ := strings.Cut(string(out), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 2 || !semver.IsValid(fields[1]) {
+		return "", fmt.Errorf("failed to parse Neovim version from %q", line)
+	}
+	return fields[1], nil
+}
+
 type LockingBuffer struct {
 	lock          sync.Mutex
 	und           bytes.Buffer
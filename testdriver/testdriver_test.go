@@ -13,11 +13,11 @@ func TestCondition(t *testing.T) {
 	t.Cleanup(cleanupEnvVars(testsetup.EnvTestscriptIssues))
 	t.Cleanup(cleanupEnvVars(testsetup.EnvVimFlavor))
 
-	_, cmd, err := testsetup.EnvLookupFlavorCommand()
+	flav, cmd, err := testsetup.EnvLookupFlavorCommand()
 	if err != nil {
 		t.Fatalf("failed to derive flavor command: %v", err)
 	}
-	vimVersion, err := getVimFlavourVersion(cmd)
+	vimVersion, err := getVimFlavourVersion(flav, cmd)
 	if err != nil {
 		t.Fatalf("failed to get vim flavour version: %v", err)
 	}
@@ -70,6 +70,17 @@ func TestCondition(t *testing.T) {
 			flavorenv: pS("gvim"),
 			cond:      "vim",
 		},
+		{
+			desc:      "neovim test",
+			flavorenv: pS("neovim"),
+			cond:      "neovim",
+			satisfied: true,
+		},
+		{
+			desc:      "neovim test running with vim",
+			flavorenv: pS("vim"),
+			cond:      "neovim",
+		},
 		{
 			// This is a bit like marking our own homework because we
 			// are contriving the result of satisfied, but that's fine;
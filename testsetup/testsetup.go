@@ -51,11 +51,18 @@ const (
 // vim versions
 const (
 	LatestVim = "v9.1.0412"
+
+	// LatestNeovim is the version of Neovim tested on CI
+	LatestNeovim = "v0.9.5"
 )
 
 var (
 	VimCommand  = Command{"vim"}
 	GvimCommand = Command{"xvfb-run", "-a", "gvim", "-f"}
+
+	// NeovimCommand runs Neovim without a UI. Neovim talks msgpack-RPC to
+	// its embedder on stdin and stdout, and exits when stdin is closed.
+	NeovimCommand = Command{"nvim", "--embed", "--headless"}
 )
 
 type Command []string
@@ -145,6 +152,8 @@ func EnvLookupFlavorCommand() (flav govim.Flavor, cmd Command, err error) {
 		cmd = VimCommand
 	case govim.FlavorGvim:
 		cmd = GvimCommand
+	case govim.FlavorNeovim:
+		cmd = NeovimCommand
 	}
 	return flav, cmd, nil
 }
@@ -0,0 +1,50 @@
+package govim
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// transport carries the messages exchanged between govim and Vim. A message
+// is identified by an id and carries a JSON-encoded payload, as per the JSON
+// messages of a Vim channel: see
+// https://vimhelp.org/channel.txt.html#channel-use. The id of a request from
+// Vim is positive, and is used to respond to that request; messages sent to
+// Vim that are not responses have an id of 0.
+type transport interface {
+	// read reads the next message from Vim. It returns io.EOF when there are
+	// no further messages.
+	read() (int, json.RawMessage, error)
+
+	// write sends a message to Vim. write is not called concurrently.
+	write(id int, payload interface{}) error
+}
+
+// jsonTransport is the transport of a Vim channel in JSON mode
+type jsonTransport struct {
+	in  *json.Decoder
+	out *json.Encoder
+}
+
+func newJSONTransport(in io.Reader, out io.Writer) *jsonTransport {
+	return &jsonTransport{
+		in:  json.NewDecoder(in),
+		out: json.NewEncoder(out),
+	}
+}
+
+func (t *jsonTransport) read() (int, json.RawMessage, error) {
+	var msg [2]json.RawMessage
+	if err := t.in.Decode(&msg); err != nil {
+		return 0, nil, err
+	}
+	var id int
+	if err := json.Unmarshal(msg[0], &id); err != nil {
+		return 0, nil, err
+	}
+	return id, msg[1], nil
+}
+
+func (t *jsonTransport) write(id int, payload interface{}) error {
+	return t.out.Encode([]interface{}{id, payload})
+}
@@ -3,11 +3,20 @@ package govim
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 )
 
 type Viewport struct {
 	Current WinInfo
 	Windows []WinInfo
+	Cursor  Cursor
+}
+
+// Cursor is the position of the cursor in the current window
+type Cursor struct {
+	BufNr int `json:"bufnr"`
+	Line  int `json:"line"`
+	Col   int `json:"col"`
 }
 
 type WinInfo struct {
@@ -29,12 +38,16 @@ type WinInfo struct {
 
 // Viewport returns the active Vim viewport
 func (g *govimImpl) Viewport() (vp Viewport, err error) {
-	// Calling s:buildCurrentViewport is something of a legacy from the previous
-	// attempt to have Vim push viewport changes to the plugin. In that setup,
-	// we called s:buildCurrentViewport on a timer and pushed changes to govim
-	// if the viewport had changed. For easy of reverting the change to remove the
-	// viewport code, we leave s:buildCurrentViewport in place, not least because
-	// it's nice an efficient to do it as one-shot in VimScript.
+	// While there are subscribers to viewport changes the cached viewport is
+	// kept up to date by Vim, so there is no need for a round trip
+	g.viewportLock.Lock()
+	if g.viewport != nil {
+		vp = *g.viewport
+		vp.Windows = append([]WinInfo(nil), vp.Windows...)
+		g.viewportLock.Unlock()
+		return
+	}
+	g.viewportLock.Unlock()
 	res, err := g.Scheduled().ChannelExpr("s:buildCurrentViewport()")
 	if err != nil {
 		err = fmt.Errorf("failed to build current viewport: %v", err)
@@ -44,6 +57,153 @@ func (g *govimImpl) Viewport() (vp Viewport, err error) {
 	return
 }
 
+// viewportSubscriber is a subscriber to changes in the viewport
+type viewportSubscriber func(Govim, Viewport) error
+
+// viewportDelta is a change in the viewport pushed by Vim: the id of the
+// current window if it has changed, the windows that are new or have
+// changed, the ids of the windows that have closed, and the cursor if it has
+// moved.
+type viewportDelta struct {
+	Current *int
+	Windows []WinInfo
+	Closed  []int
+	Cursor  *Cursor
+}
+
+// OnViewportChange implements Govim.OnViewportChange
+func (g *govimImpl) OnViewportChange(f func(Govim, Viewport) error) (func(), error) {
+	g.viewportCallLock.Lock()
+	defer g.viewportCallLock.Unlock()
+	g.viewportLock.Lock()
+	id := g.viewportNextID
+	g.viewportNextID++
+	g.viewportSubs[id] = f
+	subscribe := !g.viewportSubscribed
+	g.viewportSubscribed = true
+	g.viewportLock.Unlock()
+	cancel := func() {
+		g.unsubscribeViewport(id)
+	}
+	if !subscribe {
+		return cancel, nil
+	}
+	// Vim responds with the viewport at the point of subscription, to which
+	// the deltas it subsequently pushes apply
+	res, err := g.ChannelCall("s:subscribeViewport")
+	if err != nil {
+		g.viewportLock.Lock()
+		delete(g.viewportSubs, id)
+		g.viewportSubscribed = false
+		g.viewportPending = nil
+		g.viewportLock.Unlock()
+		return nil, fmt.Errorf("failed to subscribe to viewport changes: %v", err)
+	}
+	var vp Viewport
+	g.decodeJSON(res, &vp)
+	g.viewportLock.Lock()
+	g.viewport = &vp
+	pending := g.viewportPending
+	g.viewportPending = nil
+	g.viewportLock.Unlock()
+	for _, d := range pending {
+		g.applyViewportDelta(d)
+	}
+	return cancel, nil
+}
+
+// unsubscribeViewport removes the subscriber with the given id. Once no
+// subscribers remain, Vim is asked to stop pushing changes in the viewport,
+// and the cached viewport is dropped.
+func (g *govimImpl) unsubscribeViewport(id int) {
+	g.viewportCallLock.Lock()
+	defer g.viewportCallLock.Unlock()
+	g.viewportLock.Lock()
+	delete(g.viewportSubs, id)
+	if len(g.viewportSubs) > 0 || !g.viewportSubscribed {
+		g.viewportLock.Unlock()
+		return
+	}
+	g.viewportSubscribed = false
+	g.viewport = nil
+	g.viewportPending = nil
+	g.viewportLock.Unlock()
+	if _, err := g.ChannelCall("s:unsubscribeViewport"); err != nil {
+		g.Logf("failed to unsubscribe from viewport changes: %v", err)
+	}
+}
+
+// applyViewportDelta applies d to the cached viewport, and notifies the
+// subscribers of the result via the event queue. A delta that arrives before
+// the response to the subscription is held until then; one that arrives
+// after the last subscriber has gone is dropped.
+func (g *govimImpl) applyViewportDelta(d viewportDelta) {
+	g.viewportLock.Lock()
+	if !g.viewportSubscribed {
+		g.viewportLock.Unlock()
+		return
+	}
+	vp := g.viewport
+	if vp == nil {
+		g.viewportPending = append(g.viewportPending, d)
+		g.viewportLock.Unlock()
+		return
+	}
+	wins := make(map[int]WinInfo)
+	for _, w := range vp.Windows {
+		wins[w.WinID] = w
+	}
+	for _, w := range d.Windows {
+		wins[w.WinID] = w
+	}
+	for _, id := range d.Closed {
+		delete(wins, id)
+	}
+	current := vp.Current.WinID
+	if d.Current != nil {
+		current = *d.Current
+	}
+	// Build a new slice of windows, because subscribers may still hold the
+	// previous one
+	windows := make([]WinInfo, 0, len(wins))
+	for _, w := range wins {
+		windows = append(windows, w)
+	}
+	sort.Slice(windows, func(i, j int) bool {
+		if windows[i].TabNr != windows[j].TabNr {
+			return windows[i].TabNr < windows[j].TabNr
+		}
+		return windows[i].WinNr < windows[j].WinNr
+	})
+	vp.Windows = windows
+	vp.Current = wins[current]
+	if d.Cursor != nil {
+		vp.Cursor = *d.Cursor
+	}
+	snapshot := *vp
+	var ids []int
+	for id := range g.viewportSubs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	var subs []viewportSubscriber
+	for _, id := range ids {
+		subs = append(subs, g.viewportSubs[id])
+	}
+	g.viewportLock.Unlock()
+	if len(subs) == 0 {
+		return
+	}
+	g.Enqueue(func(g Govim) error {
+		for _, f := range subs {
+			if err := f(g, snapshot); err != nil {
+				g.Logf("viewport change subscriber failed: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
 func (wi *WinInfo) UnmarshalJSON(b []byte) error {
 	var w struct {
 		WinNr    int `json:"winnr"`
@@ -0,0 +1,1733 @@
+// Code generated by genvimfn from builtins.txt. DO NOT EDIT.
+
+package vimfn
+
+import "encoding/json"
+
+// BufAdd calls bufadd(); see :help bufadd().
+func (v Vim) BufAdd(name string) (int, error) {
+	var res int
+	err := v.call(&res, "bufadd", name)
+	return res, err
+}
+
+// BufAdd adds a call of bufadd() to the batch; see :help bufadd().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) BufAdd(name string) func() (int, error) {
+	r := b.b.ChannelCall("bufadd", name)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// BufExists calls bufexists(); see :help bufexists().
+func (v Vim) BufExists(buf interface{}) (int, error) {
+	var res int
+	err := v.call(&res, "bufexists", buf)
+	return res, err
+}
+
+// BufExists adds a call of bufexists() to the batch; see :help bufexists().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) BufExists(buf interface{}) func() (int, error) {
+	r := b.b.ChannelCall("bufexists", buf)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// BufNr calls bufnr(); see :help bufnr().
+func (v Vim) BufNr(buf ...interface{}) (int, error) {
+	args := []interface{}{}
+	for _, a := range buf {
+		args = append(args, a)
+	}
+	var res int
+	err := v.call(&res, "bufnr", args...)
+	return res, err
+}
+
+// BufNr adds a call of bufnr() to the batch; see :help bufnr().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) BufNr(buf ...interface{}) func() (int, error) {
+	args := []interface{}{}
+	for _, a := range buf {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("bufnr", args...)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// GetBufInfo calls getbufinfo(); see :help getbufinfo().
+func (v Vim) GetBufInfo(buf ...interface{}) ([]BufInfo, error) {
+	args := []interface{}{}
+	for _, a := range buf {
+		args = append(args, a)
+	}
+	var res []BufInfo
+	err := v.call(&res, "getbufinfo", args...)
+	return res, err
+}
+
+// GetBufInfo adds a call of getbufinfo() to the batch; see :help getbufinfo().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) GetBufInfo(buf ...interface{}) func() ([]BufInfo, error) {
+	args := []interface{}{}
+	for _, a := range buf {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("getbufinfo", args...)
+	return func() (res []BufInfo, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// GetBufLine calls getbufline(); see :help getbufline().
+func (v Vim) GetBufLine(buf interface{}, lnum interface{}, end ...interface{}) ([]string, error) {
+	args := []interface{}{buf, lnum}
+	for _, a := range end {
+		args = append(args, a)
+	}
+	var res []string
+	err := v.call(&res, "getbufline", args...)
+	return res, err
+}
+
+// GetBufLine adds a call of getbufline() to the batch; see :help getbufline().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) GetBufLine(buf interface{}, lnum interface{}, end ...interface{}) func() ([]string, error) {
+	args := []interface{}{buf, lnum}
+	for _, a := range end {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("getbufline", args...)
+	return func() (res []string, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// SetBufLine calls setbufline(); see :help setbufline().
+func (v Vim) SetBufLine(buf interface{}, lnum interface{}, text interface{}) (int, error) {
+	var res int
+	err := v.call(&res, "setbufline", buf, lnum, text)
+	return res, err
+}
+
+// SetBufLine adds a call of setbufline() to the batch; see :help setbufline().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) SetBufLine(buf interface{}, lnum interface{}, text interface{}) func() (int, error) {
+	r := b.b.ChannelCall("setbufline", buf, lnum, text)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// SetBufVar calls setbufvar(); see :help setbufvar().
+func (v Vim) SetBufVar(buf interface{}, varname string, val interface{}) error {
+	return v.call(nil, "setbufvar", buf, varname, val)
+}
+
+// SetBufVar adds a call of setbufvar() to the batch; see :help setbufvar().
+func (b Batch) SetBufVar(buf interface{}, varname string, val interface{}) {
+	b.b.ChannelCall("setbufvar", buf, varname, val)
+}
+
+// ListenerAdd calls listener_add(); see :help listener_add().
+func (v Vim) ListenerAdd(callback string, buf ...interface{}) (int, error) {
+	args := []interface{}{callback}
+	for _, a := range buf {
+		args = append(args, a)
+	}
+	var res int
+	err := v.call(&res, "listener_add", args...)
+	return res, err
+}
+
+// ListenerAdd adds a call of listener_add() to the batch; see :help listener_add().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) ListenerAdd(callback string, buf ...interface{}) func() (int, error) {
+	args := []interface{}{callback}
+	for _, a := range buf {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("listener_add", args...)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// ListenerRemove calls listener_remove(); see :help listener_remove().
+func (v Vim) ListenerRemove(id int) (int, error) {
+	var res int
+	err := v.call(&res, "listener_remove", id)
+	return res, err
+}
+
+// ListenerRemove adds a call of listener_remove() to the batch; see :help listener_remove().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) ListenerRemove(id int) func() (int, error) {
+	r := b.b.ChannelCall("listener_remove", id)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// Cursor calls cursor(); see :help cursor().
+func (v Vim) Cursor(lnum interface{}, col int, off ...int) (int, error) {
+	args := []interface{}{lnum, col}
+	for _, a := range off {
+		args = append(args, a)
+	}
+	var res int
+	err := v.call(&res, "cursor", args...)
+	return res, err
+}
+
+// Cursor adds a call of cursor() to the batch; see :help cursor().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) Cursor(lnum interface{}, col int, off ...int) func() (int, error) {
+	args := []interface{}{lnum, col}
+	for _, a := range off {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("cursor", args...)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// GetCwd calls getcwd(); see :help getcwd().
+func (v Vim) GetCwd(winnr ...int) (string, error) {
+	args := []interface{}{}
+	for _, a := range winnr {
+		args = append(args, a)
+	}
+	var res string
+	err := v.call(&res, "getcwd", args...)
+	return res, err
+}
+
+// GetCwd adds a call of getcwd() to the batch; see :help getcwd().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) GetCwd(winnr ...int) func() (string, error) {
+	args := []interface{}{}
+	for _, a := range winnr {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("getcwd", args...)
+	return func() (res string, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// WinGotoID calls win_gotoid(); see :help win_gotoid().
+func (v Vim) WinGotoID(winid int) (int, error) {
+	var res int
+	err := v.call(&res, "win_gotoid", winid)
+	return res, err
+}
+
+// WinGotoID adds a call of win_gotoid() to the batch; see :help win_gotoid().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) WinGotoID(winid int) func() (int, error) {
+	r := b.b.ChannelCall("win_gotoid", winid)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// WinWidth calls winwidth(); see :help winwidth().
+func (v Vim) WinWidth(winnr int) (int, error) {
+	var res int
+	err := v.call(&res, "winwidth", winnr)
+	return res, err
+}
+
+// WinWidth adds a call of winwidth() to the batch; see :help winwidth().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) WinWidth(winnr int) func() (int, error) {
+	r := b.b.ChannelCall("winwidth", winnr)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// ScreenPos calls screenpos(); see :help screenpos().
+func (v Vim) ScreenPos(winid int, lnum int, col int) (ScreenPosition, error) {
+	var res ScreenPosition
+	err := v.call(&res, "screenpos", winid, lnum, col)
+	return res, err
+}
+
+// ScreenPos adds a call of screenpos() to the batch; see :help screenpos().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) ScreenPos(winid int, lnum int, col int) func() (ScreenPosition, error) {
+	r := b.b.ChannelCall("screenpos", winid, lnum, col)
+	return func() (res ScreenPosition, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// PopupAtCursor calls popup_atcursor(); see :help popup_atcursor().
+func (v Vim) PopupAtCursor(what interface{}, options PopupOptions) (int, error) {
+	var res int
+	err := v.call(&res, "popup_atcursor", what, options)
+	return res, err
+}
+
+// PopupAtCursor adds a call of popup_atcursor() to the batch; see :help popup_atcursor().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) PopupAtCursor(what interface{}, options PopupOptions) func() (int, error) {
+	r := b.b.ChannelCall("popup_atcursor", what, options)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// PopupClear calls popup_clear(); see :help popup_clear().
+func (v Vim) PopupClear(force ...bool) error {
+	args := []interface{}{}
+	for _, a := range force {
+		args = append(args, a)
+	}
+	return v.call(nil, "popup_clear", args...)
+}
+
+// PopupClear adds a call of popup_clear() to the batch; see :help popup_clear().
+func (b Batch) PopupClear(force ...bool) {
+	args := []interface{}{}
+	for _, a := range force {
+		args = append(args, a)
+	}
+	b.b.ChannelCall("popup_clear", args...)
+}
+
+// PopupClose calls popup_close(); see :help popup_close().
+func (v Vim) PopupClose(id int, result ...interface{}) error {
+	args := []interface{}{id}
+	for _, a := range result {
+		args = append(args, a)
+	}
+	return v.call(nil, "popup_close", args...)
+}
+
+// PopupClose adds a call of popup_close() to the batch; see :help popup_close().
+func (b Batch) PopupClose(id int, result ...interface{}) {
+	args := []interface{}{id}
+	for _, a := range result {
+		args = append(args, a)
+	}
+	b.b.ChannelCall("popup_close", args...)
+}
+
+// PopupCreate calls popup_create(); see :help popup_create().
+func (v Vim) PopupCreate(what interface{}, options PopupOptions) (int, error) {
+	var res int
+	err := v.call(&res, "popup_create", what, options)
+	return res, err
+}
+
+// PopupCreate adds a call of popup_create() to the batch; see :help popup_create().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) PopupCreate(what interface{}, options PopupOptions) func() (int, error) {
+	r := b.b.ChannelCall("popup_create", what, options)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// PopupDialog calls popup_dialog(); see :help popup_dialog().
+func (v Vim) PopupDialog(what interface{}, options PopupOptions) (int, error) {
+	var res int
+	err := v.call(&res, "popup_dialog", what, options)
+	return res, err
+}
+
+// PopupDialog adds a call of popup_dialog() to the batch; see :help popup_dialog().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) PopupDialog(what interface{}, options PopupOptions) func() (int, error) {
+	r := b.b.ChannelCall("popup_dialog", what, options)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// PopupFindInfo calls popup_findinfo(); see :help popup_findinfo().
+func (v Vim) PopupFindInfo() (int, error) {
+	var res int
+	err := v.call(&res, "popup_findinfo")
+	return res, err
+}
+
+// PopupFindInfo adds a call of popup_findinfo() to the batch; see :help popup_findinfo().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) PopupFindInfo() func() (int, error) {
+	r := b.b.ChannelCall("popup_findinfo")
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// PopupGetOptions calls popup_getoptions(); see :help popup_getoptions().
+func (v Vim) PopupGetOptions(id int) (map[string]interface{}, error) {
+	var res map[string]interface{}
+	err := v.call(&res, "popup_getoptions", id)
+	return res, err
+}
+
+// PopupGetOptions adds a call of popup_getoptions() to the batch; see :help popup_getoptions().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) PopupGetOptions(id int) func() (map[string]interface{}, error) {
+	r := b.b.ChannelCall("popup_getoptions", id)
+	return func() (res map[string]interface{}, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// PopupGetPos calls popup_getpos(); see :help popup_getpos().
+func (v Vim) PopupGetPos(id int) (PopupPosition, error) {
+	var res PopupPosition
+	err := v.call(&res, "popup_getpos", id)
+	return res, err
+}
+
+// PopupGetPos adds a call of popup_getpos() to the batch; see :help popup_getpos().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) PopupGetPos(id int) func() (PopupPosition, error) {
+	r := b.b.ChannelCall("popup_getpos", id)
+	return func() (res PopupPosition, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// PopupHide calls popup_hide(); see :help popup_hide().
+func (v Vim) PopupHide(id int) error {
+	return v.call(nil, "popup_hide", id)
+}
+
+// PopupHide adds a call of popup_hide() to the batch; see :help popup_hide().
+func (b Batch) PopupHide(id int) {
+	b.b.ChannelCall("popup_hide", id)
+}
+
+// PopupList calls popup_list(); see :help popup_list().
+func (v Vim) PopupList() ([]int, error) {
+	var res []int
+	err := v.call(&res, "popup_list")
+	return res, err
+}
+
+// PopupList adds a call of popup_list() to the batch; see :help popup_list().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) PopupList() func() ([]int, error) {
+	r := b.b.ChannelCall("popup_list")
+	return func() (res []int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// PopupLocate calls popup_locate(); see :help popup_locate().
+func (v Vim) PopupLocate(row int, col int) (int, error) {
+	var res int
+	err := v.call(&res, "popup_locate", row, col)
+	return res, err
+}
+
+// PopupLocate adds a call of popup_locate() to the batch; see :help popup_locate().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) PopupLocate(row int, col int) func() (int, error) {
+	r := b.b.ChannelCall("popup_locate", row, col)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// PopupMenu calls popup_menu(); see :help popup_menu().
+func (v Vim) PopupMenu(what interface{}, options PopupOptions) (int, error) {
+	var res int
+	err := v.call(&res, "popup_menu", what, options)
+	return res, err
+}
+
+// PopupMenu adds a call of popup_menu() to the batch; see :help popup_menu().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) PopupMenu(what interface{}, options PopupOptions) func() (int, error) {
+	r := b.b.ChannelCall("popup_menu", what, options)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// PopupMove calls popup_move(); see :help popup_move().
+func (v Vim) PopupMove(id int, options PopupOptions) error {
+	return v.call(nil, "popup_move", id, options)
+}
+
+// PopupMove adds a call of popup_move() to the batch; see :help popup_move().
+func (b Batch) PopupMove(id int, options PopupOptions) {
+	b.b.ChannelCall("popup_move", id, options)
+}
+
+// PopupNotification calls popup_notification(); see :help popup_notification().
+func (v Vim) PopupNotification(what interface{}, options PopupOptions) (int, error) {
+	var res int
+	err := v.call(&res, "popup_notification", what, options)
+	return res, err
+}
+
+// PopupNotification adds a call of popup_notification() to the batch; see :help popup_notification().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) PopupNotification(what interface{}, options PopupOptions) func() (int, error) {
+	r := b.b.ChannelCall("popup_notification", what, options)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// PopupSetOptions calls popup_setoptions(); see :help popup_setoptions().
+func (v Vim) PopupSetOptions(id int, options PopupOptions) error {
+	return v.call(nil, "popup_setoptions", id, options)
+}
+
+// PopupSetOptions adds a call of popup_setoptions() to the batch; see :help popup_setoptions().
+func (b Batch) PopupSetOptions(id int, options PopupOptions) {
+	b.b.ChannelCall("popup_setoptions", id, options)
+}
+
+// PopupSetText calls popup_settext(); see :help popup_settext().
+func (v Vim) PopupSetText(id int, text interface{}) error {
+	return v.call(nil, "popup_settext", id, text)
+}
+
+// PopupSetText adds a call of popup_settext() to the batch; see :help popup_settext().
+func (b Batch) PopupSetText(id int, text interface{}) {
+	b.b.ChannelCall("popup_settext", id, text)
+}
+
+// PopupShow calls popup_show(); see :help popup_show().
+func (v Vim) PopupShow(id int) error {
+	return v.call(nil, "popup_show", id)
+}
+
+// PopupShow adds a call of popup_show() to the batch; see :help popup_show().
+func (b Batch) PopupShow(id int) {
+	b.b.ChannelCall("popup_show", id)
+}
+
+// PropAdd calls prop_add(); see :help prop_add().
+func (v Vim) PropAdd(lnum int, col int, props PropOptions) error {
+	return v.call(nil, "prop_add", lnum, col, props)
+}
+
+// PropAdd adds a call of prop_add() to the batch; see :help prop_add().
+func (b Batch) PropAdd(lnum int, col int, props PropOptions) {
+	b.b.ChannelCall("prop_add", lnum, col, props)
+}
+
+// PropClear calls prop_clear(); see :help prop_clear().
+func (v Vim) PropClear(lnum int, lnumEnd ...interface{}) error {
+	args := []interface{}{lnum}
+	for _, a := range lnumEnd {
+		args = append(args, a)
+	}
+	return v.call(nil, "prop_clear", args...)
+}
+
+// PropClear adds a call of prop_clear() to the batch; see :help prop_clear().
+func (b Batch) PropClear(lnum int, lnumEnd ...interface{}) {
+	args := []interface{}{lnum}
+	for _, a := range lnumEnd {
+		args = append(args, a)
+	}
+	b.b.ChannelCall("prop_clear", args...)
+}
+
+// PropFind calls prop_find(); see :help prop_find().
+func (v Vim) PropFind(props PropOptions, direction ...string) (Prop, error) {
+	args := []interface{}{props}
+	for _, a := range direction {
+		args = append(args, a)
+	}
+	var res Prop
+	err := v.call(&res, "prop_find", args...)
+	return res, err
+}
+
+// PropFind adds a call of prop_find() to the batch; see :help prop_find().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) PropFind(props PropOptions, direction ...string) func() (Prop, error) {
+	args := []interface{}{props}
+	for _, a := range direction {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("prop_find", args...)
+	return func() (res Prop, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// PropList calls prop_list(); see :help prop_list().
+func (v Vim) PropList(lnum int, props ...PropOptions) ([]Prop, error) {
+	args := []interface{}{lnum}
+	for _, a := range props {
+		args = append(args, a)
+	}
+	var res []Prop
+	err := v.call(&res, "prop_list", args...)
+	return res, err
+}
+
+// PropList adds a call of prop_list() to the batch; see :help prop_list().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) PropList(lnum int, props ...PropOptions) func() ([]Prop, error) {
+	args := []interface{}{lnum}
+	for _, a := range props {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("prop_list", args...)
+	return func() (res []Prop, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// PropRemove calls prop_remove(); see :help prop_remove().
+func (v Vim) PropRemove(props PropOptions, lnum ...int) (int, error) {
+	args := []interface{}{props}
+	for _, a := range lnum {
+		args = append(args, a)
+	}
+	var res int
+	err := v.call(&res, "prop_remove", args...)
+	return res, err
+}
+
+// PropRemove adds a call of prop_remove() to the batch; see :help prop_remove().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) PropRemove(props PropOptions, lnum ...int) func() (int, error) {
+	args := []interface{}{props}
+	for _, a := range lnum {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("prop_remove", args...)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// PropTypeAdd calls prop_type_add(); see :help prop_type_add().
+func (v Vim) PropTypeAdd(name string, props PropTypeOptions) error {
+	return v.call(nil, "prop_type_add", name, props)
+}
+
+// PropTypeAdd adds a call of prop_type_add() to the batch; see :help prop_type_add().
+func (b Batch) PropTypeAdd(name string, props PropTypeOptions) {
+	b.b.ChannelCall("prop_type_add", name, props)
+}
+
+// PropTypeChange calls prop_type_change(); see :help prop_type_change().
+func (v Vim) PropTypeChange(name string, props PropTypeOptions) error {
+	return v.call(nil, "prop_type_change", name, props)
+}
+
+// PropTypeChange adds a call of prop_type_change() to the batch; see :help prop_type_change().
+func (b Batch) PropTypeChange(name string, props PropTypeOptions) {
+	b.b.ChannelCall("prop_type_change", name, props)
+}
+
+// PropTypeDelete calls prop_type_delete(); see :help prop_type_delete().
+func (v Vim) PropTypeDelete(name string, props ...PropTypeOptions) error {
+	args := []interface{}{name}
+	for _, a := range props {
+		args = append(args, a)
+	}
+	return v.call(nil, "prop_type_delete", args...)
+}
+
+// PropTypeDelete adds a call of prop_type_delete() to the batch; see :help prop_type_delete().
+func (b Batch) PropTypeDelete(name string, props ...PropTypeOptions) {
+	args := []interface{}{name}
+	for _, a := range props {
+		args = append(args, a)
+	}
+	b.b.ChannelCall("prop_type_delete", args...)
+}
+
+// PropTypeGet calls prop_type_get(); see :help prop_type_get().
+func (v Vim) PropTypeGet(name string, props ...PropTypeOptions) (PropType, error) {
+	args := []interface{}{name}
+	for _, a := range props {
+		args = append(args, a)
+	}
+	var res PropType
+	err := v.call(&res, "prop_type_get", args...)
+	return res, err
+}
+
+// PropTypeGet adds a call of prop_type_get() to the batch; see :help prop_type_get().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) PropTypeGet(name string, props ...PropTypeOptions) func() (PropType, error) {
+	args := []interface{}{name}
+	for _, a := range props {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("prop_type_get", args...)
+	return func() (res PropType, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// PropTypeList calls prop_type_list(); see :help prop_type_list().
+func (v Vim) PropTypeList(props ...PropTypeOptions) ([]string, error) {
+	args := []interface{}{}
+	for _, a := range props {
+		args = append(args, a)
+	}
+	var res []string
+	err := v.call(&res, "prop_type_list", args...)
+	return res, err
+}
+
+// PropTypeList adds a call of prop_type_list() to the batch; see :help prop_type_list().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) PropTypeList(props ...PropTypeOptions) func() ([]string, error) {
+	args := []interface{}{}
+	for _, a := range props {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("prop_type_list", args...)
+	return func() (res []string, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// SignDefine calls sign_define(); see :help sign_define().
+func (v Vim) SignDefine(name string, dict SignDefinition) (int, error) {
+	var res int
+	err := v.call(&res, "sign_define", name, dict)
+	return res, err
+}
+
+// SignDefine adds a call of sign_define() to the batch; see :help sign_define().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) SignDefine(name string, dict SignDefinition) func() (int, error) {
+	r := b.b.ChannelCall("sign_define", name, dict)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// SignGetDefined calls sign_getdefined(); see :help sign_getdefined().
+func (v Vim) SignGetDefined(name ...string) ([]SignDefinition, error) {
+	args := []interface{}{}
+	for _, a := range name {
+		args = append(args, a)
+	}
+	var res []SignDefinition
+	err := v.call(&res, "sign_getdefined", args...)
+	return res, err
+}
+
+// SignGetDefined adds a call of sign_getdefined() to the batch; see :help sign_getdefined().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) SignGetDefined(name ...string) func() ([]SignDefinition, error) {
+	args := []interface{}{}
+	for _, a := range name {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("sign_getdefined", args...)
+	return func() (res []SignDefinition, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// SignGetPlaced calls sign_getplaced(); see :help sign_getplaced().
+func (v Vim) SignGetPlaced(buf interface{}, dict ...SignPlaceOptions) ([]BufSigns, error) {
+	args := []interface{}{buf}
+	for _, a := range dict {
+		args = append(args, a)
+	}
+	var res []BufSigns
+	err := v.call(&res, "sign_getplaced", args...)
+	return res, err
+}
+
+// SignGetPlaced adds a call of sign_getplaced() to the batch; see :help sign_getplaced().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) SignGetPlaced(buf interface{}, dict ...SignPlaceOptions) func() ([]BufSigns, error) {
+	args := []interface{}{buf}
+	for _, a := range dict {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("sign_getplaced", args...)
+	return func() (res []BufSigns, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// SignPlace calls sign_place(); see :help sign_place().
+func (v Vim) SignPlace(id int, group string, name string, buf interface{}, dict ...SignPlaceOptions) (int, error) {
+	args := []interface{}{id, group, name, buf}
+	for _, a := range dict {
+		args = append(args, a)
+	}
+	var res int
+	err := v.call(&res, "sign_place", args...)
+	return res, err
+}
+
+// SignPlace adds a call of sign_place() to the batch; see :help sign_place().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) SignPlace(id int, group string, name string, buf interface{}, dict ...SignPlaceOptions) func() (int, error) {
+	args := []interface{}{id, group, name, buf}
+	for _, a := range dict {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("sign_place", args...)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// SignPlaceList calls sign_placelist(); see :help sign_placelist().
+func (v Vim) SignPlaceList(list []SignPlacement) ([]int, error) {
+	var res []int
+	err := v.call(&res, "sign_placelist", list)
+	return res, err
+}
+
+// SignPlaceList adds a call of sign_placelist() to the batch; see :help sign_placelist().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) SignPlaceList(list []SignPlacement) func() ([]int, error) {
+	r := b.b.ChannelCall("sign_placelist", list)
+	return func() (res []int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// SignUndefine calls sign_undefine(); see :help sign_undefine().
+func (v Vim) SignUndefine(name ...string) (int, error) {
+	args := []interface{}{}
+	for _, a := range name {
+		args = append(args, a)
+	}
+	var res int
+	err := v.call(&res, "sign_undefine", args...)
+	return res, err
+}
+
+// SignUndefine adds a call of sign_undefine() to the batch; see :help sign_undefine().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) SignUndefine(name ...string) func() (int, error) {
+	args := []interface{}{}
+	for _, a := range name {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("sign_undefine", args...)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// SignUnplace calls sign_unplace(); see :help sign_unplace().
+func (v Vim) SignUnplace(group string, dict ...SignPlaceOptions) (int, error) {
+	args := []interface{}{group}
+	for _, a := range dict {
+		args = append(args, a)
+	}
+	var res int
+	err := v.call(&res, "sign_unplace", args...)
+	return res, err
+}
+
+// SignUnplace adds a call of sign_unplace() to the batch; see :help sign_unplace().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) SignUnplace(group string, dict ...SignPlaceOptions) func() (int, error) {
+	args := []interface{}{group}
+	for _, a := range dict {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("sign_unplace", args...)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// SignUnplaceList calls sign_unplacelist(); see :help sign_unplacelist().
+func (v Vim) SignUnplaceList(list []SignPlacement) ([]int, error) {
+	var res []int
+	err := v.call(&res, "sign_unplacelist", list)
+	return res, err
+}
+
+// SignUnplaceList adds a call of sign_unplacelist() to the batch; see :help sign_unplacelist().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) SignUnplaceList(list []SignPlacement) func() ([]int, error) {
+	r := b.b.ChannelCall("sign_unplacelist", list)
+	return func() (res []int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// GetQFList calls getqflist(); see :help getqflist().
+func (v Vim) GetQFList(what ...QFListProperties) (interface{}, error) {
+	args := []interface{}{}
+	for _, a := range what {
+		args = append(args, a)
+	}
+	var res interface{}
+	err := v.call(&res, "getqflist", args...)
+	return res, err
+}
+
+// GetQFList adds a call of getqflist() to the batch; see :help getqflist().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) GetQFList(what ...QFListProperties) func() (interface{}, error) {
+	args := []interface{}{}
+	for _, a := range what {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("getqflist", args...)
+	return func() (res interface{}, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// SetLocList calls setloclist(); see :help setloclist().
+func (v Vim) SetLocList(nr int, list []QFEntry, action ...interface{}) (int, error) {
+	args := []interface{}{nr, list}
+	for _, a := range action {
+		args = append(args, a)
+	}
+	var res int
+	err := v.call(&res, "setloclist", args...)
+	return res, err
+}
+
+// SetLocList adds a call of setloclist() to the batch; see :help setloclist().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) SetLocList(nr int, list []QFEntry, action ...interface{}) func() (int, error) {
+	args := []interface{}{nr, list}
+	for _, a := range action {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("setloclist", args...)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// SetQFList calls setqflist(); see :help setqflist().
+func (v Vim) SetQFList(list []QFEntry, action ...interface{}) (int, error) {
+	args := []interface{}{list}
+	for _, a := range action {
+		args = append(args, a)
+	}
+	var res int
+	err := v.call(&res, "setqflist", args...)
+	return res, err
+}
+
+// SetQFList adds a call of setqflist() to the batch; see :help setqflist().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) SetQFList(list []QFEntry, action ...interface{}) func() (int, error) {
+	args := []interface{}{list}
+	for _, a := range action {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("setqflist", args...)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// TimerInfo calls timer_info(); see :help timer_info().
+func (v Vim) TimerInfo(id ...int) ([]Timer, error) {
+	args := []interface{}{}
+	for _, a := range id {
+		args = append(args, a)
+	}
+	var res []Timer
+	err := v.call(&res, "timer_info", args...)
+	return res, err
+}
+
+// TimerInfo adds a call of timer_info() to the batch; see :help timer_info().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) TimerInfo(id ...int) func() ([]Timer, error) {
+	args := []interface{}{}
+	for _, a := range id {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("timer_info", args...)
+	return func() (res []Timer, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// TimerPause calls timer_pause(); see :help timer_pause().
+func (v Vim) TimerPause(id int, pause bool) error {
+	return v.call(nil, "timer_pause", id, pause)
+}
+
+// TimerPause adds a call of timer_pause() to the batch; see :help timer_pause().
+func (b Batch) TimerPause(id int, pause bool) {
+	b.b.ChannelCall("timer_pause", id, pause)
+}
+
+// TimerStart calls timer_start(); see :help timer_start().
+func (v Vim) TimerStart(time int, callback string, options ...TimerOptions) (int, error) {
+	args := []interface{}{time, callback}
+	for _, a := range options {
+		args = append(args, a)
+	}
+	var res int
+	err := v.call(&res, "timer_start", args...)
+	return res, err
+}
+
+// TimerStart adds a call of timer_start() to the batch; see :help timer_start().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) TimerStart(time int, callback string, options ...TimerOptions) func() (int, error) {
+	args := []interface{}{time, callback}
+	for _, a := range options {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("timer_start", args...)
+	return func() (res int, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// TimerStop calls timer_stop(); see :help timer_stop().
+func (v Vim) TimerStop(id int) error {
+	return v.call(nil, "timer_stop", id)
+}
+
+// TimerStop adds a call of timer_stop() to the batch; see :help timer_stop().
+func (b Batch) TimerStop(id int) {
+	b.b.ChannelCall("timer_stop", id)
+}
+
+// TimerStopAll calls timer_stopall(); see :help timer_stopall().
+func (v Vim) TimerStopAll() error {
+	return v.call(nil, "timer_stopall")
+}
+
+// TimerStopAll adds a call of timer_stopall() to the batch; see :help timer_stopall().
+func (b Batch) TimerStopAll() {
+	b.b.ChannelCall("timer_stopall")
+}
+
+// Execute calls execute(); see :help execute().
+func (v Vim) Execute(command interface{}, silent ...string) (string, error) {
+	args := []interface{}{command}
+	for _, a := range silent {
+		args = append(args, a)
+	}
+	var res string
+	err := v.call(&res, "execute", args...)
+	return res, err
+}
+
+// Execute adds a call of execute() to the batch; see :help execute().
+// The result and error of the call are available once the batch has
+// ended.
+func (b Batch) Execute(command interface{}, silent ...string) func() (string, error) {
+	args := []interface{}{command}
+	for _, a := range silent {
+		args = append(args, a)
+	}
+	r := b.b.ChannelCall("execute", args...)
+	return func() (res string, err error) {
+		err = r.Decode(&res)
+		return res, err
+	}
+}
+
+// BufInfo is a dict returned by getbufinfo(); see :help getbufinfo().
+type BufInfo struct {
+	BufNr       int                    `json:"bufnr"`
+	Changed     int                    `json:"changed"`
+	ChangedTick int                    `json:"changedtick"`
+	Hidden      int                    `json:"hidden"`
+	LastUsed    int                    `json:"lastused"`
+	Listed      int                    `json:"listed"`
+	Lnum        int                    `json:"lnum"`
+	LineCount   int                    `json:"linecount"`
+	Loaded      int                    `json:"loaded"`
+	Name        string                 `json:"name"`
+	Windows     []int                  `json:"windows"`
+	Popups      []int                  `json:"popups"`
+	Variables   map[string]interface{} `json:"variables"`
+}
+
+// ScreenPosition is a dict returned by screenpos(); see :help screenpos().
+type ScreenPosition struct {
+	Row     int `json:"row"`
+	Col     int `json:"col"`
+	EndCol  int `json:"endcol"`
+	CursCol int `json:"curscol"`
+}
+
+// PopupOptions is the dict of options of popup_create-arguments; see :help popup_create-arguments. Only the
+// fields that are set are passed to Vim.
+type PopupOptions struct {
+	Line               interface{} `json:"line"`
+	Col                interface{} `json:"col"`
+	Pos                string      `json:"pos"`
+	PosInvert          *bool       `json:"posinvert"`
+	TextProp           string      `json:"textprop"`
+	TextPropWin        int         `json:"textpropwin"`
+	TextPropID         int         `json:"textpropid"`
+	Fixed              *bool       `json:"fixed"`
+	Flip               *bool       `json:"flip"`
+	MaxHeight          int         `json:"maxheight"`
+	MinHeight          int         `json:"minheight"`
+	MaxWidth           int         `json:"maxwidth"`
+	MinWidth           int         `json:"minwidth"`
+	FirstLine          *int        `json:"firstline"`
+	Hidden             *bool       `json:"hidden"`
+	Tabpage            int         `json:"tabpage"`
+	Title              string      `json:"title"`
+	Wrap               *bool       `json:"wrap"`
+	Drag               *bool       `json:"drag"`
+	Resize             *bool       `json:"resize"`
+	Close              string      `json:"close"`
+	Highlight          string      `json:"highlight"`
+	Padding            []int       `json:"padding"`
+	Border             []int       `json:"border"`
+	BorderHighlight    []string    `json:"borderhighlight"`
+	BorderChars        []string    `json:"borderchars"`
+	Scrollbar          *bool       `json:"scrollbar"`
+	ScrollbarHighlight string      `json:"scrollbarhighlight"`
+	ThumbHighlight     string      `json:"thumbhighlight"`
+	ZIndex             int         `json:"zindex"`
+	Mask               [][]int     `json:"mask"`
+	Time               int         `json:"time"`
+	Moved              interface{} `json:"moved"`
+	MouseMoved         interface{} `json:"mousemoved"`
+	CursorLine         *bool       `json:"cursorline"`
+	Filter             string      `json:"filter"`
+	Mapping            *bool       `json:"mapping"`
+	FilterMode         string      `json:"filtermode"`
+	Callback           string      `json:"callback"`
+}
+
+func (o PopupOptions) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{})
+	if o.Line != nil {
+		m["line"] = o.Line
+	}
+	if o.Col != nil {
+		m["col"] = o.Col
+	}
+	if o.Pos != "" {
+		m["pos"] = o.Pos
+	}
+	if o.PosInvert != nil {
+		m["posinvert"] = o.PosInvert
+	}
+	if o.TextProp != "" {
+		m["textprop"] = o.TextProp
+	}
+	if o.TextPropWin != 0 {
+		m["textpropwin"] = o.TextPropWin
+	}
+	if o.TextPropID != 0 {
+		m["textpropid"] = o.TextPropID
+	}
+	if o.Fixed != nil {
+		m["fixed"] = o.Fixed
+	}
+	if o.Flip != nil {
+		m["flip"] = o.Flip
+	}
+	if o.MaxHeight != 0 {
+		m["maxheight"] = o.MaxHeight
+	}
+	if o.MinHeight != 0 {
+		m["minheight"] = o.MinHeight
+	}
+	if o.MaxWidth != 0 {
+		m["maxwidth"] = o.MaxWidth
+	}
+	if o.MinWidth != 0 {
+		m["minwidth"] = o.MinWidth
+	}
+	if o.FirstLine != nil {
+		m["firstline"] = o.FirstLine
+	}
+	if o.Hidden != nil {
+		m["hidden"] = o.Hidden
+	}
+	if o.Tabpage != 0 {
+		m["tabpage"] = o.Tabpage
+	}
+	if o.Title != "" {
+		m["title"] = o.Title
+	}
+	if o.Wrap != nil {
+		m["wrap"] = o.Wrap
+	}
+	if o.Drag != nil {
+		m["drag"] = o.Drag
+	}
+	if o.Resize != nil {
+		m["resize"] = o.Resize
+	}
+	if o.Close != "" {
+		m["close"] = o.Close
+	}
+	if o.Highlight != "" {
+		m["highlight"] = o.Highlight
+	}
+	if o.Padding != nil {
+		m["padding"] = o.Padding
+	}
+	if o.Border != nil {
+		m["border"] = o.Border
+	}
+	if o.BorderHighlight != nil {
+		m["borderhighlight"] = o.BorderHighlight
+	}
+	if o.BorderChars != nil {
+		m["borderchars"] = o.BorderChars
+	}
+	if o.Scrollbar != nil {
+		m["scrollbar"] = o.Scrollbar
+	}
+	if o.ScrollbarHighlight != "" {
+		m["scrollbarhighlight"] = o.ScrollbarHighlight
+	}
+	if o.ThumbHighlight != "" {
+		m["thumbhighlight"] = o.ThumbHighlight
+	}
+	if o.ZIndex != 0 {
+		m["zindex"] = o.ZIndex
+	}
+	if o.Mask != nil {
+		m["mask"] = o.Mask
+	}
+	if o.Time != 0 {
+		m["time"] = o.Time
+	}
+	if o.Moved != nil {
+		m["moved"] = o.Moved
+	}
+	if o.MouseMoved != nil {
+		m["mousemoved"] = o.MouseMoved
+	}
+	if o.CursorLine != nil {
+		m["cursorline"] = o.CursorLine
+	}
+	if o.Filter != "" {
+		m["filter"] = o.Filter
+	}
+	if o.Mapping != nil {
+		m["mapping"] = o.Mapping
+	}
+	if o.FilterMode != "" {
+		m["filtermode"] = o.FilterMode
+	}
+	if o.Callback != "" {
+		m["callback"] = o.Callback
+	}
+	return json.Marshal(m)
+}
+
+// PopupPosition is a dict returned by popup_getpos(); see :help popup_getpos().
+type PopupPosition struct {
+	Line       int `json:"line"`
+	Col        int `json:"col"`
+	Width      int `json:"width"`
+	Height     int `json:"height"`
+	CoreLine   int `json:"core_line"`
+	CoreCol    int `json:"core_col"`
+	CoreWidth  int `json:"core_width"`
+	CoreHeight int `json:"core_height"`
+	FirstLine  int `json:"firstline"`
+	LastLine   int `json:"lastline"`
+	Scrollbar  int `json:"scrollbar"`
+	Visible    int `json:"visible"`
+}
+
+// PropOptions is the dict of options of prop_add(); see :help prop_add(). Only the
+// fields that are set are passed to Vim.
+type PropOptions struct {
+	Type        string   `json:"type"`
+	Types       []string `json:"types"`
+	ID          *int     `json:"id"`
+	IDs         []int    `json:"ids"`
+	Bufnr       int      `json:"bufnr"`
+	Length      *int     `json:"length"`
+	EndLnum     int      `json:"end_lnum"`
+	EndCol      int      `json:"end_col"`
+	Text        string   `json:"text"`
+	TextAlign   string   `json:"text_align"`
+	TextPadLeft int      `json:"text_padding_left"`
+	TextWrap    string   `json:"text_wrap"`
+	Both        *bool    `json:"both"`
+	All         *bool    `json:"all"`
+	Lnum        int      `json:"lnum"`
+	Col         int      `json:"col"`
+	SkipStart   *bool    `json:"skipstart"`
+}
+
+func (o PropOptions) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{})
+	if o.Type != "" {
+		m["type"] = o.Type
+	}
+	if o.Types != nil {
+		m["types"] = o.Types
+	}
+	if o.ID != nil {
+		m["id"] = o.ID
+	}
+	if o.IDs != nil {
+		m["ids"] = o.IDs
+	}
+	if o.Bufnr != 0 {
+		m["bufnr"] = o.Bufnr
+	}
+	if o.Length != nil {
+		m["length"] = o.Length
+	}
+	if o.EndLnum != 0 {
+		m["end_lnum"] = o.EndLnum
+	}
+	if o.EndCol != 0 {
+		m["end_col"] = o.EndCol
+	}
+	if o.Text != "" {
+		m["text"] = o.Text
+	}
+	if o.TextAlign != "" {
+		m["text_align"] = o.TextAlign
+	}
+	if o.TextPadLeft != 0 {
+		m["text_padding_left"] = o.TextPadLeft
+	}
+	if o.TextWrap != "" {
+		m["text_wrap"] = o.TextWrap
+	}
+	if o.Both != nil {
+		m["both"] = o.Both
+	}
+	if o.All != nil {
+		m["all"] = o.All
+	}
+	if o.Lnum != 0 {
+		m["lnum"] = o.Lnum
+	}
+	if o.Col != 0 {
+		m["col"] = o.Col
+	}
+	if o.SkipStart != nil {
+		m["skipstart"] = o.SkipStart
+	}
+	return json.Marshal(m)
+}
+
+// PropTypeOptions is the dict of options of prop_type_add(); see :help prop_type_add(). Only the
+// fields that are set are passed to Vim.
+type PropTypeOptions struct {
+	Bufnr     int    `json:"bufnr"`
+	Highlight string `json:"highlight"`
+	Priority  *int   `json:"priority"`
+	Combine   *bool  `json:"combine"`
+	Override  *bool  `json:"override"`
+	StartIncl *bool  `json:"start_incl"`
+	EndIncl   *bool  `json:"end_incl"`
+}
+
+func (o PropTypeOptions) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{})
+	if o.Bufnr != 0 {
+		m["bufnr"] = o.Bufnr
+	}
+	if o.Highlight != "" {
+		m["highlight"] = o.Highlight
+	}
+	if o.Priority != nil {
+		m["priority"] = o.Priority
+	}
+	if o.Combine != nil {
+		m["combine"] = o.Combine
+	}
+	if o.Override != nil {
+		m["override"] = o.Override
+	}
+	if o.StartIncl != nil {
+		m["start_incl"] = o.StartIncl
+	}
+	if o.EndIncl != nil {
+		m["end_incl"] = o.EndIncl
+	}
+	return json.Marshal(m)
+}
+
+// Prop is a dict returned by prop_list(); see :help prop_list().
+type Prop struct {
+	Lnum      int    `json:"lnum"`
+	Col       int    `json:"col"`
+	Length    int    `json:"length"`
+	ID        int    `json:"id"`
+	Type      string `json:"type"`
+	TypeBufnr int    `json:"type_bufnr"`
+	Start     int    `json:"start"`
+	End       int    `json:"end"`
+	Text      string `json:"text"`
+}
+
+// PropType is a dict returned by prop_type_get(); see :help prop_type_get().
+type PropType struct {
+	Bufnr     int    `json:"bufnr"`
+	Highlight string `json:"highlight"`
+	Priority  int    `json:"priority"`
+	Combine   int    `json:"combine"`
+	Override  int    `json:"override"`
+	StartIncl int    `json:"start_incl"`
+	EndIncl   int    `json:"end_incl"`
+}
+
+// SignDefinition is the dict of options of sign_define(); see :help sign_define(). Only the
+// fields that are set are passed to Vim.
+type SignDefinition struct {
+	Name   string `json:"name"`
+	Icon   string `json:"icon"`
+	LineHl string `json:"linehl"`
+	NumHl  string `json:"numhl"`
+	Text   string `json:"text"`
+	TextHl string `json:"texthl"`
+	CulHl  string `json:"culhl"`
+}
+
+func (o SignDefinition) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{})
+	if o.Name != "" {
+		m["name"] = o.Name
+	}
+	if o.Icon != "" {
+		m["icon"] = o.Icon
+	}
+	if o.LineHl != "" {
+		m["linehl"] = o.LineHl
+	}
+	if o.NumHl != "" {
+		m["numhl"] = o.NumHl
+	}
+	if o.Text != "" {
+		m["text"] = o.Text
+	}
+	if o.TextHl != "" {
+		m["texthl"] = o.TextHl
+	}
+	if o.CulHl != "" {
+		m["culhl"] = o.CulHl
+	}
+	return json.Marshal(m)
+}
+
+// SignPlaceOptions is the dict of options of sign_place(); see :help sign_place(). Only the
+// fields that are set are passed to Vim.
+type SignPlaceOptions struct {
+	Buffer   interface{} `json:"buffer"`
+	Group    *string     `json:"group"`
+	ID       int         `json:"id"`
+	Lnum     interface{} `json:"lnum"`
+	Priority int         `json:"priority"`
+}
+
+func (o SignPlaceOptions) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{})
+	if o.Buffer != nil {
+		m["buffer"] = o.Buffer
+	}
+	if o.Group != nil {
+		m["group"] = o.Group
+	}
+	if o.ID != 0 {
+		m["id"] = o.ID
+	}
+	if o.Lnum != nil {
+		m["lnum"] = o.Lnum
+	}
+	if o.Priority != 0 {
+		m["priority"] = o.Priority
+	}
+	return json.Marshal(m)
+}
+
+// SignPlacement is the dict of options of sign_placelist(); see :help sign_placelist(). Only the
+// fields that are set are passed to Vim.
+type SignPlacement struct {
+	Buffer   interface{} `json:"buffer"`
+	Group    string      `json:"group"`
+	ID       int         `json:"id"`
+	Lnum     interface{} `json:"lnum"`
+	Name     string      `json:"name"`
+	Priority int         `json:"priority"`
+}
+
+func (o SignPlacement) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{})
+	if o.Buffer != nil {
+		m["buffer"] = o.Buffer
+	}
+	if o.Group != "" {
+		m["group"] = o.Group
+	}
+	if o.ID != 0 {
+		m["id"] = o.ID
+	}
+	if o.Lnum != nil {
+		m["lnum"] = o.Lnum
+	}
+	if o.Name != "" {
+		m["name"] = o.Name
+	}
+	if o.Priority != 0 {
+		m["priority"] = o.Priority
+	}
+	return json.Marshal(m)
+}
+
+// BufSigns is a dict returned by sign_getplaced(); see :help sign_getplaced().
+type BufSigns struct {
+	Bufnr int          `json:"bufnr"`
+	Signs []PlacedSign `json:"signs"`
+}
+
+// PlacedSign is a dict returned by sign_getplaced(); see :help sign_getplaced().
+type PlacedSign struct {
+	Group    string `json:"group"`
+	ID       int    `json:"id"`
+	Lnum     int    `json:"lnum"`
+	Name     string `json:"name"`
+	Priority int    `json:"priority"`
+}
+
+// QFEntry is the dict of options of setqflist(); see :help setqflist(). Only the
+// fields that are set are passed to Vim.
+type QFEntry struct {
+	Bufnr    int         `json:"bufnr"`
+	Filename string      `json:"filename"`
+	Module   string      `json:"module"`
+	Lnum     int         `json:"lnum"`
+	EndLnum  int         `json:"end_lnum"`
+	Pattern  string      `json:"pattern"`
+	Col      int         `json:"col"`
+	Vcol     *bool       `json:"vcol"`
+	EndCol   int         `json:"end_col"`
+	Nr       int         `json:"nr"`
+	Text     string      `json:"text"`
+	Type     string      `json:"type"`
+	Valid    *bool       `json:"valid"`
+	UserData interface{} `json:"user_data"`
+}
+
+func (o QFEntry) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{})
+	if o.Bufnr != 0 {
+		m["bufnr"] = o.Bufnr
+	}
+	if o.Filename != "" {
+		m["filename"] = o.Filename
+	}
+	if o.Module != "" {
+		m["module"] = o.Module
+	}
+	if o.Lnum != 0 {
+		m["lnum"] = o.Lnum
+	}
+	if o.EndLnum != 0 {
+		m["end_lnum"] = o.EndLnum
+	}
+	if o.Pattern != "" {
+		m["pattern"] = o.Pattern
+	}
+	if o.Col != 0 {
+		m["col"] = o.Col
+	}
+	if o.Vcol != nil {
+		m["vcol"] = o.Vcol
+	}
+	if o.EndCol != 0 {
+		m["end_col"] = o.EndCol
+	}
+	if o.Nr != 0 {
+		m["nr"] = o.Nr
+	}
+	if o.Text != "" {
+		m["text"] = o.Text
+	}
+	if o.Type != "" {
+		m["type"] = o.Type
+	}
+	if o.Valid != nil {
+		m["valid"] = o.Valid
+	}
+	if o.UserData != nil {
+		m["user_data"] = o.UserData
+	}
+	return json.Marshal(m)
+}
+
+// QFListProperties is the dict of options of setqflist-what; see :help setqflist-what. Only the
+// fields that are set are passed to Vim.
+type QFListProperties struct {
+	Context          interface{} `json:"context"`
+	Efm              string      `json:"efm"`
+	ID               int         `json:"id"`
+	Idx              interface{} `json:"idx"`
+	Items            []QFEntry   `json:"items"`
+	Lines            []string    `json:"lines"`
+	Nr               interface{} `json:"nr"`
+	QuickfixTextFunc string      `json:"quickfixtextfunc"`
+	Title            string      `json:"title"`
+	All              *int        `json:"all"`
+}
+
+func (o QFListProperties) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{})
+	if o.Context != nil {
+		m["context"] = o.Context
+	}
+	if o.Efm != "" {
+		m["efm"] = o.Efm
+	}
+	if o.ID != 0 {
+		m["id"] = o.ID
+	}
+	if o.Idx != nil {
+		m["idx"] = o.Idx
+	}
+	if o.Items != nil {
+		m["items"] = o.Items
+	}
+	if o.Lines != nil {
+		m["lines"] = o.Lines
+	}
+	if o.Nr != nil {
+		m["nr"] = o.Nr
+	}
+	if o.QuickfixTextFunc != "" {
+		m["quickfixtextfunc"] = o.QuickfixTextFunc
+	}
+	if o.Title != "" {
+		m["title"] = o.Title
+	}
+	if o.All != nil {
+		m["all"] = o.All
+	}
+	return json.Marshal(m)
+}
+
+// TimerOptions is the dict of options of timer_start(); see :help timer_start(). Only the
+// fields that are set are passed to Vim.
+type TimerOptions struct {
+	Repeat int `json:"repeat"`
+}
+
+func (o TimerOptions) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{})
+	if o.Repeat != 0 {
+		m["repeat"] = o.Repeat
+	}
+	return json.Marshal(m)
+}
+
+// Timer is a dict returned by timer_info(); see :help timer_info().
+type Timer struct {
+	ID        int         `json:"id"`
+	Time      int         `json:"time"`
+	Remaining int         `json:"remaining"`
+	Repeat    int         `json:"repeat"`
+	Callback  interface{} `json:"callback"`
+	Paused    int         `json:"paused"`
+}
@@ -0,0 +1,58 @@
+// Package vimfn provides typed wrappers for Vim's builtin functions, for use
+// in place of ChannelCall and the untyped arguments and results it requires.
+// Dict arguments are represented by options types whose fields are only
+// passed to Vim when set, and results are decoded into Go types.
+//
+// The wrappers are available on both a govim.Govim, via New, and on a batch of
+// calls, via NewBatch. They are generated by genvimfn from builtins.txt: to
+// wrap another builtin, or to add a field to a type, edit builtins.txt and
+// run go generate.
+package vimfn
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/govim/govim"
+)
+
+//go:generate go run github.com/govim/govim/internal/cmd/genvimfn
+
+// Caller calls Vim functions. It is implemented by govim.Govim.
+type Caller interface {
+	ChannelCall(fn string, args ...interface{}) (json.RawMessage, error)
+}
+
+// Vim calls Vim's builtin functions via a Caller
+type Vim struct {
+	c Caller
+}
+
+// New returns a Vim that calls builtin functions via c
+func New(c Caller) Vim {
+	return Vim{c: c}
+}
+
+func (v Vim) call(res interface{}, fn string, args ...interface{}) error {
+	raw, err := v.c.ChannelCall(fn, args...)
+	if err != nil {
+		return err
+	}
+	if res == nil {
+		return nil
+	}
+	if err := json.Unmarshal(raw, res); err != nil {
+		return fmt.Errorf("failed to decode result of %v(): %v", fn, err)
+	}
+	return nil
+}
+
+// Batch adds calls of Vim's builtin functions to a govim.Batch
+type Batch struct {
+	b *govim.Batch
+}
+
+// NewBatch returns a Batch that adds calls of builtin functions to b
+func NewBatch(b *govim.Batch) Batch {
+	return Batch{b: b}
+}
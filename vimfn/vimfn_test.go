@@ -0,0 +1,116 @@
+package vimfn
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/govim/govim"
+)
+
+type fakeCall struct {
+	fn   string
+	args string
+}
+
+// fakeCaller records the calls made of it, returning result to each
+type fakeCaller struct {
+	calls  []fakeCall
+	result string
+	err    error
+}
+
+func (f *fakeCaller) record(fn string, args []interface{}) {
+	byts, err := json.Marshal(args)
+	if err != nil {
+		panic(err)
+	}
+	f.calls = append(f.calls, fakeCall{fn: fn, args: string(byts)})
+}
+
+func (f *fakeCaller) ChannelCall(fn string, args ...interface{}) (json.RawMessage, error) {
+	f.record(fn, args)
+	return json.RawMessage(f.result), f.err
+}
+
+func TestVim(t *testing.T) {
+	c := &fakeCaller{result: "42"}
+	wrap := false
+	first := 0
+	id, err := New(c).PopupCreate([]string{"hello"}, PopupOptions{
+		Line:      "cursor+1",
+		Wrap:      &wrap,
+		FirstLine: &first,
+		Border:    []int{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 42 {
+		t.Errorf("got popup id %v; want 42", id)
+	}
+	want := fakeCall{
+		fn:   "popup_create",
+		args: `[["hello"],{"border":[],"firstline":0,"line":"cursor+1","wrap":false}]`,
+	}
+	if len(c.calls) != 1 || c.calls[0] != want {
+		t.Errorf("got calls %+v; want %+v", c.calls, want)
+	}
+
+	c = &fakeCaller{result: `[{"bufnr":3,"name":"main.go","windows":[1000]}]`}
+	infos, err := New(c).GetBufInfo(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].BufNr != 3 || infos[0].Name != "main.go" || len(infos[0].Windows) != 1 {
+		t.Errorf("unexpected buffer info: %+v", infos)
+	}
+	if c.calls[0].args != `[3]` {
+		t.Errorf("got args %v; want [3]", c.calls[0].args)
+	}
+
+	c = &fakeCaller{result: `"not a number"`}
+	if _, err := New(c).BufNr(); err == nil {
+		t.Errorf("expected an error decoding the result")
+	}
+	c = &fakeCaller{err: errors.New("boom")}
+	if err := New(c).PopupClose(1, -1); err == nil || err.Error() != "boom" {
+		t.Errorf("got error %v; want boom", err)
+	}
+	if want := `[1,-1]`; c.calls[0].args != want {
+		t.Errorf("got args %v; want %v", c.calls[0].args, want)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	c := &fakeCaller{result: `{"results":[0,[1,2]],"failed":-1,"error":""}`}
+	gb := govim.NewBatch(c)
+	b := NewBatch(gb)
+	b.PopupSetOptions(7, PopupOptions{Time: 3000})
+	ids := b.SignPlaceList([]SignPlacement{{Buffer: 1, Lnum: 2, Name: "err"}})
+	if _, err := gb.End(); err != nil {
+		t.Fatal(err)
+	}
+	want := fakeCall{
+		fn:   "s:batchCall",
+		args: `[[["call",["s:mustNoError",null],"popup_setoptions",7,{"time":3000}],["call",["s:mustNoError",null],"sign_placelist",[{"buffer":1,"lnum":2,"name":"err"}]]]]`,
+	}
+	if len(c.calls) != 1 || c.calls[0] != want {
+		t.Errorf("got calls %+v; want %+v", c.calls, want)
+	}
+	if got, err := ids(); err != nil || len(got) != 2 || got[1] != 2 {
+		t.Errorf("got result %v, %v; want [1 2], nil", got, err)
+	}
+
+	c = &fakeCaller{result: `{"results":[],"failed":0,"error":"failed to call popup_setoptions"}`}
+	gb = govim.NewBatch(c)
+	b = NewBatch(gb)
+	b.PopupSetOptions(7, PopupOptions{})
+	ids = b.SignPlaceList(nil)
+	if _, err := gb.End(); err == nil {
+		t.Fatal("expected batch to fail")
+	}
+	if _, err := ids(); err != govim.ErrBatchCallNotMade {
+		t.Errorf("got error %v; want %v", err, govim.ErrBatchCallNotMade)
+	}
+}